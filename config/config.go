@@ -4,13 +4,113 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Avatar size limits in megabytes
+const (
+	DefaultMaxAvatarSizeMB = 5
+	MinAvatarSizeMB        = 1
+	MaxAvatarSizeMB        = 50
+)
+
+// Account lockout defaults for repeated failed logins
+const (
+	DefaultLoginMaxFailures    = 5
+	DefaultLoginFailureWindow  = 15 // minutes
+	DefaultLoginLockoutMinutes = 15
+)
+
+// DefaultMaxCommentDepth is how deeply replies may nest when MAX_COMMENT_DEPTH is not set
+const DefaultMaxCommentDepth = 5
+
+// DefaultUsernameChangeCooldownDays is the wait between username changes when USERNAME_CHANGE_COOLDOWN is not set
+const DefaultUsernameChangeCooldownDays = 30
+
+// What happens to a banned user's existing posts and comments (BANNED_CONTENT_POLICY)
+const (
+	BannedContentKeep = "keep" // content stays visible
+	BannedContentHide = "hide" // content is hidden from listings while the ban lasts
+)
+
+// DefaultUploadsCacheMaxAge is how long (in seconds) uploads are cached when UPLOADS_CACHE_MAX_AGE is not set.
+// Upload names are never reused, so a year is safe.
+const DefaultUploadsCacheMaxAge = 365 * 24 * 60 * 60
+
+// DefaultStaticCacheMaxAge is how long (in seconds) /static assets are cached when
+// STATIC_CACHE_MAX_AGE is not set. Asset names aren't versioned, so by default
+// browsers revalidate them; deployments with hashed names can raise it.
+const DefaultStaticCacheMaxAge = 0
+
+// DefaultCollapseScoreThreshold is the score (likes minus dislikes) at or below which
+// content is collapsed when COLLAPSE_SCORE_THRESHOLD is not set
+const DefaultCollapseScoreThreshold = -5
+
+// DefaultDuplicatePostWindow is how long (in minutes) an author's identical post counts
+// as an accidental repeat when DUPLICATE_POST_WINDOW is not set
+const DefaultDuplicatePostWindow = 5
+
+// DefaultCategoryCacheTTL is how long (in seconds) the category list is cached when
+// CATEGORY_CACHE_TTL is not set
+const DefaultCategoryCacheTTL = 60
+
+// DefaultNewMemberDays is how many days after signing up an account is flagged as a
+// new member when NEW_MEMBER_DAYS is not set
+const DefaultNewMemberDays = 7
+
+// DefaultSessionCookieName is used when SESSION_COOKIE_NAME is not set
+const DefaultSessionCookieName = "forum_session"
+
+// Environments accepted in APP_ENV
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// Password policies accepted in PASSWORD_POLICY
+const (
+	PasswordPolicyBasic    = "basic"    // length only, with a higher minimum, so passphrases work
+	PasswordPolicyStandard = "standard" // upper, lower, digit and special characters
+	PasswordPolicyStrict   = "strict"   // standard rules, a higher minimum and no obvious patterns
 )
 
 // Config struct holds all application configuration settings
 // and centralizes config management in one place
 type Config struct {
-	Port        string // HTTP servet port
-	DatabaseURL string // Path to SQLite database file
+	Port                       string   // HTTP servet port
+	DatabaseURL                string   // Path to SQLite database file
+	DatabaseReadURL            string   // Optional read-only database (e.g. a replica); empty shares DatabaseURL
+	MaxAvatarSizeMB            int      // Maximum avatar upload size in megabytes
+	MaintenanceMode            bool     // Start with the API in maintenance mode
+	AdminUsernames             []string // Users promoted to admin at startup
+	CommentEditWindowMinutes   int      // How long authors may edit their comments
+	SessionCookieName          string   // Name of the session cookie
+	TrustedProxies             []string // Proxy CIDRs/IPs allowed to set X-Forwarded-For
+	LoginMaxFailures           int      // failed logins allowed within the failure window before an account is locked
+	LoginFailureWindowMinutes  int      // how far back failed logins are counted
+	LoginLockoutMinutes        int      // how long a locked account stays locked
+	MaxCommentDepth            int      // deepest reply level allowed (top-level comments are 0)
+	AvatarAllowedTypes         []string // MIME types accepted for avatars (empty keeps the defaults)
+	UsernameChangeCooldownDays int      // how long users wait between username changes
+	BannedContentPolicy        string   // "keep" or "hide"
+	AllowSelfVotes             bool     // whether users may vote on their own posts and comments
+	UploadsCacheMaxAge         int      // seconds browsers may cache uploaded files
+	StaticCacheMaxAge          int      // seconds browsers may cache /static assets; 0 revalidates every time
+	CollapseScoreThreshold     int      // score at or below which comments collapse and posts leave listings
+	RegistrationEnabled        bool     // whether new accounts can be created at all
+	RegistrationInviteOnly     bool     // whether new accounts need an unused invite code
+	EmailDomainBlocklistFile   string   // file of disposable email domains to reject, one per line
+	EmailMXCheck               bool     // whether email domains must have MX records
+	Environment                string   // "development" or "production"
+	PrettyJSON                 bool     // indent JSON responses; defaults to on in development
+	DefaultPostSort            string   // sort used when post listings don't ask for one
+	PasswordPolicy             string   // "basic", "standard" or "strict"
+	DuplicatePostCheck         bool     // whether repeats of an author's recent post are rejected
+	DuplicatePostWindowMinutes int      // how far back a repeated post is looked for
+	CategoryCacheTTL           int      // seconds the category list is cached; 0 turns the cache off
+	NewMemberDays              int      // days an account is flagged as a new member; 0 turns the flag off
 }
 
 // AppConfig is the global configuration instance
@@ -19,8 +119,113 @@ var AppConfig Config
 // Load initializes the application configuration
 func Load() {
 	AppConfig = Config{
-		Port:        getEnv("PORT", ":8080"),
-		DatabaseURL: getEnv("DATABASE_URL", "./database/forum.db"),
+		Port:                       getEnv("PORT", ":8080"),
+		DatabaseURL:                getEnv("DATABASE_URL", "./database/forum.db"),
+		DatabaseReadURL:            getEnv("DATABASE_READ_URL", ""),
+		MaxAvatarSizeMB:            getEnvInt("MAX_AVATAR_SIZE", DefaultMaxAvatarSizeMB),
+		MaintenanceMode:            getEnvBool("MAINTENANCE_MODE", false),
+		AdminUsernames:             getEnvList("ADMIN_USERNAMES"),
+		CommentEditWindowMinutes:   getEnvInt("COMMENT_EDIT_WINDOW", 15),
+		SessionCookieName:          getEnv("SESSION_COOKIE_NAME", DefaultSessionCookieName),
+		TrustedProxies:             getEnvList("TRUSTED_PROXIES"),
+		LoginMaxFailures:           getEnvInt("LOGIN_MAX_FAILURES", DefaultLoginMaxFailures),
+		LoginFailureWindowMinutes:  getEnvInt("LOGIN_FAILURE_WINDOW", DefaultLoginFailureWindow),
+		LoginLockoutMinutes:        getEnvInt("LOGIN_LOCKOUT_DURATION", DefaultLoginLockoutMinutes),
+		MaxCommentDepth:            getEnvInt("MAX_COMMENT_DEPTH", DefaultMaxCommentDepth),
+		AvatarAllowedTypes:         getEnvList("AVATAR_ALLOWED_TYPES"),
+		UsernameChangeCooldownDays: getEnvInt("USERNAME_CHANGE_COOLDOWN", DefaultUsernameChangeCooldownDays),
+		BannedContentPolicy:        strings.ToLower(getEnv("BANNED_CONTENT_POLICY", BannedContentKeep)),
+		AllowSelfVotes:             getEnvBool("ALLOW_SELF_VOTES", true),
+		UploadsCacheMaxAge:         getEnvInt("UPLOADS_CACHE_MAX_AGE", DefaultUploadsCacheMaxAge),
+		StaticCacheMaxAge:          getEnvInt("STATIC_CACHE_MAX_AGE", DefaultStaticCacheMaxAge),
+		CollapseScoreThreshold:     getEnvInt("COLLAPSE_SCORE_THRESHOLD", DefaultCollapseScoreThreshold),
+		RegistrationEnabled:        getEnvBool("REGISTRATION_ENABLED", true),
+		RegistrationInviteOnly:     getEnvBool("REGISTRATION_INVITE_ONLY", false),
+		EmailDomainBlocklistFile:   getEnv("EMAIL_DOMAIN_BLOCKLIST_FILE", ""),
+		EmailMXCheck:               getEnvBool("EMAIL_CHECK_MX", false),
+		Environment:                strings.ToLower(getEnv("APP_ENV", EnvProduction)),
+		DefaultPostSort:            strings.ToLower(getEnv("DEFAULT_POST_SORT", "newest")),
+		PasswordPolicy:             strings.ToLower(getEnv("PASSWORD_POLICY", PasswordPolicyStandard)),
+		DuplicatePostCheck:         getEnvBool("DUPLICATE_POST_CHECK", true),
+		DuplicatePostWindowMinutes: getEnvInt("DUPLICATE_POST_WINDOW", DefaultDuplicatePostWindow),
+		CategoryCacheTTL:           getEnvInt("CATEGORY_CACHE_TTL", DefaultCategoryCacheTTL),
+		NewMemberDays:              getEnvInt("NEW_MEMBER_DAYS", DefaultNewMemberDays),
+	}
+
+	if AppConfig.Environment != EnvDevelopment && AppConfig.Environment != EnvProduction {
+		log.Printf("Warning: APP_ENV must be %q or %q, using %q", EnvDevelopment, EnvProduction, EnvProduction)
+		AppConfig.Environment = EnvProduction
+	}
+	AppConfig.PrettyJSON = getEnvBool("PRETTY_JSON", AppConfig.Environment == EnvDevelopment)
+
+	switch AppConfig.PasswordPolicy {
+	case PasswordPolicyBasic, PasswordPolicyStandard, PasswordPolicyStrict:
+	default:
+		log.Printf("Warning: PASSWORD_POLICY must be %q, %q or %q, using %q",
+			PasswordPolicyBasic, PasswordPolicyStandard, PasswordPolicyStrict, PasswordPolicyStandard)
+		AppConfig.PasswordPolicy = PasswordPolicyStandard
+	}
+
+	// Keep avatar size within a sane range
+	if AppConfig.MaxAvatarSizeMB < MinAvatarSizeMB || AppConfig.MaxAvatarSizeMB > MaxAvatarSizeMB {
+		log.Printf("Warning: MAX_AVATAR_SIZE must be between %d and %d MB, using default %d MB",
+			MinAvatarSizeMB, MaxAvatarSizeMB, DefaultMaxAvatarSizeMB)
+		AppConfig.MaxAvatarSizeMB = DefaultMaxAvatarSizeMB
+	}
+
+	// Lockout settings must be positive to make sense
+	if AppConfig.LoginMaxFailures < 1 {
+		log.Printf("Warning: LOGIN_MAX_FAILURES must be at least 1, using default %d", DefaultLoginMaxFailures)
+		AppConfig.LoginMaxFailures = DefaultLoginMaxFailures
+	}
+	if AppConfig.LoginFailureWindowMinutes < 1 {
+		log.Printf("Warning: LOGIN_FAILURE_WINDOW must be at least 1 minute, using default %d", DefaultLoginFailureWindow)
+		AppConfig.LoginFailureWindowMinutes = DefaultLoginFailureWindow
+	}
+	if AppConfig.LoginLockoutMinutes < 1 {
+		log.Printf("Warning: LOGIN_LOCKOUT_DURATION must be at least 1 minute, using default %d", DefaultLoginLockoutMinutes)
+		AppConfig.LoginLockoutMinutes = DefaultLoginLockoutMinutes
+	}
+
+	if AppConfig.MaxCommentDepth < 1 {
+		log.Printf("Warning: MAX_COMMENT_DEPTH must be at least 1, using default %d", DefaultMaxCommentDepth)
+		AppConfig.MaxCommentDepth = DefaultMaxCommentDepth
+	}
+
+	if AppConfig.BannedContentPolicy != BannedContentKeep && AppConfig.BannedContentPolicy != BannedContentHide {
+		log.Printf("Warning: BANNED_CONTENT_POLICY must be %q or %q, using %q",
+			BannedContentKeep, BannedContentHide, BannedContentKeep)
+		AppConfig.BannedContentPolicy = BannedContentKeep
+	}
+
+	if AppConfig.UploadsCacheMaxAge < 0 {
+		log.Printf("Warning: UPLOADS_CACHE_MAX_AGE must not be negative, using default %d", DefaultUploadsCacheMaxAge)
+		AppConfig.UploadsCacheMaxAge = DefaultUploadsCacheMaxAge
+	}
+	if AppConfig.StaticCacheMaxAge < 0 {
+		log.Printf("Warning: STATIC_CACHE_MAX_AGE must not be negative, using default %d", DefaultStaticCacheMaxAge)
+		AppConfig.StaticCacheMaxAge = DefaultStaticCacheMaxAge
+	}
+
+	// New content starts at 0, so a threshold of 0 or more would collapse everything
+	if AppConfig.CollapseScoreThreshold >= 0 {
+		log.Printf("Warning: COLLAPSE_SCORE_THRESHOLD must be negative, using default %d", DefaultCollapseScoreThreshold)
+		AppConfig.CollapseScoreThreshold = DefaultCollapseScoreThreshold
+	}
+
+	if AppConfig.DuplicatePostWindowMinutes < 1 {
+		log.Printf("Warning: DUPLICATE_POST_WINDOW must be at least 1 minute, using default %d", DefaultDuplicatePostWindow)
+		AppConfig.DuplicatePostWindowMinutes = DefaultDuplicatePostWindow
+	}
+
+	if AppConfig.CategoryCacheTTL < 0 {
+		log.Printf("Warning: CATEGORY_CACHE_TTL must not be negative, using default %d", DefaultCategoryCacheTTL)
+		AppConfig.CategoryCacheTTL = DefaultCategoryCacheTTL
+	}
+
+	if AppConfig.NewMemberDays < 0 {
+		log.Printf("Warning: NEW_MEMBER_DAYS must not be negative, using default %d", DefaultNewMemberDays)
+		AppConfig.NewMemberDays = DefaultNewMemberDays
 	}
 
 	fmt.Println()
@@ -38,6 +243,109 @@ func GetDatabaseURL() string {
 	return AppConfig.DatabaseURL
 }
 
+// GetDatabaseReadURL returns the read database path, or "" when reads use the main database
+func GetDatabaseReadURL() string {
+	return AppConfig.DatabaseReadURL
+}
+
+// GetMaxAvatarSize returns the maximum avatar upload size in bytes
+func GetMaxAvatarSize() int64 {
+	return int64(AppConfig.MaxAvatarSizeMB) << 20
+}
+
+// IsMaintenanceMode reports whether the API should start in maintenance mode
+func IsMaintenanceMode() bool {
+	return AppConfig.MaintenanceMode
+}
+
+// GetAdminUsernames returns the usernames to promote to admin at startup
+func GetAdminUsernames() []string {
+	return AppConfig.AdminUsernames
+}
+
+// GetCommentEditWindowMinutes returns the default comment edit window in minutes
+func GetCommentEditWindowMinutes() int {
+	return AppConfig.CommentEditWindowMinutes
+}
+
+// GetSessionCookieName returns the name of the session cookie
+func GetSessionCookieName() string {
+	if AppConfig.SessionCookieName == "" {
+		return DefaultSessionCookieName
+	}
+	return AppConfig.SessionCookieName
+}
+
+// GetTrustedProxies returns the proxies whose forwarding headers are trusted
+func GetTrustedProxies() []string {
+	return AppConfig.TrustedProxies
+}
+
+// GetLoginMaxFailures returns how many failed logins within the window lock an account
+func GetLoginMaxFailures() int {
+	return AppConfig.LoginMaxFailures
+}
+
+// GetLoginFailureWindow returns the window in which failed logins are counted
+func GetLoginFailureWindow() time.Duration {
+	return time.Duration(AppConfig.LoginFailureWindowMinutes) * time.Minute
+}
+
+// GetLoginLockoutDuration returns how long an account stays locked
+func GetLoginLockoutDuration() time.Duration {
+	return time.Duration(AppConfig.LoginLockoutMinutes) * time.Minute
+}
+
+// GetAvatarAllowedTypes returns the configured avatar MIME types, if any
+func GetAvatarAllowedTypes() []string {
+	return AppConfig.AvatarAllowedTypes
+}
+
+// GetUsernameChangeCooldownDays returns the default wait between username changes in days
+func GetUsernameChangeCooldownDays() int {
+	return AppConfig.UsernameChangeCooldownDays
+}
+
+// GetMaxCommentDepth returns how many levels deep replies may nest
+func GetMaxCommentDepth() int {
+	return AppConfig.MaxCommentDepth
+}
+
+// HideBannedContent reports whether banned users' posts and comments are hidden while the ban lasts
+func HideBannedContent() bool {
+	return AppConfig.BannedContentPolicy == BannedContentHide
+}
+
+// AllowSelfVotes reports whether users may vote on their own content
+func AllowSelfVotes() bool {
+	return AppConfig.AllowSelfVotes
+}
+
+// GetUploadsCacheMaxAge returns how many seconds uploaded files may be cached
+func GetUploadsCacheMaxAge() int {
+	return AppConfig.UploadsCacheMaxAge
+}
+
+// GetStaticCacheMaxAge returns how many seconds /static assets may be cached
+func GetStaticCacheMaxAge() int {
+	return AppConfig.StaticCacheMaxAge
+}
+
+// IsRegistrationEnabled reports whether registration starts open (runtime settings may override it)
+func IsRegistrationEnabled() bool {
+	return AppConfig.RegistrationEnabled
+}
+
+// IsRegistrationInviteOnly reports whether registration starts invite-only (runtime settings may override it)
+func IsRegistrationInviteOnly() bool {
+	return AppConfig.RegistrationInviteOnly
+}
+
+// GetCollapseScoreThreshold returns the score at or below which content is collapsed
+func GetCollapseScoreThreshold() int {
+	return AppConfig.CollapseScoreThreshold
+}
+
 // getEnv is a helper function that reads environment variables
 // If the environment variable doesn't exist, it returns the default value
 func getEnv(key, defaultValue string) string {
@@ -46,3 +354,96 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvInt reads an integer environment variable
+// If the variable is missing or not a valid integer, it returns the default value
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: invalid value for %s (%q), using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvBool reads a boolean environment variable ("true", "1", "false", "0", ...)
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Warning: invalid value for %s (%q), using default %t", key, value, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvList reads a comma-separated environment variable into a slice
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// GetEmailDomainBlocklistFile returns the path of the blocked email domain list, or "" for none
+func GetEmailDomainBlocklistFile() string {
+	return AppConfig.EmailDomainBlocklistFile
+}
+
+// IsEmailMXCheckEnabled reports whether email domains must have MX records
+func IsEmailMXCheckEnabled() bool {
+	return AppConfig.EmailMXCheck
+}
+
+// IsDevelopment reports whether the server runs in development mode
+func IsDevelopment() bool {
+	return AppConfig.Environment == EnvDevelopment
+}
+
+// IsPrettyJSON reports whether JSON responses should be indented for readability
+func IsPrettyJSON() bool {
+	return AppConfig.PrettyJSON
+}
+
+// GetDefaultPostSort returns the configured default post sort (checked by the models package)
+func GetDefaultPostSort() string {
+	return AppConfig.DefaultPostSort
+}
+
+// GetPasswordPolicy returns the password policy new passwords must meet
+func GetPasswordPolicy() string {
+	return AppConfig.PasswordPolicy
+}
+
+// IsDuplicatePostCheckEnabled reports whether repeats of an author's recent post are rejected
+func IsDuplicatePostCheckEnabled() bool {
+	return AppConfig.DuplicatePostCheck
+}
+
+// GetDuplicatePostWindow returns how far back a repeated post is looked for
+func GetDuplicatePostWindow() time.Duration {
+	return time.Duration(AppConfig.DuplicatePostWindowMinutes) * time.Minute
+}
+
+// GetCategoryCacheTTL returns how long the category list may be cached; 0 disables the cache
+func GetCategoryCacheTTL() time.Duration {
+	return time.Duration(AppConfig.CategoryCacheTTL) * time.Second
+}
+
+// GetNewMemberPeriod returns how long after signing up an account counts as a new
+// member; 0 means accounts are never flagged
+func GetNewMemberPeriod() time.Duration {
+	return time.Duration(AppConfig.NewMemberDays) * 24 * time.Hour
+}
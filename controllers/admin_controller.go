@@ -0,0 +1,347 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"forum/middleware"
+	"forum/models"
+	"forum/utils"
+)
+
+// MaintenanceRequest represents the JSON structure for toggling maintenance mode
+type MaintenanceRequest struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message"`
+}
+
+// GetMaintenanceController handles GET /api/admin/maintenance
+func GetMaintenanceController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	utils.Success(w, "Maintenance status retrieved", middleware.GetMaintenanceStatus())
+}
+
+// SetMaintenanceController handles PUT /api/admin/maintenance (admin only)
+func SetMaintenanceController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.MethodNotAllowed(w, "Only PUT method allowed")
+		return
+	}
+
+	var req MaintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid JSON format")
+		return
+	}
+
+	// Persist so the state survives restarts
+	if err := models.Settings.Set(models.SettingMaintenanceMode, strconv.FormatBool(req.Enabled)); err != nil {
+		utils.InternalServerError(w, "Failed to save maintenance mode")
+		return
+	}
+	if err := models.Settings.Set(models.SettingMaintenanceMessage, req.Message); err != nil {
+		utils.InternalServerError(w, "Failed to save maintenance message")
+		return
+	}
+
+	middleware.SetMaintenanceMode(req.Enabled, req.Message)
+
+	message := "Maintenance mode disabled"
+	if req.Enabled {
+		message = "Maintenance mode enabled"
+	}
+	utils.Success(w, message, middleware.GetMaintenanceStatus())
+}
+
+// GetSettingsController handles GET /api/admin/settings (admin only)
+func GetSettingsController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	utils.Success(w, "Settings retrieved successfully", models.Settings.All())
+}
+
+// UpdateSettingsController handles PUT /api/admin/settings (admin only)
+// The body is a JSON object of key -> value; a null value removes the override.
+func UpdateSettingsController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.MethodNotAllowed(w, "Only PUT method allowed")
+		return
+	}
+
+	var req map[string]*string
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid JSON format")
+		return
+	}
+
+	var errors utils.ValidationErrors
+	for key := range req {
+		if !models.IsValidSettingKey(key) {
+			errors.Add(key, "invalid setting key")
+		} else if key == models.SettingMaintenanceMode || key == models.SettingMaintenanceMessage {
+			// Saving these here wouldn't switch the running site, so they have their own endpoint
+			errors.Add(key, "use PUT /api/admin/maintenance to change maintenance mode")
+		}
+	}
+	if errors.HasErrors() {
+		utils.ValidationError(w, errors)
+		return
+	}
+
+	for key, value := range req {
+		var err error
+		if value == nil {
+			err = models.Settings.Delete(key)
+		} else {
+			err = models.Settings.Set(key, *value)
+		}
+		if err != nil {
+			utils.InternalServerError(w, "Failed to update settings")
+			return
+		}
+	}
+
+	utils.Success(w, "Settings updated successfully", models.Settings.All())
+}
+
+// RecountVotesController handles POST /api/admin/maintenance/recount-votes (admin only)
+// It drops orphaned votes and resyncs every cached like/dislike counter with the votes table.
+func RecountVotesController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w, "Only POST method allowed")
+		return
+	}
+
+	result, err := models.RecountVotes()
+	if err != nil {
+		utils.InternalServerError(w, "Failed to recount votes")
+		return
+	}
+
+	utils.Success(w, "Vote counts recounted successfully", result)
+}
+
+// RecountCategoryPostsController handles POST /api/admin/maintenance/recount-category-posts (admin only)
+// It resyncs every category's cached post count with post_categories.
+func RecountCategoryPostsController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w, "Only POST method allowed")
+		return
+	}
+
+	updated, err := models.RecountCategoryPostCounts()
+	if err != nil {
+		utils.InternalServerError(w, "Failed to recount category posts")
+		return
+	}
+
+	utils.Success(w, "Category post counts recounted successfully", map[string]int{
+		"categories_updated": updated,
+	})
+}
+
+// ClearCategoryCacheController handles POST /api/admin/maintenance/clear-category-cache (admin only)
+// The next category listing is loaded fresh from the database, e.g. after editing it by hand.
+func ClearCategoryCacheController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w, "Only POST method allowed")
+		return
+	}
+
+	models.InvalidateCategoryCache()
+	utils.Success(w, "Category cache cleared successfully", nil)
+}
+
+// CreateInviteRequest represents the JSON structure for generating an invite code
+type CreateInviteRequest struct {
+	ExpiresInHours int `json:"expires_in_hours"` // 0 for a code that never expires
+}
+
+// CreateInviteController handles POST /api/admin/invites (admin only)
+func CreateInviteController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w, "Only POST method allowed")
+		return
+	}
+
+	adminID, _ := middleware.GetUserIDFromContext(r)
+
+	// The body is optional
+	var req CreateInviteRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.BadRequest(w, "Invalid JSON format")
+			return
+		}
+	}
+	if req.ExpiresInHours < 0 {
+		utils.BadRequest(w, "expires_in_hours must not be negative")
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInHours > 0 {
+		t := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		expiresAt = &t
+	}
+
+	invite, err := models.CreateInvite(adminID, expiresAt)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to create invite")
+		return
+	}
+
+	utils.Created(w, "Invite created successfully", invite)
+}
+
+// GetInvitesController handles GET /api/admin/invites (admin only)
+func GetInvitesController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+
+	limit, offset, err := utils.ValidatePagination(page, limit)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+
+	invites, total, err := models.GetInvites(limit, offset)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve invites")
+		return
+	}
+
+	pagination := map[string]interface{}{
+		"current_page": page,
+		"per_page":     limit,
+		"total":        total,
+		"total_pages":  (total + limit - 1) / limit,
+		"has_next":     page < (total+limit-1)/limit,
+		"has_prev":     page > 1,
+	}
+
+	utils.SetPaginationLinks(w, r, page, limit, total)
+	utils.PaginatedSuccess(w, "Invites retrieved successfully", invites, pagination)
+}
+
+// DeleteInviteController handles DELETE /api/admin/invites/{id} (admin only)
+// Only unused invites can be revoked.
+func DeleteInviteController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.MethodNotAllowed(w, "Only DELETE method allowed")
+		return
+	}
+
+	inviteID, err := utils.GetIDFromURL(r, "/admin/invites/")
+	if err != nil {
+		utils.BadRequest(w, "Invalid invite ID")
+		return
+	}
+
+	deleted, err := models.DeleteInvite(inviteID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to revoke invite")
+		return
+	}
+	if !deleted {
+		utils.NotFound(w, "Unused invite not found")
+		return
+	}
+
+	utils.Success(w, "Invite revoked successfully", nil)
+}
+
+// GetModerationLogController handles GET /api/admin/moderation-log (moderators only)
+// Optional filters: actor_id, action, target_type, target_id.
+func GetModerationLogController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	query := r.URL.Query()
+
+	filter := models.ModerationLogFilter{
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+	}
+	if filter.TargetType != "" && filter.TargetType != models.TargetPost &&
+		filter.TargetType != models.TargetComment && filter.TargetType != models.TargetUser &&
+		filter.TargetType != models.TargetCategory {
+		utils.BadRequest(w, "Target type must be 'post', 'comment', 'user' or 'category'")
+		return
+	}
+	if v := query.Get("actor_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			utils.BadRequest(w, "Invalid actor ID")
+			return
+		}
+		filter.ActorID = id
+	}
+	if v := query.Get("target_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			utils.BadRequest(w, "Invalid target ID")
+			return
+		}
+		filter.TargetID = id
+	}
+
+	// Parse pagination parameters
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+
+	limit, offset, err := utils.ValidatePagination(page, limit)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+
+	entries, total, err := models.GetModerationLog(filter, limit, offset)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve moderation log")
+		return
+	}
+
+	pagination := map[string]interface{}{
+		"current_page": page,
+		"per_page":     limit,
+		"total":        total,
+		"total_pages":  (total + limit - 1) / limit,
+		"has_next":     page < (total+limit-1)/limit,
+		"has_prev":     page > 1,
+	}
+
+	utils.SetPaginationLinks(w, r, page, limit, total)
+	utils.PaginatedSuccess(w, "Moderation log retrieved successfully", entries, pagination)
+}
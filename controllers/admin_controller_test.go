@@ -0,0 +1,130 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"forum/database"
+	"forum/middleware"
+	"forum/models"
+)
+
+func TestUpdateSettings(t *testing.T) {
+	admin := newUser(t, models.RoleAdmin)
+	key := uniqueName("test.setting_")
+	t.Cleanup(func() { models.Settings.Delete(key) })
+
+	res, body := admin.do(http.MethodPut, "/api/admin/settings", map[string]interface{}{key: "12"})
+	expectStatus(t, res, body, http.StatusOK)
+	if got := models.Settings.GetInt(key, 0); got != 12 {
+		t.Fatalf("setting = %d, want 12", got)
+	}
+
+	// null removes the override
+	res, body = admin.do(http.MethodPut, "/api/admin/settings", map[string]interface{}{key: nil})
+	expectStatus(t, res, body, http.StatusOK)
+	if _, ok := models.Settings.Lookup(key); ok {
+		t.Fatal("setting still stored after a null update")
+	}
+
+	res, body = newUser(t, "").do(http.MethodPut, "/api/admin/settings", map[string]interface{}{key: "1"})
+	expectStatus(t, res, body, http.StatusForbidden)
+}
+
+func TestUpdateSettingsRefusesMaintenanceKeys(t *testing.T) {
+	admin := newUser(t, models.RoleAdmin)
+
+	for _, key := range []string{models.SettingMaintenanceMode, models.SettingMaintenanceMessage} {
+		t.Run(key, func(t *testing.T) {
+			res, body := admin.do(http.MethodPut, "/api/admin/settings", map[string]interface{}{key: "true"})
+			expectStatus(t, res, body, http.StatusUnprocessableEntity)
+			if len(body.Errors) != 1 || body.Errors[0].Field != key {
+				t.Fatalf("errors = %+v, want one on %s", body.Errors, key)
+			}
+
+			if _, ok := models.Settings.Lookup(key); ok {
+				t.Fatal("maintenance setting was stored")
+			}
+			if middleware.GetMaintenanceStatus().Enabled {
+				t.Fatal("maintenance mode was switched on")
+			}
+		})
+	}
+}
+
+func TestModeratorDeleteIsLogged(t *testing.T) {
+	author := newUser(t, "")
+	moderator := newUser(t, models.RoleModerator)
+	removed := author.createPost()
+	ownDelete := author.createPost()
+
+	res, body := moderator.do(http.MethodDelete, fmt.Sprintf("/api/posts/%d?reason=spam", removed), nil)
+	expectStatus(t, res, body, http.StatusOK)
+	// Authors deleting their own posts aren't moderation
+	res, body = author.do(http.MethodDelete, fmt.Sprintf("/api/posts/%d", ownDelete), nil)
+	expectStatus(t, res, body, http.StatusOK)
+
+	logFor := func(postID int) []models.ModerationLogEntry {
+		t.Helper()
+
+		res, body := moderator.do(http.MethodGet, fmt.Sprintf("/api/admin/moderation-log?target_type=post&target_id=%d", postID), nil)
+		expectStatus(t, res, body, http.StatusOK)
+		var entries []models.ModerationLogEntry
+		decodeData(t, body, &entries)
+		return entries
+	}
+
+	entries := logFor(removed)
+	if len(entries) != 1 {
+		t.Fatalf("got %d log entries, want 1: %+v", len(entries), entries)
+	}
+	entry := entries[0]
+	if entry.ActorID != moderator.User.ID || entry.ActorUsername != moderator.User.Username ||
+		entry.Action != models.ModActionDeletePost || entry.TargetType != models.TargetPost ||
+		entry.TargetID != removed || entry.Reason != "spam" {
+		t.Fatalf("log entry = %+v", entry)
+	}
+
+	if entries := logFor(ownDelete); len(entries) != 0 {
+		t.Fatalf("own delete was logged: %+v", entries)
+	}
+
+	// The log itself is for moderators only
+	res, body = author.do(http.MethodGet, "/api/admin/moderation-log", nil)
+	expectStatus(t, res, body, http.StatusForbidden)
+}
+
+func TestRecountVotesEndpoint(t *testing.T) {
+	author := newUser(t, "")
+	postID := author.createPost()
+	res, body := newUser(t, "").do(http.MethodPost, fmt.Sprintf("/api/posts/%d/vote", postID), map[string]string{"vote_type": "like"})
+	expectStatus(t, res, body, http.StatusOK)
+
+	if _, err := database.GetDB().Exec(`UPDATE posts SET likes = 9, dislikes = 3 WHERE id = ?`, postID); err != nil {
+		t.Fatal(err)
+	}
+
+	const path = "/api/admin/maintenance/recount-votes"
+	res, body = newUser(t, models.RoleModerator).do(http.MethodPost, path, nil)
+	expectStatus(t, res, body, http.StatusForbidden)
+
+	res, body = newUser(t, models.RoleAdmin).do(http.MethodPost, path, nil)
+	expectStatus(t, res, body, http.StatusOK)
+	var result models.VoteRecountResult
+	decodeData(t, body, &result)
+	if result.PostsUpdated < 1 {
+		t.Fatalf("result = %+v, want the drifted post counted", result)
+	}
+
+	res, body = author.do(http.MethodGet, fmt.Sprintf("/api/posts/%d", postID), nil)
+	expectStatus(t, res, body, http.StatusOK)
+	var post struct {
+		LikeCount    int `json:"like_count"`
+		DislikeCount int `json:"dislike_count"`
+	}
+	decodeData(t, body, &post)
+	if post.LikeCount != 1 || post.DislikeCount != 0 {
+		t.Fatalf("post counts = %d/%d, want 1/0", post.LikeCount, post.DislikeCount)
+	}
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"forum/middleware"
+	"forum/models"
+	"forum/utils"
+)
+
+// AddPostAttachmentController handles POST /api/posts/{id}/attachments
+// The file is sent as multipart form data in the "file" field.
+func AddPostAttachmentController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w, "Only POST method allowed")
+		return
+	}
+
+	userID, exists := middleware.GetUserIDFromContext(r)
+	if !exists {
+		utils.Unauthorized(w, "Authentication required")
+		return
+	}
+
+	postID, err := getPostIDFromPath(r.URL.Path)
+	if err != nil {
+		utils.BadRequest(w, "Invalid post ID")
+		return
+	}
+
+	post := models.Post{}
+	if err := post.GetByID(postID, &userID); err != nil {
+		utils.NotFound(w, "Post not found")
+		return
+	}
+
+	if post.UserID != userID {
+		utils.Forbidden(w, "You can only add attachments to your own posts")
+		return
+	}
+
+	count, err := models.CountPostAttachments(postID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to check attachments")
+		return
+	}
+	if count >= models.MaxAttachmentsPerPost {
+		utils.BadRequest(w, fmt.Sprintf("A post can have at most %d attachments", models.MaxAttachmentsPerPost))
+		return
+	}
+
+	uploadResult, err := utils.HandleFileUpload(r, "file", utils.AttachmentUploadConfig)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+
+	attachment := models.Attachment{
+		PostID:       postID,
+		UserID:       userID,
+		Filename:     uploadResult.Filename,
+		OriginalName: uploadResult.OriginalName,
+		MimeType:     uploadResult.MimeType,
+		Size:         uploadResult.Size,
+		URL:          uploadResult.URL,
+	}
+	if err := attachment.Create(); err != nil {
+		// If the record can't be stored, don't leave the file behind
+		utils.DeleteFile(utils.GetAttachmentFilePath(uploadResult.Filename))
+		utils.InternalServerError(w, "Failed to save attachment")
+		return
+	}
+
+	utils.Created(w, "Attachment added successfully", newAttachmentResponses([]models.Attachment{attachment})[0])
+}
+
+// newAttachmentResponses converts attachments for a post response, never returning nil
+func newAttachmentResponses(attachments []models.Attachment) []AttachmentResponse {
+	responses := make([]AttachmentResponse, 0, len(attachments))
+	for _, a := range attachments {
+		responses = append(responses, AttachmentResponse{
+			ID:           a.ID,
+			URL:          a.URL,
+			OriginalName: a.OriginalName,
+			MimeType:     a.MimeType,
+			Size:         a.Size,
+		})
+	}
+	return responses
+}
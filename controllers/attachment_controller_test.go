@@ -0,0 +1,118 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// attachment is an attachment as post responses show it
+type attachment struct {
+	ID           int    `json:"id"`
+	URL          string `json:"url"`
+	OriginalName string `json:"original_name"`
+	MimeType     string `json:"mime_type"`
+	Size         int64  `json:"size"`
+}
+
+func TestAddPostAttachment(t *testing.T) {
+	author := newUser(t, "")
+	postID := author.createPost()
+	attachPath := fmt.Sprintf("/api/posts/%d/attachments", postID)
+	image := testPNG(t, 4, 4)
+
+	res, body := author.upload(attachPath, "file", "diagram.png", "image/png", image)
+	expectStatus(t, res, body, http.StatusCreated)
+
+	var added attachment
+	decodeData(t, body, &added)
+	if added.ID == 0 || added.OriginalName != "diagram.png" || added.MimeType != "image/png" || added.Size != int64(len(image)) {
+		t.Fatalf("attachment = %+v", added)
+	}
+	if !strings.HasPrefix(added.URL, "/uploads/attachments/") {
+		t.Fatalf("url = %q, want one under /uploads/attachments/", added.URL)
+	}
+	stored, err := os.ReadFile(filepath.Join("uploads", "attachments", path.Base(added.URL)))
+	if err != nil || len(stored) != len(image) {
+		t.Fatalf("stored file = %d bytes, %v", len(stored), err)
+	}
+
+	// The post now lists it
+	res, body = newVisitor(t).do(http.MethodGet, fmt.Sprintf("/api/posts/%d", postID), nil)
+	expectStatus(t, res, body, http.StatusOK)
+	var post struct {
+		Attachments []attachment `json:"attachments"`
+	}
+	decodeData(t, body, &post)
+	if !reflect.DeepEqual(post.Attachments, []attachment{added}) {
+		t.Fatalf("attachments = %+v, want [%+v]", post.Attachments, added)
+	}
+
+	t.Run("someone else's post", func(t *testing.T) {
+		res, body := newUser(t, "").upload(attachPath, "file", "diagram.png", "image/png", image)
+		expectStatus(t, res, body, http.StatusForbidden)
+	})
+	t.Run("not an image", func(t *testing.T) {
+		res, body := author.upload(attachPath, "file", "notes.txt", "text/plain", []byte("just some text"))
+		expectStatus(t, res, body, http.StatusBadRequest)
+	})
+	t.Run("visitor", func(t *testing.T) {
+		res, body := newVisitor(t).upload(attachPath, "file", "diagram.png", "image/png", image)
+		expectStatus(t, res, body, http.StatusUnauthorized)
+	})
+
+	// Deleting the post removes the file
+	res, body = author.do(http.MethodDelete, fmt.Sprintf("/api/posts/%d", postID), nil)
+	expectStatus(t, res, body, http.StatusOK)
+	if _, err := os.Stat(filepath.Join("uploads", "attachments", path.Base(added.URL))); !os.IsNotExist(err) {
+		t.Fatalf("attachment file after deleting the post: %v", err)
+	}
+}
+
+func TestPostsWithoutAttachmentsListEmpty(t *testing.T) {
+	author := newUser(t, "")
+	postID := author.createPost()
+
+	res, body := author.do(http.MethodGet, fmt.Sprintf("/api/posts/%d", postID), nil)
+	expectStatus(t, res, body, http.StatusOK)
+	if !strings.Contains(string(body.Data), `"attachments":[]`) {
+		t.Fatalf("post = %s, want an empty attachments list", body.Data)
+	}
+}
+
+func TestHasAttachmentsFilter(t *testing.T) {
+	categoryID := newCategory(t)
+	author := newUser(t, "")
+	plain := author.createPost(categoryID)
+	attached := author.createPost(categoryID)
+	res, body := author.upload(fmt.Sprintf("/api/posts/%d/attachments", attached), "file", "photo.png", "image/png", testPNG(t, 2, 2))
+	expectStatus(t, res, body, http.StatusCreated)
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"", []int{attached, plain}},
+		{"&has_attachments=false", []int{attached, plain}},
+		{"&has_attachments=true", []int{attached}},
+		{"&has_attachments=true&attachment_type=image/png", []int{attached}},
+		{"&has_attachments=true&attachment_type=image/gif", []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			res, body := author.do(http.MethodGet, fmt.Sprintf("/api/posts?category=%d&sort=newest%s", categoryID, tt.query), nil)
+			expectStatus(t, res, body, http.StatusOK)
+			if got := listIDs(t, body); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("posts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	res, body = author.do(http.MethodGet, fmt.Sprintf("/api/posts?category=%d&has_attachments=maybe", categoryID), nil)
+	expectStatus(t, res, body, http.StatusBadRequest)
+}
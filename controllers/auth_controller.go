@@ -3,18 +3,25 @@ package controllers
 import (
 	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"forum/config"
+	"forum/events"
+	"forum/middleware"
 	"forum/models"
 	"forum/utils"
 )
 
 // RegisterRequest represents the JSON structure for user registration
 type RegisterRequest struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Username   string `json:"username"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	InviteCode string `json:"invite_code,omitempty"` // required while registration is invite-only
 }
 
 // LoginRequest represents the JSON structure for user login
@@ -31,11 +38,13 @@ type AuthResponse struct {
 
 // UserResponse respresents user data sent to client (no sensitive info)
 type UserResponse struct {
-	ID       int       `json:"id"`
-	Username string    `json:"username"`
-	Email    string    `json:"email"`
-	Avatar   string    `json:"avatar"`
-	JoinedAt time.Time `json:"joined_at"`
+	ID        int            `json:"id"`
+	Username  string         `json:"username"`
+	Email     string         `json:"email"`
+	Avatar    string         `json:"avatar"`
+	Role      string         `json:"role,omitempty"`
+	JoinedAt  utils.JSONTime `json:"joined_at"`
+	NewMember bool           `json:"new_member"` // joined within the new member period
 }
 
 // RegisterController handles user registration
@@ -46,6 +55,11 @@ func RegisterController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !models.RegistrationEnabled() {
+		utils.ErrorWithCode(w, http.StatusForbidden, "registration_closed", "Registration is currently closed")
+		return
+	}
+
 	// Parse JSON request body
 	var req RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -54,7 +68,13 @@ func RegisterController(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate registration form
-	if errors := utils.ValidateRegistrationForm(req.Username, req.Email, req.Password); errors.HasErrors() {
+	errors := utils.ValidateRegistrationForm(req.Username, req.Email, req.Password)
+	inviteOnly := models.RegistrationInviteOnly()
+	req.InviteCode = strings.TrimSpace(req.InviteCode)
+	if inviteOnly && req.InviteCode == "" {
+		errors.Add("invite_code", "An invite code is required to register")
+	}
+	if errors.HasErrors() {
 		utils.ValidationError(w, errors)
 		return
 	}
@@ -70,6 +90,21 @@ func RegisterController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Claim the invite before creating the account so a code can't be used twice
+	inviteID := 0
+	if inviteOnly {
+		inviteID, err = models.ReserveInvite(req.InviteCode)
+		if err == models.ErrInvalidInvite {
+			errors.Add("invite_code", "Invite code is invalid, expired or already used")
+			utils.ValidationError(w, errors)
+			return
+		}
+		if err != nil {
+			utils.InternalServerError(w, "Database error")
+			return
+		}
+	}
+
 	// Create new user
 	user := models.User{
 		Username:     req.Username,
@@ -78,10 +113,27 @@ func RegisterController(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := user.Create(); err != nil {
+		if inviteID > 0 {
+			if err := models.ReleaseInvite(inviteID); err != nil {
+				log.Printf("Failed to release invite %d: %v", inviteID, err)
+			}
+		}
 		utils.InternalServerError(w, "Failed to created user account")
 		return
 	}
 
+	if inviteID > 0 {
+		if err := models.CompleteInvite(inviteID, user.ID); err != nil {
+			log.Printf("Failed to record invite %d use by user %d: %v", inviteID, user.ID, err)
+		}
+	}
+
+	events.Publish(events.UserRegistered, map[string]interface{}{
+		"id":         user.ID,
+		"username":   user.Username,
+		"created_at": utils.NewJSONTime(user.CreatedAt),
+	})
+
 	// Create session for new user
 	session, err := utils.CreateSession(user.ID)
 	if err != nil {
@@ -89,16 +141,19 @@ func RegisterController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set session cookie
+	// Set session and CSRF cookies
 	utils.SetSessionCookie(w, session)
+	utils.SetCSRFCookie(w, session)
 
 	// Prepare response
 	UserResponse := UserResponse{
-		ID:       user.ID,
-		Username: user.Username,
-		Email:    user.Email,
-		Avatar:   user.Avatar,
-		JoinedAt: user.CreatedAt,
+		ID:        user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Avatar:    user.Avatar,
+		Role:      user.Role,
+		JoinedAt:  utils.NewJSONTime(user.CreatedAt),
+		NewMember: user.IsNewMember(),
 	}
 
 	authResponse := AuthResponse{
@@ -109,6 +164,21 @@ func RegisterController(w http.ResponseWriter, r *http.Request) {
 	utils.Created(w, "Account created successfully", authResponse)
 }
 
+// RegistrationStatusController handles GET /api/auth/registration
+// It tells clients whether to show the sign-up form and whether it needs an invite code.
+func RegistrationStatusController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	utils.Success(w, "Registration status retrieved", map[string]interface{}{
+		"enabled":         models.RegistrationEnabled(),
+		"invite_only":     models.RegistrationInviteOnly(),
+		"password_policy": config.GetPasswordPolicy(),
+	})
+}
+
 // LoginController handles user login
 func LoginController(w http.ResponseWriter, r *http.Request) {
 	// Only allow POST requests
@@ -137,17 +207,42 @@ func LoginController(w http.ResponseWriter, r *http.Request) {
 	if err = user.GetByUsername(req.Username); err != nil {
 		// If not found , try by email
 		if err = user.GetByEmail(req.Username); err != nil {
-			utils.Unauthorized(w, "Invalid username/email or password")
-			return
+			user = models.User{}
 		}
 	}
 
+	// Unknown names are counted and locked just like accounts, so the responses
+	// don't tell an attacker which accounts exist
+	loginKey := models.LoginKey(user.ID, req.Username)
+
+	// Refuse locked accounts before checking the password
+	if lockedUntil, locked, err := models.GetLoginLockout(loginKey); err != nil {
+		utils.InternalServerError(w, "Login failed")
+		return
+	} else if locked {
+		accountLocked(w, lockedUntil)
+		return
+	}
+
 	// Verify password
-	if !user.CheckPassword(req.Password) {
+	if user.ID == 0 || !user.CheckPassword(req.Password) {
+		lockedUntil, locked, err := models.RecordFailedLogin(loginKey, middleware.ClientIP(r),
+			config.GetLoginMaxFailures(), config.GetLoginFailureWindow(), config.GetLoginLockoutDuration())
+		if err != nil {
+			log.Printf("Failed to record failed login for %s: %v", loginKey, err)
+		} else if locked {
+			accountLocked(w, lockedUntil)
+			return
+		}
 		utils.Unauthorized(w, "Invalid username/email or password")
 		return
 	}
 
+	// A successful login resets the failure count
+	if err := models.ClearFailedLogins(loginKey); err != nil {
+		log.Printf("Failed to clear failed logins for user %d: %v", user.ID, err)
+	}
+
 	// Update last login time
 	user.UpdateLastLogin()
 
@@ -158,16 +253,19 @@ func LoginController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set session cookie
+	// Set session and CSRF cookies
 	utils.SetSessionCookie(w, session)
+	utils.SetCSRFCookie(w, session)
 
 	// Prepare response
 	userResponse := UserResponse{
-		ID:       user.ID,
-		Username: user.Username,
-		Email:    user.Email,
-		Avatar:   user.Avatar,
-		JoinedAt: user.CreatedAt,
+		ID:        user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Avatar:    user.Avatar,
+		Role:      user.Role,
+		JoinedAt:  utils.NewJSONTime(user.CreatedAt),
+		NewMember: user.IsNewMember(),
 	}
 
 	authResponse := AuthResponse{
@@ -178,6 +276,13 @@ func LoginController(w http.ResponseWriter, r *http.Request) {
 	utils.Success(w, "Login successful", authResponse)
 }
 
+// accountLocked responds 429 telling the client when it may try again
+func accountLocked(w http.ResponseWriter, lockedUntil time.Time) {
+	retryAfter := int(time.Until(lockedUntil).Seconds()) + 1
+	utils.Throttled(w, "account_locked",
+		"Too many failed login attempts. Please try again in "+strconv.Itoa((retryAfter+59)/60)+" minute(s).", retryAfter)
+}
+
 // LogoutController handles user logout
 func LogoutController(w http.ResponseWriter, r *http.Request) {
 	// Only allow POST requests
@@ -186,7 +291,7 @@ func LogoutController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sessionID, err := r.Cookie("forum_session")
+	sessionID, err := r.Cookie(utils.SessionCookieName())
 	if err != nil {
 		utils.Success(w, "Logged out successfully", nil) // Already logged out
 		return
@@ -198,8 +303,9 @@ func LogoutController(w http.ResponseWriter, r *http.Request) {
 		// utils.LogError("Failed to delete session", err)
 	}
 
-	// Clear session cookie
+	// Clear session and CSRF cookies
 	utils.ClearSessionCookie(w)
+	utils.ClearCSRFCookie(w)
 
 	utils.Success(w, "Logged out successfully", nil)
 }
@@ -228,11 +334,13 @@ func MeController(w http.ResponseWriter, r *http.Request) {
 
 	// Prepare response
 	userResponse := UserResponse{
-		ID:       user.ID,
-		Username: user.Username,
-		Email:    user.Email,
-		Avatar:   user.Avatar,
-		JoinedAt: user.CreatedAt,
+		ID:        user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Avatar:    user.Avatar,
+		Role:      user.Role,
+		JoinedAt:  utils.NewJSONTime(user.CreatedAt),
+		NewMember: user.IsNewMember(),
 	}
 
 	utils.Success(w, "User data retrieved", userResponse)
@@ -247,7 +355,7 @@ func RefreshSessionController(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get current session
-	sessionCookie, err := r.Cookie("forum_session")
+	sessionCookie, err := r.Cookie(utils.SessionCookieName())
 	if err != nil {
 		utils.Unauthorized(w, "No active session")
 		return
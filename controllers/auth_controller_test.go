@@ -0,0 +1,186 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"forum/config"
+	"forum/database"
+	"forum/models"
+)
+
+// login attempts a login as a fresh visitor and returns the response status
+func login(t *testing.T, username, password string) *http.Response {
+	t.Helper()
+
+	res, _ := newVisitor(t).do(http.MethodPost, "/api/auth/login", map[string]string{
+		"username": username,
+		"password": password,
+	})
+	return res
+}
+
+func TestLoginLockout(t *testing.T) {
+	maxFailures := config.GetLoginMaxFailures()
+
+	// Real and unknown accounts must answer the same way, or lockouts reveal which exist
+	names := map[string]string{
+		"existing account": newUser(t, "").User.Username,
+		"unknown account":  uniqueName("ghost"),
+	}
+	for name, username := range names {
+		t.Run(name, func(t *testing.T) {
+			for i := 1; i < maxFailures; i++ {
+				if res := login(t, username, "wrong-password"); res.StatusCode != http.StatusUnauthorized {
+					t.Fatalf("failure %d: status = %d, want 401", i, res.StatusCode)
+				}
+			}
+
+			res := login(t, username, "wrong-password")
+			if res.StatusCode != http.StatusTooManyRequests {
+				t.Fatalf("failure %d: status = %d, want 429", maxFailures, res.StatusCode)
+			}
+			if res.Header.Get("Retry-After") == "" {
+				t.Fatal("lockout response has no Retry-After")
+			}
+
+			// Even the right password is refused while locked
+			if res := login(t, username, testPassword); res.StatusCode != http.StatusTooManyRequests {
+				t.Fatalf("login while locked: status = %d, want 429", res.StatusCode)
+			}
+		})
+	}
+}
+
+func TestLoginSuccessResetsFailures(t *testing.T) {
+	username := newUser(t, "").User.Username
+	maxFailures := config.GetLoginMaxFailures()
+
+	for round := 0; round < 2; round++ {
+		for i := 1; i < maxFailures; i++ {
+			if res := login(t, username, "wrong-password"); res.StatusCode != http.StatusUnauthorized {
+				t.Fatalf("round %d failure %d: status = %d, want 401", round, i, res.StatusCode)
+			}
+		}
+		if res := login(t, username, testPassword); res.StatusCode != http.StatusOK {
+			t.Fatalf("round %d: login status = %d, want 200", round, res.StatusCode)
+		}
+	}
+}
+
+// register signs up a fresh account as a new visitor, with an optional invite code
+func register(t *testing.T, inviteCode string) (*http.Response, apiResponse) {
+	t.Helper()
+
+	name := uniqueName("user")
+	return newVisitor(t).do(http.MethodPost, "/api/auth/register", map[string]string{
+		"username":    name,
+		"email":       name + "@example.com",
+		"password":    testPassword,
+		"invite_code": inviteCode,
+	})
+}
+
+// withSetting sets a runtime setting for one test
+func withSetting(t *testing.T, key, value string) {
+	t.Helper()
+
+	if err := models.Settings.Set(key, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { models.Settings.Delete(key) })
+}
+
+// registrationStatus fetches whether registration is open and invite-only
+func registrationStatus(t *testing.T) (enabled, inviteOnly bool) {
+	t.Helper()
+
+	res, body := newVisitor(t).do(http.MethodGet, "/api/auth/registration", nil)
+	expectStatus(t, res, body, http.StatusOK)
+	var status struct {
+		Enabled    bool `json:"enabled"`
+		InviteOnly bool `json:"invite_only"`
+	}
+	decodeData(t, body, &status)
+	return status.Enabled, status.InviteOnly
+}
+
+func TestRegistrationClosed(t *testing.T) {
+	withSetting(t, models.SettingRegistrationEnabled, "false")
+
+	res, body := register(t, "")
+	expectStatus(t, res, body, http.StatusForbidden)
+	if body.Code != "registration_closed" {
+		t.Fatalf("code = %q, want registration_closed", body.Code)
+	}
+	if enabled, _ := registrationStatus(t); enabled {
+		t.Fatal("registration status says enabled")
+	}
+
+	// Reopening takes effect straight away
+	withSetting(t, models.SettingRegistrationEnabled, "true")
+	res, body = register(t, "")
+	expectStatus(t, res, body, http.StatusCreated)
+}
+
+func TestInviteOnlyRegistration(t *testing.T) {
+	admin := newUser(t, models.RoleAdmin)
+	plainUser := newUser(t, "")
+
+	withSetting(t, models.SettingRegistrationInviteOnly, "true")
+	if _, inviteOnly := registrationStatus(t); !inviteOnly {
+		t.Fatal("registration status doesn't say invite-only")
+	}
+
+	newInvite := func(t *testing.T) models.Invite {
+		t.Helper()
+
+		res, body := admin.do(http.MethodPost, "/api/admin/invites", nil)
+		expectStatus(t, res, body, http.StatusCreated)
+		var invite models.Invite
+		decodeData(t, body, &invite)
+		return invite
+	}
+	expectInviteRefused := func(t *testing.T, code string) {
+		t.Helper()
+
+		res, body := register(t, code)
+		expectStatus(t, res, body, http.StatusUnprocessableEntity)
+		if len(body.Errors) != 1 || body.Errors[0].Field != "invite_code" {
+			t.Fatalf("errors = %+v, want one on invite_code", body.Errors)
+		}
+	}
+
+	t.Run("no code", func(t *testing.T) { expectInviteRefused(t, "") })
+	t.Run("unknown code", func(t *testing.T) { expectInviteRefused(t, "not-a-real-code") })
+
+	t.Run("valid code, once", func(t *testing.T) {
+		invite := newInvite(t)
+		res, body := register(t, invite.Code)
+		expectStatus(t, res, body, http.StatusCreated)
+
+		expectInviteRefused(t, invite.Code)
+	})
+
+	t.Run("expired code", func(t *testing.T) {
+		invite := newInvite(t)
+		if _, err := database.GetDB().Exec(`UPDATE invites SET expires_at = ? WHERE id = ?`, time.Now().Add(-time.Minute), invite.ID); err != nil {
+			t.Fatal(err)
+		}
+		expectInviteRefused(t, invite.Code)
+	})
+
+	t.Run("revoked code", func(t *testing.T) {
+		invite := newInvite(t)
+		res, body := admin.do(http.MethodDelete, fmt.Sprintf("/api/admin/invites/%d", invite.ID), nil)
+		expectStatus(t, res, body, http.StatusOK)
+		expectInviteRefused(t, invite.Code)
+	})
+
+	t.Run("admins only", func(t *testing.T) {
+		res, body := plainUser.do(http.MethodPost, "/api/admin/invites", nil)
+		expectStatus(t, res, body, http.StatusForbidden)
+	})
+}
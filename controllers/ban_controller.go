@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"forum/middleware"
+	"forum/models"
+	"forum/utils"
+)
+
+// MaxBanDurationHours caps temporary bans at ten years; longer bans should be permanent
+const MaxBanDurationHours = 24 * 365 * 10
+
+// BanRequest represents the JSON structure for banning a user
+type BanRequest struct {
+	Reason        string `json:"reason"`
+	DurationHours int    `json:"duration_hours"` // 0 or omitted bans permanently
+}
+
+// BanUserController handles POST /api/users/{id}/ban (moderators only)
+func BanUserController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w, "Only POST method allowed")
+		return
+	}
+
+	actorID, _ := middleware.GetUserIDFromContext(r)
+
+	target, ok := getBanTarget(w, r, actorID)
+	if !ok {
+		return
+	}
+
+	var req BanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid JSON format")
+		return
+	}
+	req.Reason = strings.TrimSpace(utils.SanitizeString(req.Reason))
+
+	if errs := validateBanRequest(req); len(errs) > 0 {
+		utils.ValidationError(w, errs)
+		return
+	}
+
+	if err := target.Ban(banExpiry(req.DurationHours), req.Reason); err != nil {
+		utils.InternalServerError(w, "Failed to ban user")
+		return
+	}
+
+	if err := models.LogModerationAction(actorID, models.ModActionBanUser, models.TargetUser, target.ID, req.Reason); err != nil {
+		log.Printf("Failed to write moderation log: %v", err)
+	}
+
+	utils.Success(w, "User banned successfully", getBanStatus(target))
+}
+
+// UnbanUserController handles POST /api/users/{id}/unban (moderators only)
+func UnbanUserController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w, "Only POST method allowed")
+		return
+	}
+
+	actorID, _ := middleware.GetUserIDFromContext(r)
+
+	target, ok := getBanTarget(w, r, actorID)
+	if !ok {
+		return
+	}
+
+	if !target.IsBanned() {
+		utils.Conflict(w, "User is not banned")
+		return
+	}
+
+	if err := target.Unban(); err != nil {
+		utils.InternalServerError(w, "Failed to unban user")
+		return
+	}
+
+	if err := models.LogModerationAction(actorID, models.ModActionUnbanUser, models.TargetUser, target.ID, ""); err != nil {
+		log.Printf("Failed to write moderation log: %v", err)
+	}
+
+	utils.Success(w, "User unbanned successfully", getBanStatus(target))
+}
+
+// getBanTarget loads the user in the URL and checks the actor may ban or unban them.
+// Nobody can ban themselves or an admin, and only admins can ban moderators.
+func getBanTarget(w http.ResponseWriter, r *http.Request, actorID int) (*models.User, bool) {
+	userID, err := utils.GetIDFromURL(r, "/users/")
+	if err != nil {
+		utils.BadRequest(w, "Invalid user ID")
+		return nil, false
+	}
+
+	target := &models.User{}
+	if err := target.GetByID(userID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.NotFound(w, "User not found")
+			return nil, false
+		}
+		utils.InternalServerError(w, "Failed to get user")
+		return nil, false
+	}
+
+	if message := checkCanBan(r, actorID, target); message != "" {
+		utils.Forbidden(w, message)
+		return nil, false
+	}
+	return target, true
+}
+
+// checkCanBan returns why the actor may not ban the target, or "" if they may
+func checkCanBan(r *http.Request, actorID int, target *models.User) string {
+	switch {
+	case target.ID == actorID:
+		return "You cannot ban yourself"
+	case target.IsAdmin():
+		return "Admins cannot be banned"
+	case target.IsModerator() && !middleware.IsAdmin(r):
+		return "Only admins can ban moderators"
+	}
+	return ""
+}
+
+// validateBanRequest checks the reason and duration of a ban
+func validateBanRequest(req BanRequest) utils.ValidationErrors {
+	var errs utils.ValidationErrors
+	if req.Reason == "" {
+		errs.Add("reason", "A reason is required")
+	}
+	if req.DurationHours < 0 || req.DurationHours > MaxBanDurationHours {
+		errs.Add("duration_hours", "Duration must be between 0 (permanent) and 87600 hours")
+	}
+	return errs
+}
+
+// banExpiry converts a requested duration into the banned_until time
+func banExpiry(durationHours int) time.Time {
+	if durationHours == 0 {
+		return models.PermanentBan
+	}
+	return time.Now().Add(time.Duration(durationHours) * time.Hour)
+}
+
+// getBanStatus describes a user's ban for API responses
+func getBanStatus(user *models.User) map[string]interface{} {
+	banned := user.IsBanned()
+	status := map[string]interface{}{
+		"user_id":      user.ID,
+		"banned":       banned,
+		"banned_until": nil,
+		"permanent":    false,
+		"reason":       user.BanReason,
+	}
+	if banned {
+		permanent := models.IsPermanentBan(*user.BannedUntil)
+		status["permanent"] = permanent
+		if !permanent {
+			status["banned_until"] = user.BannedUntil
+		}
+	}
+	return status
+}
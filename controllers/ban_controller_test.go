@@ -0,0 +1,51 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"forum/models"
+)
+
+func TestBanBlocksActionsUntilUnbanned(t *testing.T) {
+	user := newUser(t, "")
+	moderator := newUser(t, models.RoleModerator)
+	postID := user.createPost()
+	banPath := fmt.Sprintf("/api/users/%d/ban", user.User.ID)
+
+	// Only moderators can ban
+	res, body := newUser(t, "").do(http.MethodPost, banPath, map[string]interface{}{"reason": "spam"})
+	expectStatus(t, res, body, http.StatusForbidden)
+
+	res, body = moderator.do(http.MethodPost, banPath, map[string]interface{}{"reason": "spam", "duration_hours": 24})
+	expectStatus(t, res, body, http.StatusOK)
+
+	actions := []struct {
+		method, path string
+		body         interface{}
+	}{
+		{http.MethodPost, "/api/posts", map[string]interface{}{
+			"title": "A post while banned", "content": "This should not be posted", "category_ids": []int{1},
+		}},
+		{http.MethodPost, fmt.Sprintf("/api/posts/%d/comments", postID), map[string]string{"content": "Banned comment"}},
+		{http.MethodPost, fmt.Sprintf("/api/posts/%d/vote", postID), map[string]string{"vote_type": "like"}},
+	}
+	for _, action := range actions {
+		res, body := user.do(action.method, action.path, action.body)
+		expectStatus(t, res, body, http.StatusForbidden)
+		if body.Code != "account_banned" || !strings.Contains(body.Error, "spam") {
+			t.Fatalf("%s %s: code = %q, message = %q %q", action.method, action.path, body.Code, body.Message, body.Error)
+		}
+	}
+
+	res, body = moderator.do(http.MethodPost, fmt.Sprintf("/api/users/%d/unban", user.User.ID), nil)
+	expectStatus(t, res, body, http.StatusOK)
+
+	// The same session works again straight away
+	user.createPost()
+	user.createComment(postID, "Back again")
+	res, body = user.do(http.MethodPost, fmt.Sprintf("/api/posts/%d/vote", postID), map[string]string{"vote_type": "like"})
+	expectStatus(t, res, body, http.StatusOK)
+}
@@ -1,25 +1,69 @@
 package controllers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
+	"forum/middleware"
 	"forum/models"
 	"forum/utils"
 )
 
 // CategoryResponse represents category data sent to client
 type CategoryResponse struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	PostCount   int    `json:"post_count"`
+	ID             int    `json:"id"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	Slug           string `json:"slug"`
+	Color          string `json:"color"`
+	Icon           string `json:"icon"`
+	Position       int    `json:"position"`
+	Archived       bool   `json:"archived"`
+	ParentID       *int   `json:"parent_id"`
+	PostCount      int    `json:"post_count"`
+	PostPermission string `json:"post_permission"`
+	CanPost        *bool  `json:"can_post,omitempty"` // whether the viewer may post here; only in listings
+}
+
+// CategoryTreeNode is a category with its subcategories nested under it.
+// TotalPostCount also counts posts filed under any subcategory.
+type CategoryTreeNode struct {
+	CategoryResponse
+	TotalPostCount int                 `json:"total_post_count"`
+	Children       []*CategoryTreeNode `json:"children"`
+}
+
+// newCategoryResponse maps a category to its response form
+func newCategoryResponse(category models.Category) CategoryResponse {
+	return CategoryResponse{
+		ID:             category.ID,
+		Name:           category.Name,
+		Description:    category.Description,
+		Slug:           category.Slug,
+		Color:          category.Color,
+		Icon:           category.Icon,
+		Position:       category.Position,
+		Archived:       category.Archived,
+		ParentID:       category.ParentID,
+		PostCount:      category.PostCount,
+		PostPermission: category.PostPermission,
+	}
 }
 
 // GetCategoriesController handles retrieving all categories
+// Archived categories are only listed for admins asking with ?include_archived=true.
+// With ?tree=true subcategories are nested under their parents. ?sort= orders the
+// list (and siblings in the tree) by position (default), name, post_count or recent_activity.
+// Each category includes can_post for the viewer, false for visitors.
+// Listings come from the category cache and carry an ETag, so unchanged ones get a 304.
 func GetCategoriesController(w http.ResponseWriter, r *http.Request) {
 	// Only allow GET requests
 	if r.Method != http.MethodGet {
@@ -27,27 +71,151 @@ func GetCategoriesController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get categories from database
-	categories, err := models.GetAllCategories()
+	includeArchived := r.URL.Query().Get("include_archived") == "true" && middleware.IsAdmin(r)
+
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy == "" {
+		sortBy = models.CategorySortPosition
+	}
+	if !models.IsValidCategorySort(sortBy) {
+		utils.BadRequest(w, "Sort must be one of: "+strings.Join(models.CategorySorts, ", "))
+		return
+	}
+
+	categories, version, err := models.GetCachedCategories(includeArchived, sortBy)
 	if err != nil {
 		utils.InternalServerError(w, "Failed to retrieve categories")
 		return
 	}
 
+	// Visitors can't post anywhere; signed-in users are checked against each category
+	_, authenticated := middleware.GetUserIDFromContext(r)
+	role, _ := middleware.GetRoleFromContext(r)
+
+	// can_post depends on the viewer's role and the tree is a different shape,
+	// so both are part of the ETag along with the cached listing's version
+	viewer := "visitor"
+	if authenticated {
+		viewer = role
+	}
+	etag := `W/"categories-` + version + "-" + viewer + "-" + strconv.FormatBool(r.URL.Query().Get("tree") == "true") + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	canPost := func(category models.Category) *bool {
+		allowed := authenticated && !category.Archived && category.CanPost(role)
+		return &allowed
+	}
+
+	if r.URL.Query().Get("tree") == "true" {
+		tree, err := buildCategoryTree(categories, canPost)
+		if err != nil {
+			utils.InternalServerError(w, "Failed to retrieve categories")
+			return
+		}
+		utils.Success(w, "Categories retrieved successfully", tree)
+		return
+	}
+
 	// Convert to response format
 	var categoryResponses []CategoryResponse
 	for _, category := range categories {
-		categoryResponses = append(categoryResponses, CategoryResponse{
-			ID:          category.ID,
-			Name:        category.Name,
-			Description: category.Description,
-			PostCount:   category.PostCount,
-		})
+		response := newCategoryResponse(category)
+		response.CanPost = canPost(category)
+		categoryResponses = append(categoryResponses, response)
 	}
 
 	utils.Success(w, "Categories retrieved successfully", categoryResponses)
 }
 
+// buildCategoryTree nests categories under their parents, keeping the listing order
+// among siblings. A category whose parent isn't listed (e.g. an archived one) is
+// shown at the top level.
+func buildCategoryTree(categories []models.Category, canPost func(models.Category) *bool) ([]*CategoryTreeNode, error) {
+	totals, err := models.GetSubtreePostCounts()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[int]*CategoryTreeNode, len(categories))
+	for _, category := range categories {
+		response := newCategoryResponse(category)
+		response.CanPost = canPost(category)
+		nodes[category.ID] = &CategoryTreeNode{
+			CategoryResponse: response,
+			TotalPostCount:   totals[category.ID],
+			Children:         []*CategoryTreeNode{},
+		}
+	}
+
+	roots := []*CategoryTreeNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if category.ParentID != nil {
+			if parent, ok := nodes[*category.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	return roots, nil
+}
+
+// Limits for GET /api/categories/popular
+const (
+	defaultPopularCategories = 5
+	maxPopularCategories     = 20
+)
+
+// GetPopularCategoriesController handles GET /api/categories/popular?limit=5&t=week
+// t is the window posts are counted over: day, week (default), month or all.
+func GetPopularCategoriesController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	query := r.URL.Query()
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 {
+		limit = defaultPopularCategories
+	}
+	if limit > maxPopularCategories {
+		limit = maxPopularCategories
+	}
+
+	window := query.Get("t")
+	if window == "" {
+		window = models.PopularWindowWeek
+	}
+	since, ok := models.PopularWindowStart(window, time.Now())
+	if !ok {
+		utils.BadRequest(w, "t must be one of: "+strings.Join(models.PopularWindows, ", "))
+		return
+	}
+
+	categories, err := models.GetPopularCategories(limit, since)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve popular categories")
+		return
+	}
+
+	categoryResponses := []CategoryResponse{}
+	for _, category := range categories {
+		categoryResponses = append(categoryResponses, newCategoryResponse(category))
+	}
+
+	utils.Success(w, "Popular categories retrieved successfully", map[string]interface{}{
+		"window":     window,
+		"categories": categoryResponses,
+	})
+}
+
 // GetCategoryController handles retrieving a single category
 func GetCategoryController(w http.ResponseWriter, r *http.Request) {
 	// Only allow GET requests
@@ -64,34 +232,138 @@ func GetCategoryController(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get category from database
-	categories, err := models.GetAllCategories()
+	category := &models.Category{}
+	if err := category.GetByID(categoryID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.NotFound(w, "Category not found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to retrieve category")
+		return
+	}
+
+	utils.Success(w, "Category retrieved successfully", newCategoryResponse(*category))
+}
+
+// GetCategoryPostsController handles GET /api/categories/{id}/posts
+// It takes the same page, limit and sort parameters as GET /api/posts and returns
+// the category alongside its posts. Archived categories are only found by admins
+// asking with ?include_archived=true.
+func GetCategoryPostsController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	categoryID, err := getCategoryIDFromPath(r.URL.Path)
 	if err != nil {
-		utils.InternalServerError(w, "Failed to retrieve categories")
+		utils.BadRequest(w, "Invalid category ID")
 		return
 	}
 
-	// Find the specific category
-	var category *models.Category
-	for _, cat := range categories {
-		if cat.ID == categoryID {
-			category = &cat
-			break
-		}
+	query := r.URL.Query()
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+
+	limit, offset, err := utils.ValidatePagination(page, limit)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
 	}
 
-	if category == nil {
+	sortBy := query.Get("sort")
+	if sortBy == "" {
+		sortBy = models.DefaultPostSort()
+	}
+	if !models.IsValidPostSort(sortBy) {
+		utils.BadRequest(w, "Sort must be one of: "+strings.Join(models.PostSorts, ", "))
+		return
+	}
+
+	category := models.Category{}
+	if err := category.GetByID(categoryID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.NotFound(w, "Category not found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to retrieve category")
+		return
+	}
+	if category.Archived && !(query.Get("include_archived") == "true" && middleware.IsAdmin(r)) {
 		utils.NotFound(w, "Category not found")
 		return
 	}
 
-	categoryResponse := CategoryResponse{
-		ID:          category.ID,
-		Name:        category.Name,
-		Description: category.Description,
-		PostCount:   category.PostCount,
+	userID, _ := middleware.GetUserIDFromContext(r)
+
+	posts, total, err := category.GetPosts(userID, limit, offset, sortBy)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve posts")
+		return
+	}
+
+	postResponses, err := getPostResponses(posts, userID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to process post data")
+		return
 	}
 
-	utils.Success(w, "Category retrieved successfully", categoryResponse)
+	pagination := map[string]interface{}{
+		"current_page": page,
+		"per_page":     limit,
+		"total":        total,
+		"total_pages":  (total + limit - 1) / limit,
+		"has_next":     page < (total+limit-1)/limit,
+		"has_prev":     page > 1,
+	}
+
+	utils.SetPaginationLinks(w, r, page, limit, total)
+	utils.PaginatedSuccess(w, "Category posts retrieved successfully", map[string]interface{}{
+		"category": newCategoryResponse(category),
+		"posts":    postResponses,
+	}, pagination)
+}
+
+// GetCategoryBySlugController handles GET /api/categories/slug/{slug}
+// A slug the category used to have answers with a permanent redirect to the current one.
+func GetCategoryBySlugController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	slug := strings.ToLower(strings.TrimPrefix(r.URL.Path, "/api/categories/slug/"))
+
+	category := models.Category{}
+	err := category.GetBySlug(slug)
+	if err == nil {
+		utils.Success(w, "Category retrieved successfully", newCategoryResponse(category))
+		return
+	}
+	if err != sql.ErrNoRows {
+		utils.InternalServerError(w, "Failed to retrieve category")
+		return
+	}
+
+	current, err := models.ResolveCategorySlugRedirect(slug)
+	if err == sql.ErrNoRows || (err == nil && current == "") {
+		utils.NotFound(w, "Category not found")
+		return
+	}
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve category")
+		return
+	}
+
+	http.Redirect(w, r, "/api/categories/slug/"+url.PathEscape(current), http.StatusMovedPermanently)
 }
 
 // Helper functions
@@ -107,18 +379,65 @@ func getCategoryIDFromPath(path string) (int, error) {
 	return strconv.Atoi(parts[0])
 }
 
-// CreateCategoryController handles category creation (admin only)
+// CategoryRequest is the body for creating or updating a category.
+// Slug, color, icon, parent_id and post_permission keep their current values on
+// update when omitted; an empty slug is derived from the name and a parent_id of 0
+// makes it top-level.
+type CategoryRequest struct {
+	Name           string  `json:"name"`
+	Description    string  `json:"description"`
+	Slug           *string `json:"slug"`
+	Color          *string `json:"color"`
+	Icon           *string `json:"icon"`
+	ParentID       *int    `json:"parent_id"`
+	PostPermission *string `json:"post_permission"` // everyone, moderators or admins
+}
+
+// applyTo copies the optional fields onto a category
+func (req *CategoryRequest) applyTo(category *models.Category) {
+	if req.Slug != nil {
+		category.Slug = *req.Slug
+	}
+	if req.Color != nil {
+		category.Color = *req.Color
+	}
+	if req.Icon != nil {
+		category.Icon = *req.Icon
+	}
+	if req.PostPermission != nil {
+		category.PostPermission = *req.PostPermission
+	}
+	if req.ParentID != nil {
+		if *req.ParentID > 0 {
+			parentID := *req.ParentID
+			category.ParentID = &parentID
+		} else {
+			category.ParentID = nil
+		}
+	}
+}
+
+// categoryParentError writes a 422 response when err is about the category's parent
+func categoryParentError(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, models.ErrCategoryParent) && !errors.Is(err, models.ErrCategoryTooDeep) {
+		return false
+	}
+	var validationErrors utils.ValidationErrors
+	validationErrors.Add("parent_id", err.Error())
+	utils.ValidationError(w, validationErrors)
+	return true
+}
+
+// CreateCategoryController handles POST /api/categories (admin only)
 func CreateCategoryController(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		utils.MethodNotAllowed(w, "Only POST method allowed")
 		return
 	}
 
-	var req struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-	}
+	adminID, _ := middleware.GetUserIDFromContext(r)
 
+	var req CategoryRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.BadRequest(w, "Invalid JSON format")
 		return
@@ -128,27 +447,329 @@ func CreateCategoryController(w http.ResponseWriter, r *http.Request) {
 		Name:        req.Name,
 		Description: req.Description,
 	}
+	req.applyTo(&category)
+	if !validateCategory(w, &category) {
+		return
+	}
 
 	if err := category.Create(); err != nil {
-		utils.BadRequest(w, err.Error())
+		if errors.Is(err, models.ErrCategoryNameTaken) {
+			utils.Conflict(w, "A category with this name already exists")
+			return
+		}
+		if errors.Is(err, models.ErrCategorySlugTaken) {
+			utils.Conflict(w, "A category with this slug already exists")
+			return
+		}
+		if categoryParentError(w, err) {
+			return
+		}
+		utils.InternalServerError(w, "Failed to create category")
 		return
 	}
 
-	utils.Created(w, "Category created successfully", category)
+	if err := models.LogModerationAction(adminID, models.ModActionCreateCategory, models.TargetCategory, category.ID, category.Name); err != nil {
+		log.Printf("Failed to write moderation log: %v", err)
+	}
+
+	utils.Created(w, "Category created successfully", newCategoryResponse(category))
 }
 
-// UpdateCategoryController handles category updates
+// UpdateCategoryController handles PUT /api/categories/{id} (admin only)
 func UpdateCategoryController(w http.ResponseWriter, r *http.Request) {
-	// Similar implementation to UpdatePostController
+	if r.Method != http.MethodPut {
+		utils.MethodNotAllowed(w, "Only PUT method allowed")
+		return
+	}
+
+	adminID, _ := middleware.GetUserIDFromContext(r)
+
+	categoryID, err := getCategoryIDFromPath(r.URL.Path)
+	if err != nil {
+		utils.BadRequest(w, "Invalid category ID")
+		return
+	}
+
+	var req CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid JSON format")
+		return
+	}
+
+	category := models.Category{}
+	if err := category.GetByID(categoryID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.NotFound(w, "Category not found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to retrieve category")
+		return
+	}
+
+	previousName := category.Name
+	category.Name = req.Name
+	category.Description = req.Description
+	req.applyTo(&category)
+	if !validateCategory(w, &category) {
+		return
+	}
+
+	if err := category.Update(); err != nil {
+		if errors.Is(err, models.ErrCategoryNameTaken) {
+			utils.Conflict(w, "A category with this name already exists")
+			return
+		}
+		if errors.Is(err, models.ErrCategorySlugTaken) {
+			utils.Conflict(w, "A category with this slug already exists")
+			return
+		}
+		if categoryParentError(w, err) {
+			return
+		}
+		utils.InternalServerError(w, "Failed to update category")
+		return
+	}
+
+	reason := category.Name
+	if previousName != category.Name {
+		reason = "renamed from " + previousName + " to " + category.Name
+	}
+	if err := models.LogModerationAction(adminID, models.ModActionUpdateCategory, models.TargetCategory, category.ID, reason); err != nil {
+		log.Printf("Failed to write moderation log: %v", err)
+	}
+
+	utils.Success(w, "Category updated successfully", newCategoryResponse(category))
 }
 
-// DeleteCategoryController handles category deletion
+// DeleteCategoryController handles DELETE /api/categories/{id} (admin only)
+// A category with posts can only be deleted with ?reassign_to={id}, which first moves
+// its posts into that category. A category with subcategories can't be deleted until
+// they are moved elsewhere.
 func DeleteCategoryController(w http.ResponseWriter, r *http.Request) {
-	// Similar implementation but with safety checks
+	if r.Method != http.MethodDelete {
+		utils.MethodNotAllowed(w, "Only DELETE method allowed")
+		return
+	}
+
+	adminID, _ := middleware.GetUserIDFromContext(r)
+
+	categoryID, err := getCategoryIDFromPath(r.URL.Path)
+	if err != nil {
+		utils.BadRequest(w, "Invalid category ID")
+		return
+	}
+
+	category := models.Category{}
+	if err := category.GetByID(categoryID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.NotFound(w, "Category not found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to retrieve category")
+		return
+	}
+
+	reason := category.Name
+	moved := 0
+
+	if raw := r.URL.Query().Get("reassign_to"); raw != "" {
+		var validationErrors utils.ValidationErrors
+		target := models.Category{}
+		targetID, err := strconv.Atoi(raw)
+		switch {
+		case err != nil || targetID <= 0:
+			validationErrors.Add("reassign_to", "reassign_to must be a category ID")
+		case targetID == category.ID:
+			validationErrors.Add("reassign_to", "Posts can't be reassigned to the category being deleted")
+		default:
+			if err := target.GetByID(targetID); err == sql.ErrNoRows {
+				validationErrors.Add("reassign_to", "Category to reassign posts to does not exist")
+			} else if err != nil {
+				utils.InternalServerError(w, "Failed to retrieve category")
+				return
+			}
+		}
+		if validationErrors.HasErrors() {
+			utils.ValidationError(w, validationErrors)
+			return
+		}
+
+		moved, err = category.ReassignAndDelete(target.ID)
+		if errors.Is(err, models.ErrCategoryHasChildren) {
+			utils.Conflict(w, "Category has subcategories; move or delete them first")
+			return
+		}
+		if err != nil {
+			utils.InternalServerError(w, "Failed to delete category")
+			return
+		}
+		reason = fmt.Sprintf("%s (%d posts moved to %s)", category.Name, moved, target.Name)
+	} else {
+		canDelete, message, err := category.CanDelete()
+		if err != nil {
+			utils.InternalServerError(w, "Failed to check category")
+			return
+		}
+		if !canDelete {
+			utils.Conflict(w, message+"; pass ?reassign_to={id} to move its posts first")
+			return
+		}
+
+		if err := category.Delete(); err != nil {
+			if errors.Is(err, models.ErrCategoryNotEmpty) {
+				utils.Conflict(w, "Category contains posts and cannot be deleted")
+				return
+			}
+			if errors.Is(err, models.ErrCategoryHasChildren) {
+				utils.Conflict(w, "Category has subcategories; move or delete them first")
+				return
+			}
+			utils.InternalServerError(w, "Failed to delete category")
+			return
+		}
+	}
+
+	if err := models.LogModerationAction(adminID, models.ModActionDeleteCategory, models.TargetCategory, category.ID, reason); err != nil {
+		log.Printf("Failed to write moderation log: %v", err)
+	}
+
+	utils.Success(w, "Category deleted successfully", map[string]int{
+		"id":          category.ID,
+		"posts_moved": moved,
+	})
+}
+
+// ArchiveCategoryController handles POST /api/admin/categories/{id}/archive (admin only)
+func ArchiveCategoryController(w http.ResponseWriter, r *http.Request) {
+	setCategoryArchived(w, r, true)
 }
 
-// GetCategoryStatsController returns detailed category statistics
+// UnarchiveCategoryController handles POST /api/admin/categories/{id}/unarchive (admin only)
+func UnarchiveCategoryController(w http.ResponseWriter, r *http.Request) {
+	setCategoryArchived(w, r, false)
+}
+
+// setCategoryArchived archives or unarchives the category in the URL and logs it
+func setCategoryArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w, "Only POST method allowed")
+		return
+	}
+
+	adminID, _ := middleware.GetUserIDFromContext(r)
+
+	categoryID, err := utils.GetIDFromURL(r, "/admin/categories/")
+	if err != nil {
+		utils.BadRequest(w, "Invalid category ID")
+		return
+	}
+
+	category := models.Category{}
+	if err := category.GetByID(categoryID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.NotFound(w, "Category not found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to retrieve category")
+		return
+	}
+
+	action, message := models.ModActionArchiveCategory, "Category archived successfully"
+	if !archived {
+		action, message = models.ModActionUnarchiveCategory, "Category unarchived successfully"
+	}
+
+	if category.Archived == archived {
+		utils.Success(w, message, newCategoryResponse(category))
+		return
+	}
+
+	if err := category.SetArchived(archived); err != nil {
+		utils.InternalServerError(w, "Failed to update category")
+		return
+	}
+
+	if err := models.LogModerationAction(adminID, action, models.TargetCategory, category.ID, category.Name); err != nil {
+		log.Printf("Failed to write moderation log: %v", err)
+	}
+
+	utils.Success(w, message, newCategoryResponse(category))
+}
+
+// CategoryOrderRequest is the body for reordering categories
+type CategoryOrderRequest struct {
+	CategoryIDs []int `json:"category_ids"` // every category ID, in display order
+}
+
+// ReorderCategoriesController handles PUT /api/admin/categories/order (admin only)
+func ReorderCategoriesController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.MethodNotAllowed(w, "Only PUT method allowed")
+		return
+	}
+
+	adminID, _ := middleware.GetUserIDFromContext(r)
+
+	var req CategoryOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid JSON format")
+		return
+	}
+
+	if err := models.ReorderCategories(req.CategoryIDs); err != nil {
+		if errors.Is(err, models.ErrCategoryOrder) {
+			var validationErrors utils.ValidationErrors
+			validationErrors.Add("category_ids", "Must list every category exactly once")
+			utils.ValidationError(w, validationErrors)
+			return
+		}
+		utils.InternalServerError(w, "Failed to reorder categories")
+		return
+	}
+
+	if err := models.LogModerationAction(adminID, models.ModActionReorderCategories, models.TargetCategory, 0, ""); err != nil {
+		log.Printf("Failed to write moderation log: %v", err)
+	}
+
+	categories, err := models.GetAllCategories(true, models.CategorySortPosition)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve categories")
+		return
+	}
+
+	categoryResponses := []CategoryResponse{}
+	for _, category := range categories {
+		categoryResponses = append(categoryResponses, newCategoryResponse(category))
+	}
+
+	utils.Success(w, "Categories reordered successfully", categoryResponses)
+}
+
+// validateCategory normalizes and checks a category, writing a 422 response when it is invalid
+func validateCategory(w http.ResponseWriter, category *models.Category) bool {
+	if err := category.Validate(); err != nil {
+		var validationErrors utils.ValidationErrors
+		field := "name"
+		for _, candidate := range []string{"description", "slug", "color", "icon", "post_permission"} {
+			if strings.Contains(err.Error(), "category "+candidate) {
+				field = candidate
+				break
+			}
+		}
+		validationErrors.Add(field, err.Error())
+		utils.ValidationError(w, validationErrors)
+		return false
+	}
+	return true
+}
+
+// GetCategoryStatsController handles GET /api/categories/{id}/stats
 func GetCategoryStatsController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
 	categoryID, err := getCategoryIDFromPath(r.URL.Path)
 	if err != nil {
 		utils.BadRequest(w, "Invalid category ID")
@@ -157,7 +778,11 @@ func GetCategoryStatsController(w http.ResponseWriter, r *http.Request) {
 
 	category := models.Category{}
 	if err := category.GetByID(categoryID); err != nil {
-		utils.NotFound(w, "Category not found")
+		if err == sql.ErrNoRows {
+			utils.NotFound(w, "Category not found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to retrieve category")
 		return
 	}
 
@@ -0,0 +1,318 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"forum/models"
+)
+
+// newCategory creates a category through the admin API and returns its ID
+func newCategory(t *testing.T) int {
+	t.Helper()
+
+	admin := newUser(t, models.RoleAdmin)
+	res, body := admin.do(http.MethodPost, "/api/categories", map[string]string{
+		"name":        uniqueName("Category "),
+		"description": "A category for testing",
+	})
+	expectStatus(t, res, body, http.StatusCreated)
+
+	var category struct {
+		ID int `json:"id"`
+	}
+	decodeData(t, body, &category)
+	return category.ID
+}
+
+func TestGetCategoryStats(t *testing.T) {
+	categoryID := newCategory(t)
+	alice, bob, carol := newUser(t, ""), newUser(t, ""), newUser(t, "")
+
+	// Carol only posted long ago, so she isn't an active user
+	old := carol.createPost(categoryID)
+	setCreatedAt(t, "posts", old, time.Now().AddDate(0, 0, -40))
+	alice.createPost(categoryID)
+	first := alice.createPost(categoryID)
+	setCreatedAt(t, "posts", first, time.Now().Add(-time.Hour))
+	latest := bob.createPost(categoryID)
+	// Posts elsewhere and comments on them don't count
+	other := bob.createPost()
+	bob.createComment(other, "A comment outside the category")
+
+	alice.createComment(latest, "A comment in the category")
+	carol.createComment(latest, "Another comment in the category")
+	bob.createComment(old, "A comment on an old post")
+
+	res, body := newVisitor(t).do(http.MethodGet, fmt.Sprintf("/api/categories/%d/stats", categoryID), nil)
+	expectStatus(t, res, body, http.StatusOK)
+	var stats models.CategoryStats
+	decodeData(t, body, &stats)
+
+	latestPost := models.Post{}
+	if err := latestPost.GetByID(latest, nil); err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalPosts != 4 || stats.TotalComments != 3 || stats.ActiveUsers != 2 ||
+		stats.PostsThisWeek != 3 || stats.CommentsThisWeek != 3 {
+		t.Fatalf("stats = %+v, want 4 posts, 3 comments, 2 active users, 3 posts and 3 comments this week", stats)
+	}
+	if stats.LastPostTitle != latestPost.Title || stats.LastPostAuthor != bob.User.Username || stats.LastPostDate == nil {
+		t.Fatalf("last post = %q by %q at %v, want %q by %q",
+			stats.LastPostTitle, stats.LastPostAuthor, stats.LastPostDate, latestPost.Title, bob.User.Username)
+	}
+
+	res, body = newVisitor(t).do(http.MethodGet, "/api/categories/999999/stats", nil)
+	expectStatus(t, res, body, http.StatusNotFound)
+}
+
+func TestGetCategoryWithSharedPosts(t *testing.T) {
+	first, second := newCategory(t), newCategory(t)
+	author := newUser(t, "")
+	author.createPost(first, second)
+	author.createPost(first)
+
+	for id, want := range map[int]int{first: 2, second: 1} {
+		res, body := newVisitor(t).do(http.MethodGet, fmt.Sprintf("/api/categories/%d", id), nil)
+		expectStatus(t, res, body, http.StatusOK)
+		var category struct {
+			ID        int `json:"id"`
+			PostCount int `json:"post_count"`
+		}
+		decodeData(t, body, &category)
+		if category.ID != id || category.PostCount != want {
+			t.Fatalf("category = %+v, want %d with %d posts", category, id, want)
+		}
+	}
+
+	res, body := newVisitor(t).do(http.MethodGet, "/api/categories/999999", nil)
+	expectStatus(t, res, body, http.StatusNotFound)
+}
+
+func TestGetCategoriesSort(t *testing.T) {
+	author := newUser(t, "")
+	visitor := newVisitor(t)
+	first, second := newCategory(t), newCategory(t)
+
+	// order lists first and second as GET /api/categories?sort=... returns them
+	order := func(sortBy string) []int {
+		t.Helper()
+
+		res, body := visitor.do(http.MethodGet, "/api/categories?sort="+sortBy, nil)
+		expectStatus(t, res, body, http.StatusOK)
+
+		var ids []int
+		for _, id := range listIDs(t, body) {
+			if id == first || id == second {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+
+	setCreatedAt(t, "posts", author.createPost(second), time.Now().Add(-time.Hour))
+	if got := order(models.CategorySortRecentActivity); !reflect.DeepEqual(got, []int{second, first}) {
+		t.Errorf("recent_activity = %v, want [%d %d]", got, second, first)
+	}
+	if got := order(models.CategorySortPostCount); !reflect.DeepEqual(got, []int{second, first}) {
+		t.Errorf("post_count = %v, want [%d %d]", got, second, first)
+	}
+
+	// A new post moves its category up, past the cached listing
+	author.createPost(first)
+	if got := order(models.CategorySortRecentActivity); !reflect.DeepEqual(got, []int{first, second}) {
+		t.Errorf("recent_activity after posting = %v, want [%d %d]", got, first, second)
+	}
+	if got := order(models.CategorySortPosition); !reflect.DeepEqual(got, []int{first, second}) {
+		t.Errorf("position = %v, want [%d %d]", got, first, second)
+	}
+
+	res, body := visitor.do(http.MethodGet, "/api/categories?sort=bogus", nil)
+	expectStatus(t, res, body, http.StatusBadRequest)
+}
+
+func TestGetPopularCategoriesEndpoint(t *testing.T) {
+	visitor := newVisitor(t)
+
+	// Enough categories with posts today to exceed the cap
+	author := newUser(t, "")
+	for i := 0; i < 21; i++ {
+		author.createPost(newCategory(t))
+	}
+
+	var popular struct {
+		Window     string `json:"window"`
+		Categories []struct {
+			ID int `json:"id"`
+		} `json:"categories"`
+	}
+
+	res, body := visitor.do(http.MethodGet, "/api/categories/popular?t=day&limit=100", nil)
+	expectStatus(t, res, body, http.StatusOK)
+	decodeData(t, body, &popular)
+	if popular.Window != models.PopularWindowDay || len(popular.Categories) != 20 {
+		t.Errorf("got %s with %d categories, want day with the limit capped at 20", popular.Window, len(popular.Categories))
+	}
+
+	res, body = visitor.do(http.MethodGet, "/api/categories/popular", nil)
+	expectStatus(t, res, body, http.StatusOK)
+	decodeData(t, body, &popular)
+	if popular.Window != models.PopularWindowWeek || len(popular.Categories) != 5 {
+		t.Errorf("defaults = %s with %d categories, want week with 5", popular.Window, len(popular.Categories))
+	}
+
+	res, body = visitor.do(http.MethodGet, "/api/categories/popular?t=year", nil)
+	expectStatus(t, res, body, http.StatusBadRequest)
+}
+
+func TestCategoryWritesReturnTheReadShape(t *testing.T) {
+	admin := newUser(t, models.RoleAdmin)
+
+	// read fetches the category the way the read endpoints return it
+	read := func(id int) map[string]interface{} {
+		t.Helper()
+
+		res, body := admin.do(http.MethodGet, fmt.Sprintf("/api/categories/%d", id), nil)
+		expectStatus(t, res, body, http.StatusOK)
+		var category map[string]interface{}
+		decodeData(t, body, &category)
+		return category
+	}
+
+	res, body := admin.do(http.MethodPost, "/api/categories", map[string]string{
+		"name":        uniqueName("Category "),
+		"description": "A category for testing",
+	})
+	expectStatus(t, res, body, http.StatusCreated)
+	var created map[string]interface{}
+	decodeData(t, body, &created)
+	id := int(created["id"].(float64))
+	if want := read(id); !reflect.DeepEqual(created, want) {
+		t.Errorf("create returned %v, want %v", created, want)
+	}
+
+	res, body = admin.do(http.MethodPut, fmt.Sprintf("/api/categories/%d", id), map[string]string{
+		"name":        uniqueName("Renamed category "),
+		"description": "A category for testing",
+	})
+	expectStatus(t, res, body, http.StatusOK)
+	var updated map[string]interface{}
+	decodeData(t, body, &updated)
+	if want := read(id); !reflect.DeepEqual(updated, want) {
+		t.Errorf("update returned %v, want %v", updated, want)
+	}
+}
+
+func TestCategoryListCacheInvalidation(t *testing.T) {
+	admin := newUser(t, models.RoleAdmin)
+	author := newUser(t, "")
+	visitor := newVisitor(t)
+
+	// etag fetches the category list and checks that its ETag is honoured
+	etag := func() string {
+		t.Helper()
+
+		res, body := visitor.do(http.MethodGet, "/api/categories", nil)
+		expectStatus(t, res, body, http.StatusOK)
+		tag := res.Header.Get("ETag")
+		if tag == "" {
+			t.Fatal("category list has no ETag")
+		}
+
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/api/categories", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-None-Match", tag)
+		res, body = visitor.send(req)
+		expectStatus(t, res, body, http.StatusNotModified)
+		return tag
+	}
+
+	categoryID := newCategory(t)
+	target := newCategory(t)
+	postID := author.createPost(categoryID)
+	postPath := fmt.Sprintf("/api/posts/%d", postID)
+	categoryPath := fmt.Sprintf("/api/categories/%d", categoryID)
+
+	tests := []struct {
+		name   string
+		mutate func()
+	}{
+		{"create category", func() { newCategory(t) }},
+		{"update category", func() {
+			res, body := admin.do(http.MethodPut, categoryPath, map[string]string{
+				"name":        uniqueName("Renamed category "),
+				"description": "A category for testing",
+			})
+			expectStatus(t, res, body, http.StatusOK)
+		}},
+		{"archive category", func() {
+			res, body := admin.do(http.MethodPost, fmt.Sprintf("/api/admin/categories/%d/archive", categoryID), nil)
+			expectStatus(t, res, body, http.StatusOK)
+		}},
+		{"unarchive category", func() {
+			res, body := admin.do(http.MethodPost, fmt.Sprintf("/api/admin/categories/%d/unarchive", categoryID), nil)
+			expectStatus(t, res, body, http.StatusOK)
+		}},
+		{"reorder categories", func() {
+			res, body := admin.do(http.MethodGet, "/api/categories?include_archived=true", nil)
+			expectStatus(t, res, body, http.StatusOK)
+			res, body = admin.do(http.MethodPut, "/api/admin/categories/order", map[string][]int{"category_ids": listIDs(t, body)})
+			expectStatus(t, res, body, http.StatusOK)
+		}},
+		{"create post", func() { author.createPost(categoryID) }},
+		{"change post categories", func() {
+			res, body := author.do(http.MethodPut, postPath, map[string]interface{}{
+				"title":        "A post that changes category",
+				"content":      "Some test post content",
+				"category_ids": []int{categoryID, target},
+			})
+			expectStatus(t, res, body, http.StatusOK)
+		}},
+		{"delete post", func() {
+			res, body := author.do(http.MethodDelete, postPath, nil)
+			expectStatus(t, res, body, http.StatusOK)
+		}},
+		{"reassign and delete category", func() {
+			res, body := admin.do(http.MethodDelete, fmt.Sprintf("%s?reassign_to=%d", categoryPath, target), nil)
+			expectStatus(t, res, body, http.StatusOK)
+		}},
+		{"delete category", func() {
+			res, body := admin.do(http.MethodDelete, fmt.Sprintf("/api/categories/%d", newCategory(t)), nil)
+			expectStatus(t, res, body, http.StatusOK)
+		}},
+		{"manual clear", func() {
+			res, body := admin.do(http.MethodPost, "/api/admin/maintenance/clear-category-cache", nil)
+			expectStatus(t, res, body, http.StatusOK)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := etag()
+			tt.mutate()
+			if after := etag(); after == before {
+				t.Fatalf("ETag %s did not change", before)
+			}
+		})
+	}
+
+	t.Run("editing only a post's text", func(t *testing.T) {
+		postID := author.createPost(target)
+		before := etag()
+
+		res, body := author.do(http.MethodPut, fmt.Sprintf("/api/posts/%d", postID), map[string]interface{}{
+			"title":        "A retitled post",
+			"content":      "Some test post content",
+			"category_ids": []int{target},
+		})
+		expectStatus(t, res, body, http.StatusOK)
+		if after := etag(); after != before {
+			t.Fatalf("ETag changed from %s to %s", before, after)
+		}
+	})
+}
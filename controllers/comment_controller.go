@@ -3,20 +3,30 @@ package controllers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"forum/config"
+	"forum/events"
 	"forum/middleware"
 	"forum/models"
 	"forum/utils"
 )
 
+// defaultCommentsPerPage is the page size of a post's comment listing when no limit is given
+const defaultCommentsPerPage = 20
+
 // CommentCreateRequest represents the JSON structure for creating comments
 type CommentCreateRequest struct {
-	Content string `json:"content"`
-	PostID  int    `json:"post_id"`
+	Content         string `json:"content"`
+	PostID          int    `json:"post_id"`
+	ParentID        *int   `json:"parent_id,omitempty"`         // optional comment being replied to
+	QuotedCommentID *int   `json:"quoted_comment_id,omitempty"` // optional comment being quoted
 }
 
 // CommentUpdateRequest represents the JSON structure for updating comments
@@ -26,15 +36,56 @@ type CommentUpdateRequest struct {
 
 // CommentResponse represents comment data sent to client
 type CommentResponse struct {
-	ID        int          `json:"id"`
-	Content   string       `json:"content"`
-	PostID    int          `json:"post_id"`
-	Author    UserResponse `json:"author"`
-	Likes     int          `json:"likes"`
-	Dislikes  int          `json:"dislikes"`
-	UserVote  *string      `json:"user_vote"`
-	CreatedAt time.Time    `json:"created_at"`
-	UpdatedAt time.Time    `json:"updated_at"`
+	ID            int                    `json:"id"`
+	Content       string                 `json:"content"`
+	ContentHTML   string                 `json:"content_html"` // escaped, safe to insert as HTML
+	PostID        int                    `json:"post_id"`
+	ParentID      *int                   `json:"parent_id"`
+	Author        CommentAuthor          `json:"author"`
+	Likes         int                    `json:"likes"`
+	Dislikes      int                    `json:"dislikes"`
+	UserVote      *string                `json:"user_vote"`
+	IsAccepted    bool                   `json:"is_accepted"`     // marked as the answer by the post author
+	Collapsed     bool                   `json:"collapsed"`       // voted down to the collapse threshold
+	Quote         *QuoteResponse         `json:"quote,omitempty"` // quoted comment snapshot, if replying to one
+	Reactions     models.ReactionSummary `json:"reactions"`
+	EditableUntil utils.JSONTime         `json:"editable_until"` // when the author's edit window closes
+	CreatedAt     utils.JSONTime         `json:"created_at"`
+	UpdatedAt     utils.JSONTime         `json:"updated_at"`
+	EditedAt      *utils.JSONTime        `json:"edited_at"` // last content edit by the author or a moderator
+	IsEdited      bool                   `json:"is_edited"`
+	DeletedBy     string                 `json:"deleted_by,omitempty"` // "author" or "moderator" on deleted comments
+	Post          *models.PostSummary    `json:"post,omitempty"`       // only with ?include=post
+	// Only present when a moderator lists deleted comments
+	DeletedAt     *utils.JSONTime `json:"deleted_at,omitempty"`
+	RemovedBy     *ModeratorRef   `json:"removed_by,omitempty"`
+	RemovalReason string          `json:"removal_reason,omitempty"`
+
+	tombstone bool // placeholder for a deleted comment, content withheld
+}
+
+// ModeratorRef identifies the moderator behind an action
+type ModeratorRef struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+// CommentAuthor is the public profile of a comment's author
+type CommentAuthor struct {
+	ID        int            `json:"id"`
+	Username  string         `json:"username"`
+	Avatar    string         `json:"avatar"`
+	JoinedAt  utils.JSONTime `json:"joined_at"`
+	NewMember bool           `json:"new_member"` // joined within the new member period
+}
+
+// QuoteResponse represents the quote block embedded in a reply
+type QuoteResponse struct {
+	CommentID *int   `json:"comment_id"`
+	Username  string `json:"username"`
+	Excerpt   string `json:"excerpt"`
+	Removed   bool   `json:"removed"`        // the quoted comment has since been deleted
+	Link      string `json:"link,omitempty"` // resolves the quoted comment's page
 }
 
 // CreateCommentController handles comment creation
@@ -64,12 +115,29 @@ func CreateCommentController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Sanitize before validating so content that is empty once cleaned is rejected
+	req.Content = utils.SanitizeString(req.Content)
+
 	// Validate comment form
 	if errors := utils.ValidateCommentForm(req.Content); errors.HasErrors() {
 		utils.ValidationError(w, errors)
 		return
 	}
 
+	// On /posts/{id}/comments the URL names the post; a body post_id may only repeat it
+	if strings.Contains(r.URL.Path, "/posts/") {
+		pathPostID, err := getPostIDFromCommentsPath(r.URL.Path)
+		if err != nil {
+			utils.BadRequest(w, "Invalid post ID")
+			return
+		}
+		if req.PostID != 0 && req.PostID != pathPostID {
+			utils.BadRequest(w, "post_id in the body does not match the post in the URL")
+			return
+		}
+		req.PostID = pathPostID
+	}
+
 	// Validate post ID
 	if err := utils.ValidateID(req.PostID, "post_id"); err != nil {
 		utils.BadRequest(w, err.Error())
@@ -83,6 +151,11 @@ func CreateCommentController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Throttle bursts of comments on a single post (moderators are exempt)
+	if !middleware.IsModerator(r) && commentFloodThrottled(w, userID, post.ID) {
+		return
+	}
+
 	// Create new comment
 	comment := models.Comment{
 		Content: req.Content,
@@ -90,11 +163,79 @@ func CreateCommentController(w http.ResponseWriter, r *http.Request) {
 		UserID:  userID,
 	}
 
+	// Replies must be to a comment on the same post
+	if req.ParentID != nil {
+		parent := models.Comment{}
+		if err := parent.GetByID(*req.ParentID, nil); err != nil {
+			utils.BadRequest(w, "Parent comment not found")
+			return
+		}
+		if err := comment.SetParent(&parent); err != nil {
+			utils.BadRequest(w, err.Error())
+			return
+		}
+
+		depth, err := parent.GetDepth()
+		if err != nil {
+			utils.InternalServerError(w, "Failed to create comment")
+			return
+		}
+		if maxDepth := config.GetMaxCommentDepth(); depth+1 > maxDepth {
+			var validationErrors utils.ValidationErrors
+			validationErrors.Add("parent_id", fmt.Sprintf("Replies can only be nested %d levels deep; reply to the parent comment instead", maxDepth))
+			utils.ValidationError(w, validationErrors)
+			return
+		}
+	}
+
+	// Snapshot the quoted comment, which must be on the same post
+	if req.QuotedCommentID != nil {
+		quoted := models.Comment{}
+		if err := quoted.GetByID(*req.QuotedCommentID, nil); err != nil {
+			utils.BadRequest(w, "Quoted comment not found")
+			return
+		}
+		if err := comment.SetQuote(&quoted); err != nil {
+			utils.BadRequest(w, err.Error())
+			return
+		}
+	}
+
 	if err := comment.Create(); err != nil {
 		utils.InternalServerError(w, "Failed to create comment")
 		return
 	}
 
+	events.Publish(events.CommentCreated, map[string]interface{}{
+		"id":         comment.ID,
+		"post_id":    comment.PostID,
+		"parent_id":  comment.ParentID,
+		"user_id":    comment.UserID,
+		"created_at": utils.NewJSONTime(comment.CreatedAt),
+	})
+
+	// Let the post author know about the new comment
+	if post.UserID != userID {
+		notification := models.Notification{
+			UserID:    post.UserID,
+			ActorID:   &userID,
+			Type:      models.NotificationComment,
+			PostID:    &post.ID,
+			CommentID: &comment.ID,
+			Message:   "commented on your post \"" + post.Title + "\"",
+		}
+		if err := notification.Create(); err != nil {
+			log.Printf("Failed to create comment notification: %v", err)
+		}
+	}
+
+	// Notify users mentioned as @username
+	if mentions := utils.ExtractMentions(comment.Content); len(mentions) > 0 {
+		if _, err := models.CreateMentionNotifications(&comment, mentions); err != nil {
+			log.Printf("Failed to create mention notifications: %v", err)
+		}
+	}
+
 	// Get full comment details for response
 	commentResponse, err := getCommentResponse(&comment)
 	if err != nil {
@@ -105,6 +246,58 @@ func CreateCommentController(w http.ResponseWriter, r *http.Request) {
 	utils.Created(w, "Comment created successfully", commentResponse)
 }
 
+// commentFloodThrottled responds 429 and returns true when the user is commenting on the
+// post too fast, either too soon after their last comment or too often within the flood window
+func commentFloodThrottled(w http.ResponseWriter, userID, postID int) bool {
+	now := time.Now()
+	interval := models.CommentMinInterval()
+
+	since := now.Add(-models.CommentFloodWindow)
+	if interval > models.CommentFloodWindow {
+		since = now.Add(-interval)
+	}
+
+	times, err := models.GetRecentCommentTimes(userID, postID, since)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to create comment")
+		return true
+	}
+	if len(times) == 0 {
+		return false
+	}
+
+	// Too soon after the previous comment: suggest adding to it instead
+	if wait := times[0].Add(interval).Sub(now); wait > 0 {
+		utils.Throttled(w, "comment_too_soon",
+			"You're commenting too quickly. Consider editing your previous comment instead.", secondsCeil(wait))
+		return true
+	}
+
+	// Too many comments within the window: wait for the oldest one to age out
+	limit := models.CommentsPerPostLimit()
+	var inWindow []time.Time
+	for _, t := range times {
+		if t.After(now.Add(-models.CommentFloodWindow)) {
+			inWindow = append(inWindow, t)
+		}
+	}
+	if limit > 0 && len(inWindow) >= limit {
+		oldest := inWindow[limit-1]
+		wait := oldest.Add(models.CommentFloodWindow).Sub(now)
+		utils.Throttled(w, "comment_flood",
+			fmt.Sprintf("You can post at most %d comments on a post every %d minutes.", limit, int(models.CommentFloodWindow.Minutes())),
+			secondsCeil(wait))
+		return true
+	}
+
+	return false
+}
+
+// secondsCeil rounds a positive duration up to whole seconds
+func secondsCeil(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
 // GetCommentController handles retrieving a single comment
 func GetCommentController(w http.ResponseWriter, r *http.Request) {
 	// Only allow GET requests
@@ -134,6 +327,12 @@ func GetCommentController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Comments hidden pending review are only visible to their author and moderators
+	if comment.Hidden && comment.UserID != userID && !middleware.IsModerator(r) {
+		utils.NotFound(w, "Comment not found")
+		return
+	}
+
 	// Get full comment details for response
 	commentResponse, err := getCommentResponse(&comment)
 	if err != nil {
@@ -141,6 +340,15 @@ func GetCommentController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if includesPost(r) {
+		responses := []CommentResponse{*commentResponse}
+		if err := attachPostSummaries(responses); err != nil {
+			utils.InternalServerError(w, "Failed to retrieve comment details")
+			return
+		}
+		commentResponse = &responses[0]
+	}
+
 	utils.Success(w, "Comment retrieved successfully", commentResponse)
 }
 
@@ -178,11 +386,17 @@ func UpdateCommentController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check ownership
-	if comment.UserID != userID {
+	// Authors may edit within the edit window, moderators can always edit
+	isModerator := middleware.IsModerator(r)
+	if comment.UserID != userID && !isModerator {
 		utils.Forbidden(w, "You can only edit your own comments")
 		return
 	}
+	if !isModerator && !comment.IsWithinEditWindow(time.Now()) {
+		utils.ErrorWithCode(w, http.StatusForbidden, "edit_window_expired",
+			"The edit window for this comment has expired")
+		return
+	}
 
 	// Parse JSON request body
 	var req CommentUpdateRequest
@@ -191,6 +405,9 @@ func UpdateCommentController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Sanitize before validating so content that is empty once cleaned is rejected
+	req.Content = utils.SanitizeString(req.Content)
+
 	// Validate comment form
 	if errors := utils.ValidateCommentForm(req.Content); errors.HasErrors() {
 		utils.ValidationError(w, errors)
@@ -205,6 +422,13 @@ func UpdateCommentController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Attribute edits made through moderator rights in the moderation log
+	if comment.UserID != userID || !comment.IsWithinEditWindow(time.Now()) {
+		if err := models.LogModerationAction(userID, models.ModActionEditComment, models.TargetComment, comment.ID, ""); err != nil {
+			log.Printf("Failed to write moderation log: %v", err)
+		}
+	}
+
 	// Get full comment details for response
 	commentResponse, err := getCommentResponse(&comment)
 	if err != nil {
@@ -249,9 +473,27 @@ func DeleteCommentController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check ownership
+	// Moderators may remove other users' comments, but must say why
 	if comment.UserID != userID {
-		utils.Forbidden(w, "You can only delete your own comments")
+		if !middleware.IsModerator(r) {
+			utils.Forbidden(w, "You can only delete your own comments")
+			return
+		}
+
+		reason := strings.TrimSpace(utils.SanitizeString(r.URL.Query().Get("reason")))
+		if reason == "" {
+			var validationErrors utils.ValidationErrors
+			validationErrors.Add("reason", "A reason is required to remove another user's comment")
+			utils.ValidationError(w, validationErrors)
+			return
+		}
+
+		if err := comment.RemoveByModerator(userID, reason); err != nil {
+			utils.InternalServerError(w, "Failed to remove comment")
+			return
+		}
+
+		utils.Success(w, "Comment removed successfully", nil)
 		return
 	}
 
@@ -302,8 +544,10 @@ func GetCommentsController(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate post exists
-	post := models.Post{}
-	if err := post.GetByID(postID, userIDPtr); err != nil {
+	if exists, err := models.PostExists(postID); err != nil {
+		utils.InternalServerError(w, "Failed to retrieve post")
+		return
+	} else if !exists {
 		utils.NotFound(w, "Post not found")
 		return
 	}
@@ -317,7 +561,7 @@ func GetCommentsController(w http.ResponseWriter, r *http.Request) {
 
 	limit, _ := strconv.Atoi(query.Get("limit"))
 	if limit <= 0 {
-		limit = 20
+		limit = defaultCommentsPerPage
 	}
 
 	// Validate pagination
@@ -327,22 +571,47 @@ func GetCommentsController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Parse sort option (oldest, newest, best)
+	sortBy := query.Get("sort")
+	if sortBy == "" {
+		sortBy = models.CommentSortOldest
+	}
+	if !models.IsValidCommentSort(sortBy) {
+		utils.BadRequest(w, "Sort must be one of: oldest, newest, best")
+		return
+	}
+
+	// Deleted comments (with their content) are only shown to moderators; others get tombstones
+	includeDeleted := query.Get("include_deleted") == "true" && middleware.IsModerator(r)
+
+	includePost := includesPost(r)
+
+	// Collapsed comments keep their content hidden unless asked for; moderators always see it
+	revealCollapsed := query.Get("show_collapsed") == "true" || middleware.IsModerator(r)
+
+	// Tree mode returns the whole thread nested, capped in size
+	if query.Get("tree") == "true" {
+		getCommentTree(w, postID, userIDPtr, sortBy, includeDeleted, includePost, revealCollapsed)
+		return
+	}
+
 	// Get comments from database
-	comments, total, err := models.GetCommentsByPostID(postID, userIDPtr, limit, offset)
+	comments, total, err := models.GetCommentsByPostID(postID, userIDPtr, sortBy, limit, offset, includeDeleted)
 	if err != nil {
 		utils.InternalServerError(w, "Failed to retrieve comments")
 		return
 	}
 
 	// Convert to response format
-	var commentResponses []CommentResponse
-	for _, comment := range comments {
-		commentResponse, err := getCommentResponse(&comment)
-		if err != nil {
-			utils.InternalServerError(w, "Failed to process comment data")
+	commentResponses := getCommentResponses(comments)
+	if !revealCollapsed {
+		withholdCollapsedContent(commentResponses, userID)
+	}
+	if includePost {
+		if err := attachPostSummaries(commentResponses); err != nil {
+			utils.InternalServerError(w, "Failed to retrieve comments")
 			return
 		}
-		commentResponses = append(commentResponses, *commentResponse)
 	}
 
 	// Prepare pagination info
@@ -355,9 +624,260 @@ func GetCommentsController(w http.ResponseWriter, r *http.Request) {
 		"has_prev":     page > 1,
 	}
 
+	utils.SetPaginationLinks(w, r, page, limit, total)
 	utils.PaginatedSuccess(w, "Comments retrieved successfully", commentResponses, pagination)
 }
 
+// GetCommentContextController handles GET /api/comments/{id}/context
+// It tells clients which page of the post's comments a comment is on, for a given limit and sort.
+func GetCommentContextController(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET requests
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	// Get authenticated user
+	userID, _ := middleware.GetUserIDFromContext(r)
+	var userIDPtr *int
+	if userID > 0 {
+		userIDPtr = &userID
+	}
+
+	// Get comment ID from URL path
+	commentID, err := getCommentIDFromPath(r.URL.Path)
+	if err != nil {
+		utils.BadRequest(w, "Invalid comment ID")
+		return
+	}
+
+	// Parse page size and sort, using the same defaults as the comments listing
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 {
+		limit = defaultCommentsPerPage
+	}
+	limit, _, err = utils.ValidatePagination(1, limit)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+
+	sortBy := query.Get("sort")
+	if sortBy == "" {
+		sortBy = models.CommentSortOldest
+	}
+	if !models.IsValidCommentSort(sortBy) {
+		utils.BadRequest(w, "Sort must be one of: oldest, newest, best")
+		return
+	}
+
+	// Hidden comments are not part of the listing, so they have no page
+	comment := models.Comment{}
+	if err := comment.GetByID(commentID, nil); err != nil || comment.Hidden {
+		utils.NotFound(w, "Comment not found")
+		return
+	}
+
+	post := models.Post{}
+	if err := post.GetByID(comment.PostID, userIDPtr); err != nil {
+		utils.NotFound(w, "Post not found")
+		return
+	}
+
+	position, err := comment.GetPosition(sortBy)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to locate comment")
+		return
+	}
+
+	utils.Success(w, "Comment context retrieved successfully", map[string]interface{}{
+		"comment_id": comment.ID,
+		"post": map[string]interface{}{
+			"id":            post.ID,
+			"title":         post.Title,
+			"author":        map[string]interface{}{"id": post.UserID, "username": post.Username},
+			"comment_count": post.CommentCount,
+			"created_at":    post.CreatedAt,
+		},
+		"position": position,
+		"page":     (position-1)/limit + 1,
+		"limit":    limit,
+		"sort":     sortBy,
+	})
+}
+
+// maxTreeComments caps how many comments are fetched for one tree
+const maxTreeComments = 500
+
+// CommentTreeNode is a comment with its nested replies
+type CommentTreeNode struct {
+	CommentResponse
+	Replies []*CommentTreeNode `json:"replies"`
+
+	depth  int
+	parent *CommentTreeNode
+}
+
+// getCommentTree writes a post's comments as a reply tree built from a single fetch
+func getCommentTree(w http.ResponseWriter, postID int, userID *int, sortBy string, includeDeleted, includePost, revealCollapsed bool) {
+	comments, total, err := models.GetCommentsByPostID(postID, userID, sortBy, maxTreeComments, 0, includeDeleted)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve comments")
+		return
+	}
+
+	responses := getCommentResponses(comments)
+	if !revealCollapsed {
+		viewerID := 0
+		if userID != nil {
+			viewerID = *userID
+		}
+		withholdCollapsedContent(responses, viewerID)
+	}
+	if includePost {
+		if err := attachPostSummaries(responses); err != nil {
+			utils.InternalServerError(w, "Failed to retrieve comments")
+			return
+		}
+	}
+
+	// Deleted comments stay in the tree as tombstones so their replies keep their place
+	// (moderators asking for deleted comments already have them in full)
+	if !includeDeleted {
+		tombstones, err := models.GetCommentTombstones(postID)
+		if err != nil {
+			utils.InternalServerError(w, "Failed to retrieve comments")
+			return
+		}
+		scores := make(map[int]int, len(tombstones))
+		for _, tombstone := range tombstones {
+			responses = append(responses, buildTombstoneResponse(tombstone))
+			scores[tombstone.ID] = tombstone.Score
+		}
+		sortWithTombstones(responses, sortBy, scores)
+	}
+
+	utils.Success(w, "Comments retrieved successfully", map[string]interface{}{
+		"comments":  pruneTombstones(buildCommentTree(responses, config.GetMaxCommentDepth())),
+		"total":     total,
+		"truncated": total > len(comments),
+	})
+}
+
+// buildCommentTree nests comments under their parents, keeping the given order among siblings.
+// Replies whose parent isn't in the list become top-level, and replies nested deeper than
+// maxDepth are attached to their ancestor at maxDepth-1 so the tree never exceeds maxDepth.
+func buildCommentTree(comments []CommentResponse, maxDepth int) []*CommentTreeNode {
+	nodes := make(map[int]*CommentTreeNode, len(comments))
+	ordered := make([]*CommentTreeNode, 0, len(comments))
+	for _, comment := range comments {
+		node := &CommentTreeNode{CommentResponse: comment, Replies: []*CommentTreeNode{}, depth: -1}
+		nodes[comment.ID] = node
+		ordered = append(ordered, node)
+	}
+
+	// Depth in the stored thread, ignoring the cap
+	var depthOf func(node *CommentTreeNode) int
+	depthOf = func(node *CommentTreeNode) int {
+		if node.depth >= 0 {
+			return node.depth
+		}
+		node.depth = 0
+		if node.ParentID != nil {
+			if parent, ok := nodes[*node.ParentID]; ok {
+				node.parent = parent
+				node.depth = depthOf(parent) + 1
+			}
+		}
+		return node.depth
+	}
+
+	roots := []*CommentTreeNode{}
+	for _, node := range ordered {
+		if depthOf(node) == 0 {
+			roots = append(roots, node)
+			continue
+		}
+
+		parent := node.parent
+		for parent.depth >= maxDepth {
+			parent = parent.parent
+		}
+		parent.Replies = append(parent.Replies, node)
+	}
+
+	return roots
+}
+
+// Placeholder text shown in place of deleted comments
+const (
+	deletedCommentText = "[deleted]"
+	removedCommentText = "[removed by moderator]"
+)
+
+// buildTombstoneResponse renders a deleted comment without its content or author
+func buildTombstoneResponse(tombstone models.CommentTombstone) CommentResponse {
+	response := CommentResponse{
+		ID:        tombstone.ID,
+		Content:   deletedCommentText,
+		PostID:    tombstone.PostID,
+		ParentID:  tombstone.ParentID,
+		Reactions: models.ReactionSummary{Counts: map[string]int{}, Mine: []string{}},
+		CreatedAt: utils.NewJSONTime(tombstone.CreatedAt),
+		UpdatedAt: utils.NewJSONTime(tombstone.CreatedAt),
+		DeletedBy: "author",
+		tombstone: true,
+	}
+	if tombstone.RemovedByModerator {
+		response.Content = removedCommentText
+		response.DeletedBy = "moderator"
+	}
+	response.ContentHTML = utils.ContentHTML(response.Content)
+	return response
+}
+
+// sortWithTombstones puts tombstones where their comments would have been listed,
+// repeating the listing's order (accepted answer first, then sortBy) over the whole slice.
+// tombstoneScores holds the scores tombstones don't expose in their responses.
+func sortWithTombstones(responses []CommentResponse, sortBy string, tombstoneScores map[int]int) {
+	score := func(c CommentResponse) int {
+		if c.tombstone {
+			return tombstoneScores[c.ID]
+		}
+		return c.Likes - c.Dislikes
+	}
+
+	sort.SliceStable(responses, func(i, j int) bool {
+		a, b := responses[i], responses[j]
+		if a.IsAccepted != b.IsAccepted {
+			return a.IsAccepted
+		}
+
+		newerFirst := sortBy == models.CommentSortNewest || sortBy == models.CommentSortBest
+		if sortBy == models.CommentSortBest && score(a) != score(b) {
+			return score(a) > score(b)
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt.Time) {
+			return a.CreatedAt.Before(b.CreatedAt.Time) != newerFirst
+		}
+		return (a.ID < b.ID) != newerFirst
+	})
+}
+
+// pruneTombstones drops tombstones that no longer hold any visible replies
+func pruneTombstones(nodes []*CommentTreeNode) []*CommentTreeNode {
+	kept := nodes[:0]
+	for _, node := range nodes {
+		node.Replies = pruneTombstones(node.Replies)
+		if node.tombstone && len(node.Replies) == 0 {
+			continue
+		}
+		kept = append(kept, node)
+	}
+	return kept
+}
+
 // VoteCommentController handles comment voting (like/dislike)
 func VoteCommentController(w http.ResponseWriter, r *http.Request) {
 	// Only allow POST requests
@@ -382,6 +902,7 @@ func VoteCommentController(w http.ResponseWriter, r *http.Request) {
 	// Parse vote type from request body
 	var voteData struct {
 		VoteType string `json:"vote_type"` // "like" or "dislike"
+		Action   string `json:"action"`    // "toggle" (default), "set" or "remove"
 	}
 	if err := json.NewDecoder(r.Body).Decode(&voteData); err != nil {
 		utils.BadRequest(w, "Invalid JSON format")
@@ -389,8 +910,8 @@ func VoteCommentController(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate vote type
-	if !utils.IsValidVoteType(voteData.VoteType) {
-		utils.BadRequest(w, "Vote type must be 'like' or 'dislike'")
+	if errors := utils.ValidateVoteForm(voteData.VoteType, voteData.Action); errors.HasErrors() {
+		utils.ValidationError(w, errors)
 		return
 	}
 
@@ -401,9 +922,10 @@ func VoteCommentController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := models.ToggleCommentVote(userID, commentID, voteData.VoteType)
+	notify := models.LikeNotifier(userID, comment.UserID, comment.PostID, &comment.ID)
+	result, err := models.ToggleCommentVote(userID, commentID, voteData.VoteType, voteData.Action, notify)
 	if err != nil {
-		utils.InternalServerError(w, "Failed to process vote")
+		writeVoteError(w, err)
 		return
 	}
 
@@ -442,28 +964,139 @@ func getPostIDFromCommentsPath(path string) (int, error) {
 	return 0, errors.New("invalid post comments path format")
 }
 
-// getCommentResponse converts a Comment model to CommentResponse with additional data
+// getCommentResponse converts a single Comment model to CommentResponse, loading its author
 func getCommentResponse(comment *models.Comment) (*CommentResponse, error) {
-	// Get author info
 	author := models.User{}
 	if err := author.GetByID(comment.UserID); err != nil {
 		return nil, err
 	}
 
-	return &CommentResponse{
-		ID:      comment.ID,
-		Content: comment.Content,
-		PostID:  comment.PostID,
-		Author: UserResponse{
-			ID:       author.ID,
-			Username: author.Username,
-			Email:    author.Email,
-			JoinedAt: author.CreatedAt,
-		},
-		Likes:     comment.Likes,
-		Dislikes:  comment.Dislikes,
-		UserVote:  comment.UserVote,
-		CreatedAt: comment.CreatedAt,
-		UpdatedAt: comment.UpdatedAt,
-	}, nil
+	response := buildCommentResponse(comment, CommentAuthor{
+		ID:        author.ID,
+		Username:  author.Username,
+		Avatar:    author.GetAvatarURL(),
+		JoinedAt:  utils.NewJSONTime(author.CreatedAt),
+		NewMember: author.IsNewMember(),
+	})
+	return &response, nil
+}
+
+// getCommentResponses converts a page of comments using the author fields the
+// listing query already joined, so no extra user lookups are needed
+func getCommentResponses(comments []models.Comment) []CommentResponse {
+	responses := make([]CommentResponse, 0, len(comments))
+	for i := range comments {
+		responses = append(responses, buildCommentResponse(&comments[i], CommentAuthor{
+			ID:        comments[i].UserID,
+			Username:  comments[i].Username,
+			Avatar:    strings.TrimSpace(comments[i].AuthorAvatar),
+			JoinedAt:  utils.NewJSONTime(comments[i].AuthorJoinedAt),
+			NewMember: models.IsNewMember(comments[i].AuthorJoinedAt),
+		}))
+	}
+	return responses
+}
+
+// buildCommentResponse assembles the response for a comment and its author
+func buildCommentResponse(comment *models.Comment, author CommentAuthor) CommentResponse {
+	response := CommentResponse{
+		ID:            comment.ID,
+		Content:       comment.Content,
+		ContentHTML:   utils.ContentHTML(comment.Content),
+		PostID:        comment.PostID,
+		ParentID:      comment.ParentID,
+		Author:        author,
+		Likes:         comment.Likes,
+		Dislikes:      comment.Dislikes,
+		UserVote:      comment.UserVote,
+		IsAccepted:    comment.IsAccepted,
+		Collapsed:     comment.Collapsed,
+		Quote:         getQuoteResponse(comment.Quote),
+		Reactions:     comment.Reactions,
+		EditableUntil: utils.NewJSONTime(comment.EditableUntil()),
+		CreatedAt:     utils.NewJSONTime(comment.CreatedAt),
+		UpdatedAt:     utils.NewJSONTime(comment.UpdatedAt),
+		EditedAt:      utils.NewJSONTimePtr(comment.EditedAt),
+		IsEdited:      comment.EditedAt != nil,
+	}
+
+	// Deletion details are only loaded for moderators reviewing deleted comments
+	if comment.DeletedAt != nil {
+		response.DeletedAt = utils.NewJSONTimePtr(comment.DeletedAt)
+		response.RemovalReason = comment.RemovalReason
+		response.DeletedBy = "author"
+		if comment.RemovedBy != nil {
+			response.DeletedBy = "moderator"
+			response.RemovedBy = &ModeratorRef{ID: *comment.RemovedBy, Username: comment.RemovedByUsername}
+		}
+	}
+	return response
+}
+
+// includesPost reports whether the include query parameter (a comma-separated list) asks for
+// each comment's post summary
+func includesPost(r *http.Request) bool {
+	return requestIncludes(r, "post")
+}
+
+// requestIncludes reports whether name is listed in the comma-separated ?include= parameter
+func requestIncludes(r *http.Request, name string) bool {
+	for _, include := range strings.Split(r.URL.Query().Get("include"), ",") {
+		if strings.TrimSpace(include) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// attachPostSummaries fills in the post summary of each comment with one query for the whole list
+func attachPostSummaries(responses []CommentResponse) error {
+	postIDs := make([]int, 0, len(responses))
+	seen := make(map[int]bool, len(responses))
+	for _, response := range responses {
+		if !seen[response.PostID] {
+			seen[response.PostID] = true
+			postIDs = append(postIDs, response.PostID)
+		}
+	}
+
+	summaries, err := models.GetPostSummaries(postIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range responses {
+		if summary, ok := summaries[responses[i].PostID]; ok {
+			responses[i].Post = &summary
+		}
+	}
+	return nil
+}
+
+// withholdCollapsedContent blanks the content of collapsed comments, except the viewer's own
+func withholdCollapsedContent(responses []CommentResponse, viewerID int) {
+	for i := range responses {
+		if responses[i].Collapsed && (viewerID == 0 || responses[i].Author.ID != viewerID) {
+			responses[i].Content = ""
+			responses[i].ContentHTML = ""
+		}
+	}
+}
+
+// getQuoteResponse converts a comment's quote snapshot to its response form
+func getQuoteResponse(quote *models.CommentQuote) *QuoteResponse {
+	if quote == nil {
+		return nil
+	}
+
+	response := &QuoteResponse{
+		CommentID: quote.CommentID,
+		Username:  quote.Username,
+		Excerpt:   quote.Excerpt,
+		Removed:   quote.Removed,
+	}
+	if quote.CommentID != nil {
+		response.Link = fmt.Sprintf("/api/comments/%d/context", *quote.CommentID)
+	}
+	return response
 }
@@ -0,0 +1,686 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/config"
+	"forum/models"
+)
+
+func TestUpdateCommentEditWindow(t *testing.T) {
+	author := newUser(t, "")
+	postID := author.createPost()
+	window := models.CommentEditWindow()
+
+	edit := func(c *testClient, commentID int) (*http.Response, apiResponse) {
+		return c.do(http.MethodPut, fmt.Sprintf("/api/comments/%d", commentID), map[string]string{
+			"content": uniqueName("Edited comment "),
+		})
+	}
+
+	t.Run("inside the window", func(t *testing.T) {
+		commentID := author.createComment(postID, "A fresh comment")
+		setCreatedAt(t, "comments", commentID, time.Now().Add(-window+time.Minute))
+
+		res, body := edit(author, commentID)
+		expectStatus(t, res, body, http.StatusOK)
+
+		var comment struct {
+			EditableUntil time.Time `json:"editable_until"`
+		}
+		decodeData(t, body, &comment)
+		if comment.EditableUntil.IsZero() || !comment.EditableUntil.After(time.Now()) {
+			t.Fatalf("editable_until = %v, want a time in the future", comment.EditableUntil)
+		}
+	})
+
+	t.Run("past the window", func(t *testing.T) {
+		commentID := author.createComment(postID, "An old comment")
+		setCreatedAt(t, "comments", commentID, time.Now().Add(-window-time.Second))
+
+		res, body := edit(author, commentID)
+		expectStatus(t, res, body, http.StatusForbidden)
+		if body.Code != "edit_window_expired" {
+			t.Fatalf("code = %q, want edit_window_expired", body.Code)
+		}
+	})
+
+	t.Run("moderator after the window", func(t *testing.T) {
+		moderator := newUser(t, models.RoleModerator)
+		commentID := author.createComment(postID, "An old comment to moderate")
+		setCreatedAt(t, "comments", commentID, time.Now().Add(-window-time.Hour))
+
+		res, body := edit(moderator, commentID)
+		expectStatus(t, res, body, http.StatusOK)
+
+		entries, _, err := models.GetModerationLog(models.ModerationLogFilter{
+			ActorID:  moderator.User.ID,
+			Action:   models.ModActionEditComment,
+			TargetID: commentID,
+		}, 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("moderation log has %d entries for the edit, want 1", len(entries))
+		}
+	})
+
+	t.Run("author inside the window isn't logged", func(t *testing.T) {
+		commentID := author.createComment(postID, "Another fresh comment")
+
+		res, body := edit(author, commentID)
+		expectStatus(t, res, body, http.StatusOK)
+
+		entries, _, err := models.GetModerationLog(models.ModerationLogFilter{
+			Action:   models.ModActionEditComment,
+			TargetID: commentID,
+		}, 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Fatalf("author's own edit was logged as moderation: %+v", entries)
+		}
+	})
+}
+
+func TestCreateCommentContent(t *testing.T) {
+	author := newUser(t, "")
+	postID := author.createPost()
+	path := fmt.Sprintf("/api/posts/%d/comments", postID)
+
+	t.Run("invisible only", func(t *testing.T) {
+		res, body := author.do(http.MethodPost, path, map[string]string{"content": "\u200b\u200d\x00\u200c\ufeff"})
+		expectStatus(t, res, body, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("joiners are kept", func(t *testing.T) {
+		content := "👨\u200d👩\u200d👧 می\u200cخواهم"
+		res, body := author.do(http.MethodPost, path, map[string]string{"content": "\x00" + content + "\u202e"})
+		expectStatus(t, res, body, http.StatusCreated)
+
+		var comment struct {
+			ID int `json:"id"`
+		}
+		decodeData(t, body, &comment)
+
+		stored := models.Comment{}
+		if err := stored.GetByID(comment.ID, nil); err != nil {
+			t.Fatal(err)
+		}
+		if stored.Content != content {
+			t.Fatalf("stored content = %q, want %q", stored.Content, content)
+		}
+	})
+}
+
+// treeNode is the part of a comment tree node the tests look at
+type treeNode struct {
+	ID        int        `json:"id"`
+	Content   string     `json:"content"`
+	DeletedBy string     `json:"deleted_by"`
+	Replies   []treeNode `json:"replies"`
+}
+
+// renderTree writes a comment tree compactly, e.g. "A(B(D),C),E", naming nodes by names[id]
+func renderTree(nodes []treeNode, names map[int]string) string {
+	parts := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		part := names[node.ID]
+		if len(node.Replies) > 0 {
+			part += "(" + renderTree(node.Replies, names) + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ",")
+}
+
+// getCommentTree fetches a post's comments in tree mode, oldest first
+func getCommentTree(t *testing.T, c *testClient, postID int) []treeNode {
+	t.Helper()
+	return getSortedCommentTree(t, c, postID, models.CommentSortOldest)
+}
+
+// getSortedCommentTree fetches a post's comments in tree mode in the given order
+func getSortedCommentTree(t *testing.T, c *testClient, postID int, sortBy string) []treeNode {
+	t.Helper()
+
+	res, body := c.do(http.MethodGet, fmt.Sprintf("/api/posts/%d/comments?tree=true&sort=%s", postID, sortBy), nil)
+	expectStatus(t, res, body, http.StatusOK)
+
+	var tree struct {
+		Comments []treeNode `json:"comments"`
+	}
+	decodeData(t, body, &tree)
+	return tree.Comments
+}
+
+func TestCommentTree(t *testing.T) {
+	author := newUser(t, "")
+	replier := newUser(t, "")
+	postID := author.createPost()
+
+	names := make(map[int]string)
+	a := author.createComment(postID, "Comment A")
+	b := replier.createReply(postID, a, "Reply B to A")
+	d := author.createReply(postID, b, "Reply D to B")
+	c := replier.createReply(postID, a, "Reply C to A")
+	e := replier.createComment(postID, "Comment E")
+	names[a], names[b], names[c], names[d], names[e] = "A", "B", "C", "D", "E"
+
+	if got := renderTree(getCommentTree(t, newVisitor(t), postID), names); got != "A(B(D),C),E" {
+		t.Fatalf("tree = %s, want A(B(D),C),E", got)
+	}
+}
+
+func TestReplyDepthLimit(t *testing.T) {
+	user := newUser(t, "")
+	postID := user.createPost()
+	maxDepth := config.GetMaxCommentDepth()
+
+	// Top-level comments are depth 0; build a chain down to depth max-1
+	chain := []int{user.createComment(postID, "Depth 0")}
+	for depth := 1; depth < maxDepth; depth++ {
+		chain = append(chain, user.createReply(postID, chain[depth-1], fmt.Sprintf("Depth %d", depth)))
+	}
+
+	// Replying at max-1 makes a comment at max, which is still allowed
+	atMax := user.createReply(postID, chain[maxDepth-1], fmt.Sprintf("Depth %d", maxDepth))
+
+	// One more level is too deep
+	res, body := user.do(http.MethodPost, fmt.Sprintf("/api/posts/%d/comments", postID), map[string]interface{}{
+		"content":   fmt.Sprintf("Depth %d", maxDepth+1),
+		"parent_id": atMax,
+	})
+	expectStatus(t, res, body, http.StatusUnprocessableEntity)
+	if len(body.Errors) != 1 || body.Errors[0].Field != "parent_id" {
+		t.Fatalf("errors = %+v, want one on parent_id", body.Errors)
+	}
+
+	// The tree holds the chain at its real depth
+	depth := 0
+	nodes := getCommentTree(t, user, postID)
+	for len(nodes) == 1 && len(nodes[0].Replies) > 0 {
+		nodes = nodes[0].Replies
+		depth++
+	}
+	if depth != maxDepth {
+		t.Fatalf("tree is %d levels deep, want %d", depth, maxDepth)
+	}
+}
+
+func TestCommentTreeCapsStoredDepth(t *testing.T) {
+	user := newUser(t, "")
+	postID := user.createPost()
+	maxDepth := config.GetMaxCommentDepth()
+
+	chain := []int{user.createComment(postID, "Depth 0")}
+	for depth := 1; depth <= maxDepth; depth++ {
+		chain = append(chain, user.createReply(postID, chain[depth-1], fmt.Sprintf("Depth %d", depth)))
+	}
+
+	// Lowering the limit afterwards folds deeper replies into the deepest allowed level
+	config.AppConfig.MaxCommentDepth = maxDepth - 1
+	t.Cleanup(func() { config.AppConfig.MaxCommentDepth = maxDepth })
+
+	nodes := getCommentTree(t, user, postID)
+	for depth := 0; depth < maxDepth-1; depth++ {
+		if len(nodes) != 1 {
+			t.Fatalf("depth %d has %d comments, want 1", depth, len(nodes))
+		}
+		nodes = nodes[0].Replies
+	}
+	if len(nodes) != 2 || nodes[0].ID != chain[maxDepth-1] || nodes[1].ID != chain[maxDepth] {
+		t.Fatalf("deepest level = %+v, want comments %d and %d side by side", nodes, chain[maxDepth-1], chain[maxDepth])
+	}
+}
+
+func TestCommentFloodControl(t *testing.T) {
+	if err := models.Settings.Set(models.SettingCommentsPerPostWindow, "3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.Settings.Set(models.SettingCommentMinInterval, "30"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(relaxCommentFloodControl)
+
+	user := newUser(t, "")
+	postID := newUser(t, "").createPost()
+	path := fmt.Sprintf("/api/posts/%d/comments", postID)
+
+	// comment posts a comment and returns the response
+	comment := func(c *testClient) (*http.Response, apiResponse) {
+		return c.do(http.MethodPost, path, map[string]string{"content": uniqueName("Flood comment ")})
+	}
+	// ago backdates the user's comments on the post, newest first, by the given minutes
+	ago := func(minutes ...int) {
+		t.Helper()
+
+		comments, _, err := models.GetCommentsByPostID(postID, nil, models.CommentSortNewest, 50, 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := 0
+		for _, c := range comments {
+			if c.UserID == user.User.ID && i < len(minutes) {
+				setCreatedAt(t, "comments", c.ID, time.Now().Add(-time.Duration(minutes[i])*time.Minute))
+				i++
+			}
+		}
+	}
+	expectThrottled := func(res *http.Response, body apiResponse, code string) {
+		t.Helper()
+
+		expectStatus(t, res, body, http.StatusTooManyRequests)
+		if body.Code != code {
+			t.Fatalf("code = %q, want %q", body.Code, code)
+		}
+		if res.Header.Get("Retry-After") == "" {
+			t.Fatal("429 without Retry-After")
+		}
+	}
+
+	res, body := comment(user)
+	expectStatus(t, res, body, http.StatusCreated)
+
+	// Straight away is too soon
+	res, body = comment(user)
+	expectThrottled(res, body, "comment_too_soon")
+
+	// After the minimum interval it's fine, up to the per-window limit
+	ago(5)
+	res, body = comment(user)
+	expectStatus(t, res, body, http.StatusCreated)
+	ago(3, 5)
+	res, body = comment(user)
+	expectStatus(t, res, body, http.StatusCreated)
+
+	ago(1, 3, 5)
+	res, body = comment(user)
+	expectThrottled(res, body, "comment_flood")
+
+	// Once the oldest comment leaves the window there's room again
+	ago(1, 3, int(models.CommentFloodWindow.Minutes())+1)
+	res, body = comment(user)
+	expectStatus(t, res, body, http.StatusCreated)
+
+	// The limits are per post
+	otherPostID := newUser(t, "").createPost()
+	user.createComment(otherPostID, "A comment somewhere else")
+
+	// Moderators aren't limited
+	moderator := newUser(t, models.RoleModerator)
+	for i := 0; i < 5; i++ {
+		res, body := comment(moderator)
+		expectStatus(t, res, body, http.StatusCreated)
+	}
+}
+
+func TestModeratorRemovesComment(t *testing.T) {
+	author := newUser(t, "")
+	moderator := newUser(t, models.RoleModerator)
+	postID := author.createPost()
+	commenter := newUser(t, "")
+	removed := commenter.createComment(postID, "An off-topic comment")
+	reply := author.createReply(postID, removed, "A reply that should keep its place")
+	path := fmt.Sprintf("/api/comments/%d", removed)
+
+	// Other regular users still can't delete it
+	res, body := newUser(t, "").do(http.MethodDelete, path+"?reason=spam", nil)
+	expectStatus(t, res, body, http.StatusForbidden)
+	// Moderators must say why
+	res, body = moderator.do(http.MethodDelete, path, nil)
+	expectStatus(t, res, body, http.StatusUnprocessableEntity)
+
+	res, body = moderator.do(http.MethodDelete, path+"?reason=off-topic", nil)
+	expectStatus(t, res, body, http.StatusOK)
+
+	// The removal is audited...
+	res, body = moderator.do(http.MethodGet, fmt.Sprintf("/api/admin/moderation-log?target_type=comment&target_id=%d", removed), nil)
+	expectStatus(t, res, body, http.StatusOK)
+	var entries []models.ModerationLogEntry
+	decodeData(t, body, &entries)
+	if len(entries) != 1 || entries[0].ActorID != moderator.User.ID ||
+		entries[0].Action != models.ModActionDeleteComment || entries[0].Reason != "off-topic" {
+		t.Fatalf("moderation log = %+v", entries)
+	}
+
+	// ...the author is told why...
+	res, body = commenter.do(http.MethodGet, "/api/notifications", nil)
+	expectStatus(t, res, body, http.StatusOK)
+	var notifications []models.Notification
+	decodeData(t, body, &notifications)
+	found := false
+	for _, n := range notifications {
+		if n.Type == models.NotificationCommentRemoved && n.CommentID != nil && *n.CommentID == removed {
+			found = strings.Contains(n.Message, "off-topic")
+		}
+	}
+	if !found {
+		t.Fatalf("no removal notification with the reason: %+v", notifications)
+	}
+
+	// ...and the thread shows a moderator removal rather than a deletion
+	nodes := getCommentTree(t, newVisitor(t), postID)
+	if len(nodes) != 1 || nodes[0].ID != removed || len(nodes[0].Replies) != 1 || nodes[0].Replies[0].ID != reply {
+		t.Fatalf("tree = %+v", nodes)
+	}
+	if nodes[0].Content != "[removed by moderator]" || nodes[0].DeletedBy != "moderator" {
+		t.Fatalf("removed comment shows %q, deleted_by %q", nodes[0].Content, nodes[0].DeletedBy)
+	}
+}
+
+func TestTombstonesKeepTheirPlace(t *testing.T) {
+	author := newUser(t, "")
+	postID := author.createPost()
+
+	// A, B and C an hour apart; B has the best score and a reply
+	names := make(map[int]string)
+	ids := make(map[string]int)
+	base := time.Now().Add(-4 * time.Hour)
+	for i, name := range []string{"A", "B", "C"} {
+		id := author.createComment(postID, "Comment "+name)
+		setCreatedAt(t, "comments", id, base.Add(time.Duration(i)*time.Hour))
+		names[id], ids[name] = name, id
+	}
+	for i := 0; i < 2; i++ {
+		res, body := newUser(t, "").do(http.MethodPost, fmt.Sprintf("/api/comments/%d/vote", ids["B"]), map[string]string{"vote_type": "like"})
+		expectStatus(t, res, body, http.StatusOK)
+	}
+	reply := author.createReply(postID, ids["B"], "A reply to B")
+	names[reply] = "D"
+
+	res, body := author.do(http.MethodDelete, fmt.Sprintf("/api/comments/%d", ids["B"]), nil)
+	expectStatus(t, res, body, http.StatusOK)
+
+	tests := []struct {
+		sortBy string
+		want   string
+	}{
+		{models.CommentSortOldest, "A,B(D),C"},
+		{models.CommentSortNewest, "C,B(D),A"},
+		{models.CommentSortBest, "B(D),C,A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sortBy, func(t *testing.T) {
+			nodes := getSortedCommentTree(t, newVisitor(t), postID, tt.sortBy)
+			if got := renderTree(nodes, names); got != tt.want {
+				t.Fatalf("tree = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentHTMLIsEscaped(t *testing.T) {
+	user := newUser(t, "")
+	postID := user.createPost()
+
+	vectors := []string{
+		"<script>alert(document.cookie)</script>",
+		`<img src=x onerror="alert(1)">`,
+		`"><svg onload=alert(1)>`,
+		"<a href=\"javascript:alert(1)\">click\u202e</a>",
+	}
+	for _, vector := range vectors {
+		t.Run(vector, func(t *testing.T) {
+			content := "Look at this: " + vector
+			id := user.createComment(postID, content)
+
+			var comment struct {
+				Content     string `json:"content"`
+				ContentHTML string `json:"content_html"`
+			}
+			res, body := user.do(http.MethodGet, fmt.Sprintf("/api/comments/%d", id), nil)
+			expectStatus(t, res, body, http.StatusOK)
+			decodeData(t, body, &comment)
+
+			// The text is kept as written, minus control characters, and only ever escaped for HTML
+			if want := strings.ReplaceAll(content, "\u202e", ""); comment.Content != want {
+				t.Fatalf("content = %q, want %q", comment.Content, want)
+			}
+			if strings.ContainsAny(comment.ContentHTML, "<>\"'\u202e") {
+				t.Fatalf("content_html = %q is not safe", comment.ContentHTML)
+			}
+
+			// Posts go through the same sanitizing
+			res, body = user.do(http.MethodPost, "/api/posts", map[string]interface{}{
+				"title": uniqueName("Test post "), "content": content, "category_ids": []int{1},
+			})
+			expectStatus(t, res, body, http.StatusCreated)
+			var post struct {
+				ContentHTML string `json:"content_html"`
+			}
+			decodeData(t, body, &post)
+			if post.ContentHTML == "" || strings.ContainsAny(post.ContentHTML, "<>\"'\u202e") {
+				t.Fatalf("post content_html = %q is not safe", post.ContentHTML)
+			}
+		})
+	}
+}
+
+func TestIncludeDeletedIsForModerators(t *testing.T) {
+	author := newUser(t, "")
+	postID := author.createPost()
+	kept := author.createComment(postID, "A comment that stays")
+	deleted := author.createComment(postID, "A comment the author regrets")
+	res, body := author.do(http.MethodDelete, fmt.Sprintf("/api/comments/%d", deleted), nil)
+	expectStatus(t, res, body, http.StatusOK)
+
+	type listedComment struct {
+		ID        int     `json:"id"`
+		Content   string  `json:"content"`
+		DeletedBy string  `json:"deleted_by"`
+		DeletedAt *string `json:"deleted_at"`
+	}
+	list := func(c *testClient, query string) []listedComment {
+		t.Helper()
+
+		res, body := c.do(http.MethodGet, fmt.Sprintf("/api/posts/%d/comments?sort=oldest%s", postID, query), nil)
+		expectStatus(t, res, body, http.StatusOK)
+		var comments []listedComment
+		decodeData(t, body, &comments)
+		return comments
+	}
+
+	moderator := newUser(t, models.RoleModerator)
+	got := list(moderator, "&include_deleted=true")
+	if len(got) != 2 || got[0].ID != kept || got[1].ID != deleted {
+		t.Fatalf("moderator listing = %+v, want comments %d and %d", got, kept, deleted)
+	}
+	if got[1].Content != "A comment the author regrets" || got[1].DeletedBy != "author" || got[1].DeletedAt == nil {
+		t.Fatalf("deleted comment = %+v", got[1])
+	}
+	if got[0].DeletedAt != nil || got[0].DeletedBy != "" {
+		t.Fatalf("live comment = %+v", got[0])
+	}
+
+	// Without the flag, or from anyone else, the deleted comment stays out
+	for name, c := range map[string]*testClient{"regular user": newUser(t, ""), "author": author, "visitor": newVisitor(t)} {
+		if got := list(c, "&include_deleted=true"); len(got) != 1 || got[0].ID != kept {
+			t.Fatalf("%s listing = %+v, want only comment %d", name, got, kept)
+		}
+	}
+	if got := list(moderator, ""); len(got) != 1 || got[0].ID != kept {
+		t.Fatalf("moderator listing without the flag = %+v, want only comment %d", got, kept)
+	}
+}
+
+func TestCommentListingQueriesDontGrowWithComments(t *testing.T) {
+	// queriesFor lists a post of n comments, each by a different author
+	queriesFor := func(n int) int {
+		postID := newUser(t, "").createPost()
+		for i := 0; i < n; i++ {
+			newUser(t, "").createComment(postID, "A comment by its own author")
+		}
+
+		visitor := newVisitor(t)
+		return countQueries(t, func() {
+			res, body := visitor.do(http.MethodGet, fmt.Sprintf("/api/posts/%d/comments?limit=50", postID), nil)
+			expectStatus(t, res, body, http.StatusOK)
+			if ids := listIDs(t, body); len(ids) != n {
+				t.Fatalf("listed %d comments, want %d", len(ids), n)
+			}
+		})
+	}
+
+	small, large := queriesFor(2), queriesFor(12)
+	if small == 0 {
+		t.Fatal("no queries counted")
+	}
+	if small != large {
+		t.Fatalf("listing made %d queries for 2 comments and %d for 12, want the same", small, large)
+	}
+}
+
+func TestIncludePostSummary(t *testing.T) {
+	author := newUser(t, "")
+	postID := author.createPost()
+	commentID := author.createComment(postID, "A comment with context")
+	post := models.Post{}
+	if err := post.GetByID(postID, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	type summary struct {
+		ID    int    `json:"id"`
+		Title string `json:"title"`
+	}
+	type withPost struct {
+		ID   int      `json:"id"`
+		Post *summary `json:"post"`
+	}
+	check := func(t *testing.T, comments []withPost, included bool) {
+		t.Helper()
+
+		if len(comments) != 1 || comments[0].ID != commentID {
+			t.Fatalf("comments = %+v, want comment %d", comments, commentID)
+		}
+		got := comments[0].Post
+		if !included {
+			if got != nil {
+				t.Fatalf("post summary %+v present without include=post", got)
+			}
+			return
+		}
+		if got == nil || got.ID != postID || got.Title != post.Title {
+			t.Fatalf("post summary = %+v, want %d %q", got, postID, post.Title)
+		}
+	}
+
+	visitor := newVisitor(t)
+	for _, included := range []bool{false, true} {
+		query := ""
+		if included {
+			query = "include=post"
+		}
+
+		t.Run(fmt.Sprintf("listing/included=%v", included), func(t *testing.T) {
+			res, body := visitor.do(http.MethodGet, fmt.Sprintf("/api/posts/%d/comments?%s", postID, query), nil)
+			expectStatus(t, res, body, http.StatusOK)
+			var comments []withPost
+			decodeData(t, body, &comments)
+			check(t, comments, included)
+		})
+		t.Run(fmt.Sprintf("tree/included=%v", included), func(t *testing.T) {
+			res, body := visitor.do(http.MethodGet, fmt.Sprintf("/api/posts/%d/comments?tree=true&%s", postID, query), nil)
+			expectStatus(t, res, body, http.StatusOK)
+			var tree struct {
+				Comments []withPost `json:"comments"`
+			}
+			decodeData(t, body, &tree)
+			check(t, tree.Comments, included)
+		})
+		t.Run(fmt.Sprintf("single/included=%v", included), func(t *testing.T) {
+			res, body := visitor.do(http.MethodGet, fmt.Sprintf("/api/comments/%d?%s", commentID, query), nil)
+			expectStatus(t, res, body, http.StatusOK)
+			var comment withPost
+			decodeData(t, body, &comment)
+			check(t, []withPost{comment}, included)
+		})
+	}
+}
+
+// withCollapseThreshold sets the collapse threshold for one test
+func withCollapseThreshold(t *testing.T, threshold int) {
+	t.Helper()
+
+	previous := config.AppConfig.CollapseScoreThreshold
+	config.AppConfig.CollapseScoreThreshold = threshold
+	t.Cleanup(func() { config.AppConfig.CollapseScoreThreshold = previous })
+}
+
+func TestCollapsedComments(t *testing.T) {
+	withCollapseThreshold(t, -2)
+
+	postAuthor := newUser(t, "")
+	postID := postAuthor.createPost()
+	commenter := newUser(t, "")
+	// Scores of exactly the threshold, one above it and one below it
+	atThreshold := commenter.createComment(postID, "Voted down to the threshold")
+	above := commenter.createComment(postID, "Voted down to one above the threshold")
+	below := commenter.createComment(postID, "Voted down past the threshold")
+	castVotes(t, fmt.Sprintf("/api/comments/%d/vote", atThreshold), "dislike", 2)
+	castVotes(t, fmt.Sprintf("/api/comments/%d/vote", above), "dislike", 1)
+	castVotes(t, fmt.Sprintf("/api/comments/%d/vote", below), "dislike", 3)
+
+	type collapsedComment struct {
+		ID        int    `json:"id"`
+		Content   string `json:"content"`
+		Collapsed bool   `json:"collapsed"`
+	}
+	listings := map[string]func(c *testClient, query string) []collapsedComment{
+		"list": func(c *testClient, query string) []collapsedComment {
+			res, body := c.do(http.MethodGet, fmt.Sprintf("/api/posts/%d/comments?sort=oldest%s", postID, query), nil)
+			expectStatus(t, res, body, http.StatusOK)
+			var comments []collapsedComment
+			decodeData(t, body, &comments)
+			return comments
+		},
+		"tree": func(c *testClient, query string) []collapsedComment {
+			res, body := c.do(http.MethodGet, fmt.Sprintf("/api/posts/%d/comments?tree=true&sort=oldest%s", postID, query), nil)
+			expectStatus(t, res, body, http.StatusOK)
+			var tree struct {
+				Comments []collapsedComment `json:"comments"`
+			}
+			decodeData(t, body, &tree)
+			return tree.Comments
+		},
+	}
+
+	tests := []struct {
+		name   string
+		viewer *testClient
+		query  string
+		reveal bool // whether collapsed comments keep their content
+	}{
+		{"visitor", newVisitor(t), "", false},
+		{"other user", postAuthor, "", false},
+		{"visitor asking for them", newVisitor(t), "&show_collapsed=true", true},
+		{"their author", commenter, "", true},
+		{"moderator", newUser(t, models.RoleModerator), "", true},
+	}
+	for name, list := range listings {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				comments := list(tt.viewer, tt.query)
+				if len(comments) != 3 {
+					t.Fatalf("got %d comments, want 3", len(comments))
+				}
+				for _, c := range comments {
+					wantCollapsed := c.ID != above
+					if c.Collapsed != wantCollapsed {
+						t.Errorf("comment %d collapsed = %v, want %v", c.ID, c.Collapsed, wantCollapsed)
+					}
+					if wantContent := !wantCollapsed || tt.reveal; (c.Content != "") != wantContent {
+						t.Errorf("comment %d content = %q, want it shown: %v", c.ID, c.Content, wantContent)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"forum/database"
+	"forum/middleware"
+	"forum/utils"
+)
+
+// HealthController handles GET /api/health
+// It stays reachable during maintenance so load balancers and status pages keep working
+func HealthController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	dbStatus := "ok"
+	if err := database.GetDB().Ping(); err != nil {
+		dbStatus = "unavailable"
+	}
+
+	utils.Success(w, "Service is running", map[string]interface{}{
+		"status":      "ok",
+		"database":    dbStatus,
+		"maintenance": middleware.GetMaintenanceStatus(),
+		"time":        time.Now(),
+	})
+}
@@ -0,0 +1,399 @@
+package controllers_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"forum/config"
+	"forum/database"
+	"forum/middleware"
+	"forum/models"
+	"forum/routes"
+	"forum/utils"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// server is the full application, middleware included, backed by a fresh database
+var server *httptest.Server
+
+// TestMain runs the package tests from a scratch directory, so uploads land under
+// its ./uploads, against a fresh database
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+
+	dir, err := os.MkdirTemp("", "forum-controllers-test")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+
+	os.Setenv("DATABASE_URL", filepath.Join(dir, "forum.db"))
+	config.Load()
+	utils.ApplyUploadConfig()
+	database.Init()
+	if err := models.Settings.Load(); err != nil {
+		panic(err)
+	}
+
+	// Every test client shares one address, so lift the per-IP limits
+	for category := range middleware.GetRateLimits() {
+		middleware.SetRateLimit(category, 1<<30, time.Hour)
+	}
+	// Likewise the comment flood control; its own tests turn it back on
+	relaxCommentFloodControl()
+
+	server = httptest.NewServer(routes.SetupRoutes())
+	code := m.Run()
+
+	server.Close()
+	database.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// queryCount counts the queries run through the counting driver
+var queryCount int64
+
+func init() {
+	sql.Register("sqlite3_counting", countingDriver{})
+}
+
+// countingDriver is the sqlite3 driver with every query counted in queryCount
+type countingDriver struct{}
+
+func (countingDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := (&sqlite3.SQLiteDriver{}).Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return countingConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+type countingConn struct {
+	*sqlite3.SQLiteConn
+}
+
+func (c countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	atomic.AddInt64(&queryCount, 1)
+	return c.SQLiteConn.QueryContext(ctx, query, args)
+}
+
+// countQueries runs fn against a counting connection to the test database
+// and returns how many queries it made, requests to the server included
+func countQueries(t *testing.T, fn func()) int {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3_counting", config.GetDatabaseURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	previous := database.DB
+	database.DB = db
+	defer func() { database.DB = previous }()
+
+	atomic.StoreInt64(&queryCount, 0)
+	fn()
+	return int(atomic.LoadInt64(&queryCount))
+}
+
+// testPassword satisfies the default password policy
+const testPassword = "Passw0rd!"
+
+var testSeq int64
+
+// uniqueName returns a name no other test in the run has used
+func uniqueName(prefix string) string {
+	return fmt.Sprintf("%s%d", prefix, atomic.AddInt64(&testSeq, 1))
+}
+
+// apiResponse is the envelope every API response uses
+type apiResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Error   string          `json:"error"`
+	Code    string          `json:"code"`
+	// Pagination is only set on list responses
+	Pagination struct {
+		Total      int `json:"total"`
+		TotalPages int `json:"total_pages"`
+	} `json:"pagination"`
+	Errors []struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// testClient is a browser-like API client that keeps cookies and sends the CSRF token
+type testClient struct {
+	t      *testing.T
+	client *http.Client
+	User   *models.User // nil for visitors
+}
+
+// newVisitor returns a client that isn't logged in
+func newVisitor(t *testing.T) *testClient {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testClient{t: t, client: &http.Client{Jar: jar}}
+}
+
+// newUser registers a fresh account with the given role ("" for a regular user)
+// and returns a client logged in as it
+func newUser(t *testing.T, role string) *testClient {
+	t.Helper()
+
+	c := newVisitor(t)
+	name := uniqueName("user")
+	res, body := c.do(http.MethodPost, "/api/auth/register", map[string]string{
+		"username": name,
+		"email":    name + "@example.com",
+		"password": testPassword,
+	})
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("register %s: %d %s", name, res.StatusCode, body.Error)
+	}
+
+	c.User = &models.User{}
+	if err := c.User.GetByUsername(name); err != nil {
+		t.Fatal(err)
+	}
+	if role != "" && role != models.RoleUser {
+		if err := c.User.UpdateRole(role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+// do sends a request with an optional JSON body and decodes the API envelope
+func (c *testClient) do(method, path string, body interface{}) (*http.Response, apiResponse) {
+	c.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, server.URL+path, reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.send(req)
+}
+
+// send adds the CSRF header to a prepared request, sends it and decodes the API envelope
+func (c *testClient) send(req *http.Request) (*http.Response, apiResponse) {
+	c.t.Helper()
+
+	if token := c.cookie(utils.CSRFCookieName); token != "" {
+		req.Header.Set(utils.CSRFHeaderName, token)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	var decoded apiResponse
+	if len(raw) > 0 && raw[0] == '{' {
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			c.t.Fatalf("%s %s: bad JSON %q: %v", req.Method, req.URL.Path, raw, err)
+		}
+	}
+	return res, decoded
+}
+
+// upload posts data as a multipart form file and decodes the API envelope
+func (c *testClient) upload(path, field, filename, contentType string, data []byte) (*http.Response, apiResponse) {
+	c.t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="`+field+`"; filename="`+filename+`"`)
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	part.Write(data)
+	writer.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+path, &body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return c.send(req)
+}
+
+// testPNG encodes a small solid PNG
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{B: 200, A: 255})
+		}
+	}
+	var out bytes.Buffer
+	if err := png.Encode(&out, img); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+// cookie returns the value of one of the client's cookies, or ""
+func (c *testClient) cookie(name string) string {
+	u, _ := url.Parse(server.URL)
+	for _, cookie := range c.client.Jar.Cookies(u) {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// expectStatus fails the test when a response doesn't have the wanted status
+func expectStatus(t *testing.T, res *http.Response, body apiResponse, want int) {
+	t.Helper()
+
+	if res.StatusCode != want {
+		t.Fatalf("%s %s: status = %d, want %d (%s %s)", res.Request.Method, res.Request.URL.Path,
+			res.StatusCode, want, body.Message, body.Error)
+	}
+}
+
+// decodeData unmarshals a response's data into v
+func decodeData(t *testing.T, body apiResponse, v interface{}) {
+	t.Helper()
+
+	if err := json.Unmarshal(body.Data, v); err != nil {
+		t.Fatalf("decode data %s: %v", body.Data, err)
+	}
+}
+
+// createPost creates a post through the API and returns its ID
+func (c *testClient) createPost(categoryIDs ...int) int {
+	c.t.Helper()
+
+	if len(categoryIDs) == 0 {
+		categoryIDs = []int{1}
+	}
+	res, body := c.do(http.MethodPost, "/api/posts", map[string]interface{}{
+		"title":        uniqueName("Test post "),
+		"content":      uniqueName("Some test post content "),
+		"category_ids": categoryIDs,
+	})
+	expectStatus(c.t, res, body, http.StatusCreated)
+
+	var post struct {
+		ID int `json:"id"`
+	}
+	decodeData(c.t, body, &post)
+	return post.ID
+}
+
+// createComment comments on a post through the API and returns the comment's ID
+func (c *testClient) createComment(postID int, content string) int {
+	c.t.Helper()
+	return c.createReply(postID, 0, content)
+}
+
+// createReply replies to a comment (or comments on the post when parentID is 0)
+// through the API and returns the new comment's ID
+func (c *testClient) createReply(postID, parentID int, content string) int {
+	c.t.Helper()
+
+	payload := map[string]interface{}{"content": content}
+	if parentID > 0 {
+		payload["parent_id"] = parentID
+	}
+	res, body := c.do(http.MethodPost, fmt.Sprintf("/api/posts/%d/comments", postID), payload)
+	expectStatus(c.t, res, body, http.StatusCreated)
+
+	var comment struct {
+		ID int `json:"id"`
+	}
+	decodeData(c.t, body, &comment)
+	return comment.ID
+}
+
+// relaxCommentFloodControl lets one user comment on a post as often as a test needs
+func relaxCommentFloodControl() {
+	if err := models.Settings.Set(models.SettingCommentsPerPostWindow, "1000000"); err != nil {
+		panic(err)
+	}
+	if err := models.Settings.Set(models.SettingCommentMinInterval, "0"); err != nil {
+		panic(err)
+	}
+}
+
+// setCreatedAt backdates a row so tests can control time windows
+func setCreatedAt(t *testing.T, table string, id int, at time.Time) {
+	t.Helper()
+
+	if _, err := database.GetDB().Exec(`UPDATE `+table+` SET created_at = ? WHERE id = ?`, at, id); err != nil {
+		t.Fatalf("backdate %s %d: %v", table, id, err)
+	}
+}
+
+// listIDs decodes the IDs of a list response's items, in order
+func listIDs(t *testing.T, body apiResponse) []int {
+	t.Helper()
+
+	var items []struct {
+		ID int `json:"id"`
+	}
+	decodeData(t, body, &items)
+
+	ids := make([]int, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"forum/middleware"
+	"forum/models"
+	"forum/utils"
+)
+
+// GetNotificationsController handles GET /api/notifications
+// Query parameters: unread=true to only list unread notifications, page, limit
+func GetNotificationsController(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET requests
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	userID, exists := middleware.GetUserIDFromContext(r)
+	if !exists {
+		utils.Unauthorized(w, "Authentication required")
+		return
+	}
+
+	// Parse pagination parameters
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+
+	limit, offset, err := utils.ValidatePagination(page, limit)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+
+	unreadOnly := query.Get("unread") == "true"
+
+	notifications, total, err := models.GetNotificationsByUserID(userID, unreadOnly, limit, offset)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve notifications")
+		return
+	}
+
+	pagination := map[string]interface{}{
+		"current_page": page,
+		"per_page":     limit,
+		"total":        total,
+		"total_pages":  (total + limit - 1) / limit,
+		"has_next":     page < (total+limit-1)/limit,
+		"has_prev":     page > 1,
+	}
+
+	utils.PaginatedSuccess(w, "Notifications retrieved successfully", notifications, pagination)
+}
+
+// GetUnreadNotificationCountController handles GET /api/notifications/unread-count
+// It returns just the count so clients can cheaply render a badge.
+func GetUnreadNotificationCountController(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET requests
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	userID, exists := middleware.GetUserIDFromContext(r)
+	if !exists {
+		utils.Unauthorized(w, "Authentication required")
+		return
+	}
+
+	count, err := models.CountUnreadNotifications(userID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to count notifications")
+		return
+	}
+
+	utils.Success(w, "Unread count retrieved successfully", count)
+}
+
+// MarkNotificationReadController handles POST /api/notifications/{id}/read
+func MarkNotificationReadController(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w, "Only POST method allowed")
+		return
+	}
+
+	userID, exists := middleware.GetUserIDFromContext(r)
+	if !exists {
+		utils.Unauthorized(w, "Authentication required")
+		return
+	}
+
+	notificationID, err := utils.GetIDFromURL(r, "/notifications/")
+	if err != nil {
+		utils.BadRequest(w, "Invalid notification ID")
+		return
+	}
+
+	if err := models.MarkNotificationRead(userID, notificationID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.NotFound(w, "Notification not found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to update notification")
+		return
+	}
+
+	utils.Success(w, "Notification marked as read", nil)
+}
+
+// MarkAllNotificationsReadController handles POST /api/notifications/read-all
+func MarkAllNotificationsReadController(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w, "Only POST method allowed")
+		return
+	}
+
+	userID, exists := middleware.GetUserIDFromContext(r)
+	if !exists {
+		utils.Unauthorized(w, "Authentication required")
+		return
+	}
+
+	updated, err := models.MarkAllNotificationsRead(userID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to update notifications")
+		return
+	}
+
+	utils.Success(w, "All notifications marked as read", map[string]int{
+		"updated": updated,
+	})
+}
+
+// GetNotificationPreferencesController handles GET /api/notifications/preferences
+func GetNotificationPreferencesController(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET requests
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	userID, exists := middleware.GetUserIDFromContext(r)
+	if !exists {
+		utils.Unauthorized(w, "Authentication required")
+		return
+	}
+
+	prefs, err := models.GetNotificationPreferences(userID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve notification preferences")
+		return
+	}
+
+	utils.Success(w, "Notification preferences retrieved successfully", prefs)
+}
+
+// UpdateNotificationPreferencesController handles PUT /api/notifications/preferences
+func UpdateNotificationPreferencesController(w http.ResponseWriter, r *http.Request) {
+	// Only allow PUT requests
+	if r.Method != http.MethodPut {
+		utils.MethodNotAllowed(w, "Only PUT method allowed")
+		return
+	}
+
+	userID, exists := middleware.GetUserIDFromContext(r)
+	if !exists {
+		utils.Unauthorized(w, "Authentication required")
+		return
+	}
+
+	// Start from the current preferences so omitted fields are left unchanged
+	prefs, err := models.GetNotificationPreferences(userID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve notification preferences")
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(prefs); err != nil {
+		utils.BadRequest(w, "Invalid JSON format")
+		return
+	}
+
+	if err := models.UpdateNotificationPreferences(userID, prefs); err != nil {
+		utils.InternalServerError(w, "Failed to update notification preferences")
+		return
+	}
+
+	utils.Success(w, "Notification preferences updated successfully", prefs)
+}
@@ -0,0 +1,59 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// unreadCount fetches the client's unread notification count
+func unreadCount(t *testing.T, c *testClient) int {
+	t.Helper()
+
+	res, body := c.do(http.MethodGet, "/api/notifications/unread-count", nil)
+	expectStatus(t, res, body, http.StatusOK)
+
+	var count int
+	decodeData(t, body, &count)
+	return count
+}
+
+func TestNotificationUnreadCount(t *testing.T) {
+	author := newUser(t, "")
+	postID := author.createPost()
+	for i := 0; i < 3; i++ {
+		newUser(t, "").createComment(postID, "A comment to notify about")
+	}
+
+	if got := unreadCount(t, author); got != 3 {
+		t.Fatalf("unread count = %d, want 3", got)
+	}
+
+	res, body := author.do(http.MethodGet, "/api/notifications", nil)
+	expectStatus(t, res, body, http.StatusOK)
+	ids := listIDs(t, body)
+	if len(ids) != 3 {
+		t.Fatalf("got %d notifications, want 3", len(ids))
+	}
+
+	// Someone else can't mark the author's notification as read
+	res, body = newUser(t, "").do(http.MethodPost, fmt.Sprintf("/api/notifications/%d/read", ids[0]), nil)
+	if res.StatusCode == http.StatusOK {
+		t.Fatal("another user marked the author's notification as read")
+	}
+	if got := unreadCount(t, author); got != 3 {
+		t.Fatalf("unread count = %d after another user's attempt, want 3", got)
+	}
+
+	res, body = author.do(http.MethodPost, fmt.Sprintf("/api/notifications/%d/read", ids[0]), nil)
+	expectStatus(t, res, body, http.StatusOK)
+	if got := unreadCount(t, author); got != 2 {
+		t.Fatalf("unread count = %d after marking one read, want 2", got)
+	}
+
+	res, body = author.do(http.MethodPost, "/api/notifications/read-all", nil)
+	expectStatus(t, res, body, http.StatusOK)
+	if got := unreadCount(t, author); got != 0 {
+		t.Fatalf("unread count = %d after marking all read, want 0", got)
+	}
+}
@@ -1,13 +1,19 @@
 package controllers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"forum/config"
+	"forum/events"
 	"forum/middleware"
 	"forum/models"
 	"forum/utils"
@@ -29,23 +35,64 @@ type PostUpdateRequest struct {
 
 // PostResponse represents post data sent to client
 type PostResponse struct {
-	ID           int             `json:"id"`
-	Title        string          `json:"title"`
-	Content      string          `json:"content"`
-	Categories   []CategoryBrief `json:"categories"`
-	Author       UserResponse    `json:"author"`
-	LikeCount    int             `json:"like_count"`
-	DislikeCount int             `json:"dislike_count"`
-	CommentCount int             `json:"comment_count"`
-	UserVote     *string         `json:"user_vote"`
-	CreatedAt    time.Time       `json:"created_at"`
-	UpdatedAt    time.Time       `json:"updated_at"`
+	ID           int                    `json:"id"`
+	Title        string                 `json:"title"`
+	Content      string                 `json:"content"`
+	ContentHTML  string                 `json:"content_html"` // escaped, safe to insert as HTML
+	Categories   []CategoryBrief        `json:"categories"`
+	Author       UserResponse           `json:"author"`
+	LikeCount    int                    `json:"like_count"`
+	DislikeCount int                    `json:"dislike_count"`
+	CommentCount int                    `json:"comment_count"`
+	UserVote     *string                `json:"user_vote"`
+	Reactions    models.ReactionSummary `json:"reactions"`
+	Attachments  []AttachmentResponse   `json:"attachments"`
+	Collapsed    bool                   `json:"collapsed"` // voted down to the collapse threshold
+	// AcceptedCommentID is the comment marked as the accepted answer (nil if none)
+	AcceptedCommentID *int            `json:"accepted_comment_id"`
+	Pinned            bool            `json:"pinned"`
+	PinScope          string          `json:"pin_scope,omitempty"`
+	Edited            bool            `json:"edited"`
+	LikedAt           *utils.JSONTime `json:"liked_at,omitempty"` // set on liked-post listings
+	// CategoryDetails carries full category objects, only with ?include=category_details
+	CategoryDetails []CategoryDetail `json:"category_details,omitempty"`
+	CreatedAt       utils.JSONTime   `json:"created_at"`
+	UpdatedAt       utils.JSONTime   `json:"updated_at"`
+}
+
+// AttachmentResponse is a file attached to a post
+type AttachmentResponse struct {
+	ID           int    `json:"id"`
+	URL          string `json:"url"`
+	OriginalName string `json:"original_name"`
+	MimeType     string `json:"mime_type"`
+	Size         int64  `json:"size"`
 }
 
 // CategoryBrief for embedding in post responses
 type CategoryBrief struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Slug  string `json:"slug"`
+	Color string `json:"color"`
+	Icon  string `json:"icon"`
+}
+
+// CategoryDetail is a category with its description, for post detail pages
+type CategoryDetail struct {
+	CategoryBrief
+	Description string `json:"description"`
+}
+
+// newCategoryBrief maps a category to its embedded form
+func newCategoryBrief(category models.Category) CategoryBrief {
+	return CategoryBrief{
+		ID:    category.ID,
+		Name:  category.Name,
+		Slug:  category.Slug,
+		Color: category.Color,
+		Icon:  category.Icon,
+	}
 }
 
 // CreatePostController handles post creation
@@ -67,25 +114,52 @@ func CreatePostController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Sanitize before validating so content that is empty once cleaned is rejected
+	req.Title = utils.SanitizeString(req.Title)
+	req.Content = utils.SanitizeString(req.Content)
+
 	// Validate post form (you'll need to update ValidatePostForm)
 	if errors := utils.ValidatePostForm(req.Title, req.Content, req.CategoryIDs); errors.HasErrors() {
 		utils.ValidationError(w, errors)
 		return
 	}
 
-	// Create new post
+	role, _ := middleware.GetRoleFromContext(r)
+	categories, categoryErrors, restricted, err := checkPostCategories(req.CategoryIDs, nil, role)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to check categories")
+		return
+	} else if categoryErrors.HasErrors() {
+		utils.ValidationError(w, categoryErrors)
+		return
+	} else if restricted != nil {
+		utils.Forbidden(w, "Only "+restricted.PostPermission+" can post in category '"+restricted.Name+"'")
+		return
+	}
+
+	// Reject an accidental repeat of a post the author just made
+	if config.IsDuplicatePostCheckEnabled() {
+		since := time.Now().Add(-config.GetDuplicatePostWindow())
+		existingID, err := models.FindRecentDuplicatePost(userID, req.Title, req.Content, since)
+		if err != nil {
+			utils.InternalServerError(w, "Failed to check for duplicate posts")
+			return
+		}
+		if existingID > 0 {
+			utils.ConflictWithData(w, "duplicate_post", "You already made this post a moment ago", map[string]interface{}{
+				"post_id": existingID,
+				"url":     fmt.Sprintf("/api/posts/%d", existingID),
+			})
+			return
+		}
+	}
+
+	// Create new post, filed under the full categories so the response can describe them
 	post := models.Post{
 		Title:      req.Title,
 		Content:    req.Content,
 		UserID:     userID,
-		Categories: make([]models.Category, 0, len(req.CategoryIDs)),
-	}
-
-	// Convert category IDs to Category objects
-	for _, catID := range req.CategoryIDs {
-		post.Categories = append(post.Categories, models.Category{
-			ID: catID,
-		})
+		Categories: categories,
 	}
 
 	if err := post.Create(); err != nil {
@@ -93,6 +167,14 @@ func CreatePostController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	events.Publish(events.PostCreated, map[string]interface{}{
+		"id":           post.ID,
+		"title":        post.Title,
+		"user_id":      post.UserID,
+		"category_ids": req.CategoryIDs,
+		"created_at":   utils.NewJSONTime(post.CreatedAt),
+	})
+
 	// Get full post details for response
 	postResponse, err := getPostResponse(&post, userID)
 	if err != nil {
@@ -133,6 +215,17 @@ func GetPostController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Descriptions come from the same query as the post, so this costs nothing extra
+	if requestIncludes(r, "category_details") {
+		postResponse.CategoryDetails = make([]CategoryDetail, 0, len(post.Categories))
+		for _, cat := range post.Categories {
+			postResponse.CategoryDetails = append(postResponse.CategoryDetails, CategoryDetail{
+				CategoryBrief: newCategoryBrief(cat),
+				Description:   cat.Description,
+			})
+		}
+	}
+
 	utils.Success(w, "Post retrieved successfully", postResponse)
 }
 
@@ -177,20 +270,44 @@ func UpdatePostController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Sanitize before validating so content that is empty once cleaned is rejected
+	req.Title = utils.SanitizeString(req.Title)
+	req.Content = utils.SanitizeString(req.Content)
+
 	// Validate post form (you'll need to update ValidatePostForm)
 	if errors := utils.ValidatePostForm(req.Title, req.Content, req.CategoryIDs); errors.HasErrors() {
 		utils.ValidationError(w, errors)
 		return
 	}
 
+	// Nothing changed, skip the write so the post isn't flagged as edited
+	if !post.HasChanges(req.Title, req.Content, req.CategoryIDs) {
+		postResponse, err := getPostResponse(&post, userID)
+		if err != nil {
+			utils.InternalServerError(w, "Failed to retrieve post details")
+			return
+		}
+		utils.Success(w, "No changes to update", postResponse)
+		return
+	}
+
+	role, _ := middleware.GetRoleFromContext(r)
+	categories, categoryErrors, restricted, err := checkPostCategories(req.CategoryIDs, post.Categories, role)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to check categories")
+		return
+	} else if categoryErrors.HasErrors() {
+		utils.ValidationError(w, categoryErrors)
+		return
+	} else if restricted != nil {
+		utils.Forbidden(w, "Only "+restricted.PostPermission+" can post in category '"+restricted.Name+"'")
+		return
+	}
+
 	// Update post fields
 	post.Title = req.Title
 	post.Content = req.Content
-	post.Categories = make([]models.Category, 0, len(req.CategoryIDs))
-
-	for _, catID := range req.CategoryIDs {
-		post.Categories = append(post.Categories, models.Category{ID: catID})
-	}
+	post.Categories = categories
 
 	if err := post.Update(); err != nil {
 		utils.InternalServerError(w, "Failed to update post")
@@ -207,6 +324,7 @@ func UpdatePostController(w http.ResponseWriter, r *http.Request) {
 }
 
 // DeletePostController handles post deletion
+// Moderators may delete any post; an optional ?reason= is kept in the moderation log.
 func DeletePostController(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		utils.MethodNotAllowed(w, "Only DELETE method allowed")
@@ -236,20 +354,56 @@ func DeletePostController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if post.UserID != userID {
+	// Moderators may remove other users' posts; those removals are audited
+	moderatorDelete := post.UserID != userID
+	if moderatorDelete && !middleware.IsModerator(r) {
 		utils.Forbidden(w, "You can only delete your own posts")
 		return
 	}
 
+	// The rows go with the post; the files are removed once the delete has committed
+	attachments, err := models.GetPostAttachments(post.ID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to delete post")
+		return
+	}
+
 	if err := post.Delete(); err != nil {
 		utils.InternalServerError(w, "Failed to delete post")
 		return
 	}
 
+	for _, attachment := range attachments {
+		if err := utils.DeleteFile(utils.GetAttachmentFilePath(attachment.Filename)); err != nil {
+			log.Printf("Failed to remove attachment file %s: %v", attachment.Filename, err)
+		}
+	}
+
+	if moderatorDelete {
+		reason := strings.TrimSpace(r.URL.Query().Get("reason"))
+		if err := models.LogModerationAction(userID, models.ModActionDeletePost, models.TargetPost, post.ID, reason); err != nil {
+			log.Printf("Failed to write moderation log: %v", err)
+		}
+	}
+
 	utils.Success(w, "Post deleted successfully", nil)
 }
 
 // GetPostsController handles retrieving multiple posts with filtering
+//
+// The author parameter accepts either a numeric user ID or a username; an unknown
+// username yields an empty page rather than an error. When author is combined with
+// sort=my_posts both filters apply, so only the current user's own posts are returned
+// and a different author produces an empty result.
+//
+// from and to limit posts to those created in that range, both ends inclusive.
+// Each takes RFC3339 or a plain date; a plain "to" date includes the whole day.
+//
+// has_attachments=true keeps only posts with attachments; attachment_type narrows
+// that to posts with an attachment of the given MIME type (e.g. image/png).
+//
+// Posts voted down to the collapse threshold are left out unless show_collapsed=true;
+// they stay reachable by ID and in the my_* lists.
 func GetPostsController(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		utils.MethodNotAllowed(w, "Only GET method allowed")
@@ -275,36 +429,85 @@ func GetPostsController(w http.ResponseWriter, r *http.Request) {
 	}
 
 	categoryID, _ := strconv.Atoi(query.Get("category"))
-	authorID, _ := strconv.Atoi(query.Get("author"))
 	sortBy := query.Get("sort")
-
 	if sortBy == "" {
-		sortBy = "newest"
+		sortBy = models.DefaultPostSort()
+	}
+	if !models.IsValidPostSort(sortBy) {
+		utils.BadRequest(w, "Sort must be one of: "+strings.Join(models.PostSorts, ", "))
+		return
 	}
 
-	userID, _ := middleware.GetUserIDFromContext(r)
+	createdFrom, err := parseDateParam(query.Get("from"), false)
+	if err != nil {
+		utils.BadRequest(w, "Invalid 'from' date, use RFC3339 or YYYY-MM-DD")
+		return
+	}
+	createdTo, err := parseDateParam(query.Get("to"), true)
+	if err != nil {
+		utils.BadRequest(w, "Invalid 'to' date, use RFC3339 or YYYY-MM-DD")
+		return
+	}
+	if createdFrom != nil && createdTo != nil && createdFrom.After(*createdTo) {
+		utils.BadRequest(w, "'from' must not be after 'to'")
+		return
+	}
 
-	posts, total, err := models.GetPosts(models.PostFilters{
-		CurrentUserID: userID,
-		CategoryID:    categoryID,
-		AuthorID:      authorID,
-		SortBy:        sortBy,
-		Limit:         limit,
-		Offset:        offset,
-	})
+	var hasAttachments bool
+	if value := query.Get("has_attachments"); value != "" {
+		hasAttachments, err = strconv.ParseBool(value)
+		if err != nil {
+			utils.BadRequest(w, "Invalid 'has_attachments' value, use true or false")
+			return
+		}
+	}
+	attachmentType := strings.ToLower(strings.TrimSpace(query.Get("attachment_type")))
+
+	authorID, authorFound, err := resolveAuthorParam(query.Get("author"))
 	if err != nil {
-		utils.InternalServerError(w, "Failed to retrieve posts")
+		utils.InternalServerError(w, "Failed to resolve author")
 		return
 	}
+	if authorFound && authorID > 0 {
+		privacy, err := privacyFor(r, authorID)
+		if err != nil && err != sql.ErrNoRows {
+			utils.InternalServerError(w, "Failed to retrieve privacy settings")
+			return
+		}
+		if privacy != nil && privacy.HidePosts {
+			utils.Forbidden(w, "This user's posts are private")
+			return
+		}
+	}
 
-	var postResponses []PostResponse
-	for _, post := range posts {
-		postResponse, err := getPostResponse(&post, userID)
+	userID, _ := middleware.GetUserIDFromContext(r)
+
+	var posts []models.Post
+	var total int
+	if authorFound {
+		posts, total, err = models.GetPosts(models.PostFilters{
+			CurrentUserID:  userID,
+			CategoryID:     categoryID,
+			AuthorID:       authorID,
+			CreatedFrom:    createdFrom,
+			CreatedTo:      createdTo,
+			HasAttachments: hasAttachments,
+			AttachmentType: attachmentType,
+			ShowCollapsed:  query.Get("show_collapsed") == "true",
+			SortBy:         sortBy,
+			Limit:          limit,
+			Offset:         offset,
+		})
 		if err != nil {
-			utils.InternalServerError(w, "Failed to process post data")
+			utils.InternalServerError(w, "Failed to retrieve posts")
 			return
 		}
-		postResponses = append(postResponses, *postResponse)
+	}
+
+	postResponses, err := getPostResponses(posts, userID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to process post data")
+		return
 	}
 
 	pagination := map[string]interface{}{
@@ -316,6 +519,7 @@ func GetPostsController(w http.ResponseWriter, r *http.Request) {
 		"has_prev":     page > 1,
 	}
 
+	utils.SetPaginationLinks(w, r, page, limit, total)
 	utils.PaginatedSuccess(w, "Posts retrieved successfully", postResponses, pagination)
 }
 
@@ -340,14 +544,15 @@ func VotePostController(w http.ResponseWriter, r *http.Request) {
 
 	var voteData struct {
 		VoteType string `json:"vote_type"`
+		Action   string `json:"action"` // "toggle" (default), "set" or "remove"
 	}
 	if err := json.NewDecoder(r.Body).Decode(&voteData); err != nil {
 		utils.BadRequest(w, "Invalid JSON format")
 		return
 	}
 
-	if !utils.IsValidVoteType(voteData.VoteType) {
-		utils.BadRequest(w, "Vote type must be 'like' or 'dislike'")
+	if errors := utils.ValidateVoteForm(voteData.VoteType, voteData.Action); errors.HasErrors() {
+		utils.ValidationError(w, errors)
 		return
 	}
 
@@ -362,9 +567,10 @@ func VotePostController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := models.TogglePostVote(userID, postID, voteData.VoteType)
+	notify := models.LikeNotifier(userID, post.UserID, post.ID, nil)
+	result, err := models.TogglePostVote(userID, postID, voteData.VoteType, voteData.Action, notify)
 	if err != nil {
-		utils.InternalServerError(w, "Failed to process vote")
+		writeVoteError(w, err)
 		return
 	}
 
@@ -376,8 +582,208 @@ func VotePostController(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// writeVoteError responds to a failed post or comment vote
+func writeVoteError(w http.ResponseWriter, err error) {
+	var budgetErr *models.VoteBudgetError
+	switch {
+	case errors.Is(err, models.ErrSelfVote):
+		utils.ErrorWithCode(w, http.StatusForbidden, "cannot_vote_own_content", "You cannot vote on your own content")
+	case errors.As(err, &budgetErr):
+		utils.Throttled(w, "vote_limit_reached",
+			fmt.Sprintf("You can cast at most %d votes per day. The limit resets at midnight UTC.", budgetErr.Limit),
+			secondsCeil(time.Until(budgetErr.ResetAt)))
+	default:
+		utils.InternalServerError(w, "Failed to process vote")
+	}
+}
+
+// AcceptAnswerController handles POST /api/posts/{id}/accept/{commentID}
+// Only the post author can mark a comment as the accepted answer; accepting
+// another comment moves the mark since a post has at most one accepted answer.
+func AcceptAnswerController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w, "Only POST method allowed")
+		return
+	}
+
+	post, comment, ok := loadAcceptAnswerTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := post.SetAcceptedComment(&comment.ID); err != nil {
+		utils.InternalServerError(w, "Failed to accept answer")
+		return
+	}
+
+	utils.Success(w, "Answer accepted successfully", map[string]interface{}{
+		"post_id":             post.ID,
+		"accepted_comment_id": post.AcceptedCommentID,
+	})
+}
+
+// UnacceptAnswerController handles DELETE /api/posts/{id}/accept/{commentID}
+func UnacceptAnswerController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.MethodNotAllowed(w, "Only DELETE method allowed")
+		return
+	}
+
+	post, comment, ok := loadAcceptAnswerTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if post.AcceptedCommentID == nil || *post.AcceptedCommentID != comment.ID {
+		utils.BadRequest(w, "This comment is not the accepted answer")
+		return
+	}
+
+	if err := post.SetAcceptedComment(nil); err != nil {
+		utils.InternalServerError(w, "Failed to remove accepted answer")
+		return
+	}
+
+	utils.Success(w, "Accepted answer removed successfully", map[string]interface{}{
+		"post_id":             post.ID,
+		"accepted_comment_id": nil,
+	})
+}
+
+// PinPostRequest represents the optional JSON body for pinning a post
+type PinPostRequest struct {
+	Scope string `json:"scope"` // "global" (default) or "category"
+}
+
+// PinPostController handles PUT /api/posts/{id}/pin (moderators only)
+func PinPostController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.MethodNotAllowed(w, "Only PUT method allowed")
+		return
+	}
+
+	// The body is optional; an empty body pins globally
+	var req PinPostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		utils.BadRequest(w, "Invalid JSON format")
+		return
+	}
+	if req.Scope == "" {
+		req.Scope = models.PinScopeGlobal
+	}
+	if !models.IsValidPinScope(req.Scope) {
+		utils.BadRequest(w, "Scope must be 'global' or 'category'")
+		return
+	}
+
+	setPostPinned(w, r, true, req.Scope)
+}
+
+// UnpinPostController handles PUT /api/posts/{id}/unpin (moderators only)
+func UnpinPostController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.MethodNotAllowed(w, "Only PUT method allowed")
+		return
+	}
+
+	setPostPinned(w, r, false, "")
+}
+
+// setPostPinned pins or unpins the post in the URL and records the moderation action
+func setPostPinned(w http.ResponseWriter, r *http.Request, pinned bool, scope string) {
+	moderatorID, _ := middleware.GetUserIDFromContext(r)
+
+	postID, err := getPostIDFromPath(r.URL.Path)
+	if err != nil {
+		utils.BadRequest(w, "Invalid post ID")
+		return
+	}
+
+	post := models.Post{}
+	if err := post.GetByID(postID, nil); err != nil {
+		utils.NotFound(w, "Post not found")
+		return
+	}
+
+	if err := post.SetPinned(pinned, scope); err != nil {
+		utils.InternalServerError(w, "Failed to update pin status")
+		return
+	}
+
+	action, message := models.ModActionPinPost, "Post pinned successfully"
+	if !pinned {
+		action, message = models.ModActionUnpinPost, "Post unpinned successfully"
+	}
+	if err := models.LogModerationAction(moderatorID, action, models.TargetPost, post.ID, scope); err != nil {
+		log.Printf("Failed to write moderation log: %v", err)
+	}
+
+	utils.Success(w, message, map[string]interface{}{
+		"post_id":   post.ID,
+		"pinned":    post.Pinned,
+		"pin_scope": post.PinScope,
+	})
+}
+
+// loadAcceptAnswerTarget loads and authorizes the post/comment pair for accept endpoints.
+// It writes the error response itself and returns ok=false on failure.
+func loadAcceptAnswerTarget(w http.ResponseWriter, r *http.Request) (*models.Post, *models.Comment, bool) {
+	userID, exists := middleware.GetUserIDFromContext(r)
+	if !exists {
+		utils.Unauthorized(w, "Authentication required")
+		return nil, nil, false
+	}
+
+	postID, err := getPostIDFromPath(r.URL.Path)
+	if err != nil {
+		utils.BadRequest(w, "Invalid post ID")
+		return nil, nil, false
+	}
+
+	commentID, err := getAcceptedCommentIDFromPath(r.URL.Path)
+	if err != nil {
+		utils.BadRequest(w, "Invalid comment ID")
+		return nil, nil, false
+	}
+
+	post := models.Post{}
+	if err := post.GetByID(postID, nil); err != nil {
+		utils.NotFound(w, "Post not found")
+		return nil, nil, false
+	}
+
+	if post.UserID != userID {
+		utils.Forbidden(w, "Only the post author can accept an answer")
+		return nil, nil, false
+	}
+
+	comment := models.Comment{}
+	if err := comment.GetByID(commentID, nil); err != nil {
+		utils.NotFound(w, "Comment not found")
+		return nil, nil, false
+	}
+
+	if comment.PostID != post.ID {
+		utils.BadRequest(w, "Comment does not belong to this post")
+		return nil, nil, false
+	}
+
+	return &post, &comment, true
+}
+
 // Helper functions
 
+// getAcceptedCommentIDFromPath extracts the comment ID from /api/posts/{id}/accept/{commentID}
+func getAcceptedCommentIDFromPath(path string) (int, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i, part := range parts {
+		if part == "accept" && i+1 < len(parts) {
+			return strconv.Atoi(parts[i+1])
+		}
+	}
+	return 0, errors.New("invalid accept path format")
+}
+
 func getPostIDFromPath(path string) (int, error) {
 	path = strings.TrimPrefix(path, "/api/posts/")
 	parts := strings.Split(path, "/")
@@ -387,7 +793,135 @@ func getPostIDFromPath(path string) (int, error) {
 	return strconv.Atoi(parts[0])
 }
 
+// checkPostCategories makes sure every category a post is being filed under
+// exists and is open for posting. Categories in current, the ones the post is
+// already in, may stay even after they are archived or restricted.
+// restricted is the strictest newly selected category whose post permission
+// the caller's role doesn't meet. selected holds the loaded categories in the
+// order given, ready to file the post under.
+func checkPostCategories(categoryIDs []int, current []models.Category, role string) (selected []models.Category, validationErrors utils.ValidationErrors, restricted *models.Category, err error) {
+	categories, err := models.GetCategoriesByIDs(categoryIDs)
+	if err != nil {
+		return nil, validationErrors, nil, err
+	}
+
+	kept := make(map[int]bool, len(current))
+	for _, category := range current {
+		kept[category.ID] = true
+	}
+
+	for _, id := range categoryIDs {
+		category, ok := categories[id]
+		if !ok {
+			validationErrors.Add("categories", fmt.Sprintf("Category %d does not exist", id))
+			break
+		}
+		if category.Archived && !kept[id] {
+			validationErrors.Add("categories", "Category '"+category.Name+"' is archived and no longer accepts posts")
+			break
+		}
+		if !kept[id] && !category.CanPost(role) && (restricted == nil || category.IsStricterThan(restricted)) {
+			category := category
+			restricted = &category
+		}
+		selected = append(selected, category)
+	}
+	return selected, validationErrors, restricted, nil
+}
+
+// resolveAuthorParam turns the author query value (numeric ID or username) into a user ID.
+// found is false when a username was given but no such user exists.
+func resolveAuthorParam(author string) (id int, found bool, err error) {
+	author = strings.TrimSpace(author)
+	if author == "" {
+		return 0, true, nil
+	}
+
+	if id, err := strconv.Atoi(author); err == nil {
+		return id, true, nil
+	}
+
+	user := models.User{}
+	if err := user.GetByUsername(author); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	return user.ID, true, nil
+}
+
+// parseDateParam reads a from/to query value as RFC3339 or a plain date (2006-01-02).
+// A plain date covers the whole day, so endOfDay moves it to the day's last second.
+// An empty value gives a nil time.
+func parseDateParam(value string, endOfDay bool) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return &t, nil
+}
+
+// getPostResponse builds the full response for a single post, looking up the viewer's vote
 func getPostResponse(post *models.Post, currentUserID int) (*PostResponse, error) {
+	var userVote *string
+	if currentUserID > 0 {
+		vote := models.Vote{}
+		if err := vote.GetByUserAndPost(currentUserID, post.ID); err == nil {
+			userVote = &vote.VoteType
+		}
+	}
+	return buildPostResponse(post, currentUserID, userVote)
+}
+
+// getPostResponses builds the responses for a list of posts, resolving the
+// viewer's votes on the whole list with one query
+func getPostResponses(posts []models.Post, currentUserID int) ([]PostResponse, error) {
+	var userVotes map[int]string
+	if currentUserID > 0 {
+		ids := make([]int, len(posts))
+		for i, post := range posts {
+			ids[i] = post.ID
+		}
+
+		var err error
+		userVotes, err = models.GetUserVoteTypes(currentUserID, models.TargetPost, ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	responses := make([]PostResponse, 0, len(posts))
+	for i := range posts {
+		var userVote *string
+		if voteType, ok := userVotes[posts[i].ID]; ok {
+			userVote = &voteType
+		}
+
+		response, err := buildPostResponse(&posts[i], currentUserID, userVote)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, *response)
+	}
+	return responses, nil
+}
+
+// buildPostResponse assembles a post response around an already resolved viewer vote
+func buildPostResponse(post *models.Post, currentUserID int, userVote *string) (*PostResponse, error) {
 	author := models.User{}
 	if err := author.GetByID(post.UserID); err != nil {
 		return nil, err
@@ -403,39 +937,52 @@ func getPostResponse(post *models.Post, currentUserID int) (*PostResponse, error
 		return nil, err
 	}
 
-	var userVote *string
+	var viewerID *int
 	if currentUserID > 0 {
-		vote := models.Vote{}
-		if err := vote.GetByUserAndPost(currentUserID, post.ID); err == nil {
-			userVote = &vote.VoteType
-		}
+		viewerID = &currentUserID
+	}
+	reactions, err := models.GetReactionSummary(models.TargetPost, post.ID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	attachments, err := models.GetPostAttachments(post.ID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Map categories from post
 	categories := make([]CategoryBrief, 0, len(post.Categories))
 	for _, cat := range post.Categories {
-		categories = append(categories, CategoryBrief{
-			ID:   cat.ID,
-			Name: cat.Name,
-		})
+		categories = append(categories, newCategoryBrief(cat))
 	}
 
 	return &PostResponse{
-		ID:         post.ID,
-		Title:      post.Title,
-		Content:    post.Content,
-		Categories: categories, // Changed from single category
+		ID:          post.ID,
+		Title:       post.Title,
+		Content:     post.Content,
+		ContentHTML: utils.ContentHTML(post.Content),
+		Categories:  categories, // Changed from single category
 		Author: UserResponse{
-			ID:       author.ID,
-			Username: author.Username,
-			Email:    author.Email,
-			JoinedAt: author.CreatedAt,
+			ID:        author.ID,
+			Username:  author.Username,
+			Email:     author.Email,
+			JoinedAt:  utils.NewJSONTime(author.CreatedAt),
+			NewMember: author.IsNewMember(),
 		},
-		LikeCount:    likeCount,
-		DislikeCount: dislikeCount,
-		CommentCount: commentCount,
-		UserVote:     userVote,
-		CreatedAt:    post.CreatedAt,
-		UpdatedAt:    post.UpdatedAt,
+		LikeCount:         likeCount,
+		DislikeCount:      dislikeCount,
+		CommentCount:      commentCount,
+		UserVote:          userVote,
+		Reactions:         reactions,
+		Attachments:       newAttachmentResponses(attachments),
+		Collapsed:         models.IsCollapsedScore(likeCount, dislikeCount),
+		AcceptedCommentID: post.AcceptedCommentID,
+		Pinned:            post.Pinned,
+		LikedAt:           utils.NewJSONTimePtr(post.LikedAt),
+		PinScope:          post.PinScope,
+		Edited:            post.IsEdited(),
+		CreatedAt:         utils.NewJSONTime(post.CreatedAt),
+		UpdatedAt:         utils.NewJSONTime(post.UpdatedAt),
 	}, nil
 }
@@ -0,0 +1,528 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/config"
+	"forum/models"
+	"forum/utils"
+)
+
+func TestGetPostsByAuthor(t *testing.T) {
+	author := newUser(t, "")
+	first := author.createPost()
+	second := author.createPost()
+	newUser(t, "").createPost() // someone else's post that must not show up
+	want := []int{first, second}
+
+	visitor := newVisitor(t)
+	tests := []struct {
+		name   string
+		author string
+		want   []int
+	}{
+		{"username", author.User.Username, want},
+		{"numeric ID", fmt.Sprint(author.User.ID), want},
+		{"unknown username", "nobody-by-that-name", []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, body := visitor.do(http.MethodGet, "/api/posts?author="+tt.author, nil)
+			expectStatus(t, res, body, http.StatusOK)
+
+			got := listIDs(t, body)
+			sort.Ints(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("posts = %v, want %v", got, tt.want)
+			}
+			if body.Pagination.Total != len(tt.want) {
+				t.Fatalf("total = %d, want %d", body.Pagination.Total, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestCreatePostRequiresCSRFToken(t *testing.T) {
+	author := newUser(t, "")
+	payload := `{"title":"A post about tokens","content":"Content long enough to pass","category_ids":[1]}`
+
+	post := func(token string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/api/posts", strings.NewReader(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set(utils.CSRFHeaderName, token)
+		}
+		res, err := author.client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	if res := post(""); res.StatusCode != http.StatusForbidden {
+		t.Fatalf("without a token: status = %d, want 403", res.StatusCode)
+	}
+	if res := post("not-the-token"); res.StatusCode != http.StatusForbidden {
+		t.Fatalf("with a wrong token: status = %d, want 403", res.StatusCode)
+	}
+	if res := post(author.cookie(utils.CSRFCookieName)); res.StatusCode != http.StatusCreated {
+		t.Fatalf("with the token: status = %d, want 201", res.StatusCode)
+	}
+}
+
+func TestListingsSendLinkHeaders(t *testing.T) {
+	author := newUser(t, "")
+	postID := author.createPost()
+	for i := 0; i < 2; i++ {
+		author.createPost()
+		author.createComment(postID, "A comment to page through")
+	}
+	author.createComment(postID, "A comment to page through")
+
+	// Each listing has three items, so page 2 of 1 is a middle page and page 3 the last
+	listings := []string{
+		fmt.Sprintf("/api/posts?author=%s", author.User.Username),
+		fmt.Sprintf("/api/posts/%d/comments", postID),
+		fmt.Sprintf("/api/users/%d/posts", author.User.ID),
+		fmt.Sprintf("/api/users/%d/comments", author.User.ID),
+	}
+	visitor := newVisitor(t)
+	for _, listing := range listings {
+		t.Run(listing, func(t *testing.T) {
+			links := func(page int) map[string]string {
+				t.Helper()
+
+				sep := "?"
+				if strings.Contains(listing, "?") {
+					sep = "&"
+				}
+				res, body := visitor.do(http.MethodGet, fmt.Sprintf("%s%spage=%d&limit=1", listing, sep, page), nil)
+				expectStatus(t, res, body, http.StatusOK)
+
+				rels := make(map[string]string)
+				for _, link := range strings.Split(res.Header.Get("Link"), ", ") {
+					target, rel, _ := strings.Cut(link, "; ")
+					u, err := url.Parse(strings.Trim(target, "<>"))
+					if err != nil {
+						t.Fatalf("bad link %q: %v", link, err)
+					}
+					rels[strings.TrimSuffix(strings.TrimPrefix(rel, `rel="`), `"`)] = u.Query().Get("page")
+				}
+				return rels
+			}
+
+			middle := links(2)
+			want := map[string]string{"first": "1", "prev": "1", "next": "3", "last": "3"}
+			if !reflect.DeepEqual(middle, want) {
+				t.Fatalf("middle page links = %v, want %v", middle, want)
+			}
+
+			last := links(3)
+			if _, ok := last["next"]; ok || last["prev"] != "2" || last["last"] != "3" {
+				t.Fatalf("last page links = %v", last)
+			}
+		})
+	}
+}
+
+func TestGetPostsByDateRange(t *testing.T) {
+	author := newUser(t, "")
+	dates := []time.Time{
+		time.Date(2020, 1, 10, 12, 0, 0, 0, time.UTC),
+		time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC),
+		time.Date(2020, 1, 20, 23, 59, 59, 0, time.UTC),
+	}
+	ids := make([]int, len(dates))
+	for i, date := range dates {
+		ids[i] = author.createPost()
+		setCreatedAt(t, "posts", ids[i], date)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int
+	}{
+		{"inclusive dates", "from=2020-01-10&to=2020-01-20", ids},
+		{"inclusive timestamps", "from=2020-01-15T00:00:00Z&to=2020-01-15T00:00:00Z", ids[1:2]},
+		{"only from", "from=2020-01-15", ids[1:]},
+		{"only to", "to=2020-01-15", ids[:2]},
+		{"timestamp with offset", "to=2020-01-15T00:59:59%2B01:00", ids[:1]},
+		{"empty range", "from=2020-01-11&to=2020-01-14", []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, body := author.do(http.MethodGet, "/api/posts?author="+author.User.Username+"&"+tt.query, nil)
+			expectStatus(t, res, body, http.StatusOK)
+
+			got := listIDs(t, body)
+			sort.Ints(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("posts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, query := range []string{"from=2020-13-01", "to=yesterday", "from=15/01/2020", "from=2020-01-20&to=2020-01-10"} {
+		t.Run(query, func(t *testing.T) {
+			res, body := author.do(http.MethodGet, "/api/posts?"+query, nil)
+			expectStatus(t, res, body, http.StatusBadRequest)
+		})
+	}
+}
+
+func TestCollapsedPostsLeaveListings(t *testing.T) {
+	withCollapseThreshold(t, -2)
+
+	categoryID := newCategory(t)
+	author := newUser(t, "")
+	atThreshold := author.createPost(categoryID)
+	above := author.createPost(categoryID)
+	castVotes(t, fmt.Sprintf("/api/posts/%d/vote", atThreshold), "dislike", 2)
+	castVotes(t, fmt.Sprintf("/api/posts/%d/vote", above), "dislike", 1)
+
+	res, body := author.do(http.MethodGet, fmt.Sprintf("/api/posts?category=%d", categoryID), nil)
+	expectStatus(t, res, body, http.StatusOK)
+	if got := listIDs(t, body); !reflect.DeepEqual(got, []int{above}) {
+		t.Fatalf("listing = %v, want only %d", got, above)
+	}
+
+	res, body = author.do(http.MethodGet, fmt.Sprintf("/api/posts?category=%d&show_collapsed=true&sort=newest", categoryID), nil)
+	expectStatus(t, res, body, http.StatusOK)
+	if got := listIDs(t, body); !reflect.DeepEqual(got, []int{above, atThreshold}) {
+		t.Fatalf("listing with show_collapsed = %v, want %v", got, []int{above, atThreshold})
+	}
+
+	// Collapsed posts stay reachable by ID, flagged
+	for id, want := range map[int]bool{atThreshold: true, above: false} {
+		res, body := newVisitor(t).do(http.MethodGet, fmt.Sprintf("/api/posts/%d", id), nil)
+		expectStatus(t, res, body, http.StatusOK)
+		var post struct {
+			Collapsed bool `json:"collapsed"`
+		}
+		decodeData(t, body, &post)
+		if post.Collapsed != want {
+			t.Fatalf("post %d collapsed = %v, want %v", id, post.Collapsed, want)
+		}
+	}
+}
+
+// timestampFields collects every timestamp in a decoded JSON value by field name
+func timestampFields(v interface{}, found map[string][]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if s, ok := value.(string); ok && (strings.HasSuffix(key, "_at") || key == "editable_until" || key == "last_active") {
+				found[key] = append(found[key], s)
+			}
+			timestampFields(value, found)
+		}
+	case []interface{}:
+		for _, item := range v {
+			timestampFields(item, found)
+		}
+	}
+}
+
+func TestResponseTimestampFormat(t *testing.T) {
+	format := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}Z$`)
+
+	author := newUser(t, "")
+	postID := author.createPost()
+	author.createComment(postID, "A comment with timestamps")
+
+	for _, path := range []string{
+		fmt.Sprintf("/api/posts/%d", postID),
+		fmt.Sprintf("/api/posts/%d/comments", postID),
+		fmt.Sprintf("/api/posts/%d/comments?tree=true", postID),
+		fmt.Sprintf("/api/users/%d", author.User.ID),
+		"/api/auth/me",
+	} {
+		t.Run(path, func(t *testing.T) {
+			res, body := author.do(http.MethodGet, path, nil)
+			expectStatus(t, res, body, http.StatusOK)
+
+			var data interface{}
+			decodeData(t, body, &data)
+			found := make(map[string][]string)
+			timestampFields(data, found)
+			if len(found["created_at"]) == 0 && len(found["joined_at"]) == 0 {
+				t.Fatalf("no created_at or joined_at in %s", body.Data)
+			}
+			for field, values := range found {
+				for _, value := range values {
+					if !format.MatchString(value) {
+						t.Errorf("%s = %q, want e.g. 2024-05-01T12:30:45.123Z", field, value)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestIncludeCategoryDetails(t *testing.T) {
+	first, second := newCategory(t), newCategory(t)
+	author := newUser(t, "")
+	postID := author.createPost(first, second)
+	path := fmt.Sprintf("/api/posts/%d", postID)
+
+	type category struct {
+		ID          int     `json:"id"`
+		Name        string  `json:"name"`
+		Description *string `json:"description"`
+	}
+	type post struct {
+		Categories      []category  `json:"categories"`
+		CategoryDetails *[]category `json:"category_details"`
+	}
+	get := func(query string) post {
+		t.Helper()
+
+		res, body := author.do(http.MethodGet, path+query, nil)
+		expectStatus(t, res, body, http.StatusOK)
+		var p post
+		decodeData(t, body, &p)
+		return p
+	}
+
+	// The brief form stays the default
+	brief := get("")
+	if brief.CategoryDetails != nil || len(brief.Categories) != 2 {
+		t.Fatalf("default response = %+v, want two brief categories and no details", brief)
+	}
+	for _, c := range brief.Categories {
+		if c.Description != nil || c.Name == "" {
+			t.Fatalf("brief category = %+v, want a name and no description", c)
+		}
+	}
+
+	for _, query := range []string{"?include=category_details", "?include=post,%20category_details"} {
+		detailed := get(query)
+		if detailed.CategoryDetails == nil || len(*detailed.CategoryDetails) != 2 {
+			t.Fatalf("%s: category_details = %v, want two", query, detailed.CategoryDetails)
+		}
+		ids := []int{}
+		for _, c := range *detailed.CategoryDetails {
+			if c.Description == nil || *c.Description != "A category for testing" || c.Name == "" {
+				t.Fatalf("%s: category detail = %+v, want its name and description", query, c)
+			}
+			ids = append(ids, c.ID)
+		}
+		sort.Ints(ids)
+		if want := []int{first, second}; !reflect.DeepEqual(ids, want) {
+			t.Fatalf("%s: category_details IDs = %v, want %v", query, ids, want)
+		}
+	}
+
+	// Details come from the query that loads the post
+	without := countQueries(t, func() { get("") })
+	with := countQueries(t, func() { get("?include=category_details") })
+	if with != without {
+		t.Fatalf("category details took %d queries, %d without them", with, without)
+	}
+}
+
+func TestPostWritesReturnFullCategoryBriefs(t *testing.T) {
+	first, second := newCategory(t), newCategory(t)
+	author := newUser(t, "")
+
+	type brief struct {
+		ID    int    `json:"id"`
+		Name  string `json:"name"`
+		Slug  string `json:"slug"`
+		Color string `json:"color"`
+		Icon  string `json:"icon"`
+	}
+	type post struct {
+		ID         int     `json:"id"`
+		Categories []brief `json:"categories"`
+	}
+	// stored is what a fresh read of the post says its categories are, by ID
+	stored := func(postID int) []brief {
+		t.Helper()
+
+		res, body := author.do(http.MethodGet, fmt.Sprintf("/api/posts/%d", postID), nil)
+		expectStatus(t, res, body, http.StatusOK)
+		var p post
+		decodeData(t, body, &p)
+		sort.Slice(p.Categories, func(i, j int) bool { return p.Categories[i].ID < p.Categories[j].ID })
+		return p.Categories
+	}
+	expectFull := func(t *testing.T, got []brief, wantIDs ...int) {
+		t.Helper()
+
+		if len(got) != len(wantIDs) {
+			t.Fatalf("categories = %+v, want %v", got, wantIDs)
+		}
+		for i, c := range got {
+			if c.ID != wantIDs[i] || c.Name == "" || c.Slug == "" {
+				t.Fatalf("category %d = %+v, want %d with its name and slug", i, c, wantIDs[i])
+			}
+		}
+	}
+
+	res, body := author.do(http.MethodPost, "/api/posts", map[string]interface{}{
+		"title":        uniqueName("Test post "),
+		"content":      uniqueName("Some test post content "),
+		"category_ids": []int{first, second},
+	})
+	expectStatus(t, res, body, http.StatusCreated)
+	var created post
+	decodeData(t, body, &created)
+	expectFull(t, created.Categories, first, second)
+	if got := stored(created.ID); !reflect.DeepEqual(created.Categories, got) {
+		t.Fatalf("created with %+v, stored as %+v", created.Categories, got)
+	}
+
+	res, body = author.do(http.MethodPut, fmt.Sprintf("/api/posts/%d", created.ID), map[string]interface{}{
+		"title":        uniqueName("Edited post "),
+		"content":      "Edited test post content",
+		"category_ids": []int{second},
+	})
+	expectStatus(t, res, body, http.StatusOK)
+	var updated post
+	decodeData(t, body, &updated)
+	expectFull(t, updated.Categories, second)
+	if got := stored(created.ID); !reflect.DeepEqual(updated.Categories, got) {
+		t.Fatalf("updated to %+v, stored as %+v", updated.Categories, got)
+	}
+}
+
+func TestPostSortValidation(t *testing.T) {
+	user := newUser(t, "")
+
+	for _, sortBy := range models.PostSorts {
+		t.Run(sortBy, func(t *testing.T) {
+			res, body := user.do(http.MethodGet, "/api/posts?sort="+sortBy, nil)
+			expectStatus(t, res, body, http.StatusOK)
+		})
+	}
+
+	for _, sortBy := range []string{"newst", "Newest", "popular ", "my_post", "newest,oldest"} {
+		t.Run("invalid "+sortBy, func(t *testing.T) {
+			res, body := user.do(http.MethodGet, "/api/posts?sort="+url.QueryEscape(sortBy), nil)
+			expectStatus(t, res, body, http.StatusBadRequest)
+			if !strings.Contains(body.Error, strings.Join(models.PostSorts, ", ")) {
+				t.Fatalf("error = %q, want it to list the valid sorts", body.Error)
+			}
+		})
+	}
+}
+
+func TestDefaultPostSortIsConfigurable(t *testing.T) {
+	categoryID := newCategory(t)
+	author := newUser(t, "")
+	older := author.createPost(categoryID)
+	setCreatedAt(t, "posts", older, time.Now().Add(-time.Hour))
+	newer := author.createPost(categoryID)
+
+	previous := config.AppConfig.DefaultPostSort
+	t.Cleanup(func() { config.AppConfig.DefaultPostSort = previous })
+
+	tests := []struct {
+		configured string
+		want       []int
+	}{
+		{models.PostSortNewest, []int{newer, older}},
+		{models.PostSortOldest, []int{older, newer}},
+		// An unsupported configured default falls back to newest
+		{"bogus", []int{newer, older}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.configured, func(t *testing.T) {
+			config.AppConfig.DefaultPostSort = tt.configured
+			res, body := author.do(http.MethodGet, fmt.Sprintf("/api/posts?category=%d", categoryID), nil)
+			expectStatus(t, res, body, http.StatusOK)
+			if got := listIDs(t, body); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("posts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDuplicatePostsAreRejected(t *testing.T) {
+	author := newUser(t, "")
+	title := uniqueName("A post made twice ")
+	content := "The same content both times"
+
+	create := func(c *testClient, title, content string) (*http.Response, apiResponse) {
+		return c.do(http.MethodPost, "/api/posts", map[string]interface{}{
+			"title":        title,
+			"content":      content,
+			"category_ids": []int{1},
+		})
+	}
+
+	res, body := create(author, title, content)
+	expectStatus(t, res, body, http.StatusCreated)
+	var original struct {
+		ID int `json:"id"`
+	}
+	decodeData(t, body, &original)
+
+	t.Run("near-duplicates are rejected", func(t *testing.T) {
+		for _, repeat := range []string{title, strings.ToUpper(title)} {
+			res, body := create(author, repeat, content)
+			expectStatus(t, res, body, http.StatusConflict)
+			if body.Code != "duplicate_post" {
+				t.Errorf("code = %q, want duplicate_post", body.Code)
+			}
+
+			var existing struct {
+				PostID int    `json:"post_id"`
+				URL    string `json:"url"`
+			}
+			decodeData(t, body, &existing)
+			if existing.PostID != original.ID || existing.URL != fmt.Sprintf("/api/posts/%d", original.ID) {
+				t.Errorf("conflict data = %+v, want post %d", existing, original.ID)
+			}
+		}
+	})
+
+	t.Run("different posts succeed", func(t *testing.T) {
+		res, body := create(author, title, content+" and then some")
+		expectStatus(t, res, body, http.StatusCreated)
+
+		res, body = create(author, title+" again", content)
+		expectStatus(t, res, body, http.StatusCreated)
+
+		res, body = create(newUser(t, ""), title, content)
+		expectStatus(t, res, body, http.StatusCreated)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		previous := config.AppConfig.DuplicatePostCheck
+		config.AppConfig.DuplicatePostCheck = false
+		t.Cleanup(func() { config.AppConfig.DuplicatePostCheck = previous })
+
+		res, body := create(author, title, content)
+		expectStatus(t, res, body, http.StatusCreated)
+	})
+
+	t.Run("outside the window", func(t *testing.T) {
+		user := newUser(t, "")
+		res, body := create(user, title, content)
+		expectStatus(t, res, body, http.StatusCreated)
+		var post struct {
+			ID int `json:"id"`
+		}
+		decodeData(t, body, &post)
+
+		setCreatedAt(t, "posts", post.ID, time.Now().Add(-config.GetDuplicatePostWindow()-time.Minute))
+		res, body = create(user, title, content)
+		expectStatus(t, res, body, http.StatusCreated)
+	})
+}
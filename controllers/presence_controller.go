@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"forum/models"
+	"forum/presence"
+	"forum/utils"
+
+	"github.com/google/uuid"
+)
+
+// presencePollTimeout is the longest a GET /api/posts/{id}/presence?known=N request is held open
+const presencePollTimeout = 25 * time.Second
+
+// PresenceRequest is the optional body of a presence heartbeat
+type PresenceRequest struct {
+	ViewerID string `json:"viewer_id"` // omit on the first heartbeat to be issued one
+}
+
+// PresenceResponse reports how many clients have a post open
+type PresenceResponse struct {
+	PostID           int    `json:"post_id"`
+	Viewers          int    `json:"viewers"`
+	ViewerID         string `json:"viewer_id,omitempty"`
+	HeartbeatSeconds int    `json:"heartbeat_seconds,omitempty"`
+}
+
+// GetPostPresenceController handles GET /api/posts/{id}/presence
+// With ?known=N it long-polls: the response is held until the viewer count is no
+// longer N, or for at most presencePollTimeout.
+func GetPostPresenceController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	postID, err := getPostIDFromPath(r.URL.Path)
+	if err != nil {
+		utils.BadRequest(w, "Invalid post ID")
+		return
+	}
+
+	if exists, err := models.PostExists(postID); err != nil {
+		utils.InternalServerError(w, "Failed to retrieve post")
+		return
+	} else if !exists {
+		utils.NotFound(w, "Post not found")
+		return
+	}
+
+	count := presence.Count(postID)
+	if raw := r.URL.Query().Get("known"); raw != "" {
+		known, err := strconv.Atoi(raw)
+		if err != nil || known < 0 {
+			utils.BadRequest(w, "known must be a non-negative number")
+			return
+		}
+		count = presence.Wait(r.Context(), postID, known, presencePollTimeout)
+	}
+
+	utils.Success(w, "Presence retrieved successfully", PresenceResponse{PostID: postID, Viewers: count})
+}
+
+// JoinPostPresenceController handles POST /api/posts/{id}/presence
+// Clients call it when they open a post and then every HeartbeatInterval while
+// it stays open. Reusing the issued viewer_id keeps a reconnecting client from
+// being counted twice; clients that stop sending heartbeats drop out after ViewerTTL.
+func JoinPostPresenceController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w, "Only POST method allowed")
+		return
+	}
+
+	postID, err := getPostIDFromPath(r.URL.Path)
+	if err != nil {
+		utils.BadRequest(w, "Invalid post ID")
+		return
+	}
+
+	var req PresenceRequest
+	if r.ContentLength != 0 {
+		if err := utils.ParseJSON(r, &req); err != nil {
+			utils.BadRequest(w, "Invalid JSON format")
+			return
+		}
+	}
+
+	if req.ViewerID == "" {
+		req.ViewerID = uuid.New().String()
+	} else if _, err := uuid.Parse(req.ViewerID); err != nil {
+		utils.BadRequest(w, "Invalid viewer ID")
+		return
+	}
+
+	if exists, err := models.PostExists(postID); err != nil {
+		utils.InternalServerError(w, "Failed to retrieve post")
+		return
+	} else if !exists {
+		utils.NotFound(w, "Post not found")
+		return
+	}
+
+	utils.Success(w, "Presence recorded", PresenceResponse{
+		PostID:           postID,
+		Viewers:          presence.Join(postID, req.ViewerID),
+		ViewerID:         req.ViewerID,
+		HeartbeatSeconds: int(presence.HeartbeatInterval.Seconds()),
+	})
+}
+
+// LeavePostPresenceController handles DELETE /api/posts/{id}/presence?viewer_id=...
+func LeavePostPresenceController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.MethodNotAllowed(w, "Only DELETE method allowed")
+		return
+	}
+
+	postID, err := getPostIDFromPath(r.URL.Path)
+	if err != nil {
+		utils.BadRequest(w, "Invalid post ID")
+		return
+	}
+
+	viewerID := r.URL.Query().Get("viewer_id")
+	if _, err := uuid.Parse(viewerID); err != nil {
+		utils.BadRequest(w, "Invalid viewer ID")
+		return
+	}
+
+	utils.Success(w, "Presence removed", PresenceResponse{PostID: postID, Viewers: presence.Leave(postID, viewerID)})
+}
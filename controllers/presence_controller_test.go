@@ -0,0 +1,124 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// presence is the data of a presence response
+type presence struct {
+	Viewers  int    `json:"viewers"`
+	ViewerID string `json:"viewer_id"`
+}
+
+// joinPresence sends a presence heartbeat for a post, reusing viewerID when it is set
+func joinPresence(t *testing.T, c *testClient, postID int, viewerID string) presence {
+	t.Helper()
+
+	var payload interface{}
+	if viewerID != "" {
+		payload = map[string]string{"viewer_id": viewerID}
+	}
+	res, body := c.do(http.MethodPost, fmt.Sprintf("/api/posts/%d/presence", postID), payload)
+	expectStatus(t, res, body, http.StatusOK)
+
+	var p presence
+	decodeData(t, body, &p)
+	return p
+}
+
+// viewerCount reads the number of viewers of a post
+func viewerCount(t *testing.T, c *testClient, query string) int {
+	t.Helper()
+
+	res, body := c.do(http.MethodGet, query, nil)
+	expectStatus(t, res, body, http.StatusOK)
+
+	var p presence
+	decodeData(t, body, &p)
+	return p.Viewers
+}
+
+func TestPostPresence(t *testing.T) {
+	author := newUser(t, "")
+	visitor := newVisitor(t)
+	postID := author.createPost()
+	path := fmt.Sprintf("/api/posts/%d/presence", postID)
+
+	if got := viewerCount(t, visitor, path); got != 0 {
+		t.Fatalf("viewers before anyone opened the post = %d, want 0", got)
+	}
+
+	first := joinPresence(t, visitor, postID, "")
+	if first.ViewerID == "" || first.Viewers != 1 {
+		t.Fatalf("first join = %+v, want a viewer ID and 1 viewer", first)
+	}
+	second := joinPresence(t, author, postID, "")
+	if second.Viewers != 2 {
+		t.Errorf("second join = %d viewers, want 2", second.Viewers)
+	}
+
+	// Reconnecting with the issued viewer ID doesn't count twice
+	if again := joinPresence(t, visitor, postID, first.ViewerID); again.Viewers != 2 || again.ViewerID != first.ViewerID {
+		t.Errorf("reconnect = %+v, want 2 viewers and viewer ID %s", again, first.ViewerID)
+	}
+	if got := viewerCount(t, visitor, path); got != 2 {
+		t.Errorf("viewers = %d, want 2", got)
+	}
+
+	// A long-poll waiting on the current count returns once someone leaves
+	polled := make(chan apiResponse, 1)
+	go func() {
+		_, body := visitor.do(http.MethodGet, path+"?known=2", nil)
+		polled <- body
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	res, body := visitor.do(http.MethodDelete, path+"?viewer_id="+first.ViewerID, nil)
+	expectStatus(t, res, body, http.StatusOK)
+	select {
+	case body := <-polled:
+		var p presence
+		decodeData(t, body, &p)
+		if p.Viewers != 1 {
+			t.Errorf("long-poll returned %d viewers, want 1", p.Viewers)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("long-poll did not return after a viewer left")
+	}
+
+	res, body = author.do(http.MethodDelete, path+"?viewer_id="+second.ViewerID, nil)
+	expectStatus(t, res, body, http.StatusOK)
+	if got := viewerCount(t, visitor, path); got != 0 {
+		t.Errorf("viewers after everyone left = %d, want 0", got)
+	}
+}
+
+func TestPostPresenceValidation(t *testing.T) {
+	visitor := newVisitor(t)
+	postID := newUser(t, "").createPost()
+	path := fmt.Sprintf("/api/posts/%d/presence", postID)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		want   int
+	}{
+		{"unknown post", http.MethodGet, "/api/posts/999999/presence", nil, http.StatusNotFound},
+		{"join unknown post", http.MethodPost, "/api/posts/999999/presence", nil, http.StatusNotFound},
+		{"negative known", http.MethodGet, path + "?known=-1", nil, http.StatusBadRequest},
+		{"bad viewer ID on join", http.MethodPost, path, map[string]string{"viewer_id": "not-a-uuid"}, http.StatusBadRequest},
+		{"bad viewer ID on leave", http.MethodDelete, path + "?viewer_id=not-a-uuid", nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, body := visitor.do(tt.method, tt.path, tt.body)
+			expectStatus(t, res, body, tt.want)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"forum/middleware"
+	"forum/models"
+	"forum/utils"
+)
+
+// ReactionRequest represents the JSON structure for adding a reaction
+type ReactionRequest struct {
+	Emoji string `json:"emoji"`
+}
+
+// AddCommentReactionController handles POST /api/comments/{id}/reactions
+func AddCommentReactionController(w http.ResponseWriter, r *http.Request) {
+	handleReaction(w, r, models.TargetComment, true)
+}
+
+// RemoveCommentReactionController handles DELETE /api/comments/{id}/reactions?emoji=...
+func RemoveCommentReactionController(w http.ResponseWriter, r *http.Request) {
+	handleReaction(w, r, models.TargetComment, false)
+}
+
+// AddPostReactionController handles POST /api/posts/{id}/reactions
+func AddPostReactionController(w http.ResponseWriter, r *http.Request) {
+	handleReaction(w, r, models.TargetPost, true)
+}
+
+// RemovePostReactionController handles DELETE /api/posts/{id}/reactions?emoji=...
+func RemovePostReactionController(w http.ResponseWriter, r *http.Request) {
+	handleReaction(w, r, models.TargetPost, false)
+}
+
+// handleReaction adds or removes the current user's reaction on a post or comment
+// and responds with the target's updated reaction summary
+func handleReaction(w http.ResponseWriter, r *http.Request, targetType string, add bool) {
+	if add && r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w, "Only POST method allowed")
+		return
+	}
+	if !add && r.Method != http.MethodDelete {
+		utils.MethodNotAllowed(w, "Only DELETE method allowed")
+		return
+	}
+
+	// Get authenticated user
+	userID, exists := middleware.GetUserIDFromContext(r)
+	if !exists {
+		utils.Unauthorized(w, "Authentication required")
+		return
+	}
+
+	// Resolve and check the target
+	var targetID int
+	var err error
+	if targetType == models.TargetComment {
+		targetID, err = getCommentIDFromPath(r.URL.Path)
+		if err != nil {
+			utils.BadRequest(w, "Invalid comment ID")
+			return
+		}
+
+		if exists, err := models.CommentExists(targetID); err != nil {
+			utils.InternalServerError(w, "Failed to retrieve comment")
+			return
+		} else if !exists {
+			utils.NotFound(w, "Comment not found")
+			return
+		}
+	} else {
+		targetID, err = getPostIDFromPath(r.URL.Path)
+		if err != nil {
+			utils.BadRequest(w, "Invalid post ID")
+			return
+		}
+
+		if exists, err := models.PostExists(targetID); err != nil {
+			utils.InternalServerError(w, "Failed to retrieve post")
+			return
+		} else if !exists {
+			utils.NotFound(w, "Post not found")
+			return
+		}
+	}
+
+	// The emoji comes from the body when adding and the query string when removing
+	var emoji string
+	if add {
+		var req ReactionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.BadRequest(w, "Invalid JSON format")
+			return
+		}
+		emoji = req.Emoji
+	} else {
+		emoji = r.URL.Query().Get("emoji")
+	}
+
+	emoji = strings.TrimSpace(emoji)
+	if !models.IsValidReaction(emoji) {
+		var validationErrors utils.ValidationErrors
+		validationErrors.Add("emoji", "Emoji must be one of: "+strings.Join(models.AllowedReactions, " "))
+		utils.ValidationError(w, validationErrors)
+		return
+	}
+
+	added := false
+	if add {
+		added, err = models.AddReaction(userID, targetType, targetID, emoji)
+	} else {
+		err = models.RemoveReaction(userID, targetType, targetID, emoji)
+	}
+	if err != nil {
+		utils.InternalServerError(w, "Failed to update reaction")
+		return
+	}
+
+	summary, err := models.GetReactionSummary(targetType, targetID, &userID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve reactions")
+		return
+	}
+
+	if added {
+		utils.Created(w, "Reaction added successfully", summary)
+		return
+	}
+	if add {
+		utils.Success(w, "Reaction already added", summary)
+		return
+	}
+	utils.Success(w, "Reaction removed successfully", summary)
+}
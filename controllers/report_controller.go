@@ -0,0 +1,374 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/middleware"
+	"forum/models"
+	"forum/utils"
+)
+
+// ReportRequest represents the JSON structure for reporting content
+type ReportRequest struct {
+	Reason  string `json:"reason"`
+	Details string `json:"details"`
+}
+
+// ResolveReportRequest represents the JSON structure for resolving a report
+type ResolveReportRequest struct {
+	Action        string `json:"action"` // "dismiss", "delete_comment" or "ban_author"
+	Reason        string `json:"reason"`
+	DurationHours int    `json:"duration_hours"` // ban length for "ban_author", 0 for permanent
+}
+
+// ReportQueueItem is a report enriched with a preview of the reported content
+type ReportQueueItem struct {
+	models.Report
+	Target interface{} `json:"target"`
+}
+
+// maxReportDetailsLength caps the free-text part of a report
+const maxReportDetailsLength = 500
+
+// ReportCommentController handles POST /api/comments/{id}/report
+func ReportCommentController(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w, "Only POST method allowed")
+		return
+	}
+
+	// Get authenticated user
+	userID, exists := middleware.GetUserIDFromContext(r)
+	if !exists {
+		utils.Unauthorized(w, "Authentication required")
+		return
+	}
+
+	// Get comment ID from URL path
+	commentID, err := getCommentIDFromPath(r.URL.Path)
+	if err != nil {
+		utils.BadRequest(w, "Invalid comment ID")
+		return
+	}
+
+	// Parse JSON request body
+	var req ReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid JSON format")
+		return
+	}
+
+	req.Details = strings.TrimSpace(utils.SanitizeString(req.Details))
+
+	var validationErrors utils.ValidationErrors
+	if !models.IsValidReportReason(req.Reason) {
+		validationErrors.Add("reason", "Reason must be one of: spam, harassment, off_topic, inappropriate, other")
+	}
+	if req.Reason == models.ReportReasonOther && req.Details == "" {
+		validationErrors.Add("details", "Please describe the problem")
+	}
+	if len(req.Details) > maxReportDetailsLength {
+		validationErrors.Add("details", "Details cannot exceed 500 characters")
+	}
+	if validationErrors.HasErrors() {
+		utils.ValidationError(w, validationErrors)
+		return
+	}
+
+	// Check if comment exists
+	comment := models.Comment{}
+	if err := comment.GetByID(commentID, nil); err != nil {
+		utils.NotFound(w, "Comment not found")
+		return
+	}
+
+	if comment.UserID == userID {
+		utils.BadRequest(w, "You cannot report your own comment")
+		return
+	}
+
+	// Rate limit reports per user per hour
+	recent, err := models.CountRecentReportsByUser(userID, time.Now().Add(-time.Hour))
+	if err != nil {
+		utils.InternalServerError(w, "Failed to submit report")
+		return
+	}
+	if recent >= models.ReportsPerHourLimit() {
+		utils.TooManyRequests(w, "You have submitted too many reports. Please try again later.")
+		return
+	}
+
+	report := models.Report{
+		ReporterID: userID,
+		TargetType: models.TargetComment,
+		TargetID:   comment.ID,
+		Reason:     req.Reason,
+		Details:    req.Details,
+	}
+	if err := report.Create(); err != nil {
+		if errors.Is(err, models.ErrAlreadyReported) {
+			utils.Conflict(w, err.Error())
+			return
+		}
+		utils.InternalServerError(w, "Failed to submit report")
+		return
+	}
+
+	// Hide the comment pending review once enough distinct users reported it
+	if !comment.Hidden {
+		count, err := models.CountPendingReports(models.TargetComment, comment.ID)
+		if err == nil && count >= models.ReportAutoHideThreshold() {
+			if err := comment.SetHidden(true); err != nil {
+				log.Printf("Failed to hide reported comment %d: %v", comment.ID, err)
+			}
+		}
+	}
+
+	utils.Created(w, "Report submitted successfully", map[string]interface{}{
+		"id":     report.ID,
+		"status": report.Status,
+	})
+}
+
+// GetReportsController handles GET /api/admin/reports (moderators only)
+// Query parameters: status (default pending, "all" for every status), target_type, page, limit
+func GetReportsController(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET requests
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	query := r.URL.Query()
+
+	status := query.Get("status")
+	switch status {
+	case "":
+		status = models.ReportStatusPending
+	case "all":
+		status = ""
+	case models.ReportStatusPending, models.ReportStatusDismissed, models.ReportStatusActioned:
+	default:
+		utils.BadRequest(w, "Status must be one of: pending, dismissed, actioned, all")
+		return
+	}
+
+	targetType := query.Get("target_type")
+	if targetType != "" && targetType != models.TargetPost && targetType != models.TargetComment {
+		utils.BadRequest(w, "Target type must be 'post' or 'comment'")
+		return
+	}
+
+	// Parse pagination parameters
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+
+	limit, offset, err := utils.ValidatePagination(page, limit)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+
+	reports, total, err := models.GetReports(status, targetType, limit, offset)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve reports")
+		return
+	}
+
+	items := make([]ReportQueueItem, 0, len(reports))
+	for _, report := range reports {
+		items = append(items, ReportQueueItem{
+			Report: report,
+			Target: getReportTarget(&report),
+		})
+	}
+
+	pagination := map[string]interface{}{
+		"current_page": page,
+		"per_page":     limit,
+		"total":        total,
+		"total_pages":  (total + limit - 1) / limit,
+		"has_next":     page < (total+limit-1)/limit,
+		"has_prev":     page > 1,
+	}
+
+	utils.PaginatedSuccess(w, "Reports retrieved successfully", items, pagination)
+}
+
+// ResolveReportController handles POST /api/admin/reports/{id}/resolve (moderators only)
+// Resolving a report closes every pending report on the same content.
+func ResolveReportController(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST requests
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w, "Only POST method allowed")
+		return
+	}
+
+	moderatorID, _ := middleware.GetUserIDFromContext(r)
+
+	reportID, err := getReportIDFromPath(r.URL.Path)
+	if err != nil {
+		utils.BadRequest(w, "Invalid report ID")
+		return
+	}
+
+	var req ResolveReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid JSON format")
+		return
+	}
+	req.Reason = strings.TrimSpace(utils.SanitizeString(req.Reason))
+
+	report := models.Report{}
+	if err := report.GetByID(reportID); err != nil {
+		utils.NotFound(w, "Report not found")
+		return
+	}
+	if report.Status != models.ReportStatusPending {
+		utils.Conflict(w, "Report has already been resolved")
+		return
+	}
+
+	status := models.ReportStatusActioned
+	switch req.Action {
+	case models.ReportResolutionDismiss:
+		status = models.ReportStatusDismissed
+
+		// Content reviewed and kept: make it visible again
+		if report.TargetType == models.TargetComment {
+			comment := models.Comment{ID: report.TargetID}
+			if err := comment.SetHidden(false); err != nil {
+				utils.InternalServerError(w, "Failed to restore comment")
+				return
+			}
+		}
+
+	case models.ReportResolutionDeleteComment:
+		if report.TargetType != models.TargetComment {
+			utils.BadRequest(w, "This action only applies to comment reports")
+			return
+		}
+
+		comment := models.Comment{}
+		if err := comment.GetByID(report.TargetID, nil); err == nil {
+			if err := comment.RemoveByModerator(moderatorID, req.Reason); err != nil {
+				utils.InternalServerError(w, "Failed to delete comment")
+				return
+			}
+		}
+
+	case models.ReportResolutionBanAuthor:
+		author, err := getReportAuthor(&report)
+		if err != nil {
+			utils.NotFound(w, "Reported content no longer exists")
+			return
+		}
+		if message := checkCanBan(r, moderatorID, author); message != "" {
+			utils.Forbidden(w, message)
+			return
+		}
+
+		ban := BanRequest{Reason: req.Reason, DurationHours: req.DurationHours}
+		if errs := validateBanRequest(ban); len(errs) > 0 {
+			utils.ValidationError(w, errs)
+			return
+		}
+		if err := author.Ban(banExpiry(ban.DurationHours), ban.Reason); err != nil {
+			utils.InternalServerError(w, "Failed to ban user")
+			return
+		}
+		if err := models.LogModerationAction(moderatorID, models.ModActionBanUser, models.TargetUser, author.ID, ban.Reason); err != nil {
+			log.Printf("Failed to write moderation log: %v", err)
+		}
+
+	default:
+		utils.BadRequest(w, "Action must be 'dismiss', 'delete_comment' or 'ban_author'")
+		return
+	}
+
+	resolved, err := models.ResolveReports(report.TargetType, report.TargetID, status, req.Action, moderatorID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to resolve report")
+		return
+	}
+
+	if err := models.LogModerationAction(moderatorID, models.ModActionResolveReport, report.TargetType, report.TargetID, req.Action); err != nil {
+		log.Printf("Failed to write moderation log: %v", err)
+	}
+
+	utils.Success(w, "Report resolved successfully", map[string]interface{}{
+		"action":           req.Action,
+		"status":           status,
+		"reports_resolved": resolved,
+	})
+}
+
+// getReportAuthor loads the author of the reported post or comment
+func getReportAuthor(report *models.Report) (*models.User, error) {
+	var authorID int
+	if report.TargetType == models.TargetComment {
+		comment := models.Comment{}
+		if err := comment.GetByID(report.TargetID, nil); err != nil {
+			return nil, err
+		}
+		authorID = comment.UserID
+	} else {
+		post := models.Post{}
+		if err := post.GetByID(report.TargetID, nil); err != nil {
+			return nil, err
+		}
+		authorID = post.UserID
+	}
+
+	author := &models.User{}
+	if err := author.GetByID(authorID); err != nil {
+		return nil, err
+	}
+	return author, nil
+}
+
+// getReportTarget returns a preview of the reported content, or nil if it no longer exists
+func getReportTarget(report *models.Report) interface{} {
+	if report.TargetType != models.TargetComment {
+		return nil
+	}
+
+	comment := models.Comment{}
+	if err := comment.GetByID(report.TargetID, nil); err != nil {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"id":              comment.ID,
+		"post_id":         comment.PostID,
+		"content":         comment.Content,
+		"author_id":       comment.UserID,
+		"author_username": comment.Username,
+		"hidden":          comment.Hidden,
+		"created_at":      comment.CreatedAt,
+	}
+}
+
+// getReportIDFromPath extracts report ID from URL path like /api/admin/reports/123/resolve
+func getReportIDFromPath(path string) (int, error) {
+	path = strings.TrimPrefix(path, "/api/admin/reports/")
+	parts := strings.Split(path, "/")
+	if len(parts) == 0 {
+		return 0, errors.New("invalid path format")
+	}
+	return strconv.Atoi(parts[0])
+}
@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"forum/models"
+	"forum/utils"
+)
+
+// Search types supported by GET /api/search
+const (
+	SearchTypeComments = "comments"
+)
+
+// SearchController handles GET /api/search?q=...&type=comments
+func SearchController(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET requests
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	searchType := r.URL.Query().Get("type")
+	if searchType == "" {
+		searchType = SearchTypeComments
+	}
+	if searchType != SearchTypeComments {
+		utils.BadRequest(w, "Search type must be 'comments'")
+		return
+	}
+
+	searchComments(w, r, nil)
+}
+
+// SearchPostCommentsController handles GET /api/posts/{id}/comments/search?q=...
+func SearchPostCommentsController(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET requests
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	postID, err := getPostIDFromCommentsPath(r.URL.Path)
+	if err != nil {
+		utils.BadRequest(w, "Invalid post ID")
+		return
+	}
+
+	// Validate post exists
+	if exists, err := models.PostExists(postID); err != nil {
+		utils.InternalServerError(w, "Failed to retrieve post")
+		return
+	} else if !exists {
+		utils.NotFound(w, "Post not found")
+		return
+	}
+
+	searchComments(w, r, &postID)
+}
+
+// searchComments runs a comment search and writes paginated results.
+// Each result carries the page it is on in its post's comment listing
+// (default page size, ordered by the optional sort parameter) for deep-linking.
+// The optional author parameter (ID or username) respects the author's privacy settings.
+func searchComments(w http.ResponseWriter, r *http.Request, postID *int) {
+	query := r.URL.Query()
+
+	q := strings.TrimSpace(utils.SanitizeString(query.Get("q")))
+	if err := utils.ValidateSearchQuery(q); err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+
+	sortBy := query.Get("sort")
+	if sortBy == "" {
+		sortBy = models.CommentSortOldest
+	}
+	if !models.IsValidCommentSort(sortBy) {
+		utils.BadRequest(w, "Sort must be one of: oldest, newest, best")
+		return
+	}
+
+	// Parse pagination parameters
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+
+	limit, offset, err := utils.ValidatePagination(page, limit)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+
+	authorID, authorFound, err := resolveAuthorParam(query.Get("author"))
+	if err != nil {
+		utils.InternalServerError(w, "Failed to resolve author")
+		return
+	}
+	if authorFound && authorID > 0 {
+		privacy, err := privacyFor(r, authorID)
+		if err != nil && err != sql.ErrNoRows {
+			utils.InternalServerError(w, "Failed to retrieve privacy settings")
+			return
+		}
+		if privacy != nil && privacy.HideComments {
+			utils.Forbidden(w, "This user's comments are private")
+			return
+		}
+	}
+
+	results := []models.CommentSearchResult{}
+	total := 0
+	if authorFound {
+		results, total, err = models.SearchComments(q, postID, authorID, limit, offset)
+		if err != nil {
+			utils.InternalServerError(w, "Failed to search comments")
+			return
+		}
+	}
+
+	ids := make([]int, len(results))
+	for i := range results {
+		ids[i] = results[i].ID
+	}
+	positions, err := models.GetCommentPositions(ids, sortBy)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to locate comments")
+		return
+	}
+
+	items := make([]map[string]interface{}, 0, len(results))
+	for i := range results {
+		position := positions[results[i].ID]
+		items = append(items, map[string]interface{}{
+			"comment":  results[i],
+			"position": position,
+			"page":     (position-1)/defaultCommentsPerPage + 1,
+		})
+	}
+
+	pagination := map[string]interface{}{
+		"current_page": page,
+		"per_page":     limit,
+		"total":        total,
+		"total_pages":  (total + limit - 1) / limit,
+		"has_next":     page < (total+limit-1)/limit,
+		"has_prev":     page > 1,
+	}
+
+	utils.PaginatedSuccess(w, "Search results retrieved successfully", items, pagination)
+}
@@ -2,35 +2,67 @@ package controllers
 
 import (
 	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"forum/database"
+	"forum/middleware"
 	"forum/models"
 	"forum/utils"
 )
 
 // UserProfile represents the public user profile data
 type UserProfile struct {
-	ID           int       `json:"id"`
-	Username     string    `json:"username"`
-	Email        string    `json:"email,omitempty"`
-	Avatar       string    `json:"avatar"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	PostCount    int       `json:"post_count"`
-	CommentCount int       `json:"comment_count"`
+	ID           int             `json:"id"`
+	Username     string          `json:"username"`
+	Email        string          `json:"email,omitempty"`
+	Avatar       string          `json:"avatar"`
+	CreatedAt    utils.JSONTime  `json:"created_at"`
+	UpdatedAt    utils.JSONTime  `json:"updated_at"`
+	PostCount    *int            `json:"post_count,omitempty"`    // omitted when the user hides their stats
+	CommentCount *int            `json:"comment_count,omitempty"` // omitted when the user hides their stats
+	LastActive   *utils.JSONTime `json:"last_active"`
+	IsOnline     bool            `json:"is_online"`
+	NewMember    bool            `json:"new_member"` // joined within the new member period
 }
 
 // UserStats represents detailed user statistics
 type UserStats struct {
 	UserProfile
-	TotalPostLikes    int `json:"total_post_likes"`
-	TotalCommentLikes int `json:"total_comment_likes"`
-	AccountAge        int `json:"account_age_days"`
+	TotalPostLikes       int `json:"total_post_likes"`       // likes received on the user's posts
+	TotalPostDislikes    int `json:"total_post_dislikes"`    // dislikes received on the user's posts
+	TotalCommentLikes    int `json:"total_comment_likes"`    // likes received on the user's visible comments
+	TotalCommentDislikes int `json:"total_comment_dislikes"` // dislikes received on the user's visible comments
+	Reputation           int `json:"reputation"`             // all likes minus all dislikes received, can be negative
+	AccountAge           int `json:"account_age_days"`
+}
+
+// ReceivedVotes counts the votes other users cast on someone's content
+type ReceivedVotes struct {
+	Likes    int `json:"likes"`
+	Dislikes int `json:"dislikes"`
+	Score    int `json:"score"` // likes minus dislikes, can be negative
+}
+
+// ReputationBreakdown splits the votes a user received between posts and comments
+type ReputationBreakdown struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	Posts    struct {
+		Count int `json:"count"`
+		ReceivedVotes
+	} `json:"posts"`
+	Comments struct {
+		Count int `json:"count"`
+		ReceivedVotes
+	} `json:"comments"`
+	Total ReceivedVotes `json:"total"`
 }
 
 // UserUpdateRequest represents the request body for updating user profile
@@ -64,28 +96,40 @@ func GetUserProfileController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user stats
-	postCount, err := getUserPostCount(userID)
+	privacy, err := privacyFor(r, userID)
 	if err != nil {
-		utils.InternalServerError(w, "Failed to get user stats")
-		return
-	}
-
-	commentCount, err := getUserCommentCount(userID)
-	if err != nil {
-		utils.InternalServerError(w, "Failed to get user stats")
+		utils.InternalServerError(w, "Failed to get user")
 		return
 	}
 
 	// Create public profile response
 	profile := UserProfile{
-		ID:           user.ID,
-		Username:     user.Username,
-		Avatar:       user.GetAvatarURL(),
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
-		PostCount:    postCount,
-		CommentCount: commentCount,
+		ID:         user.ID,
+		Username:   user.Username,
+		Avatar:     user.GetAvatarURL(),
+		CreatedAt:  utils.NewJSONTime(user.CreatedAt),
+		UpdatedAt:  utils.NewJSONTime(user.UpdatedAt),
+		LastActive: utils.NewJSONTimePtr(user.LastActive),
+		IsOnline:   user.IsOnline(),
+		NewMember:  user.IsNewMember(),
+	}
+
+	// Get user stats, unless the user keeps them private
+	if !privacy.HideStats {
+		postCount, err := getUserPostCount(userID)
+		if err != nil {
+			utils.InternalServerError(w, "Failed to get user stats")
+			return
+		}
+
+		commentCount, err := getUserCommentCount(userID)
+		if err != nil {
+			utils.InternalServerError(w, "Failed to get user stats")
+			return
+		}
+
+		profile.PostCount = &postCount
+		profile.CommentCount = &commentCount
 	}
 
 	// Add email only for profile owner
@@ -124,6 +168,15 @@ func UpdateUserProfileController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// New avatars go through the upload endpoint, which owns the files; taking any
+	// URL here would let a user point at someone else's file and get it deleted
+	if updateReq.Avatar != "" && updateReq.Avatar != currentUser.Avatar {
+		var validationErrors utils.ValidationErrors
+		validationErrors.Add("avatar", "Upload a new avatar with POST /api/users/{id}/avatar")
+		utils.ValidationError(w, validationErrors)
+		return
+	}
+
 	// Update fields if provided
 	updated := false
 
@@ -134,6 +187,14 @@ func UpdateUserProfileController(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// Usernames can only change once per cooldown period
+		if next := currentUser.NextUsernameChangeAt(); time.Now().Before(next) {
+			utils.Throttled(w, "username_change_cooldown",
+				"You can change your username again on "+next.UTC().Format("January 2, 2006"),
+				int(time.Until(next).Seconds())+1)
+			return
+		}
+
 		// Check if username is already taken
 		var existingUser models.User
 		err := existingUser.GetByUsername(updateReq.Username)
@@ -158,6 +219,12 @@ func UpdateUserProfileController(w http.ResponseWriter, r *http.Request) {
 			utils.BadRequest(w, "Invalid email: "+err.Error())
 			return
 		}
+		if err := utils.ValidateEmailDomain(updateReq.Email); err != nil {
+			var validationErrors utils.ValidationErrors
+			validationErrors.Add("email", err.Error())
+			utils.ValidationError(w, validationErrors)
+			return
+		}
 
 		// Check if email is already taken
 		var existingUser models.User
@@ -177,14 +244,8 @@ func UpdateUserProfileController(w http.ResponseWriter, r *http.Request) {
 		updated = true
 	}
 
-	// Update avatar if provided
+	// Sending back the current avatar is accepted and leaves it as it is
 	if updateReq.Avatar != "" {
-		err = currentUser.UpdateAvatar(updateReq.Avatar)
-		if err != nil {
-			utils.InternalServerError(w, "Failed to update avatar")
-			return
-		}
-
 		updated = true
 	}
 
@@ -203,10 +264,11 @@ func UpdateUserProfileController(w http.ResponseWriter, r *http.Request) {
 		Username:     currentUser.Username,
 		Email:        currentUser.Email,
 		Avatar:       currentUser.GetAvatarURL(),
-		CreatedAt:    currentUser.CreatedAt,
-		UpdatedAt:    currentUser.UpdatedAt,
-		PostCount:    postCount,
-		CommentCount: commentCount,
+		CreatedAt:    utils.NewJSONTime(currentUser.CreatedAt),
+		UpdatedAt:    utils.NewJSONTime(currentUser.UpdatedAt),
+		PostCount:    &postCount,
+		CommentCount: &commentCount,
+		NewMember:    currentUser.IsNewMember(),
 	}
 
 	utils.Success(w, "Profile updated successfully", profile)
@@ -240,17 +302,10 @@ func UploadAvatarController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete old avatar file if it exists and is not default
-	if currentUser.Avatar != "" && !strings.Contains(currentUser.Avatar, "default.png") {
-		oldFilename := utils.ExtractFilenameFromURL(currentUser.Avatar)
-		if oldFilename != "" {
-			oldFilePath := utils.GetAvatarFilePath(oldFilename)
-			utils.DeleteFile(oldFilePath)
-		}
-	}
-
-	// Update user avatar in database
-	err = currentUser.UpdateAvatar(uploadResult.URL)
+	// Point the user at the new file before touching the old one, so a failed
+	// update leaves the previous avatar in place. The old avatar comes from the
+	// update itself: with uploads racing, currentUser may already be stale.
+	oldAvatar, err := currentUser.UpdateAvatar(uploadResult.URL)
 	if err != nil {
 		// If database update fails, remove uploaded file
 		utils.DeleteFile(utils.GetAvatarFilePath(uploadResult.Filename))
@@ -258,6 +313,9 @@ func UploadAvatarController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The old file is no longer referenced
+	deleteAvatarFile(oldAvatar)
+
 	// Return upload result
 	response := map[string]interface{}{
 		"avatar_url": uploadResult.URL,
@@ -267,6 +325,16 @@ func UploadAvatarController(w http.ResponseWriter, r *http.Request) {
 	utils.Success(w, "Avatar uploaded successfully", response)
 }
 
+// deleteAvatarFile removes an uploaded avatar file; the default avatar is left alone
+func deleteAvatarFile(avatarURL string) {
+	if avatarURL == "" || strings.Contains(avatarURL, "default.png") {
+		return
+	}
+	if filename := utils.ExtractFilenameFromURL(avatarURL); filename != "" {
+		utils.DeleteFile(utils.GetAvatarFilePath(filename))
+	}
+}
+
 // DeleteAvatarController handles DELETE /api/users/{id}/avatar
 func DeleteAvatarController(w http.ResponseWriter, r *http.Request) {
 	// Get current user from session
@@ -288,22 +356,16 @@ func DeleteAvatarController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete current avatar file if it exists and is not default
-	if currentUser.Avatar != "" && !strings.Contains(currentUser.Avatar, "default.png") {
-		filename := utils.ExtractFilenameFromURL(currentUser.Avatar)
-		if filename != "" {
-			filePath := utils.GetAvatarFilePath(filename)
-			utils.DeleteFile(filePath)
-		}
-	}
-
 	// Reset avatar to default in database
-	err = currentUser.UpdateAvatar("")
+	oldAvatar, err := currentUser.UpdateAvatar("")
 	if err != nil {
 		utils.InternalServerError(w, "Failed to reset avatar")
 		return
 	}
 
+	// Only remove the file once nothing points at it
+	deleteAvatarFile(oldAvatar)
+
 	utils.Success(w, "Avatar deleted successfully", map[string]string{
 		"avatar_url": currentUser.GetAvatarURL(),
 	})
@@ -340,6 +402,16 @@ func GetUserPostsController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	privacy, err := privacyFor(r, userID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to get user")
+		return
+	}
+	if privacy.HidePosts {
+		utils.Forbidden(w, "This user's posts are private")
+		return
+	}
+
 	// Get user's posts with pagination
 	posts, err := getUserPosts(userID, limit, offset)
 	if err != nil {
@@ -367,9 +439,269 @@ func GetUserPostsController(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
+	utils.SetPaginationLinks(w, r, page, limit, totalPosts)
 	utils.Success(w, "User posts retrieved successfully", response)
 }
 
+// GetUserCommentedPostsController handles GET /api/users/{id}/commented-posts, listing
+// the posts a user has commented on, the ones they commented on most recently first.
+// Hidden along with the user's comments when they make those private.
+func GetUserCommentedPostsController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	userID, err := utils.GetIDFromURL(r, "/users/")
+	if err != nil {
+		utils.BadRequest(w, "Invalid user ID")
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+
+	limit, offset, err := utils.ValidatePagination(page, limit)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+
+	var user models.User
+	if err := user.GetByID(userID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.NotFound(w, "User not found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to get user")
+		return
+	}
+
+	privacy, err := privacyFor(r, userID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to get user")
+		return
+	}
+	if privacy.HideComments {
+		utils.Forbidden(w, "This user's comments are private")
+		return
+	}
+
+	viewerID, _ := utils.GetUserIDFromSession(r)
+	posts, total, err := models.GetUserCommentedPosts(userID, viewerID, limit, offset)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve commented posts")
+		return
+	}
+
+	postResponses, err := getPostResponses(posts, viewerID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to process post data")
+		return
+	}
+
+	pagination := map[string]interface{}{
+		"current_page": page,
+		"per_page":     limit,
+		"total":        total,
+		"total_pages":  (total + limit - 1) / limit,
+		"has_next":     page < (total+limit-1)/limit,
+		"has_prev":     page > 1,
+	}
+
+	utils.SetPaginationLinks(w, r, page, limit, total)
+	utils.PaginatedSuccess(w, "Commented posts retrieved successfully", postResponses, pagination)
+}
+
+// GetLikedPostsController handles GET /api/users/me/liked-posts, listing the posts
+// the current user liked, most recently liked first
+func GetLikedPostsController(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := GetCurrentUser(r)
+	if err != nil {
+		utils.Unauthorized(w, "Authentication required")
+		return
+	}
+	userID := currentUser.ID
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+
+	limit, offset, err := utils.ValidatePagination(page, limit)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+
+	posts, total, err := models.GetUserLikedPosts(userID, limit, offset)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve liked posts")
+		return
+	}
+
+	postResponses, err := getPostResponses(posts, userID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to process post data")
+		return
+	}
+
+	pagination := map[string]interface{}{
+		"current_page": page,
+		"per_page":     limit,
+		"total":        total,
+		"total_pages":  (total + limit - 1) / limit,
+		"has_next":     page < (total+limit-1)/limit,
+		"has_prev":     page > 1,
+	}
+
+	utils.SetPaginationLinks(w, r, page, limit, total)
+	utils.PaginatedSuccess(w, "Liked posts retrieved successfully", postResponses, pagination)
+}
+
+// GetLikedCommentsController handles GET /api/users/me/liked-comments, listing the
+// comments the current user liked, most recently liked first. Only the user
+// themself can see this list.
+func GetLikedCommentsController(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := GetCurrentUser(r)
+	if err != nil {
+		utils.Unauthorized(w, "Authentication required")
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+
+	limit, offset, err := utils.ValidatePagination(page, limit)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+
+	comments, total, err := models.GetUserLikedComments(currentUser.ID, limit, offset)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve liked comments")
+		return
+	}
+
+	pagination := map[string]interface{}{
+		"current_page": page,
+		"per_page":     limit,
+		"total":        total,
+		"total_pages":  (total + limit - 1) / limit,
+		"has_next":     page < (total+limit-1)/limit,
+		"has_prev":     page > 1,
+	}
+
+	utils.SetPaginationLinks(w, r, page, limit, total)
+	utils.PaginatedSuccess(w, "Liked comments retrieved successfully", comments, pagination)
+}
+
+// GetVoteHistoryController handles GET /api/users/me/votes, listing the current user's
+// votes newest first. Optional filters: type (like or dislike) and target (post or
+// comment). Pages are cursor based: pass the returned next_cursor as ?cursor= to
+// get the following page.
+func GetVoteHistoryController(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := GetCurrentUser(r)
+	if err != nil {
+		utils.Unauthorized(w, "Authentication required")
+		return
+	}
+
+	query := r.URL.Query()
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 {
+		limit = 20
+	}
+	limit, _, err = utils.ValidatePagination(1, limit)
+	if err != nil {
+		utils.BadRequest(w, err.Error())
+		return
+	}
+
+	filter := models.VoteHistoryFilter{
+		VoteType:   query.Get("type"),
+		TargetType: query.Get("target"),
+	}
+	if filter.VoteType != "" && filter.VoteType != "like" && filter.VoteType != "dislike" {
+		utils.BadRequest(w, "Type must be 'like' or 'dislike'")
+		return
+	}
+	if filter.TargetType != "" && filter.TargetType != models.TargetPost && filter.TargetType != models.TargetComment {
+		utils.BadRequest(w, "Target must be 'post' or 'comment'")
+		return
+	}
+	if value := query.Get("cursor"); value != "" {
+		filter.After, err = decodeVoteCursor(value)
+		if err != nil {
+			utils.BadRequest(w, "Invalid cursor")
+			return
+		}
+	}
+
+	entries, next, err := models.GetUserVoteHistory(currentUser.ID, filter, limit)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve vote history")
+		return
+	}
+
+	pagination := map[string]interface{}{
+		"per_page":    limit,
+		"has_next":    next != nil,
+		"next_cursor": nil,
+	}
+	if next != nil {
+		pagination["next_cursor"] = encodeVoteCursor(next)
+	}
+
+	utils.PaginatedSuccess(w, "Vote history retrieved successfully", entries, pagination)
+}
+
+// encodeVoteCursor turns a vote history position into an opaque query value
+func encodeVoteCursor(cursor *models.VoteHistoryCursor) string {
+	raw := strconv.FormatFloat(cursor.SortKey, 'g', -1, 64) + ":" + strconv.Itoa(cursor.VoteID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeVoteCursor reverses encodeVoteCursor
+func decodeVoteCursor(value string) (*models.VoteHistoryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	sortKey, voteID, found := strings.Cut(string(raw), ":")
+	if !found {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+
+	cursor := &models.VoteHistoryCursor{}
+	if cursor.SortKey, err = strconv.ParseFloat(sortKey, 64); err != nil {
+		return nil, err
+	}
+	if cursor.VoteID, err = strconv.Atoi(voteID); err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
 // GetUserCommentsController handles GET /api/users/{id}/comments
 func GetUserCommentsController(w http.ResponseWriter, r *http.Request) {
 	userID, err := utils.GetIDFromURL(r, "/users/")
@@ -401,6 +733,16 @@ func GetUserCommentsController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	privacy, err := privacyFor(r, userID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to get user")
+		return
+	}
+	if privacy.HideComments {
+		utils.Forbidden(w, "This user's comments are private")
+		return
+	}
+
 	// Get user's comments with pagination
 	comments, err := getUserComments(userID, limit, offset)
 	if err != nil {
@@ -428,6 +770,7 @@ func GetUserCommentsController(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
+	utils.SetPaginationLinks(w, r, page, limit, totalComments)
 	utils.Success(w, "User comments retrieved successfully", response)
 }
 
@@ -451,6 +794,16 @@ func GetUserStatsController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	privacy, err := privacyFor(r, userID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to get user")
+		return
+	}
+	if privacy.HideStats {
+		utils.Forbidden(w, "This user's stats are private")
+		return
+	}
+
 	// Get detailed stats
 	postCount, err := getUserPostCount(userID)
 	if err != nil {
@@ -464,13 +817,13 @@ func GetUserStatsController(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	postLikes, err := getUserPostLikes(userID)
+	postVotes, err := getUserPostVotes(userID)
 	if err != nil {
 		utils.InternalServerError(w, "Failed to get post likes")
 		return
 	}
 
-	commentLikes, err := getUserCommentLikes(userID)
+	commentVotes, err := getUserCommentVotes(userID)
 	if err != nil {
 		utils.InternalServerError(w, "Failed to get comment likes")
 		return
@@ -484,19 +837,204 @@ func GetUserStatsController(w http.ResponseWriter, r *http.Request) {
 			ID:           user.ID,
 			Username:     user.Username,
 			Avatar:       user.GetAvatarURL(),
-			CreatedAt:    user.CreatedAt,
-			UpdatedAt:    user.UpdatedAt,
-			PostCount:    postCount,
-			CommentCount: commentCount,
+			CreatedAt:    utils.NewJSONTime(user.CreatedAt),
+			UpdatedAt:    utils.NewJSONTime(user.UpdatedAt),
+			PostCount:    &postCount,
+			CommentCount: &commentCount,
+			LastActive:   utils.NewJSONTimePtr(user.LastActive),
+			IsOnline:     user.IsOnline(),
+			NewMember:    user.IsNewMember(),
 		},
-		TotalPostLikes:    postLikes,
-		TotalCommentLikes: commentLikes,
-		AccountAge:        accountAge,
+		TotalPostLikes:       postVotes.Likes,
+		TotalPostDislikes:    postVotes.Dislikes,
+		TotalCommentLikes:    commentVotes.Likes,
+		TotalCommentDislikes: commentVotes.Dislikes,
+		Reputation:           postVotes.Score + commentVotes.Score,
+		AccountAge:           accountAge,
 	}
 
 	utils.Success(w, "User stats retrieved successfully", stats)
 }
 
+// GetUserReputationController handles GET /api/users/{id}/reputation
+// It breaks down the likes and dislikes a user received on posts and on comments.
+func GetUserReputationController(w http.ResponseWriter, r *http.Request) {
+	userID, err := utils.GetIDFromURL(r, "/users/")
+	if err != nil {
+		utils.BadRequest(w, "Invalid user ID")
+		return
+	}
+
+	var user models.User
+	err = user.GetByID(userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			utils.NotFound(w, "User not found")
+			return
+		}
+		utils.InternalServerError(w, "Failed to get user")
+		return
+	}
+
+	privacy, err := privacyFor(r, userID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to get user")
+		return
+	}
+	if privacy.HideStats {
+		utils.Forbidden(w, "This user's stats are private")
+		return
+	}
+
+	breakdown := ReputationBreakdown{UserID: user.ID, Username: user.Username}
+
+	if breakdown.Posts.Count, err = getUserPostCount(userID); err != nil {
+		utils.InternalServerError(w, "Failed to get post count")
+		return
+	}
+	if breakdown.Comments.Count, err = getUserCommentCount(userID); err != nil {
+		utils.InternalServerError(w, "Failed to get comment count")
+		return
+	}
+	if breakdown.Posts.ReceivedVotes, err = getUserPostVotes(userID); err != nil {
+		utils.InternalServerError(w, "Failed to get post likes")
+		return
+	}
+	if breakdown.Comments.ReceivedVotes, err = getUserCommentVotes(userID); err != nil {
+		utils.InternalServerError(w, "Failed to get comment likes")
+		return
+	}
+
+	breakdown.Total = ReceivedVotes{
+		Likes:    breakdown.Posts.Likes + breakdown.Comments.Likes,
+		Dislikes: breakdown.Posts.Dislikes + breakdown.Comments.Dislikes,
+	}
+	breakdown.Total.Score = breakdown.Total.Likes - breakdown.Total.Dislikes
+
+	utils.Success(w, "User reputation retrieved successfully", breakdown)
+}
+
+// GetPrivacySettingsController handles GET /api/users/me/privacy
+func GetPrivacySettingsController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	currentUser, err := GetCurrentUser(r)
+	if err != nil {
+		utils.Unauthorized(w, "Authentication required")
+		return
+	}
+
+	settings, err := models.GetPrivacySettings(currentUser.ID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve privacy settings")
+		return
+	}
+
+	utils.Success(w, "Privacy settings retrieved successfully", settings)
+}
+
+// UpdatePrivacySettingsController handles PUT /api/users/me/privacy
+func UpdatePrivacySettingsController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.MethodNotAllowed(w, "Only PUT method allowed")
+		return
+	}
+
+	currentUser, err := GetCurrentUser(r)
+	if err != nil {
+		utils.Unauthorized(w, "Authentication required")
+		return
+	}
+
+	// Start from the current settings so omitted fields are left unchanged
+	settings, err := models.GetPrivacySettings(currentUser.ID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve privacy settings")
+		return
+	}
+
+	if err := utils.ParseJSON(r, settings); err != nil {
+		utils.BadRequest(w, "Invalid JSON format")
+		return
+	}
+
+	if err := models.UpdatePrivacySettings(currentUser.ID, settings); err != nil {
+		utils.InternalServerError(w, "Failed to update privacy settings")
+		return
+	}
+
+	utils.Success(w, "Privacy settings updated successfully", settings)
+}
+
+// exportFlushEvery controls how often the CSV export is flushed to the client
+const exportFlushEvery = 100
+
+// ExportUserDataController handles GET /api/users/{id}/export (owner only)
+// It streams the user's posts and comments as CSV.
+func ExportUserDataController(w http.ResponseWriter, r *http.Request) {
+	currentUser, err := GetCurrentUser(r)
+	if err != nil {
+		utils.Unauthorized(w, "Authentication required")
+		return
+	}
+
+	userID, err := utils.GetIDFromURL(r, "/users/")
+	if err != nil {
+		utils.BadRequest(w, "Invalid user ID")
+		return
+	}
+
+	if currentUser.ID != userID {
+		utils.Forbidden(w, "You can only export your own data")
+		return
+	}
+
+	filename := fmt.Sprintf("forum-export-%s-%s.csv", currentUser.Username, time.Now().Format("20060102"))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Cache-Control", "no-store")
+
+	writer := csv.NewWriter(w)
+	flusher, _ := w.(http.Flusher)
+
+	writer.Write([]string{"type", "id", "post_id", "post_title", "content", "categories",
+		"likes", "dislikes", "created_at", "updated_at"})
+
+	written := 0
+	err = models.ExportUserContent(userID, func(record models.ExportRecord) error {
+		writer.Write([]string{
+			record.Type,
+			strconv.Itoa(record.ID),
+			strconv.Itoa(record.PostID),
+			record.PostTitle,
+			record.Content,
+			record.Categories,
+			strconv.Itoa(record.Likes),
+			strconv.Itoa(record.Dislikes),
+			record.CreatedAt.Format(time.RFC3339),
+			record.UpdatedAt.Format(time.RFC3339),
+		})
+
+		written++
+		if written%exportFlushEvery == 0 {
+			writer.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return writer.Error()
+	})
+
+	writer.Flush()
+	if err != nil {
+		// Headers are already sent, so the best we can do is log and cut the stream short
+		log.Printf("User export for %d failed after %d rows: %v", userID, written, err)
+	}
+}
+
 // GetCurrentUser gets the current user from session and returns the User model
 func GetCurrentUser(r *http.Request) (*models.User, error) {
 	userID, err := utils.GetUserIDFromSession(r)
@@ -515,6 +1053,19 @@ func GetCurrentUser(r *http.Request) (*models.User, error) {
 
 // Helper functions
 
+// privacyFor returns a user's privacy settings as they apply to the requester.
+// Users always see all of their own activity, and moderators everyone's, so for
+// them nothing is hidden.
+func privacyFor(r *http.Request, userID int) (*models.PrivacySettings, error) {
+	if viewerID, err := utils.GetUserIDFromSession(r); err == nil && viewerID == userID {
+		return &models.PrivacySettings{}, nil
+	}
+	if middleware.IsModerator(r) {
+		return &models.PrivacySettings{}, nil
+	}
+	return models.GetPrivacySettings(userID)
+}
+
 func getUserPostCount(userID int) (int, error) {
 	query := `SELECT COUNT(*) FROM posts WHERE user_id = ?`
 	var count int
@@ -523,34 +1074,38 @@ func getUserPostCount(userID int) (int, error) {
 }
 
 func getUserCommentCount(userID int) (int, error) {
-	query := `SELECT COUNT(*) FROM comments WHERE user_id = ?`
+	query := `SELECT COUNT(*) FROM visible_comments WHERE user_id = ?`
 	var count int
 	err := database.GetDB().QueryRow(query, userID).Scan(&count)
 	return count, err
 }
 
-func getUserPostLikes(userID int) (int, error) {
+// getUserPostVotes counts the likes and dislikes received on a user's posts
+func getUserPostVotes(userID int) (ReceivedVotes, error) {
 	query := `
-		SELECT COALESCE(SUM(CASE WHEN v.vote_type = 'like' THEN 1 WHEN v.vote_type = 'dislike' THEN -1 ELSE 0 END), 0)
+		SELECT COALESCE(SUM(v.vote_type = 'like'), 0), COALESCE(SUM(v.vote_type = 'dislike'), 0)
 		FROM posts p
-		LEFT JOIN votes v ON p.id = v.post_id AND v.comment_id IS NULL
+		JOIN votes v ON p.id = v.post_id AND v.comment_id IS NULL
 		WHERE p.user_id = ?
 	`
-	var likes int
-	err := database.GetDB().QueryRow(query, userID).Scan(&likes)
-	return likes, err
+	var votes ReceivedVotes
+	err := database.GetDB().QueryRow(query, userID).Scan(&votes.Likes, &votes.Dislikes)
+	votes.Score = votes.Likes - votes.Dislikes
+	return votes, err
 }
 
-func getUserCommentLikes(userID int) (int, error) {
+// getUserCommentVotes counts the likes and dislikes received on a user's visible comments
+func getUserCommentVotes(userID int) (ReceivedVotes, error) {
 	query := `
-		SELECT COALESCE(SUM(CASE WHEN v.vote_type = 'like' THEN 1 WHEN v.vote_type = 'dislike' THEN -1 ELSE 0 END), 0)
-		FROM comments c
-		LEFT JOIN votes v ON c.id = v.comment_id
+		SELECT COALESCE(SUM(v.vote_type = 'like'), 0), COALESCE(SUM(v.vote_type = 'dislike'), 0)
+		FROM visible_comments c
+		JOIN votes v ON c.id = v.comment_id
 		WHERE c.user_id = ?
 	`
-	var likes int
-	err := database.GetDB().QueryRow(query, userID).Scan(&likes)
-	return likes, err
+	var votes ReceivedVotes
+	err := database.GetDB().QueryRow(query, userID).Scan(&votes.Likes, &votes.Dislikes)
+	votes.Score = votes.Likes - votes.Dislikes
+	return votes, err
 }
 
 func getUserPosts(userID, limit, offset int) ([]map[string]interface{}, error) {
@@ -562,7 +1117,7 @@ func getUserPosts(userID, limit, offset int) ([]map[string]interface{}, error) {
 		FROM posts p
 		LEFT JOIN users u ON p.user_id = u.id
 		LEFT JOIN votes v ON p.id = v.post_id AND v.comment_id IS NULL
-		LEFT JOIN comments c ON p.id = c.post_id
+		LEFT JOIN visible_comments c ON p.id = c.post_id
 		WHERE p.user_id = ?
 		GROUP BY p.id, p.title, p.content, p.created_at, p.updated_at, u.username, u.avatar
 		ORDER BY p.created_at DESC
@@ -655,7 +1210,7 @@ func getUserComments(userID, limit, offset int) ([]map[string]interface{}, error
 			   p.title as post_title,
 			   u.username, u.avatar,
 			   COALESCE(SUM(CASE WHEN v.vote_type = 'like' THEN 1 WHEN v.vote_type = 'dislike' THEN -1 ELSE 0 END), 0) as vote_score
-		FROM comments c
+		FROM visible_comments c
 		LEFT JOIN posts p ON c.post_id = p.id
 		LEFT JOIN users u ON c.user_id = u.id
 		LEFT JOIN votes v ON c.id = v.comment_id
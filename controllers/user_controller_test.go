@@ -0,0 +1,635 @@
+package controllers_test
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"forum/config"
+	"forum/database"
+	"forum/models"
+	"forum/utils"
+)
+
+// getProfile fetches a user's public profile as the given client
+func getProfile(t *testing.T, c *testClient, userID int) map[string]interface{} {
+	t.Helper()
+
+	res, body := c.do(http.MethodGet, fmt.Sprintf("/api/users/%d", userID), nil)
+	expectStatus(t, res, body, http.StatusOK)
+
+	var profile map[string]interface{}
+	decodeData(t, body, &profile)
+	return profile
+}
+
+// lastActive reads a user's stored last_active
+func lastActive(t *testing.T, userID int) *time.Time {
+	t.Helper()
+
+	user := models.User{}
+	if err := user.GetByID(userID); err != nil {
+		t.Fatal(err)
+	}
+	return user.LastActive
+}
+
+func TestActivityTracking(t *testing.T) {
+	user := newUser(t, "")
+	visitor := newVisitor(t)
+
+	if _, err := database.GetDB().Exec(`UPDATE users SET last_active = NULL WHERE id = ?`, user.User.ID); err != nil {
+		t.Fatal(err)
+	}
+	if online := getProfile(t, visitor, user.User.ID)["is_online"]; online != false {
+		t.Fatalf("is_online = %v before any activity, want false", online)
+	}
+
+	// Any authenticated request counts as activity
+	before := time.Now().Add(-time.Second)
+	res, body := user.do(http.MethodGet, "/api/auth/me", nil)
+	expectStatus(t, res, body, http.StatusOK)
+
+	active := lastActive(t, user.User.ID)
+	if active == nil || active.Before(before) {
+		t.Fatalf("last_active = %v, want it updated by the request", active)
+	}
+	profile := getProfile(t, visitor, user.User.ID)
+	if profile["is_online"] != true || profile["last_active"] == nil {
+		t.Fatalf("profile = %v, want the user online", profile)
+	}
+
+	// Once the user has been quiet for longer than the online window they show as offline
+	quiet := time.Now().Add(-models.OnlineWindow - time.Minute)
+	if err := models.UpdateLastActive(user.User.ID, quiet); err != nil {
+		t.Fatal(err)
+	}
+	if online := getProfile(t, visitor, user.User.ID)["is_online"]; online != false {
+		t.Fatalf("is_online = %v after going quiet, want false", online)
+	}
+
+	// Writes are throttled, so another request straight away doesn't touch last_active
+	res, body = user.do(http.MethodGet, "/api/auth/me", nil)
+	expectStatus(t, res, body, http.StatusOK)
+	if active := lastActive(t, user.User.ID); active == nil || !active.Equal(quiet) {
+		t.Fatalf("last_active = %v, want the throttled value %v", active, quiet)
+	}
+}
+
+func TestExportUserData(t *testing.T) {
+	owner := newUser(t, "")
+	postID := owner.createPost()
+	otherPostID := newUser(t, "").createPost()
+	commentID := owner.createComment(otherPostID, "My exported comment")
+	path := fmt.Sprintf("/api/users/%d/export", owner.User.ID)
+
+	res, err := owner.client.Get(server.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", res.StatusCode)
+	}
+	if disposition := res.Header.Get("Content-Disposition"); !strings.HasPrefix(disposition, "attachment;") {
+		t.Fatalf("Content-Disposition = %q, want an attachment", disposition)
+	}
+
+	records, err := csv.NewReader(res.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported := make(map[string]bool)
+	for _, record := range records[1:] {
+		exported[record[0]+" "+record[1]] = true
+	}
+	for _, want := range []string{"post " + strconv.Itoa(postID), "comment " + strconv.Itoa(commentID)} {
+		if !exported[want] {
+			t.Fatalf("export is missing %s: %v", want, records)
+		}
+	}
+	if len(records) != 3 {
+		t.Fatalf("export has %d rows, want a header and 2 records", len(records))
+	}
+
+	// Only the owner may export
+	res2, body := newUser(t, "").do(http.MethodGet, path, nil)
+	expectStatus(t, res2, body, http.StatusForbidden)
+	res2, body = newVisitor(t).do(http.MethodGet, path, nil)
+	expectStatus(t, res2, body, http.StatusUnauthorized)
+}
+
+func TestUsernameChangeCooldown(t *testing.T) {
+	user := newUser(t, "")
+	path := fmt.Sprintf("/api/users/%d", user.User.ID)
+	rename := func() (*http.Response, apiResponse, string) {
+		name := uniqueName("renamed")
+		res, body := user.do(http.MethodPut, path, map[string]string{"username": name})
+		return res, body, name
+	}
+
+	// The first change is always allowed
+	res, body, name := rename()
+	expectStatus(t, res, body, http.StatusOK)
+	if err := user.User.GetByID(user.User.ID); err != nil || user.User.Username != name {
+		t.Fatalf("username = %q, want %q (%v)", user.User.Username, name, err)
+	}
+
+	// Within the cooldown another change is refused
+	cooldown := models.UsernameChangeCooldown()
+	backdate := func(d time.Duration) {
+		t.Helper()
+		query := `UPDATE users SET username_changed_at = ? WHERE id = ?`
+		if _, err := database.GetDB().Exec(query, time.Now().Add(-d), user.User.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backdate(cooldown - time.Hour)
+
+	res, body, _ = rename()
+	expectStatus(t, res, body, http.StatusTooManyRequests)
+	if body.Code != "username_change_cooldown" || res.Header.Get("Retry-After") == "" {
+		t.Fatalf("code = %q, Retry-After = %q", body.Code, res.Header.Get("Retry-After"))
+	}
+
+	// Once it has passed the change goes through
+	backdate(cooldown + time.Hour)
+	res, body, name = rename()
+	expectStatus(t, res, body, http.StatusOK)
+	if err := user.User.GetByID(user.User.ID); err != nil || user.User.Username != name {
+		t.Fatalf("username = %q, want %q (%v)", user.User.Username, name, err)
+	}
+}
+
+func TestAvatarDeletionRefusesTraversal(t *testing.T) {
+	user := newUser(t, "")
+	path := fmt.Sprintf("/api/users/%d", user.User.ID)
+
+	// The tests run from a scratch directory holding the database and the uploads
+	victim := "victim.txt"
+	if err := os.WriteFile(victim, []byte("keep me"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(victim) })
+
+	for _, avatar := range []string{
+		"/uploads/avatars/../../forum.db",
+		"../../victim.txt",
+		"/uploads/avatars/..\\..\\victim.txt",
+		"uploads/../victim.txt",
+	} {
+		res, body := user.do(http.MethodPut, path, map[string]string{"avatar": avatar})
+		expectStatus(t, res, body, http.StatusUnprocessableEntity)
+
+		// Avatars stored before the profile update stopped taking URLs
+		if _, err := user.User.UpdateAvatar(avatar); err != nil {
+			t.Fatal(err)
+		}
+		res, body = user.do(http.MethodDelete, path+"/avatar", nil)
+		expectStatus(t, res, body, http.StatusOK)
+
+		for _, file := range []string{"forum.db", victim} {
+			if _, err := os.Stat(file); err != nil {
+				t.Fatalf("deleting avatar %q removed %s: %v", avatar, file, err)
+			}
+		}
+	}
+}
+
+func TestReceivedVotesAreCountedSeparately(t *testing.T) {
+	author := newUser(t, "")
+	postID := author.createPost()
+	commentID := author.createComment(postID, "A comment that gets voted down")
+	castVotes(t, fmt.Sprintf("/api/posts/%d/vote", postID), "like", 2)
+	castVotes(t, fmt.Sprintf("/api/posts/%d/vote", postID), "dislike", 1)
+	castVotes(t, fmt.Sprintf("/api/comments/%d/vote", commentID), "dislike", 3)
+	viewer := newUser(t, "")
+
+	res, body := viewer.do(http.MethodGet, fmt.Sprintf("/api/users/%d/stats", author.User.ID), nil)
+	expectStatus(t, res, body, http.StatusOK)
+	var stats struct {
+		PostLikes       int `json:"total_post_likes"`
+		PostDislikes    int `json:"total_post_dislikes"`
+		CommentLikes    int `json:"total_comment_likes"`
+		CommentDislikes int `json:"total_comment_dislikes"`
+		Reputation      int `json:"reputation"`
+	}
+	decodeData(t, body, &stats)
+	if stats.PostLikes != 2 || stats.PostDislikes != 1 || stats.CommentLikes != 0 || stats.CommentDislikes != 3 || stats.Reputation != -2 {
+		t.Fatalf("stats = %+v, want post 2/1, comment 0/3 and reputation -2", stats)
+	}
+
+	res, body = viewer.do(http.MethodGet, fmt.Sprintf("/api/users/%d/reputation", author.User.ID), nil)
+	expectStatus(t, res, body, http.StatusOK)
+	type received struct {
+		Count    int `json:"count"`
+		Likes    int `json:"likes"`
+		Dislikes int `json:"dislikes"`
+		Score    int `json:"score"`
+	}
+	var breakdown struct {
+		UserID   int      `json:"user_id"`
+		Posts    received `json:"posts"`
+		Comments received `json:"comments"`
+		Total    received `json:"total"`
+	}
+	decodeData(t, body, &breakdown)
+	if breakdown.UserID != author.User.ID ||
+		breakdown.Posts != (received{Count: 1, Likes: 2, Dislikes: 1, Score: 1}) ||
+		breakdown.Comments != (received{Count: 1, Likes: 0, Dislikes: 3, Score: -3}) ||
+		breakdown.Total != (received{Likes: 2, Dislikes: 4, Score: -2}) {
+		t.Fatalf("breakdown = %+v", breakdown)
+	}
+}
+
+func TestBlockedEmailDomains(t *testing.T) {
+	blocklist := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(blocklist, []byte("mailinator.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := utils.LoadEmailDomainBlocklist(blocklist); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.WriteFile(blocklist, nil, 0o644)
+		utils.LoadEmailDomainBlocklist(blocklist)
+	})
+
+	signUp := func(domain string) (*http.Response, apiResponse) {
+		name := uniqueName("user")
+		return newVisitor(t).do(http.MethodPost, "/api/auth/register", map[string]string{
+			"username": name,
+			"email":    name + "@" + domain,
+			"password": testPassword,
+		})
+	}
+	expectEmailRefused := func(res *http.Response, body apiResponse) {
+		t.Helper()
+
+		expectStatus(t, res, body, http.StatusUnprocessableEntity)
+		if len(body.Errors) != 1 || body.Errors[0].Field != "email" {
+			t.Fatalf("errors = %+v, want one on email", body.Errors)
+		}
+	}
+
+	expectEmailRefused(signUp("mailinator.com"))
+	res, body := signUp("example.com")
+	expectStatus(t, res, body, http.StatusCreated)
+
+	// Changing to a blocked address is refused the same way
+	user := newUser(t, "")
+	path := fmt.Sprintf("/api/users/%d", user.User.ID)
+	expectEmailRefused(user.do(http.MethodPut, path, map[string]string{"email": uniqueName("user") + "@mailinator.com"}))
+	res, body = user.do(http.MethodPut, path, map[string]string{"email": uniqueName("user") + "@example.org"})
+	expectStatus(t, res, body, http.StatusOK)
+}
+
+// avatarFiles lists the files in the avatar upload directory
+func avatarFiles(t *testing.T) map[string]bool {
+	t.Helper()
+
+	entries, err := os.ReadDir(utils.AvatarUploadConfig.UploadDir)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	files := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		files[entry.Name()] = true
+	}
+	return files
+}
+
+// uploadAvatar uploads a fresh PNG as the client's avatar and returns the response
+func (c *testClient) uploadAvatar() (*http.Response, apiResponse) {
+	c.t.Helper()
+	return c.upload(fmt.Sprintf("/api/users/%d/avatar", c.User.ID), "avatar", "me.png", "image/png", testPNG(c.t, 8, 8))
+}
+
+// storedAvatar reads a user's avatar column
+func storedAvatar(t *testing.T, userID int) string {
+	t.Helper()
+
+	var avatar string
+	if err := database.GetDB().QueryRow(`SELECT COALESCE(avatar, '') FROM users WHERE id = ?`, userID).Scan(&avatar); err != nil {
+		t.Fatal(err)
+	}
+	return avatar
+}
+
+func TestAvatarUploadReplacesTheOldFile(t *testing.T) {
+	user := newUser(t, "")
+
+	res, body := user.uploadAvatar()
+	expectStatus(t, res, body, http.StatusOK)
+	first := storedAvatar(t, user.User.ID)
+	res, body = user.uploadAvatar()
+	expectStatus(t, res, body, http.StatusOK)
+	second := storedAvatar(t, user.User.ID)
+
+	files := avatarFiles(t)
+	if first == second || files[filepath.Base(first)] || !files[filepath.Base(second)] {
+		t.Fatalf("avatar went from %q to %q, files now %v; want only the new file kept", first, second, files)
+	}
+}
+
+func TestAvatarUploadKeepsTheOldAvatarWhenTheUpdateFails(t *testing.T) {
+	user := newUser(t, "")
+	res, body := user.uploadAvatar()
+	expectStatus(t, res, body, http.StatusOK)
+	previous := storedAvatar(t, user.User.ID)
+	before := avatarFiles(t)
+
+	// Make the database refuse this user's avatar updates
+	db := database.GetDB()
+	trigger := fmt.Sprintf(`
+		CREATE TRIGGER fail_avatar_update BEFORE UPDATE OF avatar ON users
+		WHEN NEW.id = %d BEGIN SELECT RAISE(ABORT, 'avatar update failed'); END
+	`, user.User.ID)
+	if _, err := db.Exec(trigger); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec(`DROP TRIGGER IF EXISTS fail_avatar_update`) })
+
+	res, body = user.uploadAvatar()
+	expectStatus(t, res, body, http.StatusInternalServerError)
+
+	if got := storedAvatar(t, user.User.ID); got != previous {
+		t.Fatalf("avatar = %q after a failed update, want %q", got, previous)
+	}
+	after := avatarFiles(t)
+	if !after[filepath.Base(previous)] {
+		t.Fatal("the old avatar file was removed")
+	}
+	if !reflect.DeepEqual(after, before) {
+		t.Fatalf("avatar files went from %v to %v, want the new upload cleaned up", before, after)
+	}
+}
+
+func TestPrivateProfileHidesActivityFromOthers(t *testing.T) {
+	owner := newUser(t, "")
+	other := newUser(t, "")
+	moderator := newUser(t, models.RoleModerator)
+	visitor := newVisitor(t)
+
+	postID := owner.createPost()
+	term := uniqueName("privatecomment")
+	commentID := owner.createComment(postID, "A comment with "+term)
+
+	res, body := owner.do(http.MethodPut, "/api/users/me/privacy", map[string]bool{
+		"hide_posts":    true,
+		"hide_comments": true,
+		"hide_stats":    true,
+	})
+	expectStatus(t, res, body, http.StatusOK)
+
+	id := strconv.Itoa(owner.User.ID)
+	paths := []string{
+		"/api/users/" + id + "/posts",
+		"/api/users/" + id + "/comments",
+		"/api/users/" + id + "/stats",
+		"/api/posts?author=" + id,
+		"/api/posts?author=" + owner.User.Username,
+		"/api/search?type=comments&q=" + term + "&author=" + id,
+		"/api/search?type=comments&q=" + term + "&author=" + owner.User.Username,
+		fmt.Sprintf("/api/posts/%d/comments/search?q=%s&author=%s", postID, term, id),
+	}
+
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			for name, c := range map[string]*testClient{"other user": other, "visitor": visitor} {
+				res, body := c.do(http.MethodGet, path, nil)
+				if res.StatusCode != http.StatusForbidden {
+					t.Errorf("%s: status = %d, want %d (%s)", name, res.StatusCode, http.StatusForbidden, body.Message)
+				}
+			}
+
+			for name, c := range map[string]*testClient{"owner": owner, "moderator": moderator} {
+				res, body := c.do(http.MethodGet, path, nil)
+				if res.StatusCode != http.StatusOK {
+					t.Errorf("%s: status = %d, want %d (%s)", name, res.StatusCode, http.StatusOK, body.Message)
+				}
+			}
+		})
+	}
+
+	// The owner's own filtered views still list their activity
+	res, body = owner.do(http.MethodGet, "/api/posts?author="+id, nil)
+	expectStatus(t, res, body, http.StatusOK)
+	if ids := listIDs(t, body); !reflect.DeepEqual(ids, []int{postID}) {
+		t.Errorf("owner's posts = %v, want [%d]", ids, postID)
+	}
+
+	res, body = owner.do(http.MethodGet, "/api/search?type=comments&q="+term+"&author="+id, nil)
+	expectStatus(t, res, body, http.StatusOK)
+	var results []struct {
+		Comment struct {
+			ID int `json:"id"`
+		} `json:"comment"`
+	}
+	decodeData(t, body, &results)
+	if len(results) != 1 || results[0].Comment.ID != commentID {
+		t.Errorf("owner's comment search = %+v, want comment %d", results, commentID)
+	}
+
+	// Making the profile public again lifts the restriction
+	res, body = owner.do(http.MethodPut, "/api/users/me/privacy", map[string]bool{
+		"hide_posts":    false,
+		"hide_comments": false,
+		"hide_stats":    false,
+	})
+	expectStatus(t, res, body, http.StatusOK)
+	for _, path := range paths {
+		res, body := visitor.do(http.MethodGet, path, nil)
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("%s after making the profile public: status = %d, want %d (%s)", path, res.StatusCode, http.StatusOK, body.Message)
+		}
+	}
+}
+
+func TestGetUserCommentedPosts(t *testing.T) {
+	commenter := newUser(t, "")
+	author := newUser(t, "")
+	visitor := newVisitor(t)
+	posts := []int{author.createPost(), author.createPost(), author.createPost(), author.createPost()}
+
+	// Comments hours ago; the first post is commented on twice and most recently
+	comments := []struct {
+		postID int
+		hours  int
+	}{
+		{posts[0], 5},
+		{posts[1], 4},
+		{posts[2], 3},
+		{posts[0], 1},
+	}
+	for _, c := range comments {
+		id := commenter.createComment(c.postID, "Taking part in this thread")
+		setCreatedAt(t, "comments", id, time.Now().Add(-time.Duration(c.hours)*time.Hour))
+	}
+	// Someone else's comment doesn't count
+	author.createComment(posts[3], "Only the author commented here")
+
+	path := fmt.Sprintf("/api/users/%d/commented-posts", commenter.User.ID)
+	res, body := visitor.do(http.MethodGet, path, nil)
+	expectStatus(t, res, body, http.StatusOK)
+	if want := []int{posts[0], posts[2], posts[1]}; !reflect.DeepEqual(listIDs(t, body), want) {
+		t.Errorf("commented posts = %v, want %v", listIDs(t, body), want)
+	}
+	if body.Pagination.Total != 3 {
+		t.Errorf("total = %d, want 3", body.Pagination.Total)
+	}
+
+	res, body = visitor.do(http.MethodGet, path+"?limit=2&page=2", nil)
+	expectStatus(t, res, body, http.StatusOK)
+	if want := []int{posts[1]}; !reflect.DeepEqual(listIDs(t, body), want) {
+		t.Errorf("second page = %v, want %v", listIDs(t, body), want)
+	}
+
+	res, body = visitor.do(http.MethodGet, "/api/users/999999/commented-posts", nil)
+	expectStatus(t, res, body, http.StatusNotFound)
+}
+
+func TestNewMemberFlag(t *testing.T) {
+	author := newUser(t, "")
+	visitor := newVisitor(t)
+	postID := author.createPost()
+	commentID := author.createComment(postID, "A comment from a newcomer")
+
+	type authorInfo struct {
+		NewMember bool `json:"new_member"`
+	}
+
+	// flags reads new_member from the post's author, a comment author in the
+	// listing, a single comment's author and the profile
+	flags := func() []bool {
+		t.Helper()
+
+		res, body := visitor.do(http.MethodGet, fmt.Sprintf("/api/posts/%d", postID), nil)
+		expectStatus(t, res, body, http.StatusOK)
+		var post struct {
+			Author authorInfo `json:"author"`
+		}
+		decodeData(t, body, &post)
+
+		res, body = visitor.do(http.MethodGet, fmt.Sprintf("/api/posts/%d/comments", postID), nil)
+		expectStatus(t, res, body, http.StatusOK)
+		var listed []struct {
+			Author authorInfo `json:"author"`
+		}
+		decodeData(t, body, &listed)
+		if len(listed) != 1 {
+			t.Fatalf("listed %d comments, want 1", len(listed))
+		}
+
+		res, body = visitor.do(http.MethodGet, fmt.Sprintf("/api/comments/%d", commentID), nil)
+		expectStatus(t, res, body, http.StatusOK)
+		var comment struct {
+			Author authorInfo `json:"author"`
+		}
+		decodeData(t, body, &comment)
+
+		profile := getProfile(t, visitor, author.User.ID)
+		return []bool{post.Author.NewMember, listed[0].Author.NewMember, comment.Author.NewMember, profile["new_member"] == true}
+	}
+
+	if got := flags(); !reflect.DeepEqual(got, []bool{true, true, true, true}) {
+		t.Errorf("fresh account: new_member = %v, want true everywhere", got)
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		previous := config.AppConfig.NewMemberDays
+		config.AppConfig.NewMemberDays = 0
+		t.Cleanup(func() { config.AppConfig.NewMemberDays = previous })
+
+		if got := flags(); !reflect.DeepEqual(got, []bool{false, false, false, false}) {
+			t.Errorf("NEW_MEMBER_DAYS=0: new_member = %v, want false everywhere", got)
+		}
+	})
+
+	setCreatedAt(t, "users", author.User.ID, time.Now().AddDate(0, -2, 0))
+	if got := flags(); !reflect.DeepEqual(got, []bool{false, false, false, false}) {
+		t.Errorf("two-month-old account: new_member = %v, want false everywhere", got)
+	}
+}
+
+func TestProfileUpdateOnlyKeepsTheCurrentAvatar(t *testing.T) {
+	user, victim := newUser(t, ""), newUser(t, "")
+	path := fmt.Sprintf("/api/users/%d", user.User.ID)
+
+	res, body := user.uploadAvatar()
+	expectStatus(t, res, body, http.StatusOK)
+	uploaded := storedAvatar(t, user.User.ID)
+	res, body = victim.uploadAvatar()
+	expectStatus(t, res, body, http.StatusOK)
+	victimAvatar := storedAvatar(t, victim.User.ID)
+
+	// Sending back the avatar the user already has keeps it and its file
+	res, body = user.do(http.MethodPut, path, map[string]string{"avatar": uploaded})
+	expectStatus(t, res, body, http.StatusOK)
+	if !avatarFiles(t)[filepath.Base(uploaded)] {
+		t.Fatalf("re-setting the same avatar deleted %s", uploaded)
+	}
+
+	// Taking over someone else's file, to have it deleted on the next change, is refused
+	res, body = user.do(http.MethodPut, path, map[string]string{"avatar": victimAvatar})
+	expectStatus(t, res, body, http.StatusUnprocessableEntity)
+	res, body = user.uploadAvatar()
+	expectStatus(t, res, body, http.StatusOK)
+
+	files := avatarFiles(t)
+	if !files[filepath.Base(victimAvatar)] {
+		t.Fatalf("another user's avatar %s was deleted", victimAvatar)
+	}
+	if files[filepath.Base(uploaded)] {
+		t.Fatalf("old avatar %s is still on disk after the new upload", uploaded)
+	}
+	if got := storedAvatar(t, user.User.ID); got == victimAvatar {
+		t.Fatalf("user's avatar is %s, another user's file", got)
+	}
+}
+
+func TestConcurrentAvatarUploadsLeaveNoOrphans(t *testing.T) {
+	user := newUser(t, "")
+	before := avatarFiles(t)
+
+	const uploads = 6
+	statuses := make(chan int, uploads)
+	var wg sync.WaitGroup
+	for i := 0; i < uploads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, _ := user.uploadAvatar()
+			statuses <- res.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	for status := range statuses {
+		if status != http.StatusOK {
+			t.Errorf("upload status = %d, want %d", status, http.StatusOK)
+		}
+	}
+
+	// Exactly one new file is left, and it is the one the user points at
+	var added []string
+	for name := range avatarFiles(t) {
+		if !before[name] {
+			added = append(added, name)
+		}
+	}
+	stored := filepath.Base(storedAvatar(t, user.User.ID))
+	if len(added) != 1 || added[0] != stored {
+		t.Fatalf("new avatar files = %v, want only the stored %s", added, stored)
+	}
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"forum/middleware"
+	"forum/models"
+	"forum/utils"
+)
+
+// MaxVoteStatusIDs caps how many post and comment IDs one vote status lookup may ask about
+const MaxVoteStatusIDs = 200
+
+// VoteStatusRequest lists the posts and comments to look up the viewer's votes on
+type VoteStatusRequest struct {
+	PostIDs    []int `json:"post_ids"`
+	CommentIDs []int `json:"comment_ids"`
+}
+
+// VoteStatusResponse maps each voted post and comment ID to "like" or "dislike".
+// IDs the user hasn't voted on, or that don't exist, are left out.
+type VoteStatusResponse struct {
+	Posts    map[int]string `json:"posts"`
+	Comments map[int]string `json:"comments"`
+}
+
+// GetVoteStatusController handles POST /api/votes/status
+// It lets clients refresh the viewer's vote state on cached lists in one request.
+func GetVoteStatusController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w, "Only POST method allowed")
+		return
+	}
+
+	userID, exists := middleware.GetUserIDFromContext(r)
+	if !exists {
+		utils.Unauthorized(w, "Authentication required")
+		return
+	}
+
+	var req VoteStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid JSON format")
+		return
+	}
+
+	if len(req.PostIDs)+len(req.CommentIDs) > MaxVoteStatusIDs {
+		utils.BadRequest(w, fmt.Sprintf("At most %d post and comment IDs can be looked up at once", MaxVoteStatusIDs))
+		return
+	}
+
+	posts, err := models.GetUserVoteTypes(userID, models.TargetPost, uniquePositiveIDs(req.PostIDs))
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve vote status")
+		return
+	}
+
+	comments, err := models.GetUserVoteTypes(userID, models.TargetComment, uniquePositiveIDs(req.CommentIDs))
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve vote status")
+		return
+	}
+
+	utils.Success(w, "Vote status retrieved successfully", VoteStatusResponse{Posts: posts, Comments: comments})
+}
+
+// VoteCountsRequest lists the comments to count votes on
+type VoteCountsRequest struct {
+	CommentIDs []int `json:"comment_ids"`
+}
+
+// VoteCountsResponse maps each requested comment ID to its current likes and dislikes
+type VoteCountsResponse struct {
+	Comments map[int]models.VoteCounts `json:"comments"`
+}
+
+// GetVoteCountsController handles POST /api/votes/counts
+// It lets clients refresh the vote counts of a rendered comment tree in one request.
+func GetVoteCountsController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w, "Only POST method allowed")
+		return
+	}
+
+	var req VoteCountsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid JSON format")
+		return
+	}
+
+	if len(req.CommentIDs) > MaxVoteStatusIDs {
+		utils.BadRequest(w, fmt.Sprintf("At most %d comment IDs can be looked up at once", MaxVoteStatusIDs))
+		return
+	}
+
+	ids := uniquePositiveIDs(req.CommentIDs)
+	counts, err := models.GetCommentVoteCounts(ids)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve vote counts")
+		return
+	}
+
+	// Comments nobody voted on still get an entry, with zero counts
+	for _, id := range ids {
+		if _, ok := counts[id]; !ok {
+			counts[id] = models.VoteCounts{}
+		}
+	}
+
+	utils.Success(w, "Vote counts retrieved successfully", VoteCountsResponse{Comments: counts})
+}
+
+// uniquePositiveIDs drops duplicates and IDs that can't exist
+func uniquePositiveIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	unique := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if id > 0 && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,215 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"forum/config"
+	"forum/models"
+)
+
+// voteTarget is a post or comment the vote tests can vote on
+type voteTarget struct {
+	name string
+	path string // the target's vote endpoint
+}
+
+// newVoteTargets creates a post and a comment by a fresh author
+func newVoteTargets(t *testing.T) (*testClient, []voteTarget) {
+	t.Helper()
+
+	author := newUser(t, "")
+	postID := author.createPost()
+	commentID := author.createComment(postID, "A comment to vote on")
+	return author, []voteTarget{
+		{"post", fmt.Sprintf("/api/posts/%d/vote", postID)},
+		{"comment", fmt.Sprintf("/api/comments/%d/vote", commentID)},
+	}
+}
+
+// castVotes has n fresh users cast voteType on the post or comment behind a vote endpoint
+func castVotes(t *testing.T, path, voteType string, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		res, body := newUser(t, "").do(http.MethodPost, path, map[string]string{"vote_type": voteType, "action": "set"})
+		expectStatus(t, res, body, http.StatusOK)
+	}
+}
+
+func TestVoteValidationErrors(t *testing.T) {
+	_, targets := newVoteTargets(t)
+	voter := newUser(t, "")
+
+	tests := []struct {
+		name  string
+		body  map[string]string
+		field string
+	}{
+		{"unknown vote type", map[string]string{"vote_type": "love"}, "vote_type"},
+		{"missing vote type", map[string]string{}, "vote_type"},
+		{"wrong case", map[string]string{"vote_type": "Like"}, "vote_type"},
+		{"unknown action", map[string]string{"vote_type": "like", "action": "flip"}, "action"},
+	}
+	for _, target := range targets {
+		for _, tt := range tests {
+			t.Run(target.name+"/"+tt.name, func(t *testing.T) {
+				res, body := voter.do(http.MethodPost, target.path, tt.body)
+				expectStatus(t, res, body, http.StatusUnprocessableEntity)
+				if body.Success || len(body.Errors) != 1 || body.Errors[0].Field != tt.field || body.Errors[0].Message == "" {
+					t.Fatalf("body = %+v, want one error on %s", body, tt.field)
+				}
+			})
+		}
+	}
+}
+
+func TestSelfVotes(t *testing.T) {
+	author, targets := newVoteTargets(t)
+	other := newUser(t, "")
+	like := map[string]string{"vote_type": "like", "action": "set"}
+
+	allowed := config.AppConfig.AllowSelfVotes
+	t.Cleanup(func() { config.AppConfig.AllowSelfVotes = allowed })
+
+	for _, target := range targets {
+		t.Run(target.name, func(t *testing.T) {
+			config.AppConfig.AllowSelfVotes = false
+			res, body := author.do(http.MethodPost, target.path, like)
+			expectStatus(t, res, body, http.StatusForbidden)
+			if body.Code != "cannot_vote_own_content" {
+				t.Fatalf("code = %q, want cannot_vote_own_content", body.Code)
+			}
+			// Everyone else votes as usual
+			res, body = other.do(http.MethodPost, target.path, like)
+			expectStatus(t, res, body, http.StatusOK)
+
+			config.AppConfig.AllowSelfVotes = true
+			res, body = author.do(http.MethodPost, target.path, like)
+			expectStatus(t, res, body, http.StatusOK)
+
+			var result struct {
+				Action    string `json:"action"`
+				LikeCount int    `json:"like_count"`
+			}
+			decodeData(t, body, &result)
+			if result.Action != "added" || result.LikeCount != 2 {
+				t.Fatalf("self vote = %+v, want added with 2 likes", result)
+			}
+		})
+	}
+}
+
+func TestVoteRetries(t *testing.T) {
+	type step struct {
+		body     map[string]string
+		action   string
+		likes    int
+		dislikes int
+	}
+	set := func(voteType string) map[string]string {
+		return map[string]string{"vote_type": voteType, "action": "set"}
+	}
+	remove := map[string]string{"action": "remove"}
+	toggle := map[string]string{"vote_type": "like"} // no action: the old toggle
+
+	sequences := map[string][]step{
+		// A timed out "set" can be resent without undoing itself
+		"set retried": {
+			{set("like"), "added", 1, 0},
+			{set("like"), "unchanged", 1, 0},
+			{set("like"), "unchanged", 1, 0},
+		},
+		"set then switch": {
+			{set("like"), "added", 1, 0},
+			{set("dislike"), "changed", 0, 1},
+			{set("dislike"), "unchanged", 0, 1},
+		},
+		"remove retried": {
+			{set("dislike"), "added", 0, 1},
+			{remove, "removed", 0, 0},
+			{remove, "unchanged", 0, 0},
+		},
+		// Clients that send no action keep toggling
+		"toggle retried": {
+			{toggle, "added", 1, 0},
+			{toggle, "removed", 0, 0},
+			{toggle, "added", 1, 0},
+		},
+	}
+
+	for name, steps := range sequences {
+		author, targets := newVoteTargets(t)
+		for _, target := range targets {
+			t.Run(name+"/"+target.name, func(t *testing.T) {
+				voter := newUser(t, "")
+				before := unreadCount(t, author)
+				for i, s := range steps {
+					res, body := voter.do(http.MethodPost, target.path, s.body)
+					expectStatus(t, res, body, http.StatusOK)
+
+					var result struct {
+						Action       string `json:"action"`
+						LikeCount    int    `json:"like_count"`
+						DislikeCount int    `json:"dislike_count"`
+					}
+					decodeData(t, body, &result)
+					if result.Action != s.action || result.LikeCount != s.likes || result.DislikeCount != s.dislikes {
+						t.Fatalf("step %d %v = %+v, want %s with %d/%d", i+1, s.body, result, s.action, s.likes, s.dislikes)
+					}
+				}
+
+				// Only newly added likes notify, however often a request is retried
+				want := 0
+				for _, s := range steps {
+					if s.action == "added" && s.likes > 0 {
+						want++
+					}
+				}
+				if got := unreadCount(t, author) - before; got != want {
+					t.Fatalf("%d notifications sent, want %d", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestDailyVoteLimitResponse(t *testing.T) {
+	if err := models.Settings.Set(models.SettingDailyVoteLimit, "1"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { models.Settings.Delete(models.SettingDailyVoteLimit) })
+
+	_, targets := newVoteTargets(t)
+	voter := newUser(t, "")
+	like := map[string]string{"vote_type": "like", "action": "set"}
+
+	res, body := voter.do(http.MethodPost, targets[0].path, like)
+	expectStatus(t, res, body, http.StatusOK)
+
+	res, body = voter.do(http.MethodPost, targets[1].path, like)
+	expectStatus(t, res, body, http.StatusTooManyRequests)
+	if body.Code != "vote_limit_reached" {
+		t.Fatalf("code = %q, want vote_limit_reached", body.Code)
+	}
+
+	// Retry-After counts down to the next midnight UTC
+	retryAfter, err := strconv.Atoi(res.Header.Get("Retry-After"))
+	if err != nil {
+		t.Fatalf("Retry-After = %q: %v", res.Header.Get("Retry-After"), err)
+	}
+	untilMidnight := time.Until(time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour))
+	if diff := time.Duration(retryAfter)*time.Second - untilMidnight; diff < 0 || diff > 2*time.Second {
+		t.Fatalf("Retry-After = %ds, want about %v", retryAfter, untilMidnight)
+	}
+	var data struct {
+		SecondsRemaining int `json:"seconds_remaining"`
+	}
+	decodeData(t, body, &data)
+	if data.SecondsRemaining != retryAfter {
+		t.Fatalf("seconds_remaining = %d, want %d", data.SecondsRemaining, retryAfter)
+	}
+}
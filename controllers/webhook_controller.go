@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"forum/events"
+	"forum/models"
+	"forum/utils"
+)
+
+// WebhookRequest is the body for creating or updating a webhook.
+// On update, omitted fields keep their current values.
+type WebhookRequest struct {
+	URL    *string  `json:"url"`
+	Secret *string  `json:"secret"` // generated on create when omitted
+	Events []string `json:"events"`
+	Active *bool    `json:"active"`
+}
+
+// WebhookSecretResponse is a webhook along with its signing secret, which is
+// only shown when it is created or changed
+type WebhookSecretResponse struct {
+	models.Webhook
+	Secret string `json:"secret"`
+}
+
+// GetWebhooksController handles GET /api/admin/webhooks (admin only)
+func GetWebhooksController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.MethodNotAllowed(w, "Only GET method allowed")
+		return
+	}
+
+	webhooks, err := models.GetWebhooks()
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve webhooks")
+		return
+	}
+
+	utils.Success(w, "Webhooks retrieved successfully", map[string]interface{}{
+		"webhooks": webhooks,
+		"events":   events.Names,
+	})
+}
+
+// CreateWebhookController handles POST /api/admin/webhooks (admin only)
+func CreateWebhookController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.MethodNotAllowed(w, "Only POST method allowed")
+		return
+	}
+
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid JSON format")
+		return
+	}
+
+	webhook := models.Webhook{Active: true}
+	if req.URL == nil {
+		var validationErrors utils.ValidationErrors
+		validationErrors.Add("url", "URL is required")
+		utils.ValidationError(w, validationErrors)
+		return
+	}
+	if req.Events == nil {
+		var validationErrors utils.ValidationErrors
+		validationErrors.Add("events", "At least one event is required")
+		utils.ValidationError(w, validationErrors)
+		return
+	}
+	if req.Secret == nil || strings.TrimSpace(*req.Secret) == "" {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			utils.InternalServerError(w, "Failed to generate webhook secret")
+			return
+		}
+		req.Secret = &secret
+	}
+
+	if validationErrors := applyWebhookRequest(&webhook, &req); validationErrors.HasErrors() {
+		utils.ValidationError(w, validationErrors)
+		return
+	}
+
+	if err := webhook.Create(); err != nil {
+		utils.InternalServerError(w, "Failed to create webhook")
+		return
+	}
+
+	utils.Created(w, "Webhook created successfully", WebhookSecretResponse{Webhook: webhook, Secret: webhook.Secret})
+}
+
+// UpdateWebhookController handles PUT /api/admin/webhooks/{id} (admin only)
+// Setting active back to true re-enables an endpoint that was disabled after failed deliveries.
+func UpdateWebhookController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.MethodNotAllowed(w, "Only PUT method allowed")
+		return
+	}
+
+	webhookID, err := utils.GetIDFromURL(r, "/admin/webhooks/")
+	if err != nil {
+		utils.BadRequest(w, "Invalid webhook ID")
+		return
+	}
+
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.BadRequest(w, "Invalid JSON format")
+		return
+	}
+
+	webhook, err := models.GetWebhookByID(webhookID)
+	if err == sql.ErrNoRows {
+		utils.NotFound(w, "Webhook not found")
+		return
+	}
+	if err != nil {
+		utils.InternalServerError(w, "Failed to retrieve webhook")
+		return
+	}
+
+	if validationErrors := applyWebhookRequest(webhook, &req); validationErrors.HasErrors() {
+		utils.ValidationError(w, validationErrors)
+		return
+	}
+
+	if err := webhook.Update(); err != nil {
+		utils.InternalServerError(w, "Failed to update webhook")
+		return
+	}
+
+	if req.Secret != nil {
+		utils.Success(w, "Webhook updated successfully", WebhookSecretResponse{Webhook: *webhook, Secret: webhook.Secret})
+		return
+	}
+	utils.Success(w, "Webhook updated successfully", webhook)
+}
+
+// DeleteWebhookController handles DELETE /api/admin/webhooks/{id} (admin only)
+func DeleteWebhookController(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.MethodNotAllowed(w, "Only DELETE method allowed")
+		return
+	}
+
+	webhookID, err := utils.GetIDFromURL(r, "/admin/webhooks/")
+	if err != nil {
+		utils.BadRequest(w, "Invalid webhook ID")
+		return
+	}
+
+	deleted, err := models.DeleteWebhook(webhookID)
+	if err != nil {
+		utils.InternalServerError(w, "Failed to delete webhook")
+		return
+	}
+	if !deleted {
+		utils.NotFound(w, "Webhook not found")
+		return
+	}
+
+	utils.Success(w, "Webhook deleted successfully", nil)
+}
+
+// applyWebhookRequest validates the fields present in req and copies them onto webhook
+func applyWebhookRequest(webhook *models.Webhook, req *WebhookRequest) utils.ValidationErrors {
+	var validationErrors utils.ValidationErrors
+
+	if req.URL != nil {
+		rawURL := strings.TrimSpace(*req.URL)
+		parsed, err := url.Parse(rawURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			validationErrors.Add("url", "URL must be an absolute http or https URL")
+		} else if len(rawURL) > 2048 {
+			validationErrors.Add("url", "URL must be at most 2048 characters")
+		} else {
+			webhook.URL = rawURL
+		}
+	}
+
+	if req.Secret != nil {
+		secret := strings.TrimSpace(*req.Secret)
+		if len(secret) < 16 || len(secret) > 255 {
+			validationErrors.Add("secret", "Secret must be between 16 and 255 characters")
+		} else {
+			webhook.Secret = secret
+		}
+	}
+
+	if req.Events != nil {
+		subscribed := []string{}
+		seen := make(map[string]bool)
+		for _, name := range req.Events {
+			if !events.IsValid(name) {
+				validationErrors.Add("events", "Unknown event '"+name+"'; expected one of "+strings.Join(events.Names, ", "))
+				break
+			}
+			if !seen[name] {
+				seen[name] = true
+				subscribed = append(subscribed, name)
+			}
+		}
+		if len(req.Events) == 0 {
+			validationErrors.Add("events", "At least one event is required")
+		}
+		webhook.Events = subscribed
+	}
+
+	if req.Active != nil {
+		webhook.Active = *req.Active
+	}
+
+	return validationErrors
+}
+
+// generateWebhookSecret makes a random signing secret
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
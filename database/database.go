@@ -13,6 +13,9 @@ import (
 // DB is the global database connection used with all models
 var DB *sql.DB
 
+// ReadDB is the optional connection for read-heavy queries; nil when reads share DB
+var ReadDB *sql.DB
+
 // Init initializes the database connection and runs migrations
 func Init() {
 	var err error
@@ -41,12 +44,34 @@ func Init() {
 
 	log.Println("Database connected successfully")
 
+	// A separate read database is opened only when configured and different,
+	// so single-file SQLite setups keep a single handle
+	if readURL := config.GetDatabaseReadURL(); readURL != "" && readURL != config.GetDatabaseURL() {
+		ReadDB, err = sql.Open("sqlite3", readURL)
+		if err != nil {
+			log.Fatal("Failed to connect to read database:", err)
+		}
+		if err = ReadDB.Ping(); err != nil {
+			log.Fatal("Failed to ping read database:", err)
+		}
+
+		ReadDB.SetMaxOpenConns(25)
+		ReadDB.SetMaxIdleConns(25)
+		ReadDB.SetConnMaxLifetime(5 * time.Minute)
+
+		log.Println("Read database connected successfully")
+	}
+
 	// Create all tables and insert default data
 	RunMigrations()
 }
 
 // Close closes the database connection
 func Close() {
+	if ReadDB != nil {
+		ReadDB.Close()
+		log.Println("Read database connection closed")
+	}
 	if DB != nil {
 		DB.Close()
 		log.Println("Database connection closed")
@@ -58,3 +83,18 @@ func Close() {
 func GetDB() *sql.DB {
 	return DB
 }
+
+// GetWriteDB returns the connection for inserts, updates, deletes and any read
+// that must see the caller's own writes. It is the same handle as GetDB.
+func GetWriteDB() *sql.DB {
+	return DB
+}
+
+// GetReadDB returns the connection for listings and other read-only queries that
+// can tolerate replica lag. Without a configured read database it is the main one.
+func GetReadDB() *sql.DB {
+	if ReadDB != nil {
+		return ReadDB
+	}
+	return DB
+}
@@ -3,6 +3,9 @@ package database
 import (
 	"fmt"
 	"log"
+	"strings"
+
+	"forum/config"
 )
 
 // RunMigrations creates all database tables and inserts default data
@@ -16,9 +19,23 @@ func RunMigrations() {
 	createCommentsTable()
 	createPostCategoriesTable() // does order matter ?
 	migratePostsToMultipleCategories()
+	addCategoryPostCounts()
+	addPostColumns()
+	addCommentColumns()
+	createVisibleCommentsView()
 
 	createVotesTable()
 	createSessionsTable()
+	createSettingsTable()
+	createModerationLogTable()
+	createReportsTable()
+	createNotificationsTable()
+	createLoginFailuresTable()
+	createUsernameHistoryTable()
+	createReactionsTable()
+	createAttachmentsTable()
+	createInvitesTable()
+	createWebhooksTable()
 
 	log.Println("Database migrations completed successfully")
 	fmt.Println()
@@ -36,6 +53,7 @@ func createUsersTable() {
 		email VARCHAR(100) UNIQUE NOT NULL,
 		password_hash VARCHAR(255) NOT NULL,
 		avatar VARCHAR(255) DEFAULT '',
+		role VARCHAR(20) NOT NULL DEFAULT 'user',
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);`
@@ -44,9 +62,25 @@ func createUsersTable() {
 		log.Fatal("Failed to create users table:", err)
 	}
 
+	// Columns added after the initial schema
+	addColumnIfNotExists("users", "role", "VARCHAR(20) NOT NULL DEFAULT 'user'")
+	addColumnIfNotExists("users", "last_active", "DATETIME")
+	addColumnIfNotExists("users", "notify_mentions", "INTEGER NOT NULL DEFAULT 1")
+	addColumnIfNotExists("users", "username_changed_at", "DATETIME")
+
+	// Bans: banned_until is stored in UTC and NULL when the user is not banned
+	addColumnIfNotExists("users", "banned_until", "DATETIME")
+	addColumnIfNotExists("users", "ban_reason", "TEXT NOT NULL DEFAULT ''")
+
+	// Privacy: parts of a user's activity hidden from everyone but themselves
+	addColumnIfNotExists("users", "hide_posts", "INTEGER NOT NULL DEFAULT 0")
+	addColumnIfNotExists("users", "hide_comments", "INTEGER NOT NULL DEFAULT 0")
+	addColumnIfNotExists("users", "hide_stats", "INTEGER NOT NULL DEFAULT 0")
+
 	// Create indexes for performance on frequently queried columns
 	createIndexIfNotExists("idx_users_username", "users", "username")
 	createIndexIfNotExists("idx_users_email", "users", "email")
+	createIndexIfNotExists("idx_users_last_active", "users", "last_active")
 
 	log.Println("✓ Users table created")
 }
@@ -70,6 +104,39 @@ func createCategoriesTable() {
 	// Create index for category lookups
 	createIndexIfNotExists("idx_categories_name", "categories", "name")
 
+	// URL slug and display style; slugs for existing rows are filled in at startup
+	addColumnIfNotExists("categories", "slug", "VARCHAR(60)")
+	addColumnIfNotExists("categories", "color", "VARCHAR(7) NOT NULL DEFAULT ''")
+	addColumnIfNotExists("categories", "icon", "VARCHAR(50) NOT NULL DEFAULT ''")
+
+	// Display order set by admins; ties (such as rows from before ordering existed) sort by name
+	addColumnIfNotExists("categories", "position", "INTEGER NOT NULL DEFAULT 0")
+
+	// Archived categories keep their posts but are hidden and closed to new ones
+	addColumnIfNotExists("categories", "archived", "INTEGER NOT NULL DEFAULT 0")
+
+	// Subcategories point at their parent; top-level categories have none
+	addColumnIfNotExists("categories", "parent_id", "INTEGER REFERENCES categories(id)")
+	createIndexIfNotExists("idx_categories_parent_id", "categories", "parent_id")
+
+	// Who may file new posts in a category: everyone, moderators or admins
+	addColumnIfNotExists("categories", "post_permission", "TEXT NOT NULL DEFAULT 'everyone'")
+	if _, err := DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_slug ON categories(slug)`); err != nil {
+		log.Printf("Warning: Failed to create index idx_categories_slug : %v", err)
+	}
+
+	// Slugs a category used to have, so old links keep working after a slug change
+	redirects := `
+	CREATE TABLE IF NOT EXISTS category_slug_redirects (
+		old_slug VARCHAR(60) PRIMARY KEY,
+		category_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+	);`
+	if _, err := DB.Exec(redirects); err != nil {
+		log.Fatal("Failed to create category_slug_redirects table:", err)
+	}
+
 	// Insert default categories for the forum
 	insertDefaultCategories()
 
@@ -264,8 +331,88 @@ func migratePostsToMultipleCategories() {
 	log.Println("✓ Successfully migrated posts table - category_id column removed")
 }
 
+// addCategoryPostCounts adds the cached per-category post count, which post writes keep
+// up to date. It runs after post_categories is filled so the first count is right.
+func addCategoryPostCounts() {
+	if !addColumnIfNotExists("categories", "post_count", "INTEGER NOT NULL DEFAULT 0") {
+		return
+	}
+
+	_, err := DB.Exec(`
+		UPDATE categories
+		SET post_count = (SELECT COUNT(*) FROM post_categories pc WHERE pc.category_id = categories.id)
+	`)
+	if err != nil {
+		log.Fatal("Failed to fill categories.post_count:", err)
+	}
+}
+
+// addPostColumns adds columns introduced after the posts table was first created.
+// It runs after migratePostsToMultipleCategories since that migration rebuilds the table.
+func addPostColumns() {
+	// The category migration rebuilt posts without the vote counters
+	addColumnIfNotExists("posts", "likes", "INTEGER DEFAULT 0")
+	addColumnIfNotExists("posts", "dislikes", "INTEGER DEFAULT 0")
+
+	addColumnIfNotExists("posts", "accepted_comment_id", "INTEGER REFERENCES comments(id) ON DELETE SET NULL")
+
+	// Pinned posts are listed first; the scope says where ('global' or 'category')
+	addColumnIfNotExists("posts", "pinned", "INTEGER NOT NULL DEFAULT 0")
+	addColumnIfNotExists("posts", "pin_scope", "VARCHAR(20) NOT NULL DEFAULT ''")
+	addColumnIfNotExists("posts", "pinned_at", "DATETIME")
+}
+
+// addCommentColumns adds columns introduced after the comments table was first created
+func addCommentColumns() {
+	// Comments hidden pending moderator review (e.g. after enough reports)
+	addColumnIfNotExists("comments", "hidden", "INTEGER NOT NULL DEFAULT 0")
+
+	// Quoted replies keep a snapshot of the quoted text so later edits don't rewrite history
+	addColumnIfNotExists("comments", "quoted_comment_id", "INTEGER REFERENCES comments(id) ON DELETE SET NULL")
+	addColumnIfNotExists("comments", "quote_username", "VARCHAR(50)")
+	addColumnIfNotExists("comments", "quote_excerpt", "TEXT")
+	addColumnIfNotExists("comments", "quote_removed", "INTEGER NOT NULL DEFAULT 0")
+
+	// Threaded replies
+	addColumnIfNotExists("comments", "parent_id", "INTEGER REFERENCES comments(id) ON DELETE SET NULL")
+	createIndexIfNotExists("idx_comments_parent_id", "comments", "parent_id")
+
+	// Soft deletion keeps the row so replies and moderation history stay intact
+	addColumnIfNotExists("comments", "deleted_at", "DATETIME")
+	// Set when a moderator (rather than the author) removed the comment
+	addColumnIfNotExists("comments", "removed_by", "INTEGER REFERENCES users(id) ON DELETE SET NULL")
+	addColumnIfNotExists("comments", "removal_reason", "TEXT")
+
+	// Set only when the content is edited; existing comments stay NULL (never edited)
+	addColumnIfNotExists("comments", "edited_at", "DATETIME")
+}
+
+// createVisibleCommentsView defines the comments readers can see. Every comment count and
+// listing reads from this view so the visibility rule lives in one place.
+func createVisibleCommentsView() {
+	// Recreated on every start so changes to the rule (and BANNED_CONTENT_POLICY) take effect
+	rule := "deleted_at IS NULL AND hidden = 0"
+	if config.HideBannedContent() {
+		rule += " AND user_id NOT IN (SELECT id FROM users WHERE banned_until > datetime('now'))"
+	}
+
+	query := `
+	DROP VIEW IF EXISTS visible_comments;
+	CREATE VIEW visible_comments AS
+		SELECT * FROM comments WHERE ` + rule + `;`
+
+	if _, err := DB.Exec(query); err != nil {
+		log.Fatal("Failed to create visible_comments view:", err)
+	}
+
+	log.Println("✓ Visible comments view created")
+}
+
 func createVotesTable() {
-	// Votes table creation with foreign keys to users, posts, and comments
+	// Votes table creation with foreign keys to users, posts, and comments.
+	// A vote targets exactly one post or one comment; uniqueness per user is
+	// enforced by the partial indexes below, since SQLite treats NULLs as distinct
+	// in a plain UNIQUE(user_id, post_id, comment_id).
 	query := `
 	CREATE TABLE IF NOT EXISTS votes (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -277,21 +424,111 @@ func createVotesTable() {
 		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
 		FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
 		FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE,
-		UNIQUE(user_id, post_id, comment_id)
+		CHECK((post_id IS NULL) != (comment_id IS NULL))
 	);`
 
 	if _, err := DB.Exec(query); err != nil {
 		log.Fatal("Failed to create votes table:", err)
 	}
 
+	migrateVotesUniqueness()
+
 	// Create indexes for performance
 	createIndexIfNotExists("idx_votes_user_id", "votes", "user_id")
 	createIndexIfNotExists("idx_votes_post_id", "votes", "post_id")
 	createIndexIfNotExists("idx_votes_comment_id", "votes", "comment_id")
 
+	// One vote per user per post, and per user per comment
+	uniqueIndexes := []string{
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_votes_user_post ON votes(user_id, post_id) WHERE comment_id IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_votes_user_comment ON votes(user_id, comment_id) WHERE post_id IS NULL`,
+	}
+	for _, index := range uniqueIndexes {
+		if _, err := DB.Exec(index); err != nil {
+			log.Fatal("Failed to create votes unique index:", err)
+		}
+	}
+
 	log.Println("✓ Votes table created")
 }
 
+// migrateVotesUniqueness rebuilds a votes table created with the old
+// UNIQUE(user_id, post_id, comment_id) constraint, which never fired for NULL targets.
+// Duplicate votes are collapsed to the newest one, rows that do not target exactly
+// one post or comment are dropped, and the like/dislike counters are recounted.
+func migrateVotesUniqueness() {
+	var schema string
+	err := DB.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'votes'`).Scan(&schema)
+	if err != nil {
+		log.Printf("Warning: Could not read votes schema: %v", err)
+		return
+	}
+	if !strings.Contains(schema, "UNIQUE(user_id, post_id, comment_id)") {
+		return
+	}
+
+	log.Println("  → Rebuilding votes table without duplicate votes...")
+
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Fatal("Failed to start votes migration:", err)
+	}
+	defer tx.Rollback() // Will be ignored if we commit successfully
+
+	steps := []string{
+		`CREATE TABLE votes_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			post_id INTEGER,
+			comment_id INTEGER,
+			vote_type VARCHAR(10) NOT NULL CHECK(vote_type IN ('like', 'dislike')),
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+			FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE,
+			CHECK((post_id IS NULL) != (comment_id IS NULL))
+		)`,
+		// Keep the newest vote per user and target; ties on created_at go to the higher id
+		`INSERT INTO votes_new (id, user_id, post_id, comment_id, vote_type, created_at)
+		SELECT id, user_id, post_id, comment_id, vote_type, created_at
+		FROM votes v
+		WHERE (v.post_id IS NULL) != (v.comment_id IS NULL)
+		AND NOT EXISTS (
+			SELECT 1 FROM votes newer
+			WHERE newer.user_id = v.user_id
+			AND newer.post_id IS v.post_id
+			AND newer.comment_id IS v.comment_id
+			AND (newer.created_at > v.created_at OR (newer.created_at = v.created_at AND newer.id > v.id))
+		)`,
+		`DROP TABLE votes`,
+		`ALTER TABLE votes_new RENAME TO votes`,
+		`UPDATE posts
+		SET likes = (SELECT COUNT(*) FROM votes WHERE post_id = posts.id AND vote_type = 'like'),
+		    dislikes = (SELECT COUNT(*) FROM votes WHERE post_id = posts.id AND vote_type = 'dislike')`,
+		`UPDATE comments
+		SET likes = (SELECT COUNT(*) FROM votes WHERE comment_id = comments.id AND vote_type = 'like'),
+		    dislikes = (SELECT COUNT(*) FROM votes WHERE comment_id = comments.id AND vote_type = 'dislike')`,
+	}
+
+	var before, after int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM votes`).Scan(&before); err != nil {
+		log.Fatal("Failed to count votes:", err)
+	}
+	for _, step := range steps {
+		if _, err := tx.Exec(step); err != nil {
+			log.Fatal("Failed to migrate votes table:", err)
+		}
+	}
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM votes`).Scan(&after); err != nil {
+		log.Fatal("Failed to count votes:", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Fatal("Failed to commit votes migration:", err)
+	}
+	log.Printf("  → Removed %d duplicate or invalid votes", before-after)
+}
+
 // createSessionsTable creates the sessions table for user authentication
 func createSessionsTable() {
 	// Sessions table creation with foreign key to users
@@ -315,6 +552,270 @@ func createSessionsTable() {
 	log.Println("✓ Sessions table created")
 }
 
+// createSettingsTable creates the key/value table for runtime-tunable settings
+func createSettingsTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS settings (
+		key VARCHAR(64) PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := DB.Exec(query); err != nil {
+		log.Fatal("Failed to create settings table:", err)
+	}
+
+	log.Println("✓ Settings table created")
+}
+
+// createModerationLogTable creates the audit trail of moderator actions
+func createModerationLogTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS moderation_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		actor_id INTEGER NOT NULL,
+		action VARCHAR(50) NOT NULL,
+		target_type VARCHAR(20) NOT NULL,
+		target_id INTEGER NOT NULL,
+		reason TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	if _, err := DB.Exec(query); err != nil {
+		log.Fatal("Failed to create moderation_log table:", err)
+	}
+
+	createIndexIfNotExists("idx_moderation_log_actor_id", "moderation_log", "actor_id")
+	createIndexIfNotExists("idx_moderation_log_created_at", "moderation_log", "created_at")
+
+	log.Println("✓ Moderation log table created")
+}
+
+// createReportsTable creates the reports table shared by all reportable content
+func createReportsTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS reports (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		reporter_id INTEGER NOT NULL,
+		target_type VARCHAR(20) NOT NULL,
+		target_id INTEGER NOT NULL,
+		reason VARCHAR(30) NOT NULL,
+		details TEXT DEFAULT '',
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		resolution VARCHAR(30) DEFAULT '',
+		resolved_by INTEGER,
+		resolved_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (resolved_by) REFERENCES users(id) ON DELETE SET NULL,
+		UNIQUE(reporter_id, target_type, target_id)
+	);`
+
+	if _, err := DB.Exec(query); err != nil {
+		log.Fatal("Failed to create reports table:", err)
+	}
+
+	createIndexIfNotExists("idx_reports_target", "reports", "target_type, target_id")
+	createIndexIfNotExists("idx_reports_status", "reports", "status")
+	createIndexIfNotExists("idx_reports_reporter_created", "reports", "reporter_id, created_at")
+
+	log.Println("✓ Reports table created")
+}
+
+// createNotificationsTable creates the notifications table for per-user alerts
+func createNotificationsTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS notifications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		actor_id INTEGER,
+		type VARCHAR(30) NOT NULL,
+		post_id INTEGER,
+		comment_id INTEGER,
+		message TEXT NOT NULL,
+		read INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+		FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE SET NULL,
+		FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+		FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+	);`
+
+	if _, err := DB.Exec(query); err != nil {
+		log.Fatal("Failed to create notifications table:", err)
+	}
+
+	// (user_id, read) keeps the unread badge count an index-only lookup
+	createIndexIfNotExists("idx_notifications_user_read", "notifications", "user_id, read")
+	createIndexIfNotExists("idx_notifications_user_created", "notifications", "user_id, created_at")
+
+	log.Println("✓ Notifications table created")
+}
+
+// createLoginFailuresTable records failed logins used to lock accounts under brute force
+func createLoginFailuresTable() {
+	// Failures used to be stored per user ID, which left unknown usernames untracked.
+	// They only matter for a few minutes, so the old table is simply replaced.
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM pragma_table_info('login_failures') WHERE name='user_id'").Scan(&count)
+	if err != nil {
+		log.Fatal("Failed to check login_failures schema:", err)
+	}
+	if count > 0 {
+		if _, err := DB.Exec(`DROP TABLE login_failures`); err != nil {
+			log.Fatal("Failed to drop old login_failures table:", err)
+		}
+		log.Println("  → Replaced login_failures table")
+	}
+
+	query := `
+	CREATE TABLE IF NOT EXISTS login_failures (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		login_key VARCHAR(160) NOT NULL,
+		ip_address VARCHAR(45),
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := DB.Exec(query); err != nil {
+		log.Fatal("Failed to create login_failures table:", err)
+	}
+
+	createIndexIfNotExists("idx_login_failures_key_created", "login_failures", "login_key, created_at")
+	createIndexIfNotExists("idx_login_failures_created", "login_failures", "created_at")
+
+	// A lock is kept until it ends rather than derived from failures that age out of the window
+	lockouts := `
+	CREATE TABLE IF NOT EXISTS login_lockouts (
+		login_key VARCHAR(160) PRIMARY KEY,
+		locked_until DATETIME NOT NULL
+	);`
+
+	if _, err := DB.Exec(lockouts); err != nil {
+		log.Fatal("Failed to create login_lockouts table:", err)
+	}
+
+	log.Println("✓ Login failures table created")
+}
+
+// createUsernameHistoryTable keeps every username change for auditing
+func createUsernameHistoryTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS username_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		old_username VARCHAR(50) NOT NULL,
+		new_username VARCHAR(50) NOT NULL,
+		changed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	if _, err := DB.Exec(query); err != nil {
+		log.Fatal("Failed to create username_history table:", err)
+	}
+
+	createIndexIfNotExists("idx_username_history_user", "username_history", "user_id, changed_at")
+
+	log.Println("✓ Username history table created")
+}
+
+// createReactionsTable stores emoji reactions on posts and comments
+func createReactionsTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS reactions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		target_type VARCHAR(20) NOT NULL,
+		target_id INTEGER NOT NULL,
+		emoji VARCHAR(16) NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	if _, err := DB.Exec(query); err != nil {
+		log.Fatal("Failed to create reactions table:", err)
+	}
+
+	// One reaction per emoji per user per target
+	if _, err := DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_reactions_unique ON reactions(user_id, target_type, target_id, emoji);`); err != nil {
+		log.Fatal("Failed to create reactions unique index:", err)
+	}
+	createIndexIfNotExists("idx_reactions_target", "reactions", "target_type, target_id")
+
+	log.Println("✓ Reactions table created")
+}
+
+// createAttachmentsTable creates the table of files uploaded to posts
+func createAttachmentsTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS attachments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		post_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		filename VARCHAR(255) NOT NULL,
+		original_name VARCHAR(255) NOT NULL DEFAULT '',
+		mime_type VARCHAR(100) NOT NULL,
+		size INTEGER NOT NULL DEFAULT 0,
+		url VARCHAR(255) NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	if _, err := DB.Exec(query); err != nil {
+		log.Fatal("Failed to create attachments table:", err)
+	}
+
+	createIndexIfNotExists("idx_attachments_post", "attachments", "post_id")
+
+	log.Println("✓ Attachments table created")
+}
+
+// createInvitesTable creates the table of registration invite codes
+func createInvitesTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS invites (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		code VARCHAR(64) UNIQUE NOT NULL,
+		created_by INTEGER,
+		used_by INTEGER,
+		used_at DATETIME,
+		expires_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL,
+		FOREIGN KEY (used_by) REFERENCES users(id) ON DELETE SET NULL
+	);`
+
+	if _, err := DB.Exec(query); err != nil {
+		log.Fatal("Failed to create invites table:", err)
+	}
+
+	log.Println("✓ Invites table created")
+}
+
+// createWebhooksTable creates the table of endpoints that receive event notifications
+func createWebhooksTable() {
+	query := `
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url VARCHAR(2048) NOT NULL,
+		secret VARCHAR(255) NOT NULL,
+		events TEXT NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT 1,
+		failure_count INTEGER NOT NULL DEFAULT 0,
+		last_delivery_at DATETIME,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := DB.Exec(query); err != nil {
+		log.Fatal("Failed to create webhooks table:", err)
+	}
+
+	log.Println("✓ Webhooks table created")
+}
+
 // createIndexIfNotExists creates an index only if it doesn't already exist
 func createIndexIfNotExists(indexName, tableName, columnName string) {
 	query := ` CREATE INDEX IF NOT EXISTS ` + indexName + ` ON ` + tableName + `(` + columnName + `);`
@@ -323,6 +824,27 @@ func createIndexIfNotExists(indexName, tableName, columnName string) {
 	}
 }
 
+// addColumnIfNotExists adds a column to an existing table when it is missing
+// (SQLite has no ADD COLUMN IF NOT EXISTS) and reports whether it did
+func addColumnIfNotExists(tableName, columnName, definition string) (added bool) {
+	var count int
+	query := `SELECT COUNT(*) FROM pragma_table_info('` + tableName + `') WHERE name = ?`
+	if err := DB.QueryRow(query, columnName).Scan(&count); err != nil {
+		log.Printf("Warning: Could not check for %s.%s column: %v", tableName, columnName, err)
+		return false
+	}
+	if count > 0 {
+		return false
+	}
+
+	alter := `ALTER TABLE ` + tableName + ` ADD COLUMN ` + columnName + ` ` + definition
+	if _, err := DB.Exec(alter); err != nil {
+		log.Fatalf("Failed to add column %s.%s: %v", tableName, columnName, err)
+	}
+	log.Printf("  → Added %s.%s column", tableName, columnName)
+	return true
+}
+
 // insertDefaultCategories populates the categories table with default forum sections
 func insertDefaultCategories() {
 	categories := []struct {
@@ -339,10 +861,10 @@ func insertDefaultCategories() {
 		{"showcase", "Showcase - Show off your projects and creations"},
 	}
 
-	// Insert each category (INSERT OR IGNORE prevents duplicates)
-	for _, cat := range categories {
-		query := `INSERT OR IGNORE INTO categories (name, description) VALUES (?, ?)`
-		_, err := DB.Exec(query, cat.name, cat.description)
+	// Insert each category (INSERT OR IGNORE prevents duplicates), in list order
+	for i, cat := range categories {
+		query := `INSERT OR IGNORE INTO categories (name, description, position) VALUES (?, ?, ?)`
+		_, err := DB.Exec(query, cat.name, cat.description, i+1)
 		if err != nil {
 			log.Printf("Warning: Failed to insert category %s: %v", cat.name, err)
 		}
@@ -0,0 +1,61 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event names published by the application
+const (
+	PostCreated    = "post.created"
+	CommentCreated = "comment.created"
+	VoteAdded      = "vote.added"
+	UserRegistered = "user.registered"
+)
+
+// Names lists every event that can be published, in display order
+var Names = []string{PostCreated, CommentCreated, VoteAdded, UserRegistered}
+
+// IsValid checks if name is a known event
+func IsValid(name string) bool {
+	for _, known := range Names {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is something that happened, with a JSON-serializable payload
+type Event struct {
+	Name       string
+	Data       interface{}
+	OccurredAt time.Time
+}
+
+// Handler receives published events. It runs on the publisher's goroutine,
+// so anything slow (like network calls) must be handed off.
+type Handler func(Event)
+
+var (
+	mu       sync.RWMutex
+	handlers []Handler
+)
+
+// Subscribe registers a handler for every published event
+func Subscribe(handler Handler) {
+	mu.Lock()
+	handlers = append(handlers, handler)
+	mu.Unlock()
+}
+
+// Publish hands an event to every subscriber. Publishing with no subscribers is a no-op.
+func Publish(name string, data interface{}) {
+	event := Event{Name: name, Data: data, OccurredAt: time.Now()}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -13,16 +14,72 @@ import (
 	"forum/config"
 	"forum/database"
 	"forum/middleware"
+	"forum/models"
+	"forum/presence"
 	"forum/routes"
+	"forum/utils"
+	"forum/webhooks"
 )
 
 func main() {
+	recountVotes := flag.Bool("recount-votes", false, "resync cached vote counters with the votes table before serving")
+	flag.Parse()
+
 	// Load Config
 	config.Load()
 
+	// Apply configurable upload limits
+	utils.ApplyUploadConfig()
+
+	// Load the disposable email domain blocklist
+	utils.ApplyEmailConfig()
+
+	// Only honor forwarding headers from configured proxies
+	middleware.SetTrustedProxies(config.GetTrustedProxies())
+
 	// Initialize database
 	database.Init()
 
+	// Promote configured admin accounts
+	if err := models.PromoteAdmins(config.GetAdminUsernames()); err != nil {
+		log.Printf("Warning: failed to promote admin users: %v", err)
+	}
+
+	// Give categories created before slugs existed a slug
+	if err := models.BackfillCategorySlugs(); err != nil {
+		log.Printf("Warning: failed to backfill category slugs: %v", err)
+	}
+
+	// Fix drifted like/dislike counters if the operator asked for it
+	if *recountVotes {
+		result, err := models.RecountVotes()
+		if err != nil {
+			log.Fatal("Failed to recount votes:", err)
+		}
+		log.Printf("Recounted votes: %d posts and %d comments updated", result.PostsUpdated, result.CommentsUpdated)
+	}
+
+	if sortBy := config.GetDefaultPostSort(); !models.IsValidPostSort(sortBy) {
+		log.Printf("Warning: DEFAULT_POST_SORT %q is not a valid sort, using %q", sortBy, models.PostSortNewest)
+	}
+
+	// Load runtime settings into memory
+	if err := models.Settings.Load(); err != nil {
+		log.Fatal("Failed to load settings:", err)
+	}
+
+	// Deliver events to registered webhooks
+	webhooks.Start()
+
+	// Expire post viewers that stop sending heartbeats
+	presence.Start()
+
+	// Start in maintenance mode if requested (env or persisted setting)
+	if config.IsMaintenanceMode() || models.Settings.GetBool(models.SettingMaintenanceMode, false) {
+		middleware.SetMaintenanceMode(true, models.Settings.GetString(models.SettingMaintenanceMessage, ""))
+		log.Println("Maintenance mode is ON - API requests will receive 503")
+	}
+
 	// Setup routes with enhanced rate limiting
 	mux := routes.SetupRoutes()
 
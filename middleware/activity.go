@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"forum/models"
+)
+
+// ActivityUpdateInterval throttles last_active writes to at most one per user per interval
+const ActivityUpdateInterval = time.Minute
+
+var (
+	activityMu       sync.Mutex
+	lastActivityByID = make(map[int]time.Time)
+)
+
+// trackActivity records that a user made an authenticated request,
+// skipping the database write if it was already recorded recently
+func trackActivity(userID int) {
+	now := time.Now()
+
+	activityMu.Lock()
+	if last, ok := lastActivityByID[userID]; ok && now.Sub(last) < ActivityUpdateInterval {
+		activityMu.Unlock()
+		return
+	}
+	lastActivityByID[userID] = now
+	activityMu.Unlock()
+
+	if err := models.UpdateLastActive(userID, now); err != nil {
+		log.Printf("Failed to update last activity for user %d: %v", userID, err)
+	}
+}
+
+// pruneActivity forgets users whose last recorded activity is older than
+// ActivityUpdateInterval; their next request writes last_active again anyway
+func pruneActivity(now time.Time) {
+	activityMu.Lock()
+	defer activityMu.Unlock()
+
+	for userID, last := range lastActivityByID {
+		if now.Sub(last) >= ActivityUpdateInterval {
+			delete(lastActivityByID, userID)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPruneActivity(t *testing.T) {
+	now := time.Now()
+
+	activityMu.Lock()
+	lastActivityByID = map[int]time.Time{
+		1: now,
+		2: now.Add(-ActivityUpdateInterval / 2),
+		3: now.Add(-ActivityUpdateInterval),
+		4: now.Add(-time.Hour),
+	}
+	activityMu.Unlock()
+	t.Cleanup(func() {
+		activityMu.Lock()
+		lastActivityByID = make(map[int]time.Time)
+		activityMu.Unlock()
+	})
+
+	pruneActivity(now)
+
+	activityMu.Lock()
+	defer activityMu.Unlock()
+	for _, userID := range []int{1, 2} {
+		if _, ok := lastActivityByID[userID]; !ok {
+			t.Errorf("user %d was pruned while still inside the update interval", userID)
+		}
+	}
+	for _, userID := range []int{3, 4} {
+		if _, ok := lastActivityByID[userID]; ok {
+			t.Errorf("user %d was kept after the update interval passed", userID)
+		}
+	}
+}
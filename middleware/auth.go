@@ -6,9 +6,9 @@ import (
 	"log"
 	"net/http"
 	"runtime/debug"
-	"strings"
 	"time"
 
+	"forum/models"
 	"forum/utils"
 )
 
@@ -22,6 +22,8 @@ const (
 	UsernameKey ContextKey = "username"
 	// SessionKey is the context key for session
 	SessionKey ContextKey = "session"
+	// RoleKey is the context key for the user's role
+	RoleKey ContextKey = "role"
 )
 
 // OptionalAuth middleware provides user info if logged in, but doesn't require it
@@ -44,7 +46,7 @@ func OptionalAuth(next http.HandlerFunc) http.HandlerFunc {
 		}
 
 		// Get user information
-		userID, username, err := utils.GetCurrentUser(r)
+		userID, username, role, err := utils.GetCurrentUser(r)
 		if err != nil {
 			// Invalid session, continue without auth
 			next(w, r)
@@ -54,7 +56,11 @@ func OptionalAuth(next http.HandlerFunc) http.HandlerFunc {
 		// Add user info to context
 		ctx := context.WithValue(r.Context(), UserIDKey, userID)
 		ctx = context.WithValue(ctx, UsernameKey, username)
+		ctx = context.WithValue(ctx, RoleKey, role)
 		ctx = context.WithValue(ctx, SessionKey, session)
+
+		trackActivity(userID)
+
 		// Continue with authenticated context
 		next(w, r.WithContext(ctx))
 	}
@@ -79,17 +85,26 @@ func RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 		}
 
 		// Get user information
-		userID, username, err := utils.GetCurrentUser(r)
+		userID, username, role, err := utils.GetCurrentUser(r)
 		if err != nil {
 			utils.Unauthorized(w, "Invalid session. Please log in again.")
 			return
 		}
 
+		// Banned users keep their session but can't act until the ban ends
+		if until, reason, err := utils.GetSessionBan(r); err == nil && until != nil {
+			utils.ErrorWithCode(w, http.StatusForbidden, "account_banned", banMessage(*until, reason))
+			return
+		}
+
 		// Add user info to context
 		ctx := context.WithValue(r.Context(), UserIDKey, userID)
 		ctx = context.WithValue(ctx, UsernameKey, username)
+		ctx = context.WithValue(ctx, RoleKey, role)
 		ctx = context.WithValue(ctx, SessionKey, session)
 
+		trackActivity(userID)
+
 		// Optional: Refresh session if it's halfway to expiration
 		if time.Until(session.ExpiresAt) < utils.SessionDuration/2 {
 			refreshedSession, err := utils.RefreshSession(session.ID)
@@ -104,6 +119,18 @@ func RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// banMessage tells a banned user how long the ban lasts and why
+func banMessage(until time.Time, reason string) string {
+	message := "Your account is banned until " + until.UTC().Format("2006-01-02 15:04 MST")
+	if models.IsPermanentBan(until) {
+		message = "Your account is permanently banned"
+	}
+	if reason != "" {
+		message += ": " + reason
+	}
+	return message
+}
+
 // GetUserIDFromContext retrieves user ID from request context
 func GetUserIDFromContext(r *http.Request) (int, bool) {
 	userID, ok := r.Context().Value(UserIDKey).(int)
@@ -116,6 +143,46 @@ func GetUsernameFromContext(r *http.Request) (string, bool) {
 	return username, ok
 }
 
+// GetRoleFromContext retrieves the user's role from request context
+func GetRoleFromContext(r *http.Request) (string, bool) {
+	role, ok := r.Context().Value(RoleKey).(string)
+	return role, ok
+}
+
+// IsAdmin checks if the request comes from an authenticated admin
+func IsAdmin(r *http.Request) bool {
+	role, _ := GetRoleFromContext(r)
+	return role == models.RoleAdmin
+}
+
+// IsModerator checks if the request comes from an authenticated moderator or admin
+func IsModerator(r *http.Request) bool {
+	role, _ := GetRoleFromContext(r)
+	return models.IsModeratorRole(role)
+}
+
+// RequireAdmin middleware requires an authenticated admin
+func RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if !IsAdmin(r) {
+			utils.Forbidden(w, "Admin access required")
+			return
+		}
+		next(w, r)
+	})
+}
+
+// RequireModerator middleware requires an authenticated moderator or admin
+func RequireModerator(next http.HandlerFunc) http.HandlerFunc {
+	return RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if !IsModerator(r) {
+			utils.Forbidden(w, "Moderator access required")
+			return
+		}
+		next(w, r)
+	})
+}
+
 // LogRequests middleware logs HTTP requests (basic logging)
 func LogRequests(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -142,37 +209,12 @@ func LogRequests(next http.HandlerFunc) http.HandlerFunc {
 			r.URL.Path,
 			wrapped.statusCode,
 			duration,
-			getClientIP(r),
+			ClientIP(r),
 			userInfo,
 		)
 	}
 }
 
-// Helper functions
-
-// getClientIP gets client IP address from request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header (for proxies)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// Take the first IP
-		ips := strings.Split(xff, ",")
-		return strings.TrimSpace(ips[0])
-	}
-
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
-
-	// Use RemoteAddr
-	ip := r.RemoteAddr
-	if colon := strings.LastIndex(ip, ":"); colon != -1 {
-		ip = ip[:colon]
-	}
-
-	return ip
-}
-
 // responseWriter wraps http.ResponseWriter to capture status code
 type responseWriter struct {
 	http.ResponseWriter
@@ -185,6 +227,13 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Flush lets streaming handlers push buffered data through the wrapper
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 
 // Recovery middleware catches panics and returns 500
 func Recovery(next http.HandlerFunc) http.HandlerFunc {
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	trustedProxiesMu sync.RWMutex
+	trustedProxies   []*net.IPNet
+)
+
+// SetTrustedProxies configures which proxies may set X-Forwarded-For / X-Real-IP.
+// Entries are CIDRs ("10.0.0.0/8") or single IPs; invalid entries are skipped with a warning.
+func SetTrustedProxies(entries []string) {
+	var nets []*net.IPNet
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("Warning: ignoring invalid trusted proxy %q: %v", entry, err)
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+
+	trustedProxiesMu.Lock()
+	trustedProxies = nets
+	trustedProxiesMu.Unlock()
+}
+
+// isTrustedProxy reports whether the IP belongs to a configured trusted proxy
+func isTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	trustedProxiesMu.RLock()
+	defer trustedProxiesMu.RUnlock()
+
+	for _, ipNet := range trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the IP address of the client that made the request.
+// Forwarding headers are only honored when the direct peer is a trusted proxy,
+// so clients can't spoof their address to dodge rate limiting.
+func ClientIP(r *http.Request) string {
+	remoteIP := extractIP(r.RemoteAddr)
+	if !isTrustedProxy(net.ParseIP(remoteIP)) {
+		return remoteIP
+	}
+
+	// Walk X-Forwarded-For from the nearest hop back, skipping our own proxies;
+	// the first untrusted address is the client. A hop we can't parse stops the
+	// walk at the last proxy we trust rather than falling back to X-Real-IP,
+	// which the client could have set itself
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		last := remoteIP
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			ip := net.ParseIP(hop)
+			if ip == nil {
+				return last
+			}
+			if !isTrustedProxy(ip) || i == 0 {
+				return ip.String()
+			}
+			last = ip.String()
+		}
+	}
+
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); xri != "" {
+		if ip := net.ParseIP(xri); ip != nil {
+			return ip.String()
+		}
+	}
+
+	return remoteIP
+}
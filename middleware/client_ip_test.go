@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientIP(t *testing.T) {
+	SetTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1", "not-an-ip"})
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        string
+		xri        string
+		want       string
+	}{
+		{"no headers", "203.0.113.5:1234", "", "", "203.0.113.5"},
+		{"spoofed X-Forwarded-For from a client", "203.0.113.5:1234", "1.2.3.4", "", "203.0.113.5"},
+		{"spoofed X-Real-IP from a client", "203.0.113.5:1234", "", "1.2.3.4", "203.0.113.5"},
+		{"X-Forwarded-For from a trusted proxy", "10.1.2.3:80", "198.51.100.7", "", "198.51.100.7"},
+		{"X-Real-IP from a trusted proxy", "192.168.1.1:80", "", "198.51.100.7", "198.51.100.7"},
+		{"spoofed hop behind a trusted proxy", "10.1.2.3:80", "1.2.3.4, 198.51.100.7", "", "198.51.100.7"},
+		{"chain of trusted proxies", "10.1.2.3:80", "198.51.100.7, 10.9.9.9", "", "198.51.100.7"},
+		{"garbage from a trusted proxy", "10.1.2.3:80", "not-an-ip", "", "10.1.2.3"},
+		{"garbage does not fall back to X-Real-IP", "10.1.2.3:80", "not-an-ip", "1.2.3.4", "10.1.2.3"},
+		{"garbage behind a chain of trusted proxies", "10.1.2.3:80", "1.2.3.4, not-an-ip, 10.9.9.9", "1.2.3.4", "10.9.9.9"},
+		{"single IP entry only trusts that IP", "192.168.1.2:80", "198.51.100.7", "", "192.168.1.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.xri != "" {
+				r.Header.Set("X-Real-IP", tt.xri)
+			}
+
+			if got := ClientIP(r); got != tt.want {
+				t.Fatalf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	SetTrustedProxies(nil)
+	previous := GetRateLimits()["auth"]
+	SetRateLimit("auth", 2, time.Hour)
+	ResetAllVisitors()
+	t.Cleanup(func() {
+		SetRateLimit("auth", previous.MaxRequests, previous.Window)
+		ResetAllVisitors()
+	})
+
+	handler := RateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	codes := make([]int, 0, 3)
+	for _, spoofed := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+		r := httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+		r.RemoteAddr = "203.0.113.9:5555"
+		r.Header.Set("X-Forwarded-For", spoofed)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		codes = append(codes, rec.Code)
+	}
+
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Fatalf("statuses = %v, want the third request limited despite new forwarded IPs", codes)
+	}
+}
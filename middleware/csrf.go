@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"forum/utils"
+)
+
+// Paths that are used before a session exists and so carry no CSRF token
+var csrfExemptPaths = map[string]bool{
+	"/api/auth/login":    true,
+	"/api/auth/register": true,
+}
+
+// CSRF middleware implements double-submit token protection for cookie-authenticated
+// sessions: state-changing requests (POST/PUT/DELETE) must send an X-CSRF-Token header
+// matching the csrf cookie. Sessions that predate the token get one issued on their next request.
+// It must run after OptionalAuth so the session is available.
+func CSRF(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, ok := r.Context().Value(SessionKey).(*utils.Session)
+		if !ok || session == nil {
+			// Not cookie-authenticated, nothing to protect
+			next(w, r)
+			return
+		}
+
+		if _, err := r.Cookie(utils.CSRFCookieName); err != nil {
+			// Issue a token so the client can use it from now on
+			utils.SetCSRFCookie(w, session)
+		}
+
+		if !isStateChanging(r.Method) || csrfExemptPaths[strings.TrimSuffix(r.URL.Path, "/")] {
+			next(w, r)
+			return
+		}
+
+		if !utils.ValidCSRFToken(r) {
+			utils.ErrorWithCode(w, http.StatusForbidden, "csrf_token_invalid", "Missing or invalid CSRF token")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// isStateChanging reports whether the HTTP method can modify server state
+func isStateChanging(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"forum/utils"
+)
+
+func TestCSRF(t *testing.T) {
+	handler := CSRF(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	session := &utils.Session{ID: "session", UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		session  bool
+		cookie   string
+		header   string
+		wantCode int
+	}{
+		{"no token", http.MethodPost, "/api/posts", true, "token", "", http.StatusForbidden},
+		{"mismatched token", http.MethodPut, "/api/posts/1", true, "token", "other", http.StatusForbidden},
+		{"header without cookie", http.MethodDelete, "/api/posts/1", true, "", "token", http.StatusForbidden},
+		{"matching token", http.MethodPost, "/api/posts", true, "token", "token", http.StatusOK},
+		{"safe method", http.MethodGet, "/api/posts", true, "", "", http.StatusOK},
+		{"login is exempt", http.MethodPost, "/api/auth/login", true, "", "", http.StatusOK},
+		{"register is exempt", http.MethodPost, "/api/auth/register/", true, "", "", http.StatusOK},
+		{"no session", http.MethodPost, "/api/posts", false, "", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.session {
+				r = r.WithContext(context.WithValue(r.Context(), SessionKey, session))
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: utils.CSRFCookieName, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				r.Header.Set(utils.CSRFHeaderName, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, r)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestCSRFIssuesMissingToken(t *testing.T) {
+	handler := CSRF(func(w http.ResponseWriter, r *http.Request) {})
+	session := &utils.Session{ID: "session", UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+	r = r.WithContext(context.WithValue(r.Context(), SessionKey, session))
+	rec := httptest.NewRecorder()
+	handler(rec, r)
+
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == utils.CSRFCookieName && cookie.Value != "" && !cookie.HttpOnly {
+			return
+		}
+	}
+	t.Fatal("no readable CSRF cookie issued for a session without one")
+}
@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// gzipMinSize is the smallest body worth compressing; smaller responses are sent as is
+const gzipMinSize = 1024
+
+// Content types that are already compressed and gain nothing from gzip
+var compressedContentTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"application/zip",
+	"application/gzip",
+	"application/pdf",
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(io.Discard)
+	},
+}
+
+// Gzip middleware compresses responses for clients that accept gzip.
+// Bodies are buffered until gzipMinSize bytes so small responses skip compression.
+func Gzip(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Range responses and HEAD requests must describe the uncompressed body
+		if r.Method == http.MethodHead || r.Header.Get("Range") != "" || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		defer gw.Close()
+
+		next(gw, r)
+	}
+}
+
+// gzipResponseWriter holds back headers until it knows whether to compress the body
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz         *gzip.Writer
+	buf        []byte
+	statusCode int
+	decided    bool // headers have been sent and compression chosen
+}
+
+// WriteHeader records the status; it is sent once the body size is known
+func (gw *gzipResponseWriter) WriteHeader(code int) {
+	if !gw.decided {
+		gw.statusCode = code
+	}
+}
+
+// Write buffers the start of the body, then streams through gzip or straight to the client
+func (gw *gzipResponseWriter) Write(b []byte) (int, error) {
+	if gw.decided {
+		if gw.gz != nil {
+			return gw.gz.Write(b)
+		}
+		return gw.ResponseWriter.Write(b)
+	}
+
+	gw.buf = append(gw.buf, b...)
+	if len(gw.buf) >= gzipMinSize {
+		if err := gw.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush sends buffered data so streaming handlers keep working
+func (gw *gzipResponseWriter) Flush() {
+	if !gw.decided {
+		gw.start(len(gw.buf) > 0)
+	}
+	if gw.gz != nil {
+		gw.gz.Flush()
+	}
+	if flusher, ok := gw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close sends whatever is still buffered and finishes the gzip stream
+func (gw *gzipResponseWriter) Close() error {
+	if !gw.decided {
+		// The whole body was small enough to send uncompressed
+		if err := gw.start(false); err != nil {
+			return err
+		}
+	}
+	if gw.gz == nil {
+		return nil
+	}
+
+	err := gw.gz.Close()
+	gw.gz.Reset(io.Discard)
+	gzipWriterPool.Put(gw.gz)
+	gw.gz = nil
+	return err
+}
+
+// start chooses whether to compress, sends the headers and writes out the buffer
+func (gw *gzipResponseWriter) start(compress bool) error {
+	gw.decided = true
+
+	header := gw.ResponseWriter.Header()
+	header.Add("Vary", "Accept-Encoding")
+
+	// Sniff the type from the plain body; net/http would otherwise sniff the gzip bytes
+	if header.Get("Content-Type") == "" && len(gw.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(gw.buf))
+	}
+
+	if compress && shouldCompress(header, gw.statusCode) {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", "gzip")
+
+		gw.gz = gzipWriterPool.Get().(*gzip.Writer)
+		gw.gz.Reset(gw.ResponseWriter)
+	}
+
+	gw.ResponseWriter.WriteHeader(gw.statusCode)
+
+	buf := gw.buf
+	gw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := gw.Write(buf)
+	return err
+}
+
+// shouldCompress reports whether a response with these headers may be gzipped
+func shouldCompress(header http.Header, statusCode int) bool {
+	if statusCode == http.StatusNoContent || statusCode == http.StatusNotModified {
+		return false
+	}
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	contentType := header.Get("Content-Type")
+	for _, prefix := range compressedContentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// acceptsGzip checks an Accept-Encoding header for gzip with a non-zero quality
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGzip(t *testing.T) {
+	type payload struct {
+		Success bool     `json:"success"`
+		Items   []string `json:"items"`
+	}
+	large := payload{Success: true, Items: make([]string, 200)}
+	for i := range large.Items {
+		large.Items[i] = "an item long enough to push the body past the minimum size"
+	}
+
+	jsonHandler := func(body payload) http.HandlerFunc {
+		return Gzip(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(body)
+		})
+	}
+
+	tests := []struct {
+		name           string
+		handler        http.HandlerFunc
+		acceptEncoding string
+		wantGzip       bool
+	}{
+		{"gzip accepted", jsonHandler(large), "gzip, deflate", true},
+		{"no Accept-Encoding", jsonHandler(large), "", false},
+		{"gzip refused with q=0", jsonHandler(large), "gzip;q=0", false},
+		{"small body", jsonHandler(payload{Success: true}), "gzip", false},
+		{"already compressed type", Gzip(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(bytes.Repeat([]byte{0x89}, 4096))
+		}), "gzip", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+			if tt.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			tt.handler(rec, r)
+
+			gzipped := rec.Header().Get("Content-Encoding") == "gzip"
+			if gzipped != tt.wantGzip {
+				t.Fatalf("gzipped = %v, want %v", gzipped, tt.wantGzip)
+			}
+			if !gzipped {
+				return
+			}
+			if !strings.Contains(rec.Header().Get("Vary"), "Accept-Encoding") {
+				t.Fatal("gzipped response doesn't vary on Accept-Encoding")
+			}
+
+			reader, err := gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plain, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got payload
+			if err := json.Unmarshal(plain, &got); err != nil {
+				t.Fatalf("decompressed body isn't JSON: %v", err)
+			}
+			if !got.Success || len(got.Items) != len(large.Items) || got.Items[0] != large.Items[0] {
+				t.Fatal("decompressed body differs from what the handler wrote")
+			}
+			if rec.Header().Get("Content-Type") != "application/json" {
+				t.Fatalf("Content-Type = %q", rec.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := map[string]bool{
+		"":                  false,
+		"gzip":              true,
+		"GZIP":              true,
+		"deflate, br":       false,
+		"deflate, gzip;q=1": true,
+		"gzip;q=0":          false,
+		"gzip;q=0.0, br":    false,
+		"*":                 true,
+		"br, *;q=0.5":       true,
+	}
+
+	for header, want := range tests {
+		if got := acceptsGzip(header); got != want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"forum/utils"
+)
+
+// DefaultMaintenanceMessage is shown when no custom message is set
+const DefaultMaintenanceMessage = "The forum is down for maintenance. Please try again shortly."
+
+var (
+	maintenanceMu      sync.RWMutex
+	maintenanceEnabled bool
+	maintenanceMessage = DefaultMaintenanceMessage
+
+	// Paths that stay reachable during maintenance (health checks, and login so admins can sign in)
+	maintenanceExemptPaths = map[string]bool{
+		"/api/health":     true,
+		"/api/auth/login": true,
+	}
+)
+
+// MaintenanceStatus describes the current maintenance state
+type MaintenanceStatus struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message"`
+}
+
+// SetMaintenanceMode turns maintenance mode on or off at runtime
+func SetMaintenanceMode(enabled bool, message string) {
+	maintenanceMu.Lock()
+	defer maintenanceMu.Unlock()
+
+	maintenanceEnabled = enabled
+	if strings.TrimSpace(message) == "" {
+		message = DefaultMaintenanceMessage
+	}
+	maintenanceMessage = message
+}
+
+// GetMaintenanceStatus returns the current maintenance state
+func GetMaintenanceStatus() MaintenanceStatus {
+	maintenanceMu.RLock()
+	defer maintenanceMu.RUnlock()
+
+	return MaintenanceStatus{
+		Enabled: maintenanceEnabled,
+		Message: maintenanceMessage,
+	}
+}
+
+// Maintenance middleware returns 503 for API requests while maintenance mode is on.
+// Health checks are always served and admins are let through so they can operate the site.
+// It must run after OptionalAuth so the user's role is available.
+func Maintenance(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := GetMaintenanceStatus()
+		if !status.Enabled || maintenanceExemptPaths[strings.TrimSuffix(r.URL.Path, "/")] || IsAdmin(r) {
+			next(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", "120")
+		utils.ServiceUnavailable(w, status.Message)
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"forum/models"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	SetMaintenanceMode(true, "Back soon")
+	t.Cleanup(func() { SetMaintenanceMode(false, "") })
+
+	handler := Maintenance(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name string
+		path string
+		role string
+		want int
+	}{
+		{"normal request", "/api/posts", "", http.StatusServiceUnavailable},
+		{"regular user", "/api/posts", models.RoleUser, http.StatusServiceUnavailable},
+		{"moderator", "/api/posts", models.RoleModerator, http.StatusServiceUnavailable},
+		{"admin", "/api/posts", models.RoleAdmin, http.StatusOK},
+		{"health check", "/api/health", "", http.StatusOK},
+		{"health check with trailing slash", "/api/health/", "", http.StatusOK},
+		{"login", "/api/auth/login", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.role != "" {
+				r = r.WithContext(context.WithValue(r.Context(), RoleKey, tt.role))
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, r)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusServiceUnavailable && rec.Header().Get("Retry-After") == "" {
+				t.Fatal("503 without Retry-After")
+			}
+		})
+	}
+}
+
+func TestMaintenanceModeOff(t *testing.T) {
+	SetMaintenanceMode(false, "")
+
+	called := false
+	handler := Maintenance(func(w http.ResponseWriter, r *http.Request) { called = true })
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/posts", nil))
+
+	if !called {
+		t.Fatal("request was blocked with maintenance mode off")
+	}
+	if status := GetMaintenanceStatus(); status.Message != DefaultMaintenanceMessage {
+		t.Fatalf("message = %q, want the default", status.Message)
+	}
+}
@@ -47,7 +47,7 @@ func startCleanup() {
 	})
 }
 
-// cleanupVisitors removes stale visitor records periodically
+// cleanupVisitors removes stale visitor records, and stale activity records, periodically
 func cleanupVisitors() {
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
@@ -93,6 +93,8 @@ func cleanupVisitors() {
 			delete(visitors, ip)
 		}
 		globalMu.Unlock()
+
+		pruneActivity(now)
 	}
 }
 
@@ -178,8 +180,8 @@ func RateLimit(next http.Handler) http.Handler {
 	startCleanup()
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Extract IP
-		ip := extractIP(r.RemoteAddr)
+		// Extract IP (forwarded headers only count behind a trusted proxy)
+		ip := ClientIP(r)
 
 		// Determine category from path
 		path := strings.TrimPrefix(r.URL.Path, "/")
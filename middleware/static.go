@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	"forum/config"
+)
+
+// ServeStatic serves the app's assets from dir, cached for STATIC_CACHE_MAX_AGE
+// seconds, or revalidated on every use when that is 0
+func ServeStatic(dir string) http.Handler {
+	fileServer := http.FileServer(http.Dir(dir))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only existing files get cache headers so a 404 isn't remembered
+		name := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+		if info, err := os.Stat(name); err == nil && !info.IsDir() {
+			if maxAge := config.GetStaticCacheMaxAge(); maxAge > 0 {
+				w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(maxAge))
+			} else {
+				w.Header().Set("Cache-Control", "no-cache")
+			}
+		}
+
+		fileServer.ServeHTTP(w, r)
+	})
+}
+
+// ServeIndex serves the single-page app's index file. It is never cached without
+// revalidation, so a deploy is picked up on the next page load.
+func ServeIndex(file string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeFile(w, r, file)
+	}
+}
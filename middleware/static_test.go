@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"forum/config"
+)
+
+func TestServeIndexIsNotCached(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "index.html")
+	if err := os.WriteFile(file, []byte("<!doctype html><title>Forum</title>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	handler := ServeIndex(file)
+
+	// The SPA fallback serves the index for the root and for client routes alike
+	for _, path := range []string{"/", "/posts/12", "/profile"} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200", path, rec.Code)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != "no-cache" {
+			t.Fatalf("%s: Cache-Control = %q, want no-cache", path, got)
+		}
+	}
+}
+
+func TestServeStatic(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "js"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"styles.css", filepath.Join("js", "app.js")} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("/* asset */"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := http.StripPrefix("/static/", ServeStatic(dir))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	tests := []struct {
+		name   string
+		maxAge int
+		want   string
+	}{
+		{"configured max age", 86400, "public, max-age=86400"},
+		{"default revalidates", 0, "no-cache"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := config.AppConfig.StaticCacheMaxAge
+			config.AppConfig.StaticCacheMaxAge = tt.maxAge
+			t.Cleanup(func() { config.AppConfig.StaticCacheMaxAge = previous })
+
+			for _, path := range []string{"/static/styles.css", "/static/js/app.js"} {
+				rec := get(path)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("%s: status = %d, want 200", path, rec.Code)
+				}
+				if got := rec.Header().Get("Cache-Control"); got != tt.want {
+					t.Fatalf("%s: Cache-Control = %q, want %q", path, got, tt.want)
+				}
+			}
+
+			// A missing asset isn't remembered
+			rec := get("/static/missing.js")
+			if rec.Code != http.StatusNotFound {
+				t.Fatalf("missing asset: status = %d, want 404", rec.Code)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "" {
+				t.Fatalf("missing asset: Cache-Control = %q on a 404", got)
+			}
+		})
+	}
+}
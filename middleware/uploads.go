@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"forum/config"
+)
+
+// ServeUploads serves uploaded files from dir. Upload names are random UUIDs that are
+// never reused, so responses can be cached for good; directories are never listed.
+func ServeUploads(dir string) http.Handler {
+	files := noDirectoryFS{http.Dir(dir)}
+	fileServer := http.FileServer(files)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "" || strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		// Check the file first so a 404 never carries the long-lived cache headers
+		f, err := files.Open(path.Clean("/" + r.URL.Path))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		f.Close()
+
+		header := w.Header()
+		header.Set("Cache-Control", "public, max-age="+strconv.Itoa(config.GetUploadsCacheMaxAge())+", immutable")
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Content-Disposition", `inline; filename="`+safeFilename(path.Base(r.URL.Path))+`"`)
+
+		fileServer.ServeHTTP(w, r)
+	})
+}
+
+// noDirectoryFS hides directories so the file server can't list them
+type noDirectoryFS struct {
+	fs http.FileSystem
+}
+
+// Open opens regular files only; directories look like missing files
+func (n noDirectoryFS) Open(name string) (http.File, error) {
+	f, err := n.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, os.ErrNotExist
+	}
+	return f, nil
+}
+
+// safeFilename keeps only characters that can't break out of a quoted header value
+func safeFilename(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '.' || r == '-' || r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"forum/config"
+)
+
+func TestServeUploads(t *testing.T) {
+	previous := config.AppConfig.UploadsCacheMaxAge
+	config.AppConfig.UploadsCacheMaxAge = 3600
+	t.Cleanup(func() { config.AppConfig.UploadsCacheMaxAge = previous })
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "avatars"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"photo.png", filepath.Join("avatars", "secret-avatar.png")} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("\x89PNG\r\n\x1a\nnot really"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := http.StripPrefix("/uploads/", ServeUploads(dir))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	for _, path := range []string{"/uploads/photo.png", "/uploads/avatars/secret-avatar.png"} {
+		rec := get(path)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200", path, rec.Code)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != "public, max-age=3600, immutable" {
+			t.Fatalf("%s: Cache-Control = %q", path, got)
+		}
+		if got, want := rec.Header().Get("Content-Disposition"), `inline; filename="`+filepath.Base(path)+`"`; got != want {
+			t.Fatalf("%s: Content-Disposition = %q, want %q", path, got, want)
+		}
+		if got := rec.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Fatalf("%s: X-Content-Type-Options = %q", path, got)
+		}
+	}
+
+	// Directories are never listed, and nothing that isn't served gets cache headers
+	for _, path := range []string{"/uploads/", "/uploads/avatars/", "/uploads/avatars", "/uploads/missing.png", "/uploads/../uploads_test.go"} {
+		rec := get(path)
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("%s: status = %d, want 404", path, rec.Code)
+		}
+		if strings.Contains(rec.Body.String(), "secret-avatar") || strings.Contains(rec.Body.String(), "photo.png") {
+			t.Fatalf("%s: listed the directory: %s", path, rec.Body.String())
+		}
+		if got := rec.Header().Get("Cache-Control"); got != "" {
+			t.Fatalf("%s: Cache-Control = %q on a 404", path, got)
+		}
+	}
+}
+
+func TestSafeFilename(t *testing.T) {
+	tests := map[string]string{
+		"3f2a-photo.png":     "3f2a-photo.png",
+		`evil".png`:          "evil_.png",
+		"line\r\nbreak.png":  "line__break.png",
+		"ünïcode_name-1.jpg": "_n_code_name-1.jpg",
+	}
+	for input, want := range tests {
+		if got := safeFilename(input); got != want {
+			t.Fatalf("safeFilename(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"forum/database"
+)
+
+// MaxAttachmentsPerPost caps how many files can be attached to one post
+const MaxAttachmentsPerPost = 10
+
+// Attachment is a file uploaded to a post
+type Attachment struct {
+	ID           int       `json:"id"`
+	PostID       int       `json:"post_id"`
+	UserID       int       `json:"user_id"`
+	Filename     string    `json:"filename"`
+	OriginalName string    `json:"original_name"`
+	MimeType     string    `json:"mime_type"`
+	Size         int64     `json:"size"`
+	URL          string    `json:"url"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Create stores a new attachment record
+func (a *Attachment) Create() error {
+	query := `
+		INSERT INTO attachments (post_id, user_id, filename, original_name, mime_type, size, url, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	a.CreatedAt = time.Now()
+	result, err := database.GetDB().Exec(query,
+		a.PostID, a.UserID, a.Filename, a.OriginalName, a.MimeType, a.Size, a.URL, a.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	a.ID = int(id)
+	return nil
+}
+
+// CountPostAttachments returns how many attachments a post has
+func CountPostAttachments(postID int) (int, error) {
+	var count int
+	err := database.GetDB().QueryRow(`SELECT COUNT(*) FROM attachments WHERE post_id = ?`, postID).Scan(&count)
+	return count, err
+}
+
+// GetAttachmentsByPostIDs loads the attachments of several posts in one query,
+// oldest first. Every requested ID gets a (possibly empty) list.
+func GetAttachmentsByPostIDs(postIDs []int) (map[int][]Attachment, error) {
+	attachments := make(map[int][]Attachment, len(postIDs))
+	if len(postIDs) == 0 {
+		return attachments, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(postIDs)), ",")
+	args := make([]interface{}, 0, len(postIDs))
+	for _, id := range postIDs {
+		args = append(args, id)
+		attachments[id] = []Attachment{}
+	}
+
+	query := `
+		SELECT id, post_id, user_id, filename, original_name, mime_type, size, url, created_at
+		FROM attachments
+		WHERE post_id IN (` + placeholders + `)
+		ORDER BY created_at ASC, id ASC
+	`
+	rows, err := database.GetReadDB().Query(query, args...)
+	if err != nil {
+		return attachments, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a Attachment
+		if err := rows.Scan(&a.ID, &a.PostID, &a.UserID, &a.Filename, &a.OriginalName,
+			&a.MimeType, &a.Size, &a.URL, &a.CreatedAt); err != nil {
+			return attachments, err
+		}
+		attachments[a.PostID] = append(attachments[a.PostID], a)
+	}
+
+	return attachments, rows.Err()
+}
+
+// GetPostAttachments loads the attachments of a single post
+func GetPostAttachments(postID int) ([]Attachment, error) {
+	attachments, err := GetAttachmentsByPostIDs([]int{postID})
+	if err != nil {
+		return []Attachment{}, err
+	}
+	return attachments[postID], nil
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"forum/database"
+	"forum/utils"
+)
+
+// PermanentBan is stored as banned_until for bans without an end date
+var PermanentBan = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// IsPermanentBan reports whether a banned_until time means the ban has no end date
+func IsPermanentBan(until time.Time) bool {
+	return !until.Before(PermanentBan)
+}
+
+// IsBanned reports whether the user is currently banned
+func (u *User) IsBanned() bool {
+	return u.BannedUntil != nil && u.BannedUntil.After(time.Now())
+}
+
+// Ban suspends the user until the given time (PermanentBan for no end date).
+// Times are stored in UTC so SQL can compare them against datetime('now').
+func (u *User) Ban(until time.Time, reason string) error {
+	until = until.UTC()
+	query := `UPDATE users SET banned_until = ?, ban_reason = ?, updated_at = ? WHERE id = ?`
+	now := time.Now()
+	if _, err := database.GetDB().Exec(query, until, reason, now, u.ID); err != nil {
+		return err
+	}
+
+	u.BannedUntil = &until
+	u.BanReason = reason
+	u.UpdatedAt = now
+	utils.ForgetUserSessions(u.ID)
+	return nil
+}
+
+// Unban lifts the user's ban; their sessions were kept, so access is restored immediately
+func (u *User) Unban() error {
+	query := `UPDATE users SET banned_until = NULL, ban_reason = '', updated_at = ? WHERE id = ?`
+	now := time.Now()
+	if _, err := database.GetDB().Exec(query, now, u.ID); err != nil {
+		return err
+	}
+
+	u.BannedUntil = nil
+	u.BanReason = ""
+	u.UpdatedAt = now
+	utils.ForgetUserSessions(u.ID)
+	return nil
+}
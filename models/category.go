@@ -1,21 +1,131 @@
 package models
 
 import (
+	"database/sql"
 	"errors"
+	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
 	"forum/database"
 )
 
+// Category errors callers may want to tell apart
+var (
+	ErrCategoryNameTaken   = errors.New("category with this name already exists")
+	ErrCategoryNotFound    = errors.New("category not found")
+	ErrCategoryNotEmpty    = errors.New("cannot delete category with existing posts")
+	ErrCategorySlugTaken   = errors.New("category with this slug already exists")
+	ErrCategoryOrder       = errors.New("category order must list every category exactly once")
+	ErrCategoryParent      = errors.New("parent category does not exist or is inside this category")
+	ErrCategoryTooDeep     = errors.New("categories can be nested at most 3 levels deep")
+	ErrCategoryHasChildren = errors.New("cannot delete category with subcategories")
+)
+
+// Category sort options for GetAllCategories
+const (
+	CategorySortPosition       = "position"        // the order admins arranged, the default
+	CategorySortName           = "name"            // alphabetical
+	CategorySortPostCount      = "post_count"      // most posts first
+	CategorySortRecentActivity = "recent_activity" // newest latest post first, empty categories last
+)
+
+// CategorySorts lists every supported category sort option
+var CategorySorts = []string{CategorySortPosition, CategorySortName, CategorySortPostCount, CategorySortRecentActivity}
+
+// categorySortOrders maps each sort option to its ORDER BY clause; ties fall back to the admin order
+var categorySortOrders = map[string]string{
+	CategorySortPosition:       "c.position, c.name",
+	CategorySortName:           "c.name, c.position",
+	CategorySortPostCount:      "c.post_count DESC, c.position, c.name",
+	CategorySortRecentActivity: categoryLastPostAt + " IS NULL, " + categoryLastPostAt + " DESC, c.position, c.name",
+}
+
+// categoryLastPostAt is when the newest post in category c was created, NULL when it has none
+const categoryLastPostAt = `(SELECT MAX(p.created_at) FROM post_categories pc JOIN posts p ON p.id = pc.post_id WHERE pc.category_id = c.id)`
+
+// IsValidCategorySort checks if a category sort option is supported
+func IsValidCategorySort(sortBy string) bool {
+	_, ok := categorySortOrders[sortBy]
+	return ok
+}
+
+// Category post permissions: the lowest role that may file new posts in a category
+const (
+	CategoryPostEveryone   = "everyone"
+	CategoryPostModerators = "moderators"
+	CategoryPostAdmins     = "admins"
+)
+
+// CategoryPostPermissions lists every post permission, least restrictive first
+var CategoryPostPermissions = []string{CategoryPostEveryone, CategoryPostModerators, CategoryPostAdmins}
+
+// Popular category windows: only posts created within the window are counted
+const (
+	PopularWindowDay   = "day"
+	PopularWindowWeek  = "week"
+	PopularWindowMonth = "month"
+	PopularWindowAll   = "all"
+)
+
+// PopularWindows lists every popular category window, shortest first
+var PopularWindows = []string{PopularWindowDay, PopularWindowWeek, PopularWindowMonth, PopularWindowAll}
+
+// PopularWindowStart returns when a window starts counting back from now; the zero
+// time for "all". ok is false for unknown windows.
+func PopularWindowStart(window string, now time.Time) (since time.Time, ok bool) {
+	switch window {
+	case PopularWindowDay:
+		return now.AddDate(0, 0, -1), true
+	case PopularWindowWeek:
+		return now.AddDate(0, 0, -7), true
+	case PopularWindowMonth:
+		return now.AddDate(0, -1, 0), true
+	case PopularWindowAll:
+		return time.Time{}, true
+	}
+	return time.Time{}, false
+}
+
+// MaxCategoryDepth is how many levels categories may nest, counting top-level ones as 1
+const MaxCategoryDepth = 3
+
+// categorySubtreeIDs selects the ID of the category given as its parameter
+// together with the IDs of all its descendants
+const categorySubtreeIDs = `
+	WITH RECURSIVE subtree(id) AS (
+		SELECT ?
+		UNION ALL
+		SELECT child.id FROM categories child JOIN subtree ON child.parent_id = subtree.id
+	)
+	SELECT id FROM subtree`
+
+// MaxCategorySlugLength caps slugs, whether derived from the name or chosen
+const MaxCategorySlugLength = 60
+
+var (
+	categorySlugRegex  = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	categoryColorRegex = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+	categoryIconRegex  = regexp.MustCompile(`^[a-z0-9-]+$`)
+	nonSlugChars       = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
 // Category represents forum category/section
 type Category struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	PostCount   int       `json:"post_count"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID             int       `json:"id"`
+	Name           string    `json:"name"`
+	Description    string    `json:"description"`
+	Slug           string    `json:"slug"`  // unique, URL-safe; kept when the category is renamed
+	Color          string    `json:"color"` // "#rrggbb", or "" for the default
+	Icon           string    `json:"icon"`  // icon name, or "" for none
+	Position       int       `json:"position"`
+	Archived       bool      `json:"archived"`        // hidden from listings and closed to new posts
+	ParentID       *int      `json:"parent_id"`       // nil for top-level categories
+	PostPermission string    `json:"post_permission"` // who may file new posts here, one of CategoryPostPermissions
+	PostCount      int       `json:"post_count"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
 
 // CategoryStats represents category statistics
@@ -26,8 +136,23 @@ type CategoryStats struct {
 	LastPostTitle  string     `json:"last_post_title"`
 	LastPostAuthor string     `json:"last_post_author"`
 	ActiveUsers    int        `json:"active_users"`
+	// Activity over the last 7 days
+	PostsThisWeek    int `json:"posts_this_week"`
+	CommentsThisWeek int `json:"comments_this_week"`
+	// TopContributors are the users with the most posts in the category, at most TopContributorsLimit
+	TopContributors []CategoryContributor `json:"top_contributors"`
 }
 
+// CategoryContributor is a user and how many posts they made in a category
+type CategoryContributor struct {
+	UserID    int    `json:"user_id"`
+	Username  string `json:"username"`
+	PostCount int    `json:"post_count"`
+}
+
+// TopContributorsLimit is how many top contributors category stats list
+const TopContributorsLimit = 5
+
 // Create adds a new category to the database
 func (c *Category) Create() error {
 	// Validate category data
@@ -39,16 +164,38 @@ func (c *Category) Create() error {
 	if exists, err := c.NameExists(); err != nil {
 		return err
 	} else if exists {
-		return errors.New("category with this name already exists")
+		return ErrCategoryNameTaken
+	}
+
+	// Derived slugs get a numeric suffix on a clash; a chosen one must be free
+	if c.Slug == "" {
+		slug, err := uniqueCategorySlug(Slugify(c.Name), 0)
+		if err != nil {
+			return err
+		}
+		c.Slug = slug
+	} else if taken, err := categorySlugTaken(c.Slug, 0); err != nil {
+		return err
+	} else if taken {
+		return ErrCategorySlugTaken
+	}
+
+	if err := c.checkParent(); err != nil {
+		return err
+	}
+
+	// New categories go after every existing one
+	if err := database.GetDB().QueryRow(`SELECT COALESCE(MAX(position), 0) + 1 FROM categories`).Scan(&c.Position); err != nil {
+		return err
 	}
 
 	query := `
-		INSERT INTO categories (name, description, created_at, updated_at)
-		VALUES (?, ?, ?, ?)
+		INSERT INTO categories (name, description, slug, color, icon, position, parent_id, post_permission, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	now := time.Now()
-	result, err := database.GetDB().Exec(query, c.Name, c.Description, now, now)
+	result, err := database.GetDB().Exec(query, c.Name, c.Description, c.Slug, c.Color, c.Icon, c.Position, c.ParentID, c.PostPermission, now, now)
 	if err != nil {
 		return err
 	}
@@ -62,56 +209,81 @@ func (c *Category) Create() error {
 	c.CreatedAt = now
 	c.UpdatedAt = now
 
+	InvalidateCategoryCache()
 	return nil
 }
 
 // GetByID retrieves a category by its ID
 func (c *Category) GetByID(id int) error {
 	query := `
-		SELECT c.id, c.name, c.description, c.created_at, c.updated_at,
-		       COUNT(p.id) as post_count
+		SELECT c.id, c.name, c.description, COALESCE(c.slug, ''), c.color, c.icon, c.position, c.archived, c.parent_id, c.post_permission, c.created_at, c.updated_at, c.post_count
 		FROM categories c
-		LEFT JOIN posts p ON c.id = p.category_id
 		WHERE c.id = ?
-		GROUP BY c.id, c.name, c.description, c.created_at, c.updated_at
 	`
 
 	row := database.GetDB().QueryRow(query, id)
-	err := row.Scan(&c.ID, &c.Name, &c.Description, &c.CreatedAt, &c.UpdatedAt, &c.PostCount)
+	err := row.Scan(&c.ID, &c.Name, &c.Description, &c.Slug, &c.Color, &c.Icon, &c.Position, &c.Archived, &c.ParentID, &c.PostPermission, &c.CreatedAt, &c.UpdatedAt, &c.PostCount)
 	return err
 }
 
+// GetBySlug retrieves a category by its current slug
+func (c *Category) GetBySlug(slug string) error {
+	query := `
+		SELECT c.id, c.name, c.description, COALESCE(c.slug, ''), c.color, c.icon, c.position, c.archived, c.parent_id, c.post_permission, c.created_at, c.updated_at, c.post_count
+		FROM categories c
+		WHERE c.slug = ?
+	`
+
+	row := database.GetDB().QueryRow(query, slug)
+	return row.Scan(&c.ID, &c.Name, &c.Description, &c.Slug, &c.Color, &c.Icon, &c.Position, &c.Archived, &c.ParentID, &c.PostPermission, &c.CreatedAt, &c.UpdatedAt, &c.PostCount)
+}
+
+// ResolveCategorySlugRedirect returns the current slug of the category that used
+// to be reachable at oldSlug, or sql.ErrNoRows when there is no such redirect
+func ResolveCategorySlugRedirect(oldSlug string) (string, error) {
+	query := `
+		SELECT COALESCE(c.slug, '')
+		FROM category_slug_redirects r
+		JOIN categories c ON c.id = r.category_id
+		WHERE r.old_slug = ?
+	`
+	var slug string
+	err := database.GetDB().QueryRow(query, oldSlug).Scan(&slug)
+	return slug, err
+}
+
 // GetByName retrieves a category by its name
 func (c *Category) GetByName(name string) error {
 	query := `
-		SELECT c.id, c.name, c.description, c.created_at, c.updated_at,
-		       COUNT(p.id) as post_count
+		SELECT c.id, c.name, c.description, COALESCE(c.slug, ''), c.color, c.icon, c.position, c.archived, c.parent_id, c.post_permission, c.created_at, c.updated_at, c.post_count
 		FROM categories c
-		LEFT JOIN posts p ON c.id = p.category_id
 		WHERE c.name = ?
-		GROUP BY c.id, c.name, c.description, c.created_at, c.updated_at
 	`
 
 	row := database.GetDB().QueryRow(query, name)
-	err := row.Scan(&c.ID, &c.Name, &c.Description, &c.CreatedAt, &c.UpdatedAt, &c.PostCount)
+	err := row.Scan(&c.ID, &c.Name, &c.Description, &c.Slug, &c.Color, &c.Icon, &c.Position, &c.Archived, &c.ParentID, &c.PostPermission, &c.CreatedAt, &c.UpdatedAt, &c.PostCount)
 	return err
 }
 
-// GetAll retrieves all categories with post counts
-func GetAllCategories() ([]Category, error) {
+// GetAll retrieves all categories with post counts, ordered by one of CategorySorts
+// (unknown values use the admin-arranged order).
+// Archived categories are left out unless includeArchived is set.
+func GetAllCategories(includeArchived bool, sortBy string) ([]Category, error) {
 	var categories []Category
 
-	query := `
-			SELECT c.id, c.name, c.description, c.created_at, c.updated_at,
-       		COUNT(DISTINCT pc.post_id) as post_count
-			FROM categories c
-			LEFT JOIN post_categories pc ON c.id = pc.category_id
-			GROUP BY c.id, c.name, c.description, c.created_at, c.updated_at
-			ORDER BY c.name
+	orderBy, ok := categorySortOrders[sortBy]
+	if !ok {
+		orderBy = categorySortOrders[CategorySortPosition]
+	}
 
-		`
+	query := `
+		SELECT c.id, c.name, c.description, COALESCE(c.slug, ''), c.color, c.icon, c.position, c.archived, c.parent_id, c.post_permission, c.created_at, c.updated_at, c.post_count
+		FROM categories c
+		WHERE ? OR c.archived = 0
+		ORDER BY ` + orderBy + `
+	`
 
-	rows, err := database.GetDB().Query(query)
+	rows, err := database.GetReadDB().Query(query, includeArchived)
 	if err != nil {
 		return categories, err
 	}
@@ -120,6 +292,7 @@ func GetAllCategories() ([]Category, error) {
 	for rows.Next() {
 		var category Category
 		err := rows.Scan(&category.ID, &category.Name, &category.Description,
+			&category.Slug, &category.Color, &category.Icon, &category.Position, &category.Archived, &category.ParentID, &category.PostPermission,
 			&category.CreatedAt, &category.UpdatedAt, &category.PostCount)
 		if err != nil {
 			continue
@@ -130,21 +303,39 @@ func GetAllCategories() ([]Category, error) {
 	return categories, nil
 }
 
-// GetPopularCategories returns categories sorted by post count
-func GetPopularCategories(limit int) ([]Category, error) {
-	var categories []Category
+// GetPopularCategories returns the unarchived categories with the most posts created
+// since the given time (all posts for the zero time). PostCount holds that count,
+// categories without any such posts are left out and ties keep the admin order.
+func GetPopularCategories(limit int, since time.Time) ([]Category, error) {
+	categories := []Category{}
 
+	// All time is just the cached count; a window has to count the posts in it.
+	// created_at values carry their own offset, so compare them normalized to UTC.
 	query := `
-		SELECT c.id, c.name, c.description, c.created_at, c.updated_at,
-		       COUNT(p.id) as post_count
+		SELECT c.id, c.name, c.description, COALESCE(c.slug, ''), c.color, c.icon, c.position, c.archived, c.parent_id, c.post_permission, c.created_at, c.updated_at,
+		       c.post_count
 		FROM categories c
-		LEFT JOIN posts p ON c.id = p.category_id
-		GROUP BY c.id, c.name, c.description, c.created_at, c.updated_at
-		ORDER BY post_count DESC, c.name
+		WHERE c.archived = 0 AND c.post_count > 0
+		ORDER BY c.post_count DESC, c.position, c.name
 		LIMIT ?
 	`
+	args := []interface{}{limit}
+	if !since.IsZero() {
+		query = `
+			SELECT c.id, c.name, c.description, COALESCE(c.slug, ''), c.color, c.icon, c.position, c.archived, c.parent_id, c.post_permission, c.created_at, c.updated_at,
+			       COUNT(DISTINCT p.id) as post_count
+			FROM categories c
+			JOIN post_categories pc ON c.id = pc.category_id
+			JOIN posts p ON p.id = pc.post_id
+			WHERE c.archived = 0 AND datetime(p.created_at) >= ?
+			GROUP BY c.id, c.name, c.description, c.slug, c.color, c.icon, c.position, c.archived, c.parent_id, c.post_permission, c.created_at, c.updated_at
+			ORDER BY post_count DESC, c.position, c.name
+			LIMIT ?
+		`
+		args = []interface{}{since.UTC().Format(sqliteDateTime), limit}
+	}
 
-	rows, err := database.GetDB().Query(query, limit)
+	rows, err := database.GetReadDB().Query(query, args...)
 	if err != nil {
 		return categories, err
 	}
@@ -153,6 +344,7 @@ func GetPopularCategories(limit int) ([]Category, error) {
 	for rows.Next() {
 		var category Category
 		err := rows.Scan(&category.ID, &category.Name, &category.Description,
+			&category.Slug, &category.Color, &category.Icon, &category.Position, &category.Archived, &category.ParentID, &category.PostPermission,
 			&category.CreatedAt, &category.UpdatedAt, &category.PostCount)
 		if err != nil {
 			continue
@@ -178,22 +370,65 @@ func (c *Category) Update() error {
 		return err
 	}
 	if count > 0 {
-		return errors.New("category name already exists")
+		return ErrCategoryNameTaken
+	}
+
+	if c.Slug == "" {
+		if c.Slug, err = uniqueCategorySlug(Slugify(c.Name), c.ID); err != nil {
+			return err
+		}
+	} else if taken, err := categorySlugTaken(c.Slug, c.ID); err != nil {
+		return err
+	} else if taken {
+		return ErrCategorySlugTaken
+	}
+
+	if err := c.checkParent(); err != nil {
+		return err
+	}
+
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldSlug string
+	if err := tx.QueryRow(`SELECT COALESCE(slug, '') FROM categories WHERE id = ?`, c.ID).Scan(&oldSlug); err != nil {
+		return err
 	}
 
 	query := `
 		UPDATE categories 
-		SET name = ?, description = ?, updated_at = ?
+		SET name = ?, description = ?, slug = ?, color = ?, icon = ?, parent_id = ?, post_permission = ?, updated_at = ?
 		WHERE id = ?
 	`
 
 	now := time.Now()
-	_, err = database.GetDB().Exec(query, c.Name, c.Description, now, c.ID)
+	_, err = tx.Exec(query, c.Name, c.Description, c.Slug, c.Color, c.Icon, c.ParentID, c.PostPermission, now, c.ID)
 	if err != nil {
 		return err
 	}
 
+	// Keep the old slug working, and stop redirecting the new one if it used to belong elsewhere
+	if oldSlug != c.Slug {
+		if _, err := tx.Exec(`DELETE FROM category_slug_redirects WHERE old_slug = ?`, c.Slug); err != nil {
+			return err
+		}
+		if oldSlug != "" {
+			redirect := `INSERT OR REPLACE INTO category_slug_redirects (old_slug, category_id, created_at) VALUES (?, ?, ?)`
+			if _, err := tx.Exec(redirect, oldSlug, c.ID, now); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
 	c.UpdatedAt = now
+	InvalidateCategoryCache()
 	return nil
 }
 
@@ -201,14 +436,20 @@ func (c *Category) Update() error {
 func (c *Category) Delete() error {
 	// Check if category has posts
 	var postCount int
-	countQuery := `SELECT COUNT(*) FROM posts WHERE category_id = ?`
+	countQuery := `SELECT COUNT(*) FROM post_categories WHERE category_id = ?`
 	err := database.GetDB().QueryRow(countQuery, c.ID).Scan(&postCount)
 	if err != nil {
 		return err
 	}
 
 	if postCount > 0 {
-		return errors.New("cannot delete category with existing posts")
+		return ErrCategoryNotEmpty
+	}
+
+	if hasChildren, err := c.HasChildren(); err != nil {
+		return err
+	} else if hasChildren {
+		return ErrCategoryHasChildren
 	}
 
 	query := `DELETE FROM categories WHERE id = ?`
@@ -223,9 +464,125 @@ func (c *Category) Delete() error {
 	}
 
 	if rowsAffected == 0 {
-		return errors.New("category not found")
+		return ErrCategoryNotFound
+	}
+
+	InvalidateCategoryCache()
+	return nil
+}
+
+// ReassignAndDelete moves every post in this category to targetID and then deletes
+// this category, all in one transaction. Posts already in the target category
+// simply leave this one. It returns how many posts were moved.
+func (c *Category) ReassignAndDelete(targetID int) (int, error) {
+	if hasChildren, err := c.HasChildren(); err != nil {
+		return 0, err
+	} else if hasChildren {
+		return 0, ErrCategoryHasChildren
+	}
+
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT OR IGNORE INTO post_categories (post_id, category_id)
+		SELECT post_id, ? FROM post_categories WHERE category_id = ?
+	`, targetID, c.ID)
+	if err != nil {
+		return 0, err
+	}
+	moved, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`UPDATE categories SET post_count = post_count + ? WHERE id = ?`, moved, targetID); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM post_categories WHERE category_id = ?`, c.ID); err != nil {
+		return 0, err
+	}
+
+	result, err = tx.Exec(`DELETE FROM categories WHERE id = ?`, c.ID)
+	if err != nil {
+		return 0, err
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return 0, err
+	} else if rowsAffected == 0 {
+		return 0, ErrCategoryNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	InvalidateCategoryCache()
+	return int(moved), nil
+}
+
+// SetArchived archives or unarchives the category
+func (c *Category) SetArchived(archived bool) error {
+	now := time.Now()
+	result, err := database.GetDB().Exec(`UPDATE categories SET archived = ?, updated_at = ? WHERE id = ?`, archived, now, c.ID)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rowsAffected == 0 {
+		return ErrCategoryNotFound
+	}
+
+	c.Archived = archived
+	c.UpdatedAt = now
+	InvalidateCategoryCache()
+	return nil
+}
+
+// ReorderCategories sets the display order to the given category IDs, first to last.
+// The list must name every category exactly once, otherwise ErrCategoryOrder is
+// returned and nothing changes.
+func ReorderCategories(ids []int) error {
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var total int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM categories`).Scan(&total); err != nil {
+		return err
+	}
+	if len(ids) != total {
+		return ErrCategoryOrder
+	}
+
+	seen := make(map[int]bool, len(ids))
+	for i, id := range ids {
+		if seen[id] {
+			return ErrCategoryOrder
+		}
+		seen[id] = true
+
+		result, err := tx.Exec(`UPDATE categories SET position = ? WHERE id = ?`, i+1, id)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rowsAffected == 0 {
+			return ErrCategoryOrder
+		}
 	}
 
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	InvalidateCategoryCache()
 	return nil
 }
 
@@ -238,11 +595,12 @@ func (c *Category) GetStats() (*CategoryStats, error) {
 		SELECT 
 			COUNT(DISTINCT p.id) as total_posts,
 			COUNT(DISTINCT co.id) as total_comments
-		FROM posts p
-		LEFT JOIN comments co ON p.id = co.post_id
-		WHERE p.category_id = ?
+		FROM post_categories pc
+		JOIN posts p ON p.id = pc.post_id
+		LEFT JOIN visible_comments co ON p.id = co.post_id
+		WHERE pc.category_id = ?
 	`
-	err := database.GetDB().QueryRow(query, c.ID).Scan(&stats.TotalPosts, &stats.TotalComments)
+	err := database.GetReadDB().QueryRow(query, c.ID).Scan(&stats.TotalPosts, &stats.TotalComments)
 	if err != nil {
 		return nil, err
 	}
@@ -250,33 +608,175 @@ func (c *Category) GetStats() (*CategoryStats, error) {
 	// Get last post info
 	lastPostQuery := `
 		SELECT p.created_at, p.title, u.username
-		FROM posts p
+		FROM post_categories pc
+		JOIN posts p ON p.id = pc.post_id
 		JOIN users u ON p.user_id = u.id
-		WHERE p.category_id = ?
+		WHERE pc.category_id = ?
 		ORDER BY p.created_at DESC
 		LIMIT 1
 	`
-	row := database.GetDB().QueryRow(lastPostQuery, c.ID)
+	row := database.GetReadDB().QueryRow(lastPostQuery, c.ID)
 	err = row.Scan(&stats.LastPostDate, &stats.LastPostTitle, &stats.LastPostAuthor)
-	if err != nil && err.Error() != "sql: no rows in result set" {
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	now := time.Now()
+	weekAgo := now.AddDate(0, 0, -7)
+
+	// Get active users count (posted in last 30 days) and posts in the last week
+	activityQuery := `
+		SELECT COUNT(DISTINCT p.user_id),
+		       COALESCE(SUM(CASE WHEN p.created_at >= ? THEN 1 ELSE 0 END), 0)
+		FROM post_categories pc
+		JOIN posts p ON p.id = pc.post_id
+		WHERE pc.category_id = ?
+		AND p.created_at >= ?
+	`
+	err = database.GetReadDB().QueryRow(activityQuery, weekAgo, c.ID, now.AddDate(0, 0, -30)).
+		Scan(&stats.ActiveUsers, &stats.PostsThisWeek)
+	if err != nil {
+		return nil, err
+	}
+
+	// Comments made in the last week on the category's posts
+	commentsQuery := `
+		SELECT COUNT(*)
+		FROM post_categories pc
+		JOIN visible_comments co ON co.post_id = pc.post_id
+		WHERE pc.category_id = ?
+		AND co.created_at >= ?
+	`
+	err = database.GetReadDB().QueryRow(commentsQuery, c.ID, weekAgo).Scan(&stats.CommentsThisWeek)
+	if err != nil {
 		return nil, err
 	}
 
-	// Get active users count (posted in last 30 days)
-	activeUsersQuery := `
-		SELECT COUNT(DISTINCT p.user_id)
-		FROM posts p
-		WHERE p.category_id = ? 
-		AND p.created_at >= datetime('now', '-30 days')
+	// Top contributors by post count
+	contributorsQuery := `
+		SELECT u.id, u.username, COUNT(*) AS post_count
+		FROM post_categories pc
+		JOIN posts p ON p.id = pc.post_id
+		JOIN users u ON u.id = p.user_id
+		WHERE pc.category_id = ?
+		GROUP BY u.id, u.username
+		ORDER BY post_count DESC, u.username
+		LIMIT ?
 	`
-	err = database.GetDB().QueryRow(activeUsersQuery, c.ID).Scan(&stats.ActiveUsers)
+	rows, err := database.GetReadDB().Query(contributorsQuery, c.ID, TopContributorsLimit)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	stats.TopContributors = []CategoryContributor{}
+	for rows.Next() {
+		var contributor CategoryContributor
+		if err := rows.Scan(&contributor.UserID, &contributor.Username, &contributor.PostCount); err != nil {
+			return nil, err
+		}
+		stats.TopContributors = append(stats.TopContributors, contributor)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return stats, nil
 }
 
+// HasChildren reports whether any category is nested directly under this one
+func (c *Category) HasChildren() (bool, error) {
+	var exists bool
+	err := database.GetDB().QueryRow(`SELECT EXISTS(SELECT 1 FROM categories WHERE parent_id = ?)`, c.ID).Scan(&exists)
+	return exists, err
+}
+
+// checkParent makes sure the parent exists, isn't this category or one of its
+// descendants, and that nesting this category's subtree under it stays within
+// MaxCategoryDepth
+func (c *Category) checkParent() error {
+	if c.ParentID == nil {
+		return nil
+	}
+
+	// Depth of the parent, counting top-level categories as 1; 0 if it doesn't exist
+	var parentDepth int
+	depthQuery := `
+		WITH RECURSIVE ancestors(id, parent_id, depth) AS (
+			SELECT id, parent_id, 1 FROM categories WHERE id = ?
+			UNION ALL
+			SELECT a.id, a.parent_id, ancestors.depth + 1
+			FROM categories a JOIN ancestors ON a.id = ancestors.parent_id
+			WHERE ancestors.depth <= ?
+		)
+		SELECT COALESCE(MAX(depth), 0), COALESCE(SUM(id = ?), 0) FROM ancestors
+	`
+	var includesSelf int
+	err := database.GetDB().QueryRow(depthQuery, *c.ParentID, MaxCategoryDepth, c.ID).Scan(&parentDepth, &includesSelf)
+	if err != nil {
+		return err
+	}
+	if parentDepth == 0 || includesSelf > 0 {
+		return ErrCategoryParent
+	}
+
+	// Levels below this category that move along with it
+	height := 0
+	if c.ID > 0 {
+		heightQuery := `
+			WITH RECURSIVE descendants(id, depth) AS (
+				SELECT id, 0 FROM categories WHERE id = ?
+				UNION ALL
+				SELECT child.id, descendants.depth + 1
+				FROM categories child JOIN descendants ON child.parent_id = descendants.id
+				WHERE descendants.depth < ?
+			)
+			SELECT COALESCE(MAX(depth), 0) FROM descendants
+		`
+		if err := database.GetDB().QueryRow(heightQuery, c.ID, MaxCategoryDepth).Scan(&height); err != nil {
+			return err
+		}
+	}
+
+	if parentDepth+1+height > MaxCategoryDepth {
+		return ErrCategoryTooDeep
+	}
+	return nil
+}
+
+// GetSubtreePostCounts returns, for every category, how many distinct posts are
+// filed under it or any of its descendants
+func GetSubtreePostCounts() (map[int]int, error) {
+	counts := make(map[int]int)
+
+	query := `
+		WITH RECURSIVE lineage(ancestor_id, category_id) AS (
+			SELECT id, id FROM categories
+			UNION ALL
+			SELECT lineage.ancestor_id, child.id
+			FROM categories child JOIN lineage ON child.parent_id = lineage.category_id
+		)
+		SELECT lineage.ancestor_id, COUNT(DISTINCT pc.post_id)
+		FROM lineage
+		JOIN post_categories pc ON pc.category_id = lineage.category_id
+		GROUP BY lineage.ancestor_id
+	`
+	rows, err := database.GetReadDB().Query(query)
+	if err != nil {
+		return counts, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var categoryID, count int
+		if err := rows.Scan(&categoryID, &count); err != nil {
+			return counts, err
+		}
+		counts[categoryID] = count
+	}
+	return counts, rows.Err()
+}
+
 // NameExists checks if a category name already exists
 func (c *Category) NameExists() (bool, error) {
 	var count int
@@ -307,42 +807,226 @@ func (c *Category) Validate() error {
 	// Trim description
 	c.Description = strings.TrimSpace(c.Description)
 
+	// An empty slug is derived from the name when the category is saved
+	c.Slug = strings.ToLower(strings.TrimSpace(c.Slug))
+	if c.Slug != "" && (len(c.Slug) > MaxCategorySlugLength || !categorySlugRegex.MatchString(c.Slug)) {
+		return fmt.Errorf("category slug must be at most %d lowercase letters, numbers and single dashes", MaxCategorySlugLength)
+	}
+
+	c.Color = strings.ToLower(strings.TrimSpace(c.Color))
+	if c.Color != "" && !categoryColorRegex.MatchString(c.Color) {
+		return errors.New("category color must be a hex color like #1e90ff")
+	}
+
+	c.Icon = strings.TrimSpace(c.Icon)
+	if c.Icon != "" && (len(c.Icon) > 50 || !categoryIconRegex.MatchString(c.Icon)) {
+		return errors.New("category icon must be at most 50 lowercase letters, numbers and dashes")
+	}
+
+	c.PostPermission = strings.ToLower(strings.TrimSpace(c.PostPermission))
+	if c.PostPermission == "" {
+		c.PostPermission = CategoryPostEveryone
+	}
+	if postPermissionRank(c.PostPermission) < 0 {
+		return errors.New("category post_permission must be one of: " + strings.Join(CategoryPostPermissions, ", "))
+	}
+
 	return nil
 }
 
-// GetPostsInCategory returns paginated posts for this category
-func (c *Category) GetPosts(limit, offset int, sortBy string) ([]Post, int, error) {
+// postPermissionRank orders post permissions from least to most restrictive;
+// unknown permissions are -1
+func postPermissionRank(permission string) int {
+	for i, known := range CategoryPostPermissions {
+		if permission == known {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsStricterThan reports whether this category's post permission is more
+// restrictive than other's
+func (c *Category) IsStricterThan(other *Category) bool {
+	return postPermissionRank(c.PostPermission) > postPermissionRank(other.PostPermission)
+}
+
+// CanPost reports whether a user with the given role may file new posts in the
+// category. Archiving is checked separately.
+func (c *Category) CanPost(role string) bool {
+	switch c.PostPermission {
+	case CategoryPostAdmins:
+		return role == RoleAdmin
+	case CategoryPostModerators:
+		return IsModeratorRole(role)
+	default:
+		return IsValidRole(role)
+	}
+}
+
+// Slugify turns a category name into a URL-safe slug
+func Slugify(name string) string {
+	slug := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(slug) > MaxCategorySlugLength {
+		slug = strings.TrimRight(slug[:MaxCategorySlugLength], "-")
+	}
+	if slug == "" {
+		slug = "category"
+	}
+	return slug
+}
+
+// categorySlugTaken checks if another category (not excludeID) already uses slug
+func categorySlugTaken(slug string, excludeID int) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM categories WHERE slug = ? AND id != ?)`
+	err := database.GetDB().QueryRow(query, slug, excludeID).Scan(&exists)
+	return exists, err
+}
+
+// uniqueCategorySlug returns base, or base with the first free numeric suffix
+func uniqueCategorySlug(base string, excludeID int) (string, error) {
+	slug := base
+	for n := 2; ; n++ {
+		taken, err := categorySlugTaken(slug, excludeID)
+		if err != nil || !taken {
+			return slug, err
+		}
+		suffix := fmt.Sprintf("-%d", n)
+		trimmed := base
+		if len(trimmed)+len(suffix) > MaxCategorySlugLength {
+			trimmed = strings.TrimRight(trimmed[:MaxCategorySlugLength-len(suffix)], "-")
+		}
+		slug = trimmed + suffix
+	}
+}
+
+// BackfillCategorySlugs gives every category without a slug one derived from its
+// name (used at startup, after migrations add the column)
+func BackfillCategorySlugs() error {
+	rows, err := database.GetDB().Query(`SELECT id, name FROM categories WHERE slug IS NULL OR slug = '' ORDER BY id`)
+	if err != nil {
+		return err
+	}
+
+	type pending struct {
+		id   int
+		name string
+	}
+	var missing []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.name); err != nil {
+			rows.Close()
+			return err
+		}
+		missing = append(missing, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range missing {
+		slug, err := uniqueCategorySlug(Slugify(p.name), p.id)
+		if err != nil {
+			return err
+		}
+		if _, err := database.GetDB().Exec(`UPDATE categories SET slug = ? WHERE id = ?`, slug, p.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetCategoriesByIDs loads several categories in one query, keyed by ID.
+// Post counts are not filled in.
+func GetCategoriesByIDs(ids []int) (map[int]Category, error) {
+	categories := make(map[int]Category, len(ids))
+	if len(ids) == 0 {
+		return categories, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	query := `
+		SELECT id, name, COALESCE(description, ''), COALESCE(slug, ''), color, icon, position, archived, parent_id, post_permission, created_at, updated_at
+		FROM categories
+		WHERE id IN (` + placeholders + `)
+	`
+	rows, err := database.GetReadDB().Query(query, args...)
+	if err != nil {
+		return categories, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c Category
+		if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.Slug, &c.Color, &c.Icon, &c.Position, &c.Archived, &c.ParentID, &c.PostPermission, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			return categories, err
+		}
+		categories[c.ID] = c
+	}
+	return categories, rows.Err()
+}
+
+// GetPostsInCategory returns paginated posts for this category, with the
+// current user's votes filled in (0 for anonymous requests)
+func (c *Category) GetPosts(currentUserID, limit, offset int, sortBy string) ([]Post, int, error) {
 	filters := PostFilters{
-		CategoryID: c.ID,
-		SortBy:     sortBy,
-		Limit:      limit,
-		Offset:     offset,
+		CurrentUserID: currentUserID,
+		CategoryID:    c.ID,
+		SortBy:        sortBy,
+		Limit:         limit,
+		Offset:        offset,
 	}
 
 	return GetPosts(filters)
 }
 
-// UpdatePostCount recalculates and updates the cached post count
+// UpdatePostCount recounts the category's cached post count from post_categories
 func (c *Category) UpdatePostCount() error {
 	query := `
-		UPDATE categories 
-		SET updated_at = ?
+		UPDATE categories
+		SET post_count = (SELECT COUNT(*) FROM post_categories WHERE category_id = categories.id)
 		WHERE id = ?
 	`
-	now := time.Now()
-	_, err := database.GetDB().Exec(query, now, c.ID)
-	if err != nil {
+	if _, err := database.GetDB().Exec(query, c.ID); err != nil {
 		return err
 	}
+	InvalidateCategoryCache()
 
-	// Refresh the post count in memory
-	return c.refreshPostCount()
+	return database.GetDB().QueryRow(`SELECT post_count FROM categories WHERE id = ?`, c.ID).Scan(&c.PostCount)
 }
 
-// refreshPostCount updates the in-memory post count
-func (c *Category) refreshPostCount() error {
-	query := `SELECT COUNT(*) FROM posts WHERE category_id = ?`
-	return database.GetDB().QueryRow(query, c.ID).Scan(&c.PostCount)
+// RecountCategoryPostCounts recomputes every category's cached post count from
+// post_categories, repairing any drift, and returns how many counts were wrong
+func RecountCategoryPostCounts() (int, error) {
+	count := `(SELECT COUNT(*) FROM post_categories pc WHERE pc.category_id = categories.id)`
+	result, err := database.GetDB().Exec(`UPDATE categories SET post_count = ` + count + ` WHERE post_count != ` + count)
+	if err != nil {
+		return 0, err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	InvalidateCategoryCache()
+	return int(updated), nil
+}
+
+// adjustCategoryPostCounts moves the cached post count of every category a post is
+// filed in by delta. Post writes call it inside their transaction.
+func adjustCategoryPostCounts(tx *sql.Tx, postID, delta int) error {
+	_, err := tx.Exec(`
+		UPDATE categories SET post_count = post_count + ?
+		WHERE id IN (SELECT category_id FROM post_categories WHERE post_id = ?)
+	`, delta, postID)
+	return err
 }
 
 // GetRecentActivity returns recent posts and comments in this category
@@ -352,14 +1036,15 @@ func (c *Category) GetRecentActivity(limit int) ([]map[string]interface{}, error
 	// Get recent posts
 	postQuery := `
 		SELECT 'post' as type, p.id, p.title as content, u.username, p.created_at
-		FROM posts p
+		FROM post_categories pc
+		JOIN posts p ON p.id = pc.post_id
 		JOIN users u ON p.user_id = u.id
-		WHERE p.category_id = ?
+		WHERE pc.category_id = ?
 		ORDER BY p.created_at DESC
 		LIMIT ?
 	`
 
-	rows, err := database.GetDB().Query(postQuery, c.ID, limit)
+	rows, err := database.GetReadDB().Query(postQuery, c.ID, limit)
 	if err != nil {
 		return activity, err
 	}
@@ -389,10 +1074,10 @@ func (c *Category) GetRecentActivity(limit int) ([]map[string]interface{}, error
 
 // IsEmpty checks if category has any posts
 func (c *Category) IsEmpty() (bool, error) {
-	var count int
-	query := `SELECT COUNT(*) FROM posts WHERE category_id = ?`
-	err := database.GetDB().QueryRow(query, c.ID).Scan(&count)
-	return count == 0, err
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM post_categories WHERE category_id = ?)`
+	err := database.GetDB().QueryRow(query, c.ID).Scan(&exists)
+	return !exists, err
 }
 
 // CanDelete checks if category can be safely deleted
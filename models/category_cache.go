@@ -0,0 +1,83 @@
+package models
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"forum/config"
+)
+
+// The category list is read on nearly every page load, so GetCachedCategories keeps
+// it in memory. Every change to categories or to which posts they hold calls
+// InvalidateCategoryCache; the TTL only bounds how stale changes made outside
+// those paths can get.
+
+// cachedCategories is one cached listing together with the version it was loaded as
+type cachedCategories struct {
+	categories []Category
+	version    uint64
+	loadedAt   time.Time
+}
+
+var categoryCache = struct {
+	sync.RWMutex
+	entries     map[string]cachedCategories
+	versions    uint64 // last version handed out; every load gets a new one
+	invalidated uint64 // bumped by InvalidateCategoryCache
+}{entries: make(map[string]cachedCategories)}
+
+// categoryCacheEpoch prefixes every version so a restarted process, whose counter
+// starts over, can't hand out a version a client already holds from before
+var categoryCacheEpoch = newCategoryCacheEpoch()
+
+// newCategoryCacheEpoch derives a version prefix unique to this process start
+func newCategoryCacheEpoch() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// categoryCacheVersion formats a version number handed out by this process
+func categoryCacheVersion(version uint64) string {
+	return categoryCacheEpoch + "." + strconv.FormatUint(version, 10)
+}
+
+// GetCachedCategories returns the same listing as GetAllCategories, from the cache
+// when it is fresh. version changes whenever the listing is reloaded, so it can be
+// used to build an ETag. Callers must not modify the returned slice.
+func GetCachedCategories(includeArchived bool, sortBy string) (categories []Category, version string, err error) {
+	ttl := config.GetCategoryCacheTTL()
+	key := strconv.FormatBool(includeArchived) + ":" + sortBy
+
+	categoryCache.RLock()
+	entry, ok := categoryCache.entries[key]
+	invalidated := categoryCache.invalidated
+	categoryCache.RUnlock()
+	if ok && time.Since(entry.loadedAt) < ttl {
+		return entry.categories, categoryCacheVersion(entry.version), nil
+	}
+
+	categories, err = GetAllCategories(includeArchived, sortBy)
+	if err != nil {
+		return nil, "", err
+	}
+
+	categoryCache.Lock()
+	categoryCache.versions++
+	entry = cachedCategories{categories: categories, version: categoryCache.versions, loadedAt: time.Now()}
+	// A listing loaded while a change was being made may already be stale
+	if ttl > 0 && categoryCache.invalidated == invalidated {
+		categoryCache.entries[key] = entry
+	}
+	categoryCache.Unlock()
+
+	return entry.categories, categoryCacheVersion(entry.version), nil
+}
+
+// InvalidateCategoryCache drops every cached category listing. Call it after changing
+// a category or the categories a post is in.
+func InvalidateCategoryCache() {
+	categoryCache.Lock()
+	categoryCache.entries = make(map[string]cachedCategories)
+	categoryCache.invalidated++
+	categoryCache.Unlock()
+}
@@ -0,0 +1,398 @@
+package models
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"forum/database"
+)
+
+func TestCategoryQueriesWithPostsInSeveralCategories(t *testing.T) {
+	author := newTestUser(t)
+	both, only := newTestCategory(t), newTestCategory(t)
+	empty := newTestCategory(t)
+
+	// shared is filed in both categories, solo only in the first
+	shared := newTestPost(t, author.ID, both.ID, only.ID)
+	solo := newTestPost(t, author.ID, both.ID)
+	newTestComment(t, author.ID, shared.ID, "A comment on the shared post")
+	setCreatedAt(t, "posts", shared.ID, time.Now().Add(-2*time.Hour))
+	setCreatedAt(t, "posts", solo.ID, time.Now().Add(-time.Hour))
+
+	wantPosts := map[int]int{both.ID: 2, only.ID: 1, empty.ID: 0}
+
+	t.Run("GetByID and GetByName", func(t *testing.T) {
+		for _, c := range []*Category{both, only, empty} {
+			byID, byName := &Category{}, &Category{}
+			if err := byID.GetByID(c.ID); err != nil {
+				t.Fatal(err)
+			}
+			if err := byName.GetByName(c.Name); err != nil {
+				t.Fatal(err)
+			}
+			if byID.PostCount != wantPosts[c.ID] || byName.PostCount != wantPosts[c.ID] {
+				t.Fatalf("category %d post count = %d by ID and %d by name, want %d", c.ID, byID.PostCount, byName.PostCount, wantPosts[c.ID])
+			}
+		}
+	})
+
+	t.Run("GetPopularCategories", func(t *testing.T) {
+		for _, since := range []time.Time{{}, time.Now().Add(-24 * time.Hour)} {
+			categories, err := GetPopularCategories(1000, since)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[int]int{}
+			for _, c := range categories {
+				if _, ok := wantPosts[c.ID]; ok {
+					got[c.ID] = c.PostCount
+				}
+			}
+			want := map[int]int{both.ID: 2, only.ID: 1}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("since %v: post counts = %v, want %v", since, got, want)
+			}
+		}
+	})
+
+	t.Run("GetStats", func(t *testing.T) {
+		for _, tt := range []struct {
+			category          *Category
+			posts, comments   int
+			lastPostTitle     string
+			contributorsPosts int
+		}{
+			{both, 2, 1, solo.Title, 2},
+			{only, 1, 1, shared.Title, 1},
+		} {
+			stats, err := tt.category.GetStats()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.TotalPosts != tt.posts || stats.TotalComments != tt.comments || stats.LastPostTitle != tt.lastPostTitle {
+				t.Fatalf("category %d stats = %+v", tt.category.ID, stats)
+			}
+			if len(stats.TopContributors) != 1 || stats.TopContributors[0].PostCount != tt.contributorsPosts {
+				t.Fatalf("category %d contributors = %+v", tt.category.ID, stats.TopContributors)
+			}
+		}
+	})
+
+	t.Run("GetRecentActivity", func(t *testing.T) {
+		for category, want := range map[*Category][]int{both: {solo.ID, shared.ID}, only: {shared.ID}, empty: nil} {
+			activity, err := category.GetRecentActivity(10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for _, item := range activity {
+				got = append(got, item["id"].(int))
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("category %d activity = %v, want %v", category.ID, got, want)
+			}
+		}
+	})
+
+	t.Run("UpdatePostCount", func(t *testing.T) {
+		if _, err := database.GetDB().Exec(`UPDATE categories SET post_count = 9 WHERE id IN (?, ?)`, both.ID, only.ID); err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range []*Category{both, only} {
+			if err := c.UpdatePostCount(); err != nil {
+				t.Fatal(err)
+			}
+			if c.PostCount != wantPosts[c.ID] {
+				t.Fatalf("category %d post count = %d, want %d", c.ID, c.PostCount, wantPosts[c.ID])
+			}
+		}
+	})
+
+	t.Run("IsEmpty and Delete", func(t *testing.T) {
+		for _, c := range []*Category{both, only} {
+			if isEmpty, err := c.IsEmpty(); err != nil || isEmpty {
+				t.Fatalf("category %d IsEmpty = %v, %v", c.ID, isEmpty, err)
+			}
+			if err := c.Delete(); err != ErrCategoryNotEmpty {
+				t.Fatalf("delete category %d = %v, want ErrCategoryNotEmpty", c.ID, err)
+			}
+		}
+
+		if isEmpty, err := empty.IsEmpty(); err != nil || !isEmpty {
+			t.Fatalf("empty category IsEmpty = %v, %v", isEmpty, err)
+		}
+		if err := empty.Delete(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestGetAllCategoriesSorts(t *testing.T) {
+	author := newTestUser(t)
+
+	// Created in this order, so that is their position; names sort differently
+	names := []string{"zz-sort", "mm-sort", "aa-sort", "bb-sort"}
+	// Posts in each category, as hours ago; the last one is the newest
+	postAges := [][]float64{{3}, {5, 4, 2}, nil, {6, 1.0 / 6}}
+
+	var ids []int
+	for i, name := range names {
+		category := &Category{Name: uniqueName(name), Description: "A category to sort"}
+		if err := category.Create(); err != nil {
+			t.Fatal(err)
+		}
+		for _, hours := range postAges[i] {
+			post := newTestPost(t, author.ID, category.ID)
+			setCreatedAt(t, "posts", post.ID, time.Now().Add(-time.Duration(hours*float64(time.Hour))))
+		}
+		ids = append(ids, category.ID)
+	}
+
+	tests := []struct {
+		sortBy string
+		want   []int
+	}{
+		{CategorySortPosition, []int{ids[0], ids[1], ids[2], ids[3]}},
+		{CategorySortName, []int{ids[2], ids[3], ids[1], ids[0]}},
+		{CategorySortPostCount, []int{ids[1], ids[3], ids[0], ids[2]}},
+		// Empty categories go last
+		{CategorySortRecentActivity, []int{ids[3], ids[1], ids[0], ids[2]}},
+		{"bogus", []int{ids[0], ids[1], ids[2], ids[3]}},
+	}
+
+	seeded := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		seeded[id] = true
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sortBy, func(t *testing.T) {
+			categories, err := GetAllCategories(false, tt.sortBy)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Other tests share the database, so only look at the seeded categories
+			var got []int
+			for _, c := range categories {
+				if seeded[c.ID] {
+					got = append(got, c.ID)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCategoryGetByID(t *testing.T) {
+	categories := []*Category{newTestCategory(t), newTestCategory(t), newTestCategory(t)}
+
+	for _, want := range categories {
+		got := &Category{}
+		if err := got.GetByID(want.ID); err != nil {
+			t.Fatalf("GetByID(%d): %v", want.ID, err)
+		}
+		if got.ID != want.ID || got.Name != want.Name {
+			t.Errorf("GetByID(%d) = %d %q, want %d %q", want.ID, got.ID, got.Name, want.ID, want.Name)
+		}
+	}
+
+	if err := (&Category{}).GetByID(999999); err != sql.ErrNoRows {
+		t.Errorf("GetByID of an unknown category = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestGetPopularCategories(t *testing.T) {
+	author := newTestUser(t)
+	now := time.Now()
+
+	// Four categories in position order, with posts this many hours old
+	postAges := [][]float64{
+		{1, 2},          // a: ties with b in every window it is in
+		{3, 4},          // b
+		{240, 250, 260}, // c: only in the month and all time
+		{48},            // d: only from the week up
+	}
+	var ids []int
+	for _, ages := range postAges {
+		category := newTestCategory(t)
+		for _, hours := range ages {
+			post := newTestPost(t, author.ID, category.ID)
+			setCreatedAt(t, "posts", post.ID, now.Add(-time.Duration(hours*float64(time.Hour))))
+		}
+		ids = append(ids, category.ID)
+	}
+	a, b, c, d := ids[0], ids[1], ids[2], ids[3]
+
+	// A post filed in two categories counts once in each
+	shared := newTestPost(t, author.ID, c, d)
+	setCreatedAt(t, "posts", shared.ID, now.Add(-500*time.Hour))
+
+	type count struct{ id, posts int }
+	tests := []struct {
+		window string
+		want   []count
+	}{
+		// Ties keep the admin-arranged order
+		{PopularWindowDay, []count{{a, 2}, {b, 2}}},
+		{PopularWindowWeek, []count{{a, 2}, {b, 2}, {d, 1}}},
+		{PopularWindowMonth, []count{{c, 4}, {a, 2}, {b, 2}, {d, 2}}},
+		{PopularWindowAll, []count{{c, 4}, {a, 2}, {b, 2}, {d, 2}}},
+	}
+
+	seeded := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		seeded[id] = true
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.window, func(t *testing.T) {
+			since, ok := PopularWindowStart(tt.window, now)
+			if !ok {
+				t.Fatalf("PopularWindowStart(%q) not ok", tt.window)
+			}
+			categories, err := GetPopularCategories(1000, since)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Other tests share the database, so only look at the seeded categories
+			var got []count
+			for _, category := range categories {
+				if seeded[category.ID] {
+					got = append(got, count{category.ID, category.PostCount})
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("popular = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("empty window", func(t *testing.T) {
+		categories, err := GetPopularCategories(1000, now.Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if categories == nil || len(categories) != 0 {
+			t.Fatalf("popular = %v, want an empty list", categories)
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		since, _ := PopularWindowStart(PopularWindowDay, now)
+		categories, err := GetPopularCategories(1, since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(categories) != 1 {
+			t.Fatalf("got %d categories, want 1", len(categories))
+		}
+	})
+
+	if _, ok := PopularWindowStart("year", now); ok {
+		t.Error("PopularWindowStart accepted an unknown window")
+	}
+}
+
+func TestCategoryPostCounts(t *testing.T) {
+	author := newTestUser(t)
+	a, b, c, d := newTestCategory(t), newTestCategory(t), newTestCategory(t), newTestCategory(t)
+
+	// counts reads the cached post_count of each category, checking it against post_categories
+	counts := func() []int {
+		t.Helper()
+
+		var got []int
+		for _, category := range []*Category{a, b, c, d} {
+			loaded := &Category{}
+			if err := loaded.GetByID(category.ID); err != nil {
+				t.Fatal(err)
+			}
+			if want := rowCount(t, "post_categories", "category_id = ?", category.ID); loaded.PostCount != want {
+				t.Errorf("category %d: post_count = %d, post_categories has %d", category.ID, loaded.PostCount, want)
+			}
+			got = append(got, loaded.PostCount)
+		}
+		return got
+	}
+
+	post := newTestPost(t, author.ID, a.ID, b.ID, c.ID)
+	if got := counts(); !reflect.DeepEqual(got, []int{1, 1, 1, 0}) {
+		t.Fatalf("after creating a post in 3 categories: counts = %v, want [1 1 1 0]", got)
+	}
+	newTestPost(t, author.ID, a.ID)
+	if got := counts(); !reflect.DeepEqual(got, []int{2, 1, 1, 0}) {
+		t.Fatalf("after a second post: counts = %v, want [2 1 1 0]", got)
+	}
+
+	post.Categories = []Category{{ID: c.ID}, {ID: d.ID}}
+	if err := post.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got := counts(); !reflect.DeepEqual(got, []int{1, 0, 1, 1}) {
+		t.Fatalf("after moving the post: counts = %v, want [1 0 1 1]", got)
+	}
+
+	if err := post.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if got := counts(); !reflect.DeepEqual(got, []int{1, 0, 0, 0}) {
+		t.Fatalf("after deleting the post: counts = %v, want [1 0 0 0]", got)
+	}
+
+	if _, err := a.ReassignAndDelete(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	loaded := &Category{}
+	if err := loaded.GetByID(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.PostCount != 1 {
+		t.Fatalf("after reassigning: post_count = %d, want 1", loaded.PostCount)
+	}
+
+	// Recounting repairs drift
+	if _, err := database.GetDB().Exec(`UPDATE categories SET post_count = 40 WHERE id = ?`, c.ID); err != nil {
+		t.Fatal(err)
+	}
+	fixed, err := RecountCategoryPostCounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixed < 1 {
+		t.Fatalf("RecountCategoryPostCounts fixed %d counts, want at least 1", fixed)
+	}
+	if err := loaded.GetByID(c.ID); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.PostCount != 0 {
+		t.Fatalf("after recounting: post_count = %d, want 0", loaded.PostCount)
+	}
+}
+
+func TestCategoryCacheVersionsSurviveRestart(t *testing.T) {
+	_, before, err := GetCachedCategories(false, CategorySortPosition)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A restarted process starts counting versions from scratch
+	categoryCache.Lock()
+	categoryCache.entries = make(map[string]cachedCategories)
+	categoryCache.versions = 0
+	categoryCache.Unlock()
+	categoryCacheEpoch = newCategoryCacheEpoch()
+
+	_, after, err := GetCachedCategories(false, CategorySortPosition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after == before {
+		t.Errorf("version %q was handed out again after a restart", after)
+	}
+}
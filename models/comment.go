@@ -1,25 +1,107 @@
 package models
 
 import (
+	"database/sql"
 	"errors"
 	"strings"
 	"time"
 
+	"forum/config"
 	"forum/database"
+	"forum/utils"
 )
 
 // Comment represents a comment on a post
 type Comment struct {
-	ID        int       `json:"id"`
-	Content   string    `json:"content"`
-	UserID    int       `json:"user_id"`
-	Username  string    `json:"username"`
-	PostID    int       `json:"post_id"`
-	Likes     int       `json:"likes"`
-	Dislikes  int       `json:"dislikes"`
-	UserVote  *string   `json:"user_vote"` // "like", "dislike", or nil
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID              int             `json:"id"`
+	Content         string          `json:"content"`
+	UserID          int             `json:"user_id"`
+	Username        string          `json:"username"`
+	AuthorAvatar    string          `json:"author_avatar"`    // loaded with comment listings
+	AuthorJoinedAt  time.Time       `json:"author_joined_at"` // loaded with comment listings
+	PostID          int             `json:"post_id"`
+	ParentID        *int            `json:"parent_id"` // comment this replies to, nil for top-level
+	Likes           int             `json:"likes"`
+	Dislikes        int             `json:"dislikes"`
+	UserVote        *string         `json:"user_vote"`   // "like", "dislike", or nil
+	IsAccepted      bool            `json:"is_accepted"` // marked as the answer by the post author
+	Hidden          bool            `json:"hidden"`      // hidden pending moderator review
+	Collapsed       bool            `json:"collapsed"`   // score at or below the collapse threshold (set on listings)
+	QuotedCommentID *int            `json:"quoted_comment_id"`
+	Quote           *CommentQuote   `json:"quote,omitempty"` // snapshot of the quoted comment
+	Reactions       ReactionSummary `json:"reactions"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+	EditedAt        *time.Time      `json:"edited_at"` // last content edit, nil if never edited
+	// Deletion details, only loaded when a moderator asks for deleted comments
+	DeletedAt         *time.Time `json:"deleted_at,omitempty"`
+	RemovedBy         *int       `json:"removed_by,omitempty"` // moderator who removed it, nil if the author deleted it
+	RemovedByUsername string     `json:"removed_by_username,omitempty"`
+	RemovalReason     string     `json:"removal_reason,omitempty"`
+}
+
+// GetDepth returns how many ancestors the comment has (0 for a top-level comment)
+func (c *Comment) GetDepth() (int, error) {
+	query := `
+		WITH RECURSIVE ancestors(id, parent_id, depth) AS (
+			SELECT id, parent_id, 0 FROM comments WHERE id = ?
+			UNION ALL
+			SELECT p.id, p.parent_id, a.depth + 1
+			FROM comments p
+			JOIN ancestors a ON p.id = a.parent_id
+		)
+		SELECT MAX(depth) FROM ancestors
+	`
+	var depth int
+	err := database.GetDB().QueryRow(query, c.ID).Scan(&depth)
+	return depth, err
+}
+
+// ErrInvalidParent is returned when replying to a comment that can't be replied to from this post
+var ErrInvalidParent = errors.New("parent comment must be a visible comment on the same post")
+
+// SetParent makes the comment a reply to the given comment
+func (c *Comment) SetParent(parent *Comment) error {
+	if parent.PostID != c.PostID || parent.Hidden {
+		return ErrInvalidParent
+	}
+
+	c.ParentID = &parent.ID
+	return nil
+}
+
+// QuoteExcerptLength is the maximum number of characters snapshotted from a quoted comment
+const QuoteExcerptLength = 200
+
+// ErrInvalidQuote is returned when the quoted comment can't be quoted from this post
+var ErrInvalidQuote = errors.New("quoted comment must be a visible comment on the same post")
+
+// CommentQuote is the snapshot of a quoted comment taken when the reply was written
+type CommentQuote struct {
+	CommentID *int   `json:"comment_id"` // nil once the quoted comment is deleted
+	Username  string `json:"username"`
+	Excerpt   string `json:"excerpt"`
+	Removed   bool   `json:"removed"`
+}
+
+// SetQuote validates the quoted comment and snapshots its author and an excerpt
+func (c *Comment) SetQuote(quoted *Comment) error {
+	if quoted.PostID != c.PostID || quoted.Hidden {
+		return ErrInvalidQuote
+	}
+
+	excerpt := []rune(strings.TrimSpace(quoted.Content))
+	if len(excerpt) > QuoteExcerptLength {
+		excerpt = append(excerpt[:QuoteExcerptLength], '…')
+	}
+
+	c.QuotedCommentID = &quoted.ID
+	c.Quote = &CommentQuote{
+		CommentID: &quoted.ID,
+		Username:  quoted.Username,
+		Excerpt:   string(excerpt),
+	}
+	return nil
 }
 
 // Create adds a new comment to the database
@@ -30,12 +112,18 @@ func (c *Comment) Create() error {
 	}
 
 	query := `
-		INSERT INTO comments (content, user_id, post_id, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO comments (content, user_id, post_id, parent_id, quoted_comment_id, quote_username, quote_excerpt, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
+	var quoteUsername, quoteExcerpt *string
+	if c.Quote != nil {
+		quoteUsername, quoteExcerpt = &c.Quote.Username, &c.Quote.Excerpt
+	}
+
 	now := time.Now()
-	result, err := database.GetDB().Exec(query, c.Content, c.UserID, c.PostID, now, now)
+	result, err := database.GetDB().Exec(query, c.Content, c.UserID, c.PostID, c.ParentID,
+		c.QuotedCommentID, quoteUsername, quoteExcerpt, now, now)
 	if err != nil {
 		return err
 	}
@@ -52,82 +140,300 @@ func (c *Comment) Create() error {
 	return nil
 }
 
+// CommentExists checks that a comment exists and is visible to readers without
+// loading it. Use it instead of GetByID when nothing but existence matters.
+func CommentExists(id int) (bool, error) {
+	var exists bool
+	err := database.GetDB().QueryRow(`SELECT EXISTS(SELECT 1 FROM visible_comments WHERE id = ?)`, id).Scan(&exists)
+	return exists, err
+}
+
+// commentLikes and commentDislikes count a comment's votes straight from the votes
+// table, as GetCommentVoteCounts does, instead of trusting the cached columns that
+// can drift. Reads select, sort and position comments by these so they all agree.
+const (
+	commentLikes    = `(SELECT COUNT(*) FROM votes v WHERE v.comment_id = c.id AND v.vote_type = 'like')`
+	commentDislikes = `(SELECT COUNT(*) FROM votes v WHERE v.comment_id = c.id AND v.vote_type = 'dislike')`
+)
+
 // GetByID retrieves a comment by its ID with optional user vote info
 func (c *Comment) GetByID(id int, userID *int) error {
 	query := `
 		SELECT c.id, c.content, c.user_id, u.username, c.post_id, 
-		       c.likes, c.dislikes, c.created_at, c.updated_at
+		       ` + commentLikes + `, ` + commentDislikes + `, c.created_at, c.updated_at, c.edited_at,
+		       (p.accepted_comment_id IS NOT NULL AND p.accepted_comment_id = c.id) AS is_accepted,
+		       c.hidden, c.parent_id, c.quoted_comment_id, c.quote_username, c.quote_excerpt, c.quote_removed
 		FROM comments c
 		JOIN users u ON c.user_id = u.id
-		WHERE c.id = ?
+		JOIN posts p ON c.post_id = p.id
+		WHERE c.id = ? AND c.deleted_at IS NULL
 	`
 
+	var quote quoteColumns
+	var parentID sql.NullInt64
+	var editedAt sql.NullTime
 	row := database.GetDB().QueryRow(query, id)
 	err := row.Scan(&c.ID, &c.Content, &c.UserID, &c.Username, &c.PostID,
-		&c.Likes, &c.Dislikes, &c.CreatedAt, &c.UpdatedAt)
+		&c.Likes, &c.Dislikes, &c.CreatedAt, &c.UpdatedAt, &editedAt, &c.IsAccepted, &c.Hidden, &parentID,
+		&quote.commentID, &quote.username, &quote.excerpt, &quote.removed)
 	if err != nil {
 		return err
 	}
+	quote.applyTo(c)
+	c.ParentID = nullIntPtr(parentID)
+	c.EditedAt = nullTimePtr(editedAt)
 
 	// Get user vote if logged in
 	if userID != nil {
 		c.getUserVote(*userID)
 	}
 
-	return nil
+	c.Reactions, err = GetReactionSummary(TargetComment, c.ID, userID)
+	return err
 }
 
+// Comment sort options
+const (
+	CommentSortOldest = "oldest"
+	CommentSortNewest = "newest"
+	CommentSortBest   = "best"
+)
+
+// commentSortOrders whitelists the ORDER BY clause for each comment sort option
+var commentSortOrders = map[string]string{
+	CommentSortOldest: "c.created_at ASC, c.id ASC",
+	CommentSortNewest: "c.created_at DESC, c.id DESC",
+	CommentSortBest:   "(" + commentLikes + " - " + commentDislikes + ") DESC, c.created_at DESC, c.id DESC",
+}
 
-// GetUserComments retrieves all comments made by a specific user
-func GetCommentsByPostID(postID int, userID *int, limit, offset int) ([]Comment, int, error) {
+// IsValidCommentSort checks if a comment sort option is supported
+func IsValidCommentSort(sortBy string) bool {
+	_, ok := commentSortOrders[sortBy]
+	return ok
+}
+
+// moderatorCommentsSource is visible_comments plus soft-deleted comments, for moderator review
+const moderatorCommentsSource = `(
+			SELECT * FROM visible_comments
+			UNION ALL
+			SELECT * FROM comments WHERE deleted_at IS NOT NULL
+		)`
+
+// GetUserCommentedPosts returns the posts a user has commented on, each once, ordered
+// by the user's latest comment on them, along with the total number of such posts.
+// viewerID fills in the viewer's own votes; 0 for visitors.
+func GetUserCommentedPosts(userID, viewerID int, limit, offset int) ([]Post, int, error) {
+	return GetPosts(PostFilters{
+		CurrentUserID:     viewerID,
+		CommentedByUserID: userID,
+		Limit:             limit,
+		Offset:            offset,
+	})
+}
+
+// GetCommentsByPostID retrieves paginated comments for a post in the requested order
+// (unknown sort values fall back to oldest first). includeDeleted adds soft-deleted
+// comments with their deletion details and must only be set for moderators.
+func GetCommentsByPostID(postID int, userID *int, sortBy string, limit, offset int, includeDeleted bool) ([]Comment, int, error) {
 	comments := []Comment{}
 
-	// Get total number of comments for pagination
-	var total int
-	countQuery := `SELECT COUNT(*) FROM comments WHERE post_id = ?`
-	if err := database.GetDB().QueryRow(countQuery, postID).Scan(&total); err != nil {
-		return comments, 0, err
+	orderBy, ok := commentSortOrders[sortBy]
+	if !ok {
+		orderBy = commentSortOrders[CommentSortOldest]
 	}
 
-	// Get paginated comments
+	source := "visible_comments"
+	if includeDeleted {
+		source = moderatorCommentsSource
+	}
+
+	// Get paginated comments, with the accepted answer (if any) always first.
+	// The window count gives the pagination total over the same rows in the same query.
 	query := `
-		SELECT c.id, c.user_id, u.username, c.post_id, c.content,
-		       c.likes, c.dislikes, c.created_at, c.updated_at
-		FROM comments c
+		SELECT c.id, c.user_id, u.username, COALESCE(u.avatar, ''), u.created_at, c.post_id, c.content,
+		       ` + commentLikes + `, ` + commentDislikes + `, c.created_at, c.updated_at, c.edited_at,
+		       (p.accepted_comment_id IS NOT NULL AND p.accepted_comment_id = c.id) AS is_accepted,
+		       c.parent_id, c.quoted_comment_id, c.quote_username, c.quote_excerpt, c.quote_removed,
+		       c.deleted_at, c.removed_by, m.username, COALESCE(c.removal_reason, ''),
+		       COUNT(*) OVER () AS total
+		FROM ` + source + ` c
 		JOIN users u ON c.user_id = u.id
+		JOIN posts p ON c.post_id = p.id
+		LEFT JOIN users m ON c.removed_by = m.id
 		WHERE c.post_id = ?
-		ORDER BY c.created_at ASC
+		ORDER BY is_accepted DESC, ` + orderBy + `
 		LIMIT ? OFFSET ?
 	`
-	rows, err := database.GetDB().Query(query, postID, limit, offset)
+	rows, err := database.GetReadDB().Query(query, postID, limit, offset)
 	if err != nil {
 		return comments, 0, err
 	}
 	defer rows.Close()
 
+	var total int
 	for rows.Next() {
 		var c Comment
-		err := rows.Scan(&c.ID, &c.UserID, &c.Username, &c.PostID, &c.Content,
-			&c.Likes, &c.Dislikes, &c.CreatedAt, &c.UpdatedAt)
+		var quote quoteColumns
+		var parentID, removedBy sql.NullInt64
+		var editedAt, deletedAt sql.NullTime
+		var removedByUsername sql.NullString
+		err := rows.Scan(&c.ID, &c.UserID, &c.Username, &c.AuthorAvatar, &c.AuthorJoinedAt, &c.PostID, &c.Content,
+			&c.Likes, &c.Dislikes, &c.CreatedAt, &c.UpdatedAt, &editedAt, &c.IsAccepted, &parentID,
+			&quote.commentID, &quote.username, &quote.excerpt, &quote.removed,
+			&deletedAt, &removedBy, &removedByUsername, &c.RemovalReason, &total)
 		if err != nil {
-			continue
+			return comments, 0, err
 		}
+		quote.applyTo(&c)
+		c.ParentID = nullIntPtr(parentID)
+		c.EditedAt = nullTimePtr(editedAt)
+		c.DeletedAt = nullTimePtr(deletedAt)
+		c.RemovedBy = nullIntPtr(removedBy)
+		c.RemovedByUsername = removedByUsername.String
+		c.Collapsed = IsCollapsedScore(c.Likes, c.Dislikes)
 
-		// If user is authenticated, check if they voted on this comment
-		if userID != nil {
-			var voteType string
-			voteQuery := `SELECT vote_type FROM votes WHERE user_id = ? AND comment_id = ? LIMIT 1`
-			if err := database.GetDB().QueryRow(voteQuery, *userID, c.ID).Scan(&voteType); err == nil {
-				c.UserVote = &voteType
-			}
+		comments = append(comments, c)
+	}
+	if err := rows.Err(); err != nil {
+		return comments, 0, err
+	}
+
+	// A page past the end has no rows to carry the total, so count separately
+	if len(comments) == 0 && offset > 0 {
+		countQuery := `
+			SELECT COUNT(*) FROM ` + source + ` c
+			JOIN users u ON c.user_id = u.id
+			WHERE c.post_id = ?
+		`
+		if err := database.GetReadDB().QueryRow(countQuery, postID).Scan(&total); err != nil {
+			return comments, 0, err
 		}
+	}
 
-		comments = append(comments, c)
+	// If user is authenticated, load their votes on the whole page at once
+	if userID != nil {
+		if err := loadCommentUserVotes(comments, *userID); err != nil {
+			return comments, 0, err
+		}
+	}
+
+	if err := loadCommentReactions(comments, userID); err != nil {
+		return comments, 0, err
 	}
 
 	return comments, total, nil
 }
 
+// loadCommentReactions sets Reactions on each comment with a single query
+func loadCommentReactions(comments []Comment, userID *int) error {
+	ids := make([]int, len(comments))
+	for i, c := range comments {
+		ids[i] = c.ID
+	}
+
+	summaries, err := GetReactionSummaries(TargetComment, ids, userID)
+	if err != nil {
+		return err
+	}
+
+	for i := range comments {
+		comments[i].Reactions = summaries[comments[i].ID]
+	}
+	return nil
+}
+
+// loadCommentUserVotes sets UserVote on each comment with a single query
+// instead of one lookup per comment
+func loadCommentUserVotes(comments []Comment, userID int) error {
+	ids := make([]int, len(comments))
+	for i, c := range comments {
+		ids[i] = c.ID
+	}
+
+	votes, err := GetUserVoteTypes(userID, TargetComment, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range comments {
+		if voteType, ok := votes[comments[i].ID]; ok {
+			comments[i].UserVote = &voteType
+		}
+	}
+	return nil
+}
+
+// GetPosition returns the 1-based position of the comment in its post's comment list
+// for the given sort, matching the order used by GetCommentsByPostID
+func (c *Comment) GetPosition(sortBy string) (int, error) {
+	positions, err := GetCommentPositions([]int{c.ID}, sortBy)
+	if err != nil {
+		return 0, err
+	}
+	position, ok := positions[c.ID]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return position, nil
+}
+
+// GetCommentPositions returns the 1-based position of each comment in its post's
+// comment list for the given sort, in one query. Positions count the visible
+// comments of the post, the accepted answer first, in the order GetCommentsByPostID
+// lists them. Comments that don't exist are missing from the map.
+func GetCommentPositions(commentIDs []int, sortBy string) (map[int]int, error) {
+	positions := make(map[int]int, len(commentIDs))
+	if len(commentIDs) == 0 {
+		return positions, nil
+	}
+
+	orderBy, ok := commentSortOrders[sortBy]
+	if !ok {
+		orderBy = commentSortOrders[CommentSortOldest]
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(commentIDs)), ",")
+	args := make([]interface{}, 0, len(commentIDs))
+	for _, id := range commentIDs {
+		args = append(args, id)
+	}
+
+	// The requested comments are ranked along with the visible ones so a comment
+	// that is itself not listed still gets the place it would have
+	query := `
+		WITH targets AS (
+			SELECT * FROM comments WHERE id IN (` + placeholders + `)
+		), ranked AS (
+			SELECT c.id,
+			       ROW_NUMBER() OVER (
+			           PARTITION BY c.post_id
+			           ORDER BY (p.accepted_comment_id IS NOT NULL AND p.accepted_comment_id = c.id) DESC, ` + orderBy + `
+			       ) AS position
+			FROM (
+				SELECT * FROM visible_comments WHERE post_id IN (SELECT post_id FROM targets)
+				UNION
+				SELECT * FROM targets
+			) c
+			JOIN posts p ON c.post_id = p.id
+		)
+		SELECT ranked.id, ranked.position FROM ranked JOIN targets ON targets.id = ranked.id
+	`
+	rows, err := database.GetDB().Query(query, args...)
+	if err != nil {
+		return positions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, position int
+		if err := rows.Scan(&id, &position); err != nil {
+			return positions, err
+		}
+		positions[id] = position
+	}
+	return positions, rows.Err()
+}
+
 // Update modifies an existing comment
 func (c *Comment) Update() error {
 	// Validate comment content
@@ -137,21 +443,22 @@ func (c *Comment) Update() error {
 
 	query := `
 		UPDATE comments 
-		SET content = ?, updated_at = ?
+		SET content = ?, updated_at = ?, edited_at = ?
 		WHERE id = ?
 	`
 
 	now := time.Now()
-	_, err := database.GetDB().Exec(query, c.Content, now, c.ID)
+	_, err := database.GetDB().Exec(query, c.Content, now, now, c.ID)
 	if err != nil {
 		return err
 	}
 
 	c.UpdatedAt = now
+	c.EditedAt = &now
 	return nil
 }
 
-// Delete removes a comment from the database
+// Delete soft-deletes a comment, hiding it from readers while keeping the row
 func (c *Comment) Delete() error {
 	// Start transaction for consistent deletion
 	tx, err := database.GetDB().Begin()
@@ -160,25 +467,151 @@ func (c *Comment) Delete() error {
 	}
 	defer tx.Rollback()
 
-	// Delete all votes for this comment first
-	_, err = tx.Exec("DELETE FROM votes WHERE comment_id = ?", c.ID)
+	if err := c.softDelete(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveByModerator soft-deletes another user's comment on a moderator's behalf. The
+// removal, its moderation log entry and the author's notification are written together,
+// so a removal is never left unaudited or unannounced.
+func (c *Comment) RemoveByModerator(moderatorID int, reason string) error {
+	tx, err := database.GetDB().Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	if err := c.softDelete(tx); err != nil {
+		return err
+	}
 
-	// Delete the comment
-	_, err = tx.Exec("DELETE FROM comments WHERE id = ?", c.ID)
+	_, err = tx.Exec("UPDATE comments SET removed_by = ?, removal_reason = ? WHERE id = ?", moderatorID, reason, c.ID)
 	if err != nil {
 		return err
 	}
 
+	if err := logModerationAction(tx, moderatorID, ModActionDeleteComment, TargetComment, c.ID, reason); err != nil {
+		return err
+	}
+
+	message := "A moderator removed your comment"
+	if reason != "" {
+		message += ": " + reason
+	}
+	notification := Notification{
+		UserID:    c.UserID,
+		Type:      NotificationCommentRemoved,
+		PostID:    &c.PostID,
+		CommentID: &c.ID,
+		Message:   message,
+	}
+	if err := notification.create(tx); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
+// softDelete marks the comment deleted and detaches what pointed at it
+func (c *Comment) softDelete(tx *sql.Tx) error {
+	// A deleted comment can't stay the accepted answer
+	_, err := tx.Exec("UPDATE posts SET accepted_comment_id = NULL WHERE accepted_comment_id = ?", c.ID)
+	if err != nil {
+		return err
+	}
+
+	// Replies quoting this comment keep their snapshot but lose the reference
+	_, err = tx.Exec("UPDATE comments SET quoted_comment_id = NULL, quote_removed = 1 WHERE quoted_comment_id = ?", c.ID)
+	if err != nil {
+		return err
+	}
+
+	// Soft delete the comment; replies keep their parent_id
+	_, err = tx.Exec("UPDATE comments SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL", time.Now(), c.ID)
+	return err
+}
+
+// CommentTombstone is the placeholder left in a thread by a deleted comment
+type CommentTombstone struct {
+	ID                 int
+	PostID             int
+	ParentID           *int
+	RemovedByModerator bool
+	Score              int // likes minus dislikes, so the tombstone sorts where its comment did
+	CreatedAt          time.Time
+}
+
+// GetCommentTombstones lists the deleted comments of a post so threads can keep their shape
+func GetCommentTombstones(postID int) ([]CommentTombstone, error) {
+	tombstones := []CommentTombstone{}
+
+	query := `
+		SELECT id, post_id, parent_id, removed_by IS NOT NULL, likes - dislikes, created_at
+		FROM comments
+		WHERE post_id = ? AND deleted_at IS NOT NULL
+		ORDER BY created_at ASC, id ASC
+	`
+	rows, err := database.GetReadDB().Query(query, postID)
+	if err != nil {
+		return tombstones, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t CommentTombstone
+		var parentID sql.NullInt64
+		if err := rows.Scan(&t.ID, &t.PostID, &parentID, &t.RemovedByModerator, &t.Score, &t.CreatedAt); err != nil {
+			return tombstones, err
+		}
+		t.ParentID = nullIntPtr(parentID)
+		tombstones = append(tombstones, t)
+	}
+
+	return tombstones, rows.Err()
+}
+
+// quoteColumns holds the nullable quote columns of a comment row
+type quoteColumns struct {
+	commentID sql.NullInt64
+	username  sql.NullString
+	excerpt   sql.NullString
+	removed   bool
+}
+
+// applyTo sets the comment's quote from the scanned columns (no excerpt means no quote)
+func (q *quoteColumns) applyTo(c *Comment) {
+	c.QuotedCommentID = nullIntPtr(q.commentID)
+	if !q.excerpt.Valid {
+		c.Quote = nil
+		return
+	}
+
+	c.Quote = &CommentQuote{
+		CommentID: c.QuotedCommentID,
+		Username:  q.username.String,
+		Excerpt:   q.excerpt.String,
+		Removed:   q.removed,
+	}
+}
+
+// SetHidden hides or restores a comment pending moderator review
+func (c *Comment) SetHidden(hidden bool) error {
+	_, err := database.GetDB().Exec(`UPDATE comments SET hidden = ? WHERE id = ?`, hidden, c.ID)
+	if err != nil {
+		return err
+	}
+
+	c.Hidden = hidden
+	return nil
+}
+
 // GetCommentCount returns the total number of comments for a post
 func GetCommentCount(postID int) (int, error) {
 	var count int
-	query := `SELECT COUNT(*) FROM comments WHERE post_id = ?`
+	query := `SELECT COUNT(*) FROM visible_comments WHERE post_id = ?`
 	err := database.GetDB().QueryRow(query, postID).Scan(&count)
 	return count, err
 }
@@ -219,6 +652,9 @@ func (c *Comment) getUserVote(userID int) {
 
 // Validate checks if comment content is valid
 func (c *Comment) Validate() error {
+	// Strip control and bidi characters whichever path the content came from
+	c.Content = utils.SanitizeString(c.Content)
+
 	// Check content length
 	if len(c.Content) == 0 {
 		return errors.New("comment content cannot be empty")
@@ -227,15 +663,81 @@ func (c *Comment) Validate() error {
 		return errors.New("comment content cannot exceed 1000 characters")
 	}
 
-	// Check for basic content (not just whitespace)
-	trimmed := strings.TrimSpace(c.Content)
-	if len(trimmed) == 0 {
+	// Check for basic content (not just whitespace or zero-width characters)
+	if utils.VisibleText(c.Content) == "" {
 		return errors.New("comment cannot be empty or contain only whitespace")
 	}
 
 	return nil
 }
 
+// SettingCommentEditWindow overrides the comment edit window (in minutes) at runtime
+const SettingCommentEditWindow = "comments.edit_window_minutes"
+
+// CommentEditWindow returns how long after creation authors may edit their comments
+func CommentEditWindow() time.Duration {
+	minutes := Settings.GetInt(SettingCommentEditWindow, config.GetCommentEditWindowMinutes())
+	return time.Duration(minutes) * time.Minute
+}
+
+// Per-post comment flood control, overridable at runtime through settings
+const (
+	SettingCommentsPerPostWindow = "comments.max_per_post_window"
+	SettingCommentMinInterval    = "comments.min_interval_seconds"
+
+	DefaultCommentsPerPostWindow     = 5
+	DefaultCommentMinIntervalSeconds = 20
+
+	// CommentFloodWindow is the period CommentsPerPostLimit applies to
+	CommentFloodWindow = 10 * time.Minute
+)
+
+// CommentsPerPostLimit returns how many comments a user may post on one post per CommentFloodWindow
+func CommentsPerPostLimit() int {
+	return Settings.GetInt(SettingCommentsPerPostWindow, DefaultCommentsPerPostWindow)
+}
+
+// CommentMinInterval returns the minimum gap between a user's consecutive comments on one post
+func CommentMinInterval() time.Duration {
+	return time.Duration(Settings.GetInt(SettingCommentMinInterval, DefaultCommentMinIntervalSeconds)) * time.Second
+}
+
+// GetRecentCommentTimes returns when a user commented on a post since the given time, newest first.
+// Deleted comments are included so deleting and reposting doesn't get around the limits.
+func GetRecentCommentTimes(userID, postID int, since time.Time) ([]time.Time, error) {
+	query := `
+		SELECT created_at FROM comments
+		WHERE user_id = ? AND post_id = ? AND created_at > ?
+		ORDER BY created_at DESC
+	`
+	rows, err := database.GetDB().Query(query, userID, postID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var times []time.Time
+	for rows.Next() {
+		var createdAt time.Time
+		if err := rows.Scan(&createdAt); err != nil {
+			return nil, err
+		}
+		times = append(times, createdAt)
+	}
+	return times, rows.Err()
+}
+
+// EditableUntil returns the moment the author's edit window closes
+func (c *Comment) EditableUntil() time.Time {
+	return c.CreatedAt.Add(CommentEditWindow())
+}
+
+// IsWithinEditWindow checks if the edit window is still open at the given time.
+// The window is half-open: an edit exactly at EditableUntil is rejected.
+func (c *Comment) IsWithinEditWindow(now time.Time) bool {
+	return now.Before(c.EditableUntil())
+}
+
 // CanEdit checks if a user can edit this comment
 func (c *Comment) CanEdit(userID int) bool {
 	return c.UserID == userID
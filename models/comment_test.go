@@ -0,0 +1,397 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"forum/database"
+)
+
+// commentIDs lists the IDs of comments in order
+func commentIDs(comments []Comment) []int {
+	ids := make([]int, 0, len(comments))
+	for _, c := range comments {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func TestGetCommentsByPostIDSorts(t *testing.T) {
+	author := newTestUser(t)
+	post := newTestPost(t, author.ID)
+
+	// Created an hour apart, oldest first, with scores +1, +2, -1 and +2
+	base := time.Now().Add(-4 * time.Hour)
+	scores := [][]string{{"like"}, {"like", "like"}, {"dislike"}, {"like", "like", "like", "dislike"}}
+	var ids []int
+	for i, votes := range scores {
+		comment := newTestComment(t, author.ID, post.ID, "Comment number "+string(rune('A'+i)))
+		setCreatedAt(t, "comments", comment.ID, base.Add(time.Duration(i)*time.Hour))
+		for _, vote := range votes {
+			voteComment(t, comment.ID, vote)
+		}
+		ids = append(ids, comment.ID)
+	}
+
+	tests := []struct {
+		sortBy string
+		want   []int
+	}{
+		{CommentSortOldest, []int{ids[0], ids[1], ids[2], ids[3]}},
+		{CommentSortNewest, []int{ids[3], ids[2], ids[1], ids[0]}},
+		// Equal scores go to the newer comment
+		{CommentSortBest, []int{ids[3], ids[1], ids[0], ids[2]}},
+		{"bogus", []int{ids[0], ids[1], ids[2], ids[3]}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sortBy, func(t *testing.T) {
+			comments, total, err := GetCommentsByPostID(post.ID, nil, tt.sortBy, 10, 0, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != len(ids) {
+				t.Fatalf("total = %d, want %d", total, len(ids))
+			}
+			if got := commentIDs(comments); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommentEditWindowBoundary(t *testing.T) {
+	window := CommentEditWindow()
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	comment := &Comment{CreatedAt: created}
+
+	if got := comment.EditableUntil(); !got.Equal(created.Add(window)) {
+		t.Fatalf("EditableUntil = %v, want %v", got, created.Add(window))
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{"just created", created, true},
+		{"one nanosecond before the edge", created.Add(window - time.Nanosecond), true},
+		{"exactly at the edge", created.Add(window), false},
+		{"after the edge", created.Add(window + time.Second), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := comment.IsWithinEditWindow(tt.now); got != tt.want {
+				t.Fatalf("IsWithinEditWindow = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommentEditWindowSetting(t *testing.T) {
+	t.Cleanup(func() { Settings.Delete(SettingCommentEditWindow) })
+
+	if err := Settings.Set(SettingCommentEditWindow, "3"); err != nil {
+		t.Fatal(err)
+	}
+	if got := CommentEditWindow(); got != 3*time.Minute {
+		t.Fatalf("CommentEditWindow = %v, want 3m", got)
+	}
+}
+
+func TestGetCommentsByPostIDLoadsUserVotesInOneQuery(t *testing.T) {
+	author := newTestUser(t)
+	viewer := newTestUser(t)
+
+	// queriesFor lists a post of n comments, half of them voted on by the viewer
+	queriesFor := func(n int) int {
+		post := newTestPost(t, author.ID)
+		want := make(map[int]string)
+		for i := 0; i < n; i++ {
+			comment := newTestComment(t, author.ID, post.ID, "A comment to vote on")
+			if i%2 == 0 {
+				if _, err := ToggleCommentVote(viewer.ID, comment.ID, "dislike", VoteModeSet, nil); err != nil {
+					t.Fatal(err)
+				}
+				want[comment.ID] = "dislike"
+			}
+		}
+
+		var comments []Comment
+		queries := countQueries(t, func() {
+			var err error
+			comments, _, err = GetCommentsByPostID(post.ID, &viewer.ID, CommentSortOldest, 50, 0, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+
+		for _, c := range comments {
+			got := ""
+			if c.UserVote != nil {
+				got = *c.UserVote
+			}
+			if got != want[c.ID] {
+				t.Fatalf("comment %d user_vote = %q, want %q", c.ID, got, want[c.ID])
+			}
+		}
+		return queries
+	}
+
+	small, large := queriesFor(2), queriesFor(12)
+	if small == 0 {
+		t.Fatal("no queries counted")
+	}
+	if small != large {
+		t.Fatalf("listing made %d queries for 2 comments and %d for 12, want the same", small, large)
+	}
+}
+
+func TestCommentCountsIgnoreDeletedAndHidden(t *testing.T) {
+	author := newTestUser(t)
+	category := newTestCategory(t)
+	post := newTestPost(t, author.ID, category.ID)
+
+	var comments []*Comment
+	for i := 0; i < 3; i++ {
+		comments = append(comments, newTestComment(t, author.ID, post.ID, "A comment to count"))
+	}
+
+	// counts gathers every place a post's comment count shows up
+	counts := func(t *testing.T) map[string]int {
+		t.Helper()
+
+		got := make(map[string]int)
+		var err error
+		if got["GetCommentCount"], err = GetCommentCount(post.ID); err != nil {
+			t.Fatal(err)
+		}
+		if got["Post.GetCommentCount"], err = post.GetCommentCount(); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded := &Post{}
+		if err := loaded.GetByID(post.ID, nil); err != nil {
+			t.Fatal(err)
+		}
+		got["Post.GetByID"] = loaded.CommentCount
+
+		posts, _, err := GetPosts(PostFilters{CategoryID: category.ID, Limit: 10})
+		if err != nil || len(posts) != 1 {
+			t.Fatalf("GetPosts = %d posts, %v", len(posts), err)
+		}
+		got["GetPosts"] = posts[0].CommentCount
+
+		if _, got["GetCommentsByPostID"], err = GetCommentsByPostID(post.ID, nil, CommentSortOldest, 10, 0, false); err != nil {
+			t.Fatal(err)
+		}
+
+		stats, err := category.GetStats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got["Category.GetStats"] = stats.TotalComments
+		return got
+	}
+	expect := func(t *testing.T, want int) {
+		t.Helper()
+
+		for source, got := range counts(t) {
+			if got != want {
+				t.Errorf("%s = %d, want %d", source, got, want)
+			}
+		}
+	}
+
+	expect(t, 3)
+
+	if err := comments[0].Delete(); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 2)
+	if err := (&Comment{}).GetByID(comments[0].ID, nil); err == nil {
+		t.Fatal("GetByID returned a deleted comment")
+	}
+
+	if _, err := database.GetDB().Exec(`UPDATE comments SET hidden = 1 WHERE id = ?`, comments[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, 1)
+}
+
+func TestGetCommentsByPostIDPageBoundaries(t *testing.T) {
+	author := newTestUser(t)
+	post := newTestPost(t, author.ID)
+
+	var ids []int
+	for i := 0; i < 5; i++ {
+		ids = append(ids, newTestComment(t, author.ID, post.ID, "A comment to page through").ID)
+	}
+	// A deleted comment counts towards neither the pages nor the total
+	if err := (&Comment{ID: ids[4]}).Delete(); err != nil {
+		t.Fatal(err)
+	}
+	ids = ids[:4]
+
+	tests := []struct {
+		name   string
+		offset int
+		want   []int
+	}{
+		{"first page", 0, ids[:2]},
+		{"last page, exactly full", 2, ids[2:]},
+		{"past the end", 4, []int{}},
+		{"far past the end", 40, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comments, total, err := GetCommentsByPostID(post.ID, nil, CommentSortOldest, 2, tt.offset, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != len(ids) {
+				t.Fatalf("total = %d, want %d", total, len(ids))
+			}
+			if got := commentIDs(comments); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("page = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommentExists(t *testing.T) {
+	author := newTestUser(t)
+	post := newTestPost(t, author.ID)
+	comment := newTestComment(t, author.ID, post.ID, "A comment that exists")
+	deleted := newTestComment(t, author.ID, post.ID, "A comment that gets deleted")
+	hidden := newTestComment(t, author.ID, post.ID, "A comment that gets hidden")
+	if err := deleted.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.GetDB().Exec(`UPDATE comments SET hidden = 1 WHERE id = ?`, hidden.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, want := range map[int]bool{comment.ID: true, deleted.ID: false, hidden.ID: false, 999999: false} {
+		if got, err := CommentExists(id); err != nil || got != want {
+			t.Fatalf("CommentExists(%d) = %v, %v, want %v", id, got, err, want)
+		}
+	}
+}
+
+func TestCommentListingUsesOneScore(t *testing.T) {
+	author := newTestUser(t)
+	post := newTestPost(t, author.ID)
+
+	// Real scores +1, +2 and 0
+	scores := [][]string{{"like"}, {"like", "like"}, {"like", "dislike"}}
+	var ids []int
+	for i, votes := range scores {
+		comment := newTestComment(t, author.ID, post.ID, "Scored comment "+string(rune('A'+i)))
+		for _, vote := range votes {
+			voteComment(t, comment.ID, vote)
+		}
+		ids = append(ids, comment.ID)
+	}
+
+	// checkListing asserts the best listing shows the counts GetCommentVoteCounts
+	// returns, is ordered and collapsed by them, and that each comment's position
+	// matches where it is listed
+	checkListing := func(t *testing.T, want []int) {
+		t.Helper()
+
+		comments, _, err := GetCommentsByPostID(post.ID, nil, CommentSortBest, 10, 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := commentIDs(comments); !reflect.DeepEqual(got, want) {
+			t.Fatalf("order = %v, want %v", got, want)
+		}
+		counts, err := GetCommentVoteCounts(ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, c := range comments {
+			if got := (VoteCounts{Likes: c.Likes, Dislikes: c.Dislikes}); got != counts[c.ID] {
+				t.Errorf("comment %d: listed %+v, votes count %+v", c.ID, got, counts[c.ID])
+			}
+			var single Comment
+			if err := single.GetByID(c.ID, nil); err != nil {
+				t.Fatal(err)
+			}
+			if single.Likes != c.Likes || single.Dislikes != c.Dislikes {
+				t.Errorf("comment %d: GetByID shows %d/%d, listed %d/%d", c.ID, single.Likes, single.Dislikes, c.Likes, c.Dislikes)
+			}
+			if i > 0 {
+				prev := comments[i-1]
+				if prev.Likes-prev.Dislikes < c.Likes-c.Dislikes {
+					t.Errorf("comment %d (score %d) listed before comment %d (score %d)",
+						prev.ID, prev.Likes-prev.Dislikes, c.ID, c.Likes-c.Dislikes)
+				}
+			}
+			if c.Collapsed != IsCollapsedScore(c.Likes, c.Dislikes) {
+				t.Errorf("comment %d: collapsed = %v with %d/%d", c.ID, c.Collapsed, c.Likes, c.Dislikes)
+			}
+			position, err := c.GetPosition(CommentSortBest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if position != i+1 {
+				t.Errorf("comment %d: position = %d, listed at %d", c.ID, position, i+1)
+			}
+		}
+	}
+
+	checkListing(t, []int{ids[1], ids[0], ids[2]})
+
+	// Drifting the cached columns changes nothing: the listing counts the votes
+	db := database.GetDB()
+	if _, err := db.Exec(`UPDATE comments SET likes = 0, dislikes = 9 WHERE id = ?`, ids[1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE comments SET likes = 5, dislikes = 0 WHERE id = ?`, ids[2]); err != nil {
+		t.Fatal(err)
+	}
+	checkListing(t, []int{ids[1], ids[0], ids[2]})
+}
+
+func TestGetCommentPositionsInOneQuery(t *testing.T) {
+	author := newTestUser(t)
+	var ids []int
+	want := map[int]int{}
+	for p := 0; p < 2; p++ {
+		post := newTestPost(t, author.ID)
+		for i := 0; i < 3; i++ {
+			comment := newTestComment(t, author.ID, post.ID, "Positioned comment")
+			ids = append(ids, comment.ID)
+			want[comment.ID] = i + 1
+		}
+	}
+
+	var positions map[int]int
+	var err error
+	queries := countQueries(t, func() {
+		positions, err = GetCommentPositions(ids, CommentSortOldest)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queries != 1 {
+		t.Errorf("made %d queries for %d comments, want 1", queries, len(ids))
+	}
+	if !reflect.DeepEqual(positions, want) {
+		t.Errorf("positions = %v, want %v", positions, want)
+	}
+
+	// Each agrees with the single-comment lookup
+	for _, id := range ids {
+		position, err := (&Comment{ID: id}).GetPosition(CommentSortOldest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if position != want[id] {
+			t.Errorf("comment %d: GetPosition = %d, want %d", id, position, want[id])
+		}
+	}
+}
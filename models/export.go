@@ -0,0 +1,82 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"forum/database"
+)
+
+// ExportRecord is one row of a user's data export: either a post or a comment
+type ExportRecord struct {
+	Type       string // "post" or "comment"
+	ID         int
+	PostID     int
+	PostTitle  string
+	Content    string
+	Categories string // post categories, "; "-separated
+	Likes      int
+	Dislikes   int
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// ExportUserContent calls fn for each of the user's posts, then each of their comments,
+// reading rows one at a time so large accounts are never held in memory.
+// Iteration stops at the first error returned by fn.
+func ExportUserContent(userID int, fn func(ExportRecord) error) error {
+	postsQuery := `
+		SELECT p.id, p.title, p.content, COALESCE(GROUP_CONCAT(c.name, '; '), ''),
+		       p.likes, p.dislikes, p.created_at, p.updated_at
+		FROM posts p
+		LEFT JOIN post_categories pc ON p.id = pc.post_id
+		LEFT JOIN categories c ON pc.category_id = c.id
+		WHERE p.user_id = ?
+		GROUP BY p.id
+		ORDER BY p.created_at ASC, p.id ASC
+	`
+	err := exportRows(postsQuery, userID, fn, func(rows *sql.Rows, r *ExportRecord) error {
+		r.Type = TargetPost
+		err := rows.Scan(&r.ID, &r.PostTitle, &r.Content, &r.Categories,
+			&r.Likes, &r.Dislikes, &r.CreatedAt, &r.UpdatedAt)
+		r.PostID = r.ID
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	commentsQuery := `
+		SELECT c.id, c.post_id, p.title, c.content, c.likes, c.dislikes, c.created_at, c.updated_at
+		FROM comments c
+		JOIN posts p ON c.post_id = p.id
+		WHERE c.user_id = ? AND c.deleted_at IS NULL
+		ORDER BY c.created_at ASC, c.id ASC
+	`
+	return exportRows(commentsQuery, userID, fn, func(rows *sql.Rows, r *ExportRecord) error {
+		r.Type = TargetComment
+		return rows.Scan(&r.ID, &r.PostID, &r.PostTitle, &r.Content,
+			&r.Likes, &r.Dislikes, &r.CreatedAt, &r.UpdatedAt)
+	})
+}
+
+// exportRows runs an export query and feeds each scanned row to fn
+func exportRows(query string, userID int, fn func(ExportRecord) error, scan func(*sql.Rows, *ExportRecord) error) error {
+	rows, err := database.GetDB().Query(query, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var record ExportRecord
+		if err := scan(rows, &record); err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,177 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"forum/config"
+	"forum/database"
+)
+
+// Registration settings, overridable at runtime; the defaults come from the config
+const (
+	SettingRegistrationEnabled    = "registration.enabled"
+	SettingRegistrationInviteOnly = "registration.invite_only"
+)
+
+// Invite states reported in listings
+const (
+	InviteStatusUnused  = "unused"
+	InviteStatusUsed    = "used"
+	InviteStatusExpired = "expired"
+)
+
+// ErrInvalidInvite is returned when an invite code is unknown, used or expired
+var ErrInvalidInvite = errors.New("invalid or already used invite code")
+
+// Invite is a single-use code that lets someone register while registration is invite-only
+type Invite struct {
+	ID        int        `json:"id"`
+	Code      string     `json:"code"`
+	CreatedBy *int       `json:"created_by"`
+	UsedBy    *int       `json:"used_by"`
+	UsedAt    *time.Time `json:"used_at"`
+	ExpiresAt *time.Time `json:"expires_at"` // nil for codes that never expire
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RegistrationEnabled reports whether new accounts can be created
+func RegistrationEnabled() bool {
+	return Settings.GetBool(SettingRegistrationEnabled, config.IsRegistrationEnabled())
+}
+
+// RegistrationInviteOnly reports whether new accounts need an invite code
+func RegistrationInviteOnly() bool {
+	return Settings.GetBool(SettingRegistrationInviteOnly, config.IsRegistrationInviteOnly())
+}
+
+// CreateInvite generates a new invite code. expiresAt may be nil for a code that never expires.
+func CreateInvite(createdBy int, expiresAt *time.Time) (*Invite, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+
+	invite := &Invite{
+		Code:      hex.EncodeToString(buf),
+		CreatedBy: &createdBy,
+		ExpiresAt: expiresAt,
+		Status:    InviteStatusUnused,
+		CreatedAt: time.Now(),
+	}
+
+	query := `INSERT INTO invites (code, created_by, expires_at, created_at) VALUES (?, ?, ?, ?)`
+	result, err := database.GetDB().Exec(query, invite.Code, createdBy, expiresAt, invite.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	invite.ID = int(id)
+	return invite, nil
+}
+
+// GetInvites returns invites newest first, along with the total number of invites
+func GetInvites(limit, offset int) ([]Invite, int, error) {
+	invites := []Invite{}
+
+	var total int
+	if err := database.GetDB().QueryRow(`SELECT COUNT(*) FROM invites`).Scan(&total); err != nil {
+		return invites, 0, err
+	}
+
+	query := `
+		SELECT id, code, created_by, used_by, used_at, expires_at, created_at
+		FROM invites
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`
+	rows, err := database.GetDB().Query(query, limit, offset)
+	if err != nil {
+		return invites, 0, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	for rows.Next() {
+		var invite Invite
+		var createdBy, usedBy sql.NullInt64
+		var usedAt, expiresAt sql.NullTime
+		if err := rows.Scan(&invite.ID, &invite.Code, &createdBy, &usedBy, &usedAt, &expiresAt, &invite.CreatedAt); err != nil {
+			return invites, 0, err
+		}
+		invite.CreatedBy = nullIntPtr(createdBy)
+		invite.UsedBy = nullIntPtr(usedBy)
+		invite.UsedAt = nullTimePtr(usedAt)
+		invite.ExpiresAt = nullTimePtr(expiresAt)
+
+		switch {
+		case invite.UsedAt != nil:
+			invite.Status = InviteStatusUsed
+		case invite.ExpiresAt != nil && !invite.ExpiresAt.After(now):
+			invite.Status = InviteStatusExpired
+		default:
+			invite.Status = InviteStatusUnused
+		}
+		invites = append(invites, invite)
+	}
+
+	return invites, total, rows.Err()
+}
+
+// DeleteInvite revokes an invite that hasn't been used; deleted is false when
+// there was no such unused invite
+func DeleteInvite(id int) (deleted bool, err error) {
+	result, err := database.GetDB().Exec(`DELETE FROM invites WHERE id = ? AND used_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// ReserveInvite marks a valid invite code as used before the account exists, so two
+// registrations can't share one code. Finish with CompleteInvite once the user is
+// created, or ReleaseInvite if creating the user fails.
+func ReserveInvite(code string) (int, error) {
+	now := time.Now()
+	query := `
+		UPDATE invites SET used_at = ?
+		WHERE code = ? AND used_at IS NULL
+		  AND (expires_at IS NULL OR datetime(expires_at) > ?)
+	`
+	result, err := database.GetDB().Exec(query, now, code, now.UTC().Format(sqliteDateTime))
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if affected == 0 {
+		return 0, ErrInvalidInvite
+	}
+
+	var id int
+	err = database.GetDB().QueryRow(`SELECT id FROM invites WHERE code = ?`, code).Scan(&id)
+	return id, err
+}
+
+// CompleteInvite records which user a reserved invite was used by
+func CompleteInvite(id, userID int) error {
+	_, err := database.GetDB().Exec(`UPDATE invites SET used_by = ? WHERE id = ?`, userID, id)
+	return err
+}
+
+// ReleaseInvite makes a reserved invite usable again
+func ReleaseInvite(id int) error {
+	_, err := database.GetDB().Exec(`UPDATE invites SET used_at = NULL WHERE id = ? AND used_by IS NULL`, id)
+	return err
+}
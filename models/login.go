@@ -0,0 +1,97 @@
+package models
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/database"
+)
+
+// LoginKey names what failed logins are counted against: the account when the submitted
+// username or email matched one, otherwise the submitted value itself, so unknown names
+// get locked the same way and responses don't reveal which accounts exist
+func LoginKey(userID int, identifier string) string {
+	if userID > 0 {
+		return "user:" + strconv.Itoa(userID)
+	}
+	return "name:" + strings.ToLower(strings.TrimSpace(identifier))
+}
+
+// RecordFailedLogin stores a failed login attempt. Once key has maxFailures failures
+// within window it is locked for lockout from now; the lock is stored so it lasts its
+// full duration however the failures age. It returns the lock when one applies.
+func RecordFailedLogin(key, ipAddress string, maxFailures int, window, lockout time.Duration) (time.Time, bool, error) {
+	now := time.Now()
+
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	defer tx.Rollback()
+
+	// Failures older than the window no longer count towards anything
+	if _, err := tx.Exec(`DELETE FROM login_failures WHERE created_at <= ?`, now.Add(-window)); err != nil {
+		return time.Time{}, false, err
+	}
+
+	query := `INSERT INTO login_failures (login_key, ip_address, created_at) VALUES (?, ?, ?)`
+	if _, err := tx.Exec(query, key, ipAddress, now); err != nil {
+		return time.Time{}, false, err
+	}
+
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM login_failures WHERE login_key = ?`, key).Scan(&count); err != nil {
+		return time.Time{}, false, err
+	}
+	if count < maxFailures {
+		return time.Time{}, false, tx.Commit()
+	}
+
+	// Lock, and start counting afresh for when the lock ends
+	lockedUntil := now.Add(lockout)
+	lockQuery := `
+		INSERT INTO login_lockouts (login_key, locked_until) VALUES (?, ?)
+		ON CONFLICT(login_key) DO UPDATE SET locked_until = excluded.locked_until
+	`
+	if _, err := tx.Exec(lockQuery, key, lockedUntil); err != nil {
+		return time.Time{}, false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM login_failures WHERE login_key = ?`, key); err != nil {
+		return time.Time{}, false, err
+	}
+
+	return lockedUntil, true, tx.Commit()
+}
+
+// ClearFailedLogins forgets a key's failed attempts and lock, e.g. after a successful login
+func ClearFailedLogins(key string) error {
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM login_failures WHERE login_key = ?`, key); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM login_lockouts WHERE login_key = ?`, key); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetLoginLockout reports whether a key is locked and until when
+func GetLoginLockout(key string) (time.Time, bool, error) {
+	var lockedUntil time.Time
+	err := database.GetDB().QueryRow(`SELECT locked_until FROM login_lockouts WHERE login_key = ?`, key).Scan(&lockedUntil)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	return lockedUntil, time.Now().Before(lockedUntil), nil
+}
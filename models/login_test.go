@@ -0,0 +1,113 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginLockout(t *testing.T) {
+	key := LoginKey(newTestUser(t).ID, "")
+
+	// fail records a failed login against key with a limit of 3 failures
+	fail := func(window, lockout time.Duration) bool {
+		t.Helper()
+
+		_, locked, err := RecordFailedLogin(key, "203.0.113.1", 3, window, lockout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return locked
+	}
+	isLocked := func() bool {
+		t.Helper()
+
+		_, locked, err := GetLoginLockout(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return locked
+	}
+
+	t.Run("locks at the limit", func(t *testing.T) {
+		if fail(time.Hour, time.Hour) || fail(time.Hour, time.Hour) || isLocked() {
+			t.Fatal("locked before reaching the limit")
+		}
+		if !fail(time.Hour, time.Hour) || !isLocked() {
+			t.Fatal("not locked at the limit")
+		}
+
+		lockedUntil, _, err := GetLoginLockout(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := time.Until(lockedUntil); d < 59*time.Minute || d > time.Hour {
+			t.Fatalf("locked for %v, want about an hour", d)
+		}
+	})
+
+	t.Run("a successful login unlocks", func(t *testing.T) {
+		if err := ClearFailedLogins(key); err != nil {
+			t.Fatal(err)
+		}
+		if isLocked() {
+			t.Fatal("still locked after clearing")
+		}
+		if fail(time.Hour, time.Hour) || fail(time.Hour, time.Hour) {
+			t.Fatal("failures from before the reset still counted")
+		}
+		if err := ClearFailedLogins(key); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("lockout longer than the window", func(t *testing.T) {
+		window := 50 * time.Millisecond
+		fail(window, time.Hour)
+		fail(window, time.Hour)
+		if !fail(window, time.Hour) {
+			t.Fatal("not locked at the limit")
+		}
+
+		// The failures have left the window, but the lock runs its full length
+		time.Sleep(2 * window)
+		if !isLocked() {
+			t.Fatal("lock ended with the failure window instead of the lockout duration")
+		}
+		if err := ClearFailedLogins(key); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("unlocks when the lockout ends", func(t *testing.T) {
+		lockout := 50 * time.Millisecond
+		fail(time.Hour, lockout)
+		fail(time.Hour, lockout)
+		if !fail(time.Hour, lockout) {
+			t.Fatal("not locked at the limit")
+		}
+
+		time.Sleep(2 * lockout)
+		if isLocked() {
+			t.Fatal("still locked after the lockout")
+		}
+		// Counting starts afresh, so one more failure doesn't lock again straight away
+		if fail(time.Hour, lockout) {
+			t.Fatal("locked again by the first failure after the lockout")
+		}
+		if err := ClearFailedLogins(key); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestLoginKey(t *testing.T) {
+	if LoginKey(7, "alice") != LoginKey(7, "alice@example.com") {
+		t.Fatal("an account's username and email count separately")
+	}
+	if LoginKey(0, " Nobody ") != LoginKey(0, "nobody") {
+		t.Fatal("unknown names differing in case and spacing count separately")
+	}
+	if LoginKey(7, "") == LoginKey(0, "7") {
+		t.Fatal("an account and an unknown name share a key")
+	}
+}
@@ -0,0 +1,184 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"forum/config"
+	"forum/database"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// TestMain runs the package tests against a fresh database in a scratch directory
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+
+	dir, err := os.MkdirTemp("", "forum-models-test")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+
+	os.Setenv("DATABASE_URL", filepath.Join(dir, "forum.db"))
+	config.Load()
+	database.Init()
+	if err := Settings.Load(); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+
+	database.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+var testSeq int64
+
+// queryCount counts the queries run through the counting driver
+var queryCount int64
+
+func init() {
+	sql.Register("sqlite3_counting", countingDriver{})
+}
+
+// countingDriver is the sqlite3 driver with every query counted in queryCount
+type countingDriver struct{}
+
+func (countingDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := (&sqlite3.SQLiteDriver{}).Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return countingConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+type countingConn struct {
+	*sqlite3.SQLiteConn
+}
+
+func (c countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	atomic.AddInt64(&queryCount, 1)
+	return c.SQLiteConn.QueryContext(ctx, query, args)
+}
+
+// countQueries runs fn against a counting connection to the test database
+// and returns how many queries it made
+func countQueries(t *testing.T, fn func()) int {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3_counting", config.GetDatabaseURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	previous := database.DB
+	database.DB = db
+	defer func() { database.DB = previous }()
+
+	atomic.StoreInt64(&queryCount, 0)
+	fn()
+	return int(atomic.LoadInt64(&queryCount))
+}
+
+// uniqueName returns a name no other test in the run has used
+func uniqueName(prefix string) string {
+	return fmt.Sprintf("%s%d", prefix, atomic.AddInt64(&testSeq, 1))
+}
+
+// newTestUser creates a user with a unique name
+func newTestUser(t *testing.T) *User {
+	t.Helper()
+
+	name := uniqueName("user")
+	user := &User{Username: name, Email: name + "@example.com", PasswordHash: "Passw0rd!"}
+	if err := user.Create(); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// newTestPost creates a post by userID in the given categories (general when none are given)
+func newTestPost(t *testing.T, userID int, categoryIDs ...int) *Post {
+	t.Helper()
+
+	if len(categoryIDs) == 0 {
+		categoryIDs = []int{1}
+	}
+	post := &Post{
+		Title:   uniqueName("Test post "),
+		Content: "Some test post content",
+		UserID:  userID,
+	}
+	for _, id := range categoryIDs {
+		post.Categories = append(post.Categories, Category{ID: id})
+	}
+	if err := post.Create(); err != nil {
+		t.Fatalf("create post: %v", err)
+	}
+	return post
+}
+
+// newTestCategory creates a top-level category with a unique name
+func newTestCategory(t *testing.T) *Category {
+	t.Helper()
+
+	category := &Category{Name: uniqueName("cat"), Description: "A test category"}
+	if err := category.Create(); err != nil {
+		t.Fatalf("create category: %v", err)
+	}
+	return category
+}
+
+// newTestComment creates a top-level comment by userID on postID
+func newTestComment(t *testing.T, userID, postID int, content string) *Comment {
+	t.Helper()
+
+	comment := &Comment{Content: content, UserID: userID, PostID: postID}
+	if err := comment.Create(); err != nil {
+		t.Fatalf("create comment: %v", err)
+	}
+	return comment
+}
+
+// setCreatedAt backdates a row so tests can control ordering and time windows
+func setCreatedAt(t *testing.T, table string, id int, at time.Time) {
+	t.Helper()
+
+	if _, err := database.GetDB().Exec(`UPDATE `+table+` SET created_at = ? WHERE id = ?`, at, id); err != nil {
+		t.Fatalf("backdate %s %d: %v", table, id, err)
+	}
+}
+
+// voteComment casts a vote from a fresh user on a comment
+func voteComment(t *testing.T, commentID int, voteType string) {
+	t.Helper()
+
+	voter := newTestUser(t)
+	if _, err := ToggleCommentVote(voter.ID, commentID, voteType, VoteModeSet, nil); err != nil {
+		t.Fatalf("vote on comment %d: %v", commentID, err)
+	}
+}
+
+// votePost casts a vote from a fresh user on a post
+func votePost(t *testing.T, postID int, voteType string) {
+	t.Helper()
+
+	voter := newTestUser(t)
+	if _, err := TogglePostVote(voter.ID, postID, voteType, VoteModeSet, nil); err != nil {
+		t.Fatalf("vote on post %d: %v", postID, err)
+	}
+}
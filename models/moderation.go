@@ -0,0 +1,132 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"forum/database"
+)
+
+// Moderation actions recorded in the moderation log
+const (
+	ModActionEditComment       = "edit_comment"
+	ModActionDeleteComment     = "delete_comment"
+	ModActionDeletePost        = "delete_post"
+	ModActionResolveReport     = "resolve_report"
+	ModActionPinPost           = "pin_post"
+	ModActionUnpinPost         = "unpin_post"
+	ModActionBanUser           = "ban_user"
+	ModActionUnbanUser         = "unban_user"
+	ModActionCreateCategory    = "create_category"
+	ModActionUpdateCategory    = "update_category"
+	ModActionDeleteCategory    = "delete_category"
+	ModActionReorderCategories = "reorder_categories"
+	ModActionArchiveCategory   = "archive_category"
+	ModActionUnarchiveCategory = "unarchive_category"
+)
+
+// Moderation target types
+const (
+	TargetPost     = "post"
+	TargetComment  = "comment"
+	TargetUser     = "user"
+	TargetCategory = "category"
+)
+
+// ModerationLogEntry represents a single moderation action
+type ModerationLogEntry struct {
+	ID            int       `json:"id"`
+	ActorID       int       `json:"actor_id"`
+	ActorUsername string    `json:"actor_username"`
+	Action        string    `json:"action"`
+	TargetType    string    `json:"target_type"`
+	TargetID      int       `json:"target_id"`
+	Reason        string    `json:"reason"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ModerationLogFilter narrows the moderation log; zero values match everything
+type ModerationLogFilter struct {
+	ActorID    int
+	Action     string
+	TargetType string
+	TargetID   int
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx, so writes can join a caller's transaction
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// LogModerationAction records a moderation action for accountability
+func LogModerationAction(actorID int, action, targetType string, targetID int, reason string) error {
+	return logModerationAction(database.GetDB(), actorID, action, targetType, targetID, reason)
+}
+
+// logModerationAction writes a moderation log entry through db
+func logModerationAction(db execer, actorID int, action, targetType string, targetID int, reason string) error {
+	query := `
+		INSERT INTO moderation_log (actor_id, action, target_type, target_id, reason, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	_, err := db.Exec(query, actorID, action, targetType, targetID, reason, time.Now())
+	return err
+}
+
+// GetModerationLog retrieves moderation actions, newest first, with the total matching count
+func GetModerationLog(filter ModerationLogFilter, limit, offset int) ([]ModerationLogEntry, int, error) {
+	entries := []ModerationLogEntry{}
+
+	where := []string{"1=1"}
+	args := []interface{}{}
+	if filter.ActorID > 0 {
+		where = append(where, "m.actor_id = ?")
+		args = append(args, filter.ActorID)
+	}
+	if filter.Action != "" {
+		where = append(where, "m.action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.TargetType != "" {
+		where = append(where, "m.target_type = ?")
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID > 0 {
+		where = append(where, "m.target_id = ?")
+		args = append(args, filter.TargetID)
+	}
+	whereClause := strings.Join(where, " AND ")
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM moderation_log m WHERE ` + whereClause
+	if err := database.GetReadDB().QueryRow(countQuery, args...).Scan(&total); err != nil {
+		return entries, 0, err
+	}
+
+	query := `
+		SELECT m.id, m.actor_id, u.username, m.action, m.target_type, m.target_id,
+		       COALESCE(m.reason, ''), m.created_at
+		FROM moderation_log m
+		JOIN users u ON m.actor_id = u.id
+		WHERE ` + whereClause + `
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT ? OFFSET ?
+	`
+	rows, err := database.GetReadDB().Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return entries, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e ModerationLogEntry
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.ActorUsername, &e.Action, &e.TargetType,
+			&e.TargetID, &e.Reason, &e.CreatedAt); err != nil {
+			return entries, 0, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, total, rows.Err()
+}
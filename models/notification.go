@@ -0,0 +1,291 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"forum/database"
+)
+
+// Notification types
+const (
+	NotificationComment = "comment" // someone commented on the user's post
+	NotificationMention = "mention" // someone mentioned the user in a comment
+	// NotificationCommentRemoved tells an author a moderator removed their comment
+	NotificationCommentRemoved = "comment_removed"
+	NotificationLike           = "like" // someone liked the user's post or comment
+)
+
+// Notification represents an alert shown to a single user
+type Notification struct {
+	ID            int       `json:"id"`
+	UserID        int       `json:"user_id"`
+	ActorID       *int      `json:"actor_id"`
+	ActorUsername *string   `json:"actor_username"`
+	Type          string    `json:"type"`
+	PostID        *int      `json:"post_id"`
+	CommentID     *int      `json:"comment_id"`
+	Message       string    `json:"message"`
+	Read          bool      `json:"read"`
+	Link          string    `json:"link,omitempty"` // where the notification should take the user
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// NotificationPreferences holds which optional notifications a user receives
+type NotificationPreferences struct {
+	Mentions bool `json:"mentions"`
+}
+
+// Create stores a new unread notification
+func (n *Notification) Create() error {
+	return n.create(database.GetDB())
+}
+
+// create stores the notification through db, which may be a transaction
+func (n *Notification) create(db execer) error {
+	query := `
+		INSERT INTO notifications (user_id, actor_id, type, post_id, comment_id, message, read, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, 0, ?)
+	`
+
+	now := time.Now()
+	result, err := db.Exec(query, n.UserID, n.ActorID, n.Type, n.PostID, n.CommentID, n.Message, now)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	n.ID = int(id)
+	n.Read = false
+	n.CreatedAt = now
+	return nil
+}
+
+// GetNotificationsByUserID retrieves a user's notifications, newest first
+func GetNotificationsByUserID(userID int, unreadOnly bool, limit, offset int) ([]Notification, int, error) {
+	notifications := []Notification{}
+
+	where := "n.user_id = ?"
+	if unreadOnly {
+		where += " AND n.read = 0"
+	}
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM notifications n WHERE ` + where
+	if err := database.GetDB().QueryRow(countQuery, userID).Scan(&total); err != nil {
+		return notifications, 0, err
+	}
+
+	query := `
+		SELECT n.id, n.user_id, n.actor_id, u.username, n.type, n.post_id, n.comment_id,
+		       n.message, n.read, n.created_at
+		FROM notifications n
+		LEFT JOIN users u ON n.actor_id = u.id
+		WHERE ` + where + `
+		ORDER BY n.created_at DESC, n.id DESC
+		LIMIT ? OFFSET ?
+	`
+	rows, err := database.GetDB().Query(query, userID, limit, offset)
+	if err != nil {
+		return notifications, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var n Notification
+		var actorID, postID, commentID sql.NullInt64
+		var actorUsername sql.NullString
+
+		err := rows.Scan(&n.ID, &n.UserID, &actorID, &actorUsername, &n.Type, &postID, &commentID,
+			&n.Message, &n.Read, &n.CreatedAt)
+		if err != nil {
+			continue
+		}
+
+		n.ActorID = nullIntPtr(actorID)
+		n.PostID = nullIntPtr(postID)
+		n.CommentID = nullIntPtr(commentID)
+		if actorUsername.Valid {
+			n.ActorUsername = &actorUsername.String
+		}
+		n.setLink()
+
+		notifications = append(notifications, n)
+	}
+
+	return notifications, total, nil
+}
+
+// CountUnreadNotifications returns how many unread notifications a user has
+func CountUnreadNotifications(userID int) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM notifications WHERE user_id = ? AND read = 0`
+	err := database.GetDB().QueryRow(query, userID).Scan(&count)
+	return count, err
+}
+
+// MarkNotificationRead marks one of the user's notifications as read.
+// It returns sql.ErrNoRows if the notification doesn't belong to the user.
+func MarkNotificationRead(userID, notificationID int) error {
+	result, err := database.GetDB().Exec(
+		`UPDATE notifications SET read = 1 WHERE id = ? AND user_id = ?`,
+		notificationID, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// MarkAllNotificationsRead marks every unread notification of the user as read
+// in a single statement and returns how many were updated
+func MarkAllNotificationsRead(userID int) (int, error) {
+	result, err := database.GetDB().Exec(
+		`UPDATE notifications SET read = 1 WHERE user_id = ? AND read = 0`,
+		userID,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return int(rowsAffected), err
+}
+
+// setLink points comment notifications at the comment's context (its page in the thread)
+// and other notifications at their post. Removed comments have no context, so those
+// notifications link to the post.
+func (n *Notification) setLink() {
+	switch {
+	case n.CommentID != nil && n.Type != NotificationCommentRemoved:
+		n.Link = fmt.Sprintf("/api/comments/%d/context", *n.CommentID)
+	case n.PostID != nil:
+		n.Link = fmt.Sprintf("/api/posts/%d", *n.PostID)
+	}
+}
+
+// CreateMentionNotifications notifies each mentioned user about the comment, skipping
+// the comment author, unknown usernames and users who opted out of mention notifications.
+// It returns the number of notifications created.
+func CreateMentionNotifications(comment *Comment, usernames []string) (int, error) {
+	if len(usernames) == 0 {
+		return 0, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(usernames)), ",")
+	args := make([]interface{}, 0, len(usernames)+1)
+	for _, username := range usernames {
+		args = append(args, strings.ToLower(username))
+	}
+	args = append(args, comment.UserID)
+
+	query := `
+		SELECT id FROM users
+		WHERE LOWER(username) IN (` + placeholders + `) AND id != ? AND notify_mentions = 1
+	`
+	rows, err := database.GetDB().Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	var userIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			userIDs = append(userIDs, id)
+		}
+	}
+	rows.Close()
+
+	created := 0
+	for _, userID := range userIDs {
+		notification := Notification{
+			UserID:    userID,
+			ActorID:   &comment.UserID,
+			Type:      NotificationMention,
+			PostID:    &comment.PostID,
+			CommentID: &comment.ID,
+			Message:   "mentioned you in a comment",
+		}
+		if err := notification.Create(); err != nil {
+			return created, err
+		}
+		created++
+	}
+
+	return created, nil
+}
+
+// LikeNotifier returns a VoteNotifier that tells the owner of a post or comment when
+// someone newly likes it. Removing or switching a vote, and liking your own content,
+// notify nobody. Pass a nil commentID for post likes.
+func LikeNotifier(actorID, ownerID, postID int, commentID *int) VoteNotifier {
+	return func(tx *sql.Tx, result *VoteResult) error {
+		if result.Action != "added" || result.VoteType != "like" || actorID == ownerID {
+			return nil
+		}
+
+		message := "liked your post"
+		if commentID != nil {
+			message = "liked your comment"
+		}
+		notification := Notification{
+			UserID:    ownerID,
+			ActorID:   &actorID,
+			Type:      NotificationLike,
+			PostID:    &postID,
+			CommentID: commentID,
+			Message:   message,
+		}
+		return notification.create(tx)
+	}
+}
+
+// GetNotificationPreferences returns the user's notification preferences
+func GetNotificationPreferences(userID int) (*NotificationPreferences, error) {
+	prefs := &NotificationPreferences{}
+	query := `SELECT notify_mentions FROM users WHERE id = ?`
+	if err := database.GetDB().QueryRow(query, userID).Scan(&prefs.Mentions); err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// UpdateNotificationPreferences saves the user's notification preferences
+func UpdateNotificationPreferences(userID int, prefs *NotificationPreferences) error {
+	query := `UPDATE users SET notify_mentions = ? WHERE id = ?`
+	_, err := database.GetDB().Exec(query, prefs.Mentions, userID)
+	return err
+}
+
+// nullIntPtr converts a nullable integer column to *int
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
+
+// nullTimePtr converts a nullable timestamp column to *time.Time
+func nullTimePtr(v sql.NullTime) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := v.Time
+	return &t
+}
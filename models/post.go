@@ -1,11 +1,15 @@
 package models
 
 import (
+	"database/sql"
+	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
+	"forum/config"
 	"forum/database"
+	"forum/utils"
 )
 
 type Post struct {
@@ -22,17 +26,69 @@ type Post struct {
 	Dislikes     int       `json:"dislikes"`
 	CommentCount int       `json:"comment_count"`
 	UserVote     *string   `json:"user_vote"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	// AcceptedCommentID is the comment the author marked as the answer (nil if none)
+	AcceptedCommentID *int      `json:"accepted_comment_id"`
+	Pinned            bool      `json:"pinned"`
+	PinScope          string    `json:"pin_scope"` // PinScopeGlobal or PinScopeCategory when pinned
+	// LikedAt is when the listing's user liked the post (only set by liked-post listings)
+	LikedAt *time.Time `json:"liked_at,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// sqliteDateTime is the layout SQLite's datetime() produces, for comparing against it
+const sqliteDateTime = "2006-01-02 15:04:05"
+
+// EditGracePeriod is how long after creation a post can change without
+// being reported as edited (covers quick typo fixes right after posting)
+const EditGracePeriod = 2 * time.Minute
+
+// Post sort options; the my_* options also filter to the current user's posts or votes
+const (
+	PostSortNewest     = "newest"
+	PostSortOldest     = "oldest"
+	PostSortPopular    = "popular"
+	PostSortMyPosts    = "my_posts"
+	PostSortMyLikes    = "my_likes"
+	PostSortMyDislikes = "my_dislikes"
+)
+
+// PostSorts lists every supported post sort option
+var PostSorts = []string{PostSortNewest, PostSortOldest, PostSortPopular, PostSortMyPosts, PostSortMyLikes, PostSortMyDislikes}
+
+// IsValidPostSort checks if a post sort option is supported
+func IsValidPostSort(sortBy string) bool {
+	for _, valid := range PostSorts {
+		if sortBy == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultPostSort returns the configured sort for post listings that don't ask
+// for one, falling back to newest when the configured value isn't supported
+func DefaultPostSort() string {
+	if sortBy := config.GetDefaultPostSort(); IsValidPostSort(sortBy) {
+		return sortBy
+	}
+	return PostSortNewest
 }
 
 type PostFilters struct {
-	CurrentUserID int
-	CategoryID    int
-	AuthorID      int
-	SortBy        string
-	Limit         int
-	Offset        int
+	CurrentUserID     int
+	CategoryID        int
+	AuthorID          int
+	LikedByUserID     int        // only posts this user liked, most recently liked first
+	CommentedByUserID int        // only posts this user commented on, latest comment first
+	CreatedFrom       *time.Time // inclusive lower bound on created_at, nil for none
+	CreatedTo         *time.Time // inclusive upper bound on created_at, nil for none
+	HasAttachments    bool       // only posts with at least one attachment
+	AttachmentType    string     // only posts with an attachment of this MIME type
+	ShowCollapsed     bool       // keep downvoted posts in the default sorts
+	SortBy            string
+	Limit             int
+	Offset            int
 }
 
 // func (p *Post) Create() error {
@@ -59,6 +115,11 @@ type PostFilters struct {
 // }
 
 func (p *Post) Create() error {
+	p.sanitize()
+	if err := p.normalizeCategories(); err != nil {
+		return err
+	}
+
 	// Start transaction
 	tx, err := database.GetDB().Begin()
 	if err != nil {
@@ -87,18 +148,60 @@ func (p *Post) Create() error {
 	p.UpdatedAt = now
 
 	// Insert categories into junction table
-	if len(p.Categories) > 0 {
-		categoryQuery := `INSERT INTO post_categories (post_id, category_id) VALUES (?, ?)`
-		for _, category := range p.Categories {
-			_, err = tx.Exec(categoryQuery, p.ID, category.ID)
-			if err != nil {
-				return err
-			}
+	categoryQuery := `INSERT INTO post_categories (post_id, category_id) VALUES (?, ?)`
+	for _, category := range p.Categories {
+		_, err = tx.Exec(categoryQuery, p.ID, category.ID)
+		if err != nil {
+			return err
 		}
 	}
+	if err := adjustCategoryPostCounts(tx, p.ID, 1); err != nil {
+		return err
+	}
 
 	// Commit transaction
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	InvalidateCategoryCache()
+	return nil
+}
+
+// PostSummary is the minimum needed to show which post something belongs to
+type PostSummary struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+// GetPostSummaries loads the titles of several posts in one query, keyed by post ID.
+// IDs of posts that don't exist are left out of the map.
+func GetPostSummaries(postIDs []int) (map[int]PostSummary, error) {
+	summaries := make(map[int]PostSummary, len(postIDs))
+	if len(postIDs) == 0 {
+		return summaries, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(postIDs)), ",")
+	args := make([]interface{}, 0, len(postIDs))
+	for _, id := range postIDs {
+		args = append(args, id)
+	}
+
+	rows, err := database.GetReadDB().Query(`SELECT id, title FROM posts WHERE id IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return summaries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var summary PostSummary
+		if err := rows.Scan(&summary.ID, &summary.Title); err != nil {
+			return summaries, err
+		}
+		summaries[summary.ID] = summary
+	}
+
+	return summaries, rows.Err()
 }
 
 // func (p *Post) GetByID(id int, userID *int) error {
@@ -126,28 +229,41 @@ func (p *Post) Create() error {
 // 	return nil
 // }
 
+// PostExists checks that a post exists without loading it. Use it instead of
+// GetByID when nothing but existence matters.
+func PostExists(id int) (bool, error) {
+	var exists bool
+	err := database.GetDB().QueryRow(`SELECT EXISTS(SELECT 1 FROM posts WHERE id = ?)`, id).Scan(&exists)
+	return exists, err
+}
+
 func (p *Post) GetByID(id int, userID *int) error {
 	query := `
 		SELECT p.id, p.title, p.content, p.user_id, u.username,
-			p.likes, p.dislikes, p.created_at, p.updated_at,
-			(SELECT COUNT(*) FROM comments WHERE post_id = p.id) as comment_count,
+			p.likes, p.dislikes, p.created_at, p.updated_at, p.accepted_comment_id,
+			p.pinned, p.pin_scope,
+			(SELECT COUNT(*) FROM visible_comments WHERE post_id = p.id) as comment_count,
 			COALESCE(GROUP_CONCAT(c.id), '') as category_ids,
-			COALESCE(GROUP_CONCAT(c.name), '') as category_names
+			COALESCE(GROUP_CONCAT(c.name), '') as category_names,
+			COALESCE(GROUP_CONCAT(COALESCE(c.description, ''), char(31)), '') as category_descriptions
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
 		LEFT JOIN post_categories pc ON p.id = pc.post_id
 		LEFT JOIN categories c ON pc.category_id = c.id
 		WHERE p.id = ?
 		GROUP BY p.id, p.title, p.content, p.user_id, u.username,
-				 p.likes, p.dislikes, p.created_at, p.updated_at
+				 p.likes, p.dislikes, p.created_at, p.updated_at, p.accepted_comment_id,
+				 p.pinned, p.pin_scope
 	`
 
-	var categoryIDs, categoryNames string
+	// Descriptions are free text, so they are joined on the unit separator rather than a comma
+	var categoryIDs, categoryNames, categoryDescriptions string
 	row := database.DB.QueryRow(query, id)
 	err := row.Scan(
 		&p.ID, &p.Title, &p.Content, &p.UserID, &p.Username,
-		&p.Likes, &p.Dislikes, &p.CreatedAt, &p.UpdatedAt, &p.CommentCount,
-		&categoryIDs, &categoryNames,
+		&p.Likes, &p.Dislikes, &p.CreatedAt, &p.UpdatedAt, &p.AcceptedCommentID,
+		&p.Pinned, &p.PinScope, &p.CommentCount,
+		&categoryIDs, &categoryNames, &categoryDescriptions,
 	)
 	if err != nil {
 		return err
@@ -157,14 +273,22 @@ func (p *Post) GetByID(id int, userID *int) error {
 	if categoryIDs != "" && categoryNames != "" {
 		ids := strings.Split(categoryIDs, ",")
 		names := strings.Split(categoryNames, ",")
+		descriptions := strings.Split(categoryDescriptions, "\x1f")
 		
 		p.Categories = make([]Category, 0, len(ids))
 		for i := range ids {
 			categoryID, _ := strconv.Atoi(ids[i])
-			p.Categories = append(p.Categories, Category{
+			category := Category{
 				ID:   categoryID,
 				Name: names[i],
-			})
+			}
+			if i < len(descriptions) {
+				category.Description = descriptions[i]
+			}
+			p.Categories = append(p.Categories, category)
+		}
+		if err := fillCategoryStyles([]*Post{p}); err != nil {
+			return err
 		}
 	}
 
@@ -295,10 +419,12 @@ func GetPosts(filters PostFilters) ([]Post, int, error) {
 	baseQuery := `
 	SELECT 
 		p.id, p.title, p.content, p.user_id, u.username,
-		p.likes, p.dislikes, p.created_at, p.updated_at,
-		(SELECT COUNT(*) FROM comments WHERE post_id = p.id) AS comment_count,
+		p.likes, p.dislikes, p.created_at, p.updated_at, p.accepted_comment_id,
+		p.pinned, p.pin_scope,
+		(SELECT COUNT(*) FROM visible_comments WHERE post_id = p.id) AS comment_count,
 		COALESCE(GROUP_CONCAT(DISTINCT c.id), '') as category_ids,
-		COALESCE(GROUP_CONCAT(DISTINCT c.name), '') as category_names
+		COALESCE(GROUP_CONCAT(DISTINCT c.name), '') as category_names,
+		%s as liked_at
 	FROM posts p
 	JOIN users u ON p.user_id = u.id
 	LEFT JOIN post_categories pc ON p.id = pc.post_id
@@ -313,42 +439,95 @@ func GetPosts(filters PostFilters) ([]Post, int, error) {
 	`
 
 	// Filters
+	// A category includes the posts of its subcategories
 	if filters.CategoryID > 0 {
-		whereClauses = append(whereClauses, "pc.category_id = ?")
+		whereClauses = append(whereClauses, "pc.category_id IN ("+categorySubtreeIDs+")")
 		args = append(args, filters.CategoryID)
 	}
 	if filters.AuthorID > 0 {
 		whereClauses = append(whereClauses, "p.user_id = ?")
 		args = append(args, filters.AuthorID)
 	}
+	// created_at values carry their own offset, so compare them normalized to UTC
+	if filters.CreatedFrom != nil {
+		whereClauses = append(whereClauses, "datetime(p.created_at) >= ?")
+		args = append(args, filters.CreatedFrom.UTC().Format(sqliteDateTime))
+	}
+	if filters.CreatedTo != nil {
+		whereClauses = append(whereClauses, "datetime(p.created_at) <= ?")
+		args = append(args, filters.CreatedTo.UTC().Format(sqliteDateTime))
+	}
+	if filters.AttachmentType != "" {
+		whereClauses = append(whereClauses, "EXISTS (SELECT 1 FROM attachments a WHERE a.post_id = p.id AND a.mime_type = ?)")
+		args = append(args, filters.AttachmentType)
+	} else if filters.HasAttachments {
+		whereClauses = append(whereClauses, "EXISTS (SELECT 1 FROM attachments a WHERE a.post_id = p.id)")
+	}
+	if config.HideBannedContent() {
+		// Same rule as the visible_comments view; banned_until is stored in UTC
+		whereClauses = append(whereClauses, "(u.banned_until IS NULL OR u.banned_until <= datetime('now'))")
+	}
 
 	// Special filters
 	switch filters.SortBy {
-	case "my_posts":
+	case PostSortMyPosts:
 		if filters.CurrentUserID > 0 {
 			whereClauses = append(whereClauses, "p.user_id = ?")
 			args = append(args, filters.CurrentUserID)
 		}
-	case "my_likes":
-		if filters.CurrentUserID > 0 {
-			joinClauses = append(joinClauses, "JOIN votes v ON p.id = v.post_id AND v.user_id = ? AND v.vote_type = 'like'")
-			args = append(args, filters.CurrentUserID)
+	case PostSortMyLikes:
+		if filters.CurrentUserID > 0 && filters.LikedByUserID == 0 {
+			filters.LikedByUserID = filters.CurrentUserID
 		}
-	case "my_dislikes":
+	case PostSortMyDislikes:
 		if filters.CurrentUserID > 0 {
 			joinClauses = append(joinClauses, "JOIN votes v ON p.id = v.post_id AND v.user_id = ? AND v.vote_type = 'dislike'")
 			args = append(args, filters.CurrentUserID)
 		}
 	}
 
+	// Posts voted down to the collapse threshold leave the public board listings;
+	// personal lists (own posts, likes, dislikes) still show them
+	switch filters.SortBy {
+	case PostSortMyPosts, PostSortMyLikes, PostSortMyDislikes:
+	default:
+		if !filters.ShowCollapsed && filters.LikedByUserID == 0 && filters.CommentedByUserID == 0 {
+			whereClauses = append(whereClauses, "(p.likes - p.dislikes) > ?")
+			args = append(args, config.GetCollapseScoreThreshold())
+		}
+	}
+
+	// Liked posts carry the time of the like, which the joined vote provides
+	likedAtColumn := "NULL"
+	if filters.LikedByUserID > 0 {
+		joinClauses = append([]string{"JOIN votes lv ON p.id = lv.post_id AND lv.user_id = ? AND lv.vote_type = 'like'"}, joinClauses...)
+		args = append([]interface{}{filters.LikedByUserID}, args...)
+		likedAtColumn = "lv.created_at"
+	}
+	baseQuery = fmt.Sprintf(baseQuery, likedAtColumn)
+
+	// One row per post the user commented on, with the time of their latest comment
+	if filters.CommentedByUserID > 0 {
+		joinClauses = append([]string{`JOIN (
+			SELECT post_id, MAX(created_at) AS last_commented_at
+			FROM visible_comments WHERE user_id = ? GROUP BY post_id
+		) uc ON uc.post_id = p.id`}, joinClauses...)
+		args = append([]interface{}{filters.CommentedByUserID}, args...)
+	}
+
 	// Sorting
 	switch filters.SortBy {
-	case "oldest":
+	case PostSortOldest:
 		orderClause = "ORDER BY p.created_at ASC"
-	case "popular":
+	case PostSortPopular:
 		orderClause = "ORDER BY p.likes DESC, p.dislikes ASC"
 	default:
 		orderClause = "ORDER BY p.created_at DESC"
+		if filters.LikedByUserID > 0 {
+			orderClause = "ORDER BY lv.created_at DESC"
+		} else if filters.CommentedByUserID > 0 {
+			orderClause = "ORDER BY uc.last_commented_at DESC, p.id DESC"
+		}
 	}
 
 	// Assemble query parts
@@ -363,9 +542,19 @@ func GetPosts(filters PostFilters) ([]Post, int, error) {
 	}
 	
 	// GROUP BY for base query
-	baseQuery += " GROUP BY p.id, p.title, p.content, p.user_id, u.username, p.likes, p.dislikes, p.created_at, p.updated_at"
-	
-	baseQuery += " " + orderClause
+	baseQuery += " GROUP BY p.id, p.title, p.content, p.user_id, u.username, p.likes, p.dislikes, p.created_at, p.updated_at, p.accepted_comment_id, p.pinned, p.pin_scope"
+
+	// Pinned posts come first whatever the sort: global pins everywhere,
+	// category pins only when browsing a category
+	pinOrder := "(p.pinned = 1 AND p.pin_scope = '" + PinScopeGlobal + "') DESC, "
+	if filters.CategoryID > 0 {
+		pinOrder = "p.pinned DESC, "
+	}
+	if filters.LikedByUserID > 0 || filters.CommentedByUserID > 0 {
+		// A personal list of likes or comments is not a board, so pins don't apply
+		pinOrder = ""
+	}
+	baseQuery += " " + strings.Replace(orderClause, "ORDER BY ", "ORDER BY "+pinOrder, 1)
 
 	// Pagination
 	baseQuery += " LIMIT ? OFFSET ?"
@@ -373,13 +562,13 @@ func GetPosts(filters PostFilters) ([]Post, int, error) {
 
 	// Count
 	var total int
-	err := database.GetDB().QueryRow(countQuery, args[:len(args)-2]...).Scan(&total)
+	err := database.GetReadDB().QueryRow(countQuery, args[:len(args)-2]...).Scan(&total)
 	if err != nil {
 		return posts, 0, err
 	}
 
 	// Execute posts query
-	rows, err := database.GetDB().Query(baseQuery, args...)
+	rows, err := database.GetReadDB().Query(baseQuery, args...)
 	if err != nil {
 		return posts, 0, err
 	}
@@ -388,13 +577,15 @@ func GetPosts(filters PostFilters) ([]Post, int, error) {
 	for rows.Next() {
 		var post Post
 		var categoryIDs, categoryNames string
+		var likedAt sql.NullTime
 		
 		err := rows.Scan(
 			&post.ID, &post.Title, &post.Content,
 			&post.UserID, &post.Username,
 			&post.Likes, &post.Dislikes,
-			&post.CreatedAt, &post.UpdatedAt, &post.CommentCount,
-			&categoryIDs, &categoryNames,
+			&post.CreatedAt, &post.UpdatedAt, &post.AcceptedCommentID,
+			&post.Pinned, &post.PinScope, &post.CommentCount,
+			&categoryIDs, &categoryNames, &likedAt,
 		)
 		if err != nil {
 			continue
@@ -415,12 +606,53 @@ func GetPosts(filters PostFilters) ([]Post, int, error) {
 			}
 		}
 
+		post.LikedAt = nullTimePtr(likedAt)
+
 		posts = append(posts, post)
 	}
 
+	postPtrs := make([]*Post, 0, len(posts))
+	for i := range posts {
+		postPtrs = append(postPtrs, &posts[i])
+	}
+	if err := fillCategoryStyles(postPtrs); err != nil {
+		return posts, total, err
+	}
+
 	return posts, total, nil
 }
 
+// fillCategoryStyles adds the slug, color and icon of each post's categories with
+// one query for all of them
+func fillCategoryStyles(posts []*Post) error {
+	var ids []int
+	seen := make(map[int]bool)
+	for _, post := range posts {
+		for _, category := range post.Categories {
+			if !seen[category.ID] {
+				seen[category.ID] = true
+				ids = append(ids, category.ID)
+			}
+		}
+	}
+
+	byID, err := GetCategoriesByIDs(ids)
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		for i := range post.Categories {
+			if category, ok := byID[post.Categories[i].ID]; ok {
+				post.Categories[i].Slug = category.Slug
+				post.Categories[i].Color = category.Color
+				post.Categories[i].Icon = category.Icon
+			}
+		}
+	}
+	return nil
+}
+
 func (p *Post) GetUserVote(userID int) {
 	query := `SELECT vote_type FROM votes WHERE user_id = ? AND post_id = ?`
 	var voteType string
@@ -449,7 +681,7 @@ func (p *Post) GetVoteCounts() (int, int, error) {
 }
 
 func (p *Post) GetCommentCount() (int, error) {
-	query := `SELECT COUNT(*) FROM comments WHERE post_id = ?`
+	query := `SELECT COUNT(*) FROM visible_comments WHERE post_id = ?`
 	var count int
 	err := database.GetDB().QueryRow(query, p.ID).Scan(&count)
 	if err != nil {
@@ -476,17 +708,60 @@ func (p *Post) GetCommentCount() (int, error) {
 // 	return nil
 // }
 
+// sanitize strips control and bidi characters from the title and content
+func (p *Post) sanitize() {
+	p.Title = utils.SanitizeString(p.Title)
+	p.Content = utils.SanitizeString(p.Content)
+}
+
+// normalizeCategories drops repeated categories, keeping the first occurrence, and
+// enforces the per-post limits so the model stays consistent whoever the caller is
+func (p *Post) normalizeCategories() error {
+	seen := make(map[int]bool, len(p.Categories))
+	categories := make([]Category, 0, len(p.Categories))
+	ids := make([]int, 0, len(p.Categories))
+	for _, category := range p.Categories {
+		if seen[category.ID] {
+			continue
+		}
+		seen[category.ID] = true
+		categories = append(categories, category)
+		ids = append(ids, category.ID)
+	}
+
+	if err := utils.ValidateCategoryIDs(ids); err != nil {
+		return err
+	}
+
+	p.Categories = categories
+	return nil
+}
+
 func (p *Post) Update() error {
+	p.sanitize()
+	if err := p.normalizeCategories(); err != nil {
+		return err
+	}
+
 	tx, err := database.GetDB().Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// Update post
-	query := `UPDATE posts SET title = ?, content = ?, updated_at = ? WHERE id = ?`
+	// Update post, only touching updated_at when title or content actually differ
+	// so category-only changes don't flag the post as edited
+	query := `
+		UPDATE posts SET title = ?, content = ?, updated_at = ?
+		WHERE id = ? AND (title != ? OR content != ?)
+	`
 	now := time.Now()
-	_, err = tx.Exec(query, p.Title, p.Content, now, p.ID)
+	result, err := tx.Exec(query, p.Title, p.Content, now, p.ID, p.Title, p.Content)
+	if err != nil {
+		return err
+	}
+
+	contentChanged, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
@@ -512,12 +787,18 @@ func (p *Post) Update() error {
 	}
 
 	// Delete categories that are no longer present
+	categoriesChanged := false
 	for id := range existingIDs {
 		if !newIDs[id] {
 			_, err = tx.Exec("DELETE FROM post_categories WHERE post_id = ? AND category_id = ?", p.ID, id)
 			if err != nil {
 				return err
 			}
+			_, err = tx.Exec("UPDATE categories SET post_count = post_count - 1 WHERE id = ?", id)
+			if err != nil {
+				return err
+			}
+			categoriesChanged = true
 		}
 	}
 
@@ -528,6 +809,11 @@ func (p *Post) Update() error {
 			if err != nil {
 				return err
 			}
+			_, err = tx.Exec("UPDATE categories SET post_count = post_count + 1 WHERE id = ?", id)
+			if err != nil {
+				return err
+			}
+			categoriesChanged = true
 		}
 	}
 
@@ -535,10 +821,105 @@ func (p *Post) Update() error {
 		return err
 	}
 
-	p.UpdatedAt = now
+	// Category post counts shift with the post
+	if categoriesChanged {
+		InvalidateCategoryCache()
+	}
+
+	if contentChanged > 0 {
+		p.UpdatedAt = now
+	}
+	return nil
+}
+
+// SetAcceptedComment marks a comment as the accepted answer, or clears it when commentID is nil.
+// Only one comment can be accepted at a time, so accepting another one moves the mark.
+func (p *Post) SetAcceptedComment(commentID *int) error {
+	query := `UPDATE posts SET accepted_comment_id = ? WHERE id = ?`
+	if _, err := database.GetDB().Exec(query, commentID, p.ID); err != nil {
+		return err
+	}
+
+	p.AcceptedCommentID = commentID
+	return nil
+}
+
+// Pin scopes
+const (
+	PinScopeGlobal   = "global"   // pinned on the front page and in every category
+	PinScopeCategory = "category" // pinned only within the post's categories
+)
+
+// IsValidPinScope checks if a pin scope is supported
+func IsValidPinScope(scope string) bool {
+	return scope == PinScopeGlobal || scope == PinScopeCategory
+}
+
+// SetPinned pins the post with the given scope, or unpins it when pinned is false
+func (p *Post) SetPinned(pinned bool, scope string) error {
+	var pinnedAt *time.Time
+	if pinned {
+		now := time.Now()
+		pinnedAt = &now
+	} else {
+		scope = ""
+	}
+
+	query := `UPDATE posts SET pinned = ?, pin_scope = ?, pinned_at = ? WHERE id = ?`
+	if _, err := database.GetDB().Exec(query, pinned, scope, pinnedAt, p.ID); err != nil {
+		return err
+	}
+
+	p.Pinned = pinned
+	p.PinScope = scope
 	return nil
 }
 
+// IsEdited reports whether the post content changed after the grace period
+func (p *Post) IsEdited() bool {
+	return p.UpdatedAt.After(p.CreatedAt.Add(EditGracePeriod))
+}
+
+// FindRecentDuplicatePost returns the ID of the author's latest post created since
+// the given time with the same title (ignoring case) and content, or 0 if there is none
+func FindRecentDuplicatePost(userID int, title, content string, since time.Time) (int, error) {
+	query := `
+		SELECT id FROM posts
+		WHERE user_id = ? AND LOWER(title) = LOWER(?) AND content = ? AND datetime(created_at) >= ?
+		ORDER BY id DESC
+		LIMIT 1
+	`
+	var id int
+	err := database.GetDB().QueryRow(query, userID, title, content, since.UTC().Format(sqliteDateTime)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// HasChanges reports whether the given values differ from the current post
+func (p *Post) HasChanges(title, content string, categoryIDs []int) bool {
+	if p.Title != title || p.Content != content {
+		return true
+	}
+
+	if len(p.Categories) != len(categoryIDs) {
+		return true
+	}
+
+	current := make(map[int]bool, len(p.Categories))
+	for _, cat := range p.Categories {
+		current[cat.ID] = true
+	}
+	for _, id := range categoryIDs {
+		if !current[id] {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (p *Post) Delete() error {
 	// Start transaction for consistent deletion
 	tx, err := database.GetDB().Begin()
@@ -553,11 +934,30 @@ func (p *Post) Delete() error {
 		return err
 	}
 
+	// Reactions aren't tied to their target by a foreign key, so remove them explicitly
+	_, err = tx.Exec(`
+		DELETE FROM reactions
+		WHERE (target_type = ? AND target_id = ?)
+		   OR (target_type = ? AND target_id IN (SELECT id FROM comments WHERE post_id = ?))`,
+		TargetPost, p.ID, TargetComment, p.ID)
+	if err != nil {
+		return err
+	}
+
+	// Its categories lose it before the cascade removes the post_categories rows
+	if err := adjustCategoryPostCounts(tx, p.ID, -1); err != nil {
+		return err
+	}
+
 	// Delete the post
 	_, err = tx.Exec("DELETE FROM posts WHERE id = ?", p.ID)
 	if err != nil {
 		return err
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	InvalidateCategoryCache()
+	return nil
 }
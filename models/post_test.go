@@ -0,0 +1,313 @@
+package models
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"forum/database"
+	"forum/utils"
+)
+
+// postIDs lists the IDs of posts in order
+func postIDs(posts []Post) []int {
+	ids := make([]int, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func TestPinnedPostsComeFirst(t *testing.T) {
+	author := newTestUser(t)
+	category := newTestCategory(t)
+
+	// Created an hour apart, oldest first, with scores 0, +1 and +2
+	base := time.Now().Add(-3 * time.Hour)
+	var posts []*Post
+	for i := 0; i < 3; i++ {
+		post := newTestPost(t, author.ID, category.ID)
+		setCreatedAt(t, "posts", post.ID, base.Add(time.Duration(i)*time.Hour))
+		for j := 0; j < i; j++ {
+			votePost(t, post.ID, "like")
+		}
+		posts = append(posts, post)
+	}
+	p1, p2, p3 := posts[0].ID, posts[1].ID, posts[2].ID
+
+	normal := map[string][]int{
+		PostSortNewest:  {p3, p2, p1},
+		PostSortOldest:  {p1, p2, p3},
+		PostSortPopular: {p3, p2, p1},
+		PostSortMyPosts: {p3, p2, p1},
+	}
+	// The middle post is never first on its own, so pinning it shows
+	pinned := map[string][]int{
+		PostSortNewest:  {p2, p3, p1},
+		PostSortOldest:  {p2, p1, p3},
+		PostSortPopular: {p2, p3, p1},
+		PostSortMyPosts: {p2, p3, p1},
+	}
+
+	// list returns the order of the three posts in a category listing or, with
+	// categoryID 0, in the front page listing narrowed to the author
+	list := func(t *testing.T, sortBy string, categoryID int) []int {
+		t.Helper()
+
+		filters := PostFilters{CurrentUserID: author.ID, SortBy: sortBy, Limit: 10}
+		if categoryID > 0 {
+			filters.CategoryID = categoryID
+		} else {
+			filters.AuthorID = author.ID
+		}
+		got, _, err := GetPosts(filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return postIDs(got)
+	}
+	check := func(t *testing.T, categoryWant, frontWant map[string][]int) {
+		t.Helper()
+
+		for sortBy := range normal {
+			if got := list(t, sortBy, category.ID); !reflect.DeepEqual(got, categoryWant[sortBy]) {
+				t.Errorf("category %s: order = %v, want %v", sortBy, got, categoryWant[sortBy])
+			}
+			if got := list(t, sortBy, 0); !reflect.DeepEqual(got, frontWant[sortBy]) {
+				t.Errorf("front page %s: order = %v, want %v", sortBy, got, frontWant[sortBy])
+			}
+		}
+	}
+
+	check(t, normal, normal)
+
+	t.Run("category pin", func(t *testing.T) {
+		if err := posts[1].SetPinned(true, PinScopeCategory); err != nil {
+			t.Fatal(err)
+		}
+		check(t, pinned, normal)
+	})
+
+	t.Run("global pin", func(t *testing.T) {
+		if err := posts[1].SetPinned(true, PinScopeGlobal); err != nil {
+			t.Fatal(err)
+		}
+		check(t, pinned, pinned)
+	})
+
+	t.Run("unpinned", func(t *testing.T) {
+		if err := posts[1].SetPinned(false, ""); err != nil {
+			t.Fatal(err)
+		}
+		check(t, normal, normal)
+	})
+}
+
+// categoryIDsOf lists the categories a post is stored in, in ID order
+func categoryIDsOf(t *testing.T, postID int) []int {
+	t.Helper()
+
+	rows, err := database.GetDB().Query(`SELECT category_id FROM post_categories WHERE post_id = ? ORDER BY category_id`, postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestPostCategoryLimits(t *testing.T) {
+	author := newTestUser(t)
+	var ids []int
+	for i := 0; i <= utils.MaxCategoriesPerPost; i++ {
+		ids = append(ids, newTestCategory(t).ID)
+	}
+	categories := func(ids ...int) []Category {
+		list := make([]Category, 0, len(ids))
+		for _, id := range ids {
+			list = append(list, Category{ID: id})
+		}
+		return list
+	}
+	newPost := func(categories []Category) *Post {
+		return &Post{Title: uniqueName("Test post "), Content: "Some test post content", UserID: author.ID, Categories: categories}
+	}
+
+	// Repeats are dropped rather than stored twice
+	post := newPost(categories(ids[1], ids[0], ids[1], ids[0]))
+	if err := post.Create(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := categoryIDsOf(t, post.ID), []int{ids[0], ids[1]}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("created with categories %v, want %v", got, want)
+	}
+
+	// Too many or none are refused without storing anything
+	before := rowCount(t, "posts", "user_id = ?", author.ID)
+	for name, list := range map[string][]Category{"too many": categories(ids...), "none": nil} {
+		if err := newPost(list).Create(); err == nil {
+			t.Fatalf("Create with %s categories succeeded", name)
+		}
+	}
+	if after := rowCount(t, "posts", "user_id = ?", author.ID); after != before {
+		t.Fatalf("%d posts stored by refused creates", after-before)
+	}
+
+	// Update applies the same rules
+	post.Categories = categories(ids[2], ids[2], ids[3])
+	if err := post.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := categoryIDsOf(t, post.ID), []int{ids[2], ids[3]}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("updated to categories %v, want %v", got, want)
+	}
+
+	for name, list := range map[string][]Category{"too many": categories(ids...), "none": nil} {
+		post.Categories = list
+		if err := post.Update(); err == nil {
+			t.Fatalf("Update with %s categories succeeded", name)
+		}
+		if got, want := categoryIDsOf(t, post.ID), []int{ids[2], ids[3]}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("categories = %v after a refused update, want %v", got, want)
+		}
+	}
+}
+
+func TestReadsAndWritesUseTheirOwnDatabases(t *testing.T) {
+	author := newTestUser(t)
+	category := newTestCategory(t)
+	before := newTestPost(t, author.ID, category.ID)
+
+	// The read database is a snapshot, so anything written after it is only in the main one
+	snapshot := filepath.Join(t.TempDir(), "read.db")
+	if _, err := database.GetDB().Exec(`VACUUM INTO ?`, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	readDB, err := sql.Open("sqlite3", snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.ReadDB = readDB
+	t.Cleanup(func() {
+		database.ReadDB = nil
+		readDB.Close()
+	})
+
+	after := newTestPost(t, author.ID, category.ID)
+	newTestComment(t, author.ID, before.ID, "A comment only the main database has")
+
+	// Writes land in the main database only
+	var inMain, inRead int
+	if err := database.GetWriteDB().QueryRow(`SELECT COUNT(*) FROM posts WHERE id = ?`, after.ID).Scan(&inMain); err != nil {
+		t.Fatal(err)
+	}
+	if err := readDB.QueryRow(`SELECT COUNT(*) FROM posts WHERE id = ?`, after.ID).Scan(&inRead); err != nil {
+		t.Fatal(err)
+	}
+	if inMain != 1 || inRead != 0 {
+		t.Fatalf("new post is in %d main and %d read rows, want 1 and 0", inMain, inRead)
+	}
+
+	// Listings come from the read database
+	posts, total, err := GetPosts(PostFilters{CategoryID: category.ID, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := postIDs(posts); total != 1 || !reflect.DeepEqual(got, []int{before.ID}) {
+		t.Fatalf("listed %v (total %d), want only %d", got, total, before.ID)
+	}
+	if _, total, err := GetCommentsByPostID(before.ID, nil, CommentSortOldest, 10, 0, false); err != nil || total != 0 {
+		t.Fatalf("listed %d comments, %v, want the read database's 0", total, err)
+	}
+
+	// Single-post reads must see the caller's own writes, so they use the main one
+	if err := (&Post{}).GetByID(after.ID, nil); err != nil {
+		t.Fatalf("GetByID of the new post: %v", err)
+	}
+
+	// Without a read database everything shares the main one
+	database.ReadDB = nil
+	if database.GetReadDB() != database.GetWriteDB() {
+		t.Fatal("GetReadDB isn't the main database when no read database is set")
+	}
+	if _, total, err := GetPosts(PostFilters{CategoryID: category.ID, Limit: 10}); err != nil || total != 2 {
+		t.Fatalf("listed %d posts, %v, want 2", total, err)
+	}
+}
+
+func TestPostExists(t *testing.T) {
+	author := newTestUser(t)
+	post := newTestPost(t, author.ID)
+	deleted := newTestPost(t, author.ID)
+	if err := deleted.Delete(); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, want := range map[int]bool{post.ID: true, deleted.ID: false, 999999: false, 0: false} {
+		if got, err := PostExists(id); err != nil || got != want {
+			t.Fatalf("PostExists(%d) = %v, %v, want %v", id, got, err, want)
+		}
+	}
+}
+
+// BenchmarkPostExists and BenchmarkPostGetByID compare the existence check with
+// loading the whole post, e.g. go test -run '^$' -bench 'Post(Exists|GetByID)' ./models
+func BenchmarkPostExists(b *testing.B) {
+	postID := benchmarkPost(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := PostExists(postID); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPostGetByID(b *testing.B) {
+	postID := benchmarkPost(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := (&Post{}).GetByID(postID, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkPost creates a post in two categories with a few comments and a vote
+func benchmarkPost(b *testing.B) int {
+	b.Helper()
+
+	name := uniqueName("user")
+	author := &User{Username: name, Email: name + "@example.com", PasswordHash: "Passw0rd!"}
+	if err := author.Create(); err != nil {
+		b.Fatal(err)
+	}
+	category := &Category{Name: uniqueName("cat"), Description: "A benchmark category"}
+	if err := category.Create(); err != nil {
+		b.Fatal(err)
+	}
+	post := &Post{Title: uniqueName("Benchmark post "), Content: "Some benchmark post content", UserID: author.ID,
+		Categories: []Category{{ID: 1}, {ID: category.ID}}}
+	if err := post.Create(); err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		comment := &Comment{Content: "A benchmark comment", UserID: author.ID, PostID: post.ID}
+		if err := comment.Create(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if _, err := TogglePostVote(author.ID, post.ID, "like", VoteModeSet, nil); err != nil {
+		b.Fatal(err)
+	}
+	return post.ID
+}
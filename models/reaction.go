@@ -0,0 +1,116 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"forum/database"
+)
+
+// AllowedReactions is the set of emoji users can react with, in display order
+var AllowedReactions = []string{"👍", "❤️", "😂", "🎉", "😮", "😢", "🚀", "👀"}
+
+// ReactionSummary aggregates the reactions on one post or comment
+type ReactionSummary struct {
+	Counts map[string]int `json:"counts"` // emoji -> number of users
+	Mine   []string       `json:"mine"`   // emoji the viewer reacted with
+}
+
+// newReactionSummary returns an empty summary that serializes as {} and []
+func newReactionSummary() ReactionSummary {
+	return ReactionSummary{Counts: map[string]int{}, Mine: []string{}}
+}
+
+// IsValidReaction checks if an emoji is in the allowed set
+func IsValidReaction(emoji string) bool {
+	for _, allowed := range AllowedReactions {
+		if emoji == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// AddReaction records a user's reaction. Reacting twice with the same emoji is a no-op;
+// added reports whether a new reaction was stored.
+func AddReaction(userID int, targetType string, targetID int, emoji string) (bool, error) {
+	query := `
+		INSERT OR IGNORE INTO reactions (user_id, target_type, target_id, emoji, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	result, err := database.GetDB().Exec(query, userID, targetType, targetID, emoji, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RemoveReaction deletes a user's reaction; removing a missing reaction is not an error
+func RemoveReaction(userID int, targetType string, targetID int, emoji string) error {
+	query := `DELETE FROM reactions WHERE user_id = ? AND target_type = ? AND target_id = ? AND emoji = ?`
+	_, err := database.GetDB().Exec(query, userID, targetType, targetID, emoji)
+	return err
+}
+
+// GetReactionSummaries loads reaction counts for several targets of one type in one query,
+// plus the viewer's own reactions when userID is set. Every requested ID gets a summary.
+func GetReactionSummaries(targetType string, targetIDs []int, userID *int) (map[int]ReactionSummary, error) {
+	summaries := make(map[int]ReactionSummary, len(targetIDs))
+	if len(targetIDs) == 0 {
+		return summaries, nil
+	}
+
+	viewerID := 0
+	if userID != nil {
+		viewerID = *userID
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(targetIDs)), ",")
+	args := make([]interface{}, 0, len(targetIDs)+2)
+	args = append(args, viewerID, targetType)
+	for _, id := range targetIDs {
+		args = append(args, id)
+		summaries[id] = newReactionSummary()
+	}
+
+	query := `
+		SELECT target_id, emoji, COUNT(*), MAX(user_id = ?)
+		FROM reactions
+		WHERE target_type = ? AND target_id IN (` + placeholders + `)
+		GROUP BY target_id, emoji
+	`
+	rows, err := database.GetDB().Query(query, args...)
+	if err != nil {
+		return summaries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var targetID, count int
+		var emoji string
+		var mine bool
+		if err := rows.Scan(&targetID, &emoji, &count, &mine); err != nil {
+			return summaries, err
+		}
+
+		summary := summaries[targetID]
+		summary.Counts[emoji] = count
+		if mine {
+			summary.Mine = append(summary.Mine, emoji)
+		}
+		summaries[targetID] = summary
+	}
+
+	return summaries, rows.Err()
+}
+
+// GetReactionSummary loads the reactions on a single post or comment
+func GetReactionSummary(targetType string, targetID int, userID *int) (ReactionSummary, error) {
+	summaries, err := GetReactionSummaries(targetType, []int{targetID}, userID)
+	if err != nil {
+		return newReactionSummary(), err
+	}
+	return summaries[targetID], nil
+}
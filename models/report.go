@@ -0,0 +1,238 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"forum/database"
+)
+
+// Report reasons
+const (
+	ReportReasonSpam          = "spam"
+	ReportReasonHarassment    = "harassment"
+	ReportReasonOffTopic      = "off_topic"
+	ReportReasonInappropriate = "inappropriate"
+	ReportReasonOther         = "other"
+)
+
+// Report statuses
+const (
+	ReportStatusPending   = "pending"
+	ReportStatusDismissed = "dismissed"
+	ReportStatusActioned  = "actioned"
+)
+
+// Report resolutions chosen by moderators
+const (
+	ReportResolutionDismiss       = "dismiss"
+	ReportResolutionDeleteComment = "delete_comment"
+	ReportResolutionBanAuthor     = "ban_author"
+)
+
+// Report tuning, overridable at runtime through settings
+const (
+	SettingReportAutoHideThreshold = "reports.auto_hide_threshold"
+	SettingReportsPerHour          = "reports.max_per_hour"
+
+	DefaultReportAutoHideThreshold = 3
+	DefaultReportsPerHour          = 10
+)
+
+// ErrAlreadyReported is returned when a user reports the same content twice
+var ErrAlreadyReported = errors.New("you have already reported this content")
+
+var validReportReasons = map[string]bool{
+	ReportReasonSpam:          true,
+	ReportReasonHarassment:    true,
+	ReportReasonOffTopic:      true,
+	ReportReasonInappropriate: true,
+	ReportReasonOther:         true,
+}
+
+// Report represents a user's report of a post or comment
+type Report struct {
+	ID               int        `json:"id"`
+	ReporterID       int        `json:"reporter_id"`
+	ReporterUsername string     `json:"reporter_username"`
+	TargetType       string     `json:"target_type"`
+	TargetID         int        `json:"target_id"`
+	Reason           string     `json:"reason"`
+	Details          string     `json:"details"`
+	Status           string     `json:"status"`
+	Resolution       string     `json:"resolution"`
+	ResolvedBy       *int       `json:"resolved_by"`
+	ResolvedAt       *time.Time `json:"resolved_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// IsValidReportReason checks if a report reason is supported
+func IsValidReportReason(reason string) bool {
+	return validReportReasons[reason]
+}
+
+// ReportAutoHideThreshold returns how many distinct reports hide content pending review
+func ReportAutoHideThreshold() int {
+	return Settings.GetInt(SettingReportAutoHideThreshold, DefaultReportAutoHideThreshold)
+}
+
+// ReportsPerHourLimit returns how many reports a user may file per hour
+func ReportsPerHourLimit() int {
+	return Settings.GetInt(SettingReportsPerHour, DefaultReportsPerHour)
+}
+
+// Create stores a new pending report
+func (r *Report) Create() error {
+	query := `
+		INSERT INTO reports (reporter_id, target_type, target_id, reason, details, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	now := time.Now()
+	result, err := database.GetDB().Exec(query, r.ReporterID, r.TargetType, r.TargetID,
+		r.Reason, r.Details, ReportStatusPending, now)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrAlreadyReported
+		}
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	r.ID = int(id)
+	r.Status = ReportStatusPending
+	r.CreatedAt = now
+	return nil
+}
+
+// GetByID retrieves a report by its ID
+func (r *Report) GetByID(id int) error {
+	query := `
+		SELECT r.id, r.reporter_id, u.username, r.target_type, r.target_id, r.reason,
+		       r.details, r.status, r.resolution, r.resolved_by, r.resolved_at, r.created_at
+		FROM reports r
+		JOIN users u ON r.reporter_id = u.id
+		WHERE r.id = ?
+	`
+	return scanReport(database.GetDB().QueryRow(query, id), r)
+}
+
+// CountRecentReportsByUser returns how many reports a user filed since the given time
+func CountRecentReportsByUser(userID int, since time.Time) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM reports WHERE reporter_id = ? AND created_at > ?`
+	err := database.GetDB().QueryRow(query, userID, since).Scan(&count)
+	return count, err
+}
+
+// CountPendingReports returns the number of distinct users with a pending report on the target
+func CountPendingReports(targetType string, targetID int) (int, error) {
+	var count int
+	query := `
+		SELECT COUNT(DISTINCT reporter_id) FROM reports
+		WHERE target_type = ? AND target_id = ? AND status = ?
+	`
+	err := database.GetDB().QueryRow(query, targetType, targetID, ReportStatusPending).Scan(&count)
+	return count, err
+}
+
+// GetReports retrieves paginated reports, optionally filtered by status and target type
+func GetReports(status, targetType string, limit, offset int) ([]Report, int, error) {
+	reports := []Report{}
+
+	where := []string{"1=1"}
+	args := []interface{}{}
+	if status != "" {
+		where = append(where, "r.status = ?")
+		args = append(args, status)
+	}
+	if targetType != "" {
+		where = append(where, "r.target_type = ?")
+		args = append(args, targetType)
+	}
+	whereClause := strings.Join(where, " AND ")
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM reports r WHERE ` + whereClause
+	if err := database.GetReadDB().QueryRow(countQuery, args...).Scan(&total); err != nil {
+		return reports, 0, err
+	}
+
+	query := `
+		SELECT r.id, r.reporter_id, u.username, r.target_type, r.target_id, r.reason,
+		       r.details, r.status, r.resolution, r.resolved_by, r.resolved_at, r.created_at
+		FROM reports r
+		JOIN users u ON r.reporter_id = u.id
+		WHERE ` + whereClause + `
+		ORDER BY r.created_at ASC, r.id ASC
+		LIMIT ? OFFSET ?
+	`
+	rows, err := database.GetReadDB().Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return reports, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var report Report
+		if err := scanReport(rows, &report); err != nil {
+			continue
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, total, nil
+}
+
+// ResolveReports closes every pending report on a target with the same outcome,
+// so acting on one report clears the whole queue entry for that content
+func ResolveReports(targetType string, targetID int, status, resolution string, moderatorID int) (int, error) {
+	query := `
+		UPDATE reports
+		SET status = ?, resolution = ?, resolved_by = ?, resolved_at = ?
+		WHERE target_type = ? AND target_id = ? AND status = ?
+	`
+	result, err := database.GetDB().Exec(query, status, resolution, moderatorID, time.Now(),
+		targetType, targetID, ReportStatusPending)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanReport reads a report row selected with the standard column list
+func scanReport(row rowScanner, r *Report) error {
+	var resolvedBy sql.NullInt64
+	var resolvedAt sql.NullTime
+	var details, resolution sql.NullString
+
+	err := row.Scan(&r.ID, &r.ReporterID, &r.ReporterUsername, &r.TargetType, &r.TargetID,
+		&r.Reason, &details, &r.Status, &resolution, &resolvedBy, &resolvedAt, &r.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	r.Details = details.String
+	r.Resolution = resolution.String
+	if resolvedBy.Valid {
+		id := int(resolvedBy.Int64)
+		r.ResolvedBy = &id
+	}
+	if resolvedAt.Valid {
+		r.ResolvedAt = &resolvedAt.Time
+	}
+	return nil
+}
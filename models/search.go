@@ -0,0 +1,118 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"forum/database"
+)
+
+// snippetRadius is how many characters of context are kept around a search match
+const snippetRadius = 60
+
+// CommentSearchResult is a comment matching a search, with its post for context
+type CommentSearchResult struct {
+	ID        int       `json:"id"`
+	PostID    int       `json:"post_id"`
+	PostTitle string    `json:"post_title"`
+	UserID    int       `json:"user_id"`
+	Username  string    `json:"username"`
+	Snippet   string    `json:"snippet"`
+	Likes     int       `json:"likes"`
+	Dislikes  int       `json:"dislikes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SearchComments finds visible comments containing the query (case-insensitive),
+// optionally restricted to a single post and/or author (authorID 0 means any), newest first
+func SearchComments(query string, postID *int, authorID, limit, offset int) ([]CommentSearchResult, int, error) {
+	results := []CommentSearchResult{}
+
+	where := "c.content LIKE ? ESCAPE '\\'"
+	args := []interface{}{"%" + escapeLike(query) + "%"}
+	if postID != nil {
+		where += " AND c.post_id = ?"
+		args = append(args, *postID)
+	}
+	if authorID > 0 {
+		where += " AND c.user_id = ?"
+		args = append(args, authorID)
+	}
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM visible_comments c WHERE ` + where
+	if err := database.GetReadDB().QueryRow(countQuery, args...).Scan(&total); err != nil {
+		return results, 0, err
+	}
+
+	searchQuery := `
+		SELECT c.id, c.post_id, p.title, c.user_id, u.username, c.content,
+		       c.likes, c.dislikes, c.created_at
+		FROM visible_comments c
+		JOIN users u ON c.user_id = u.id
+		JOIN posts p ON c.post_id = p.id
+		WHERE ` + where + `
+		ORDER BY c.created_at DESC, c.id DESC
+		LIMIT ? OFFSET ?
+	`
+	rows, err := database.GetReadDB().Query(searchQuery, append(args, limit, offset)...)
+	if err != nil {
+		return results, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r CommentSearchResult
+		var content string
+		err := rows.Scan(&r.ID, &r.PostID, &r.PostTitle, &r.UserID, &r.Username, &content,
+			&r.Likes, &r.Dislikes, &r.CreatedAt)
+		if err != nil {
+			continue
+		}
+
+		r.Snippet = buildSnippet(content, query, snippetRadius)
+		results = append(results, r)
+	}
+
+	return results, total, nil
+}
+
+// escapeLike escapes LIKE wildcards so user input is matched literally
+func escapeLike(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(s)
+}
+
+// buildSnippet returns the text around the first case-insensitive match of query,
+// with ellipses where the content was cut
+func buildSnippet(content, query string, radius int) string {
+	runes := []rune(content)
+	lowerRunes := []rune(strings.ToLower(content))
+
+	// Lowercasing can change the length of some runes; fall back to the start then
+	start := 0
+	if len(lowerRunes) == len(runes) {
+		if idx := strings.Index(string(lowerRunes), strings.ToLower(query)); idx >= 0 {
+			start = utf8.RuneCountInString(string(lowerRunes)[:idx])
+		}
+	}
+
+	from := start - radius
+	if from < 0 {
+		from = 0
+	}
+	to := start + utf8.RuneCountInString(query) + radius
+	if to > len(runes) {
+		to = len(runes)
+	}
+
+	snippet := strings.TrimSpace(string(runes[from:to]))
+	if from > 0 {
+		snippet = "…" + snippet
+	}
+	if to < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"forum/database"
+)
+
+// Setting keys shared across the application
+const (
+	SettingMaintenanceMode    = "maintenance_mode"
+	SettingMaintenanceMessage = "maintenance_message"
+)
+
+// settingKeyRegex restricts keys to lowercase identifiers like "comments.edit_window"
+var settingKeyRegex = regexp.MustCompile(`^[a-z0-9_.]{1,64}$`)
+
+// SettingsStore is an in-memory cache over the settings table.
+// Values are loaded once at startup and refreshed after every write.
+type SettingsStore struct {
+	mu     sync.RWMutex
+	values map[string]string
+}
+
+// Settings is the global runtime settings accessor
+var Settings = &SettingsStore{values: make(map[string]string)}
+
+// Load reads all settings from the database into memory
+func (s *SettingsStore) Load() error {
+	rows, err := database.GetDB().Query(`SELECT key, value FROM settings`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	values := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		values[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.values = values
+	s.mu.Unlock()
+	return nil
+}
+
+// Set stores a setting and refreshes the cache
+func (s *SettingsStore) Set(key, value string) error {
+	if !IsValidSettingKey(key) {
+		return errors.New("invalid setting key")
+	}
+
+	query := `
+		INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
+	`
+	if _, err := database.GetDB().Exec(query, key, value, time.Now()); err != nil {
+		return err
+	}
+
+	return s.Load()
+}
+
+// Delete removes a setting so its default applies again
+func (s *SettingsStore) Delete(key string) error {
+	if _, err := database.GetDB().Exec(`DELETE FROM settings WHERE key = ?`, key); err != nil {
+		return err
+	}
+
+	return s.Load()
+}
+
+// Lookup returns the raw value of a setting and whether it is set
+func (s *SettingsStore) Lookup(key string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.values[key]
+	return value, ok
+}
+
+// GetString returns a setting or the default when unset
+func (s *SettingsStore) GetString(key, defaultValue string) string {
+	if value, ok := s.Lookup(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// GetInt returns an integer setting or the default when unset or invalid
+func (s *SettingsStore) GetInt(key string, defaultValue int) int {
+	value, ok := s.Lookup(key)
+	if !ok {
+		return defaultValue
+	}
+
+	parsed, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return defaultValue
+	}
+	return parsed
+}
+
+// GetBool returns a boolean setting or the default when unset or invalid
+func (s *SettingsStore) GetBool(key string, defaultValue bool) bool {
+	value, ok := s.Lookup(key)
+	if !ok {
+		return defaultValue
+	}
+
+	parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return defaultValue
+	}
+	return parsed
+}
+
+// All returns a copy of every stored setting
+func (s *SettingsStore) All() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]string, len(s.values))
+	for k, v := range s.values {
+		result[k] = v
+	}
+	return result
+}
+
+// IsValidSettingKey checks if a setting key has an acceptable format
+func IsValidSettingKey(key string) bool {
+	return settingKeyRegex.MatchString(key)
+}
@@ -0,0 +1,90 @@
+package models
+
+import "testing"
+
+func TestSettingsDefaults(t *testing.T) {
+	key := uniqueName("test.unset_")
+
+	if got := Settings.GetString(key, "fallback"); got != "fallback" {
+		t.Fatalf("GetString = %q, want the default", got)
+	}
+	if got := Settings.GetInt(key, 42); got != 42 {
+		t.Fatalf("GetInt = %d, want the default", got)
+	}
+	if got := Settings.GetBool(key, true); !got {
+		t.Fatal("GetBool = false, want the default")
+	}
+	if _, ok := Settings.Lookup(key); ok {
+		t.Fatal("Lookup found a setting that was never stored")
+	}
+}
+
+func TestSettingsOverride(t *testing.T) {
+	key := uniqueName("test.limit_")
+	t.Cleanup(func() { Settings.Delete(key) })
+
+	if err := Settings.Set(key, " 15 "); err != nil {
+		t.Fatal(err)
+	}
+	if got := Settings.GetInt(key, 5); got != 15 {
+		t.Fatalf("GetInt = %d, want 15", got)
+	}
+
+	// Values that don't parse fall back to the default rather than zero
+	if err := Settings.Set(key, "lots"); err != nil {
+		t.Fatal(err)
+	}
+	if got := Settings.GetInt(key, 5); got != 5 {
+		t.Fatalf("GetInt of a bad value = %d, want the default", got)
+	}
+	if got := Settings.GetBool(key, true); !got {
+		t.Fatal("GetBool of a bad value = false, want the default")
+	}
+	if got := Settings.GetString(key, ""); got != "lots" {
+		t.Fatalf("GetString = %q, want the raw value", got)
+	}
+
+	if err := Settings.Set("Not A Key", "x"); err == nil {
+		t.Fatal("Set accepted an invalid key")
+	}
+}
+
+func TestSettingsCacheRefreshesOnWrite(t *testing.T) {
+	key := uniqueName("test.flag_")
+	t.Cleanup(func() { Settings.Delete(key) })
+
+	if err := Settings.Set(key, "true"); err != nil {
+		t.Fatal(err)
+	}
+	if !Settings.GetBool(key, false) {
+		t.Fatal("cache missed the first write")
+	}
+
+	if err := Settings.Set(key, "false"); err != nil {
+		t.Fatal(err)
+	}
+	if Settings.GetBool(key, true) {
+		t.Fatal("cache kept the old value after an update")
+	}
+	if got := Settings.All()[key]; got != "false" {
+		t.Fatalf("All()[%q] = %q, want false", key, got)
+	}
+
+	if err := Settings.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+	if !Settings.GetBool(key, true) {
+		t.Fatal("cache kept the value after a delete")
+	}
+
+	// A fresh load sees the same state as the cache
+	if err := Settings.Set(key, "7"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Settings.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := Settings.GetInt(key, 0); got != 7 {
+		t.Fatalf("after Load GetInt = %d, want 7", got)
+	}
+}
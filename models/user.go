@@ -1,25 +1,50 @@
 package models
 
 import (
+	"database/sql"
 	"errors"
 	"strings"
+	"sync"
 	"time"
 
+	"forum/config"
 	"forum/database"
+	"forum/utils"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+// User roles, from least to most privileged
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 type User struct {
 	ID           int       `json:"id"`
 	Username     string    `json:"username"`
 	Email        string    `json:"email"`
 	PasswordHash string    `json:"-"`
+	Role         string    `json:"role"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 	Avatar       string    `json:"avatar"`
+	// LastActive is when the user last made an authenticated request (nil if never)
+	LastActive *time.Time `json:"last_active"`
+	// UsernameChangedAt is when the username was last changed (nil if never)
+	UsernameChangedAt *time.Time `json:"username_changed_at"`
+	// BannedUntil is when the user's ban ends (nil if never banned or unbanned)
+	BannedUntil *time.Time `json:"banned_until"`
+	BanReason   string     `json:"ban_reason"`
 }
 
+// userColumns is the standard column list read by scan
+const userColumns = "id, username, email, password_hash, role, avatar, created_at, updated_at, last_active, username_changed_at, banned_until, ban_reason"
+
+// OnlineWindow is how recently a user must have been active to count as online
+const OnlineWindow = 5 * time.Minute
+
 // Create user and insert it to the database
 func (u *User) Create() error {
 	// Validate input
@@ -58,6 +83,7 @@ func (u *User) Create() error {
 	}
 
 	u.ID = int(id)
+	u.Role = RoleUser
 	u.CreatedAt = now
 	u.UpdatedAt = now
 	return nil
@@ -65,23 +91,44 @@ func (u *User) Create() error {
 
 // GetByUsername fills the user struct with data from the database taking username as input.
 func (u *User) GetByUsername(username string) error {
-	query := `SELECT id, username, email, password_hash, avatar, created_at, updated_at FROM users WHERE username = ?`
-	row := database.GetDB().QueryRow(query, username)
-	return row.Scan(&u.ID, &u.Username, &u.Email, &u.PasswordHash, &u.Avatar, &u.CreatedAt, &u.UpdatedAt)
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = ?`
+	return u.scan(database.GetDB().QueryRow(query, username))
 }
 
 // GetByEmail fills the user struct with data from the database taking email as input.
 func (u *User) GetByEmail(email string) error {
-	query := `SELECT id, username, email, password_hash, avatar, created_at, updated_at FROM users WHERE email = ?`
-	row := database.GetDB().QueryRow(query, email)
-	return row.Scan(&u.ID, &u.Username, &u.Email, &u.PasswordHash, &u.Avatar, &u.CreatedAt, &u.UpdatedAt)
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = ?`
+	return u.scan(database.GetDB().QueryRow(query, email))
 }
 
 // GetByID fills the user struct with data from the database taking id as input.
 func (u *User) GetByID(id int) error {
-	query := `SELECT id, username, email, password_hash, avatar, created_at, updated_at FROM users WHERE id = ?`
-	row := database.GetDB().QueryRow(query, id)
-	return row.Scan(&u.ID, &u.Username, &u.Email, &u.PasswordHash, &u.Avatar, &u.CreatedAt, &u.UpdatedAt)
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+	return u.scan(database.GetDB().QueryRow(query, id))
+}
+
+// scan reads a user row selected with the standard column list
+func (u *User) scan(row rowScanner) error {
+	var lastActive, usernameChangedAt, bannedUntil sql.NullTime
+	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.PasswordHash, &u.Role, &u.Avatar,
+		&u.CreatedAt, &u.UpdatedAt, &lastActive, &usernameChangedAt, &bannedUntil, &u.BanReason)
+	if err != nil {
+		return err
+	}
+
+	u.LastActive = nil
+	if lastActive.Valid {
+		u.LastActive = &lastActive.Time
+	}
+	u.UsernameChangedAt = nil
+	if usernameChangedAt.Valid {
+		u.UsernameChangedAt = &usernameChangedAt.Time
+	}
+	u.BannedUntil = nil
+	if bannedUntil.Valid {
+		u.BannedUntil = &bannedUntil.Time
+	}
+	return nil
 }
 
 // Exists checks for duplicate users
@@ -138,6 +185,7 @@ func (u *User) UpdatePassword(newPassword string) error {
 
 	u.PasswordHash = string(hashedBytes)
 	u.UpdatedAt = now
+	utils.ForgetUserSessions(u.ID)
 	return nil
 }
 
@@ -147,18 +195,55 @@ func (u *User) UpdateUsername(newUsername string) error {
 		return errors.New("username cannot be empty")
 	}
 
-	query := `UPDATE users SET username = ?, updated_at = ? WHERE id = ?`
+	tx, err := database.GetDB().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET username = ?, username_changed_at = ?, updated_at = ? WHERE id = ?`
 	now := time.Now()
-	_, err := database.GetDB().Exec(query, newUsername, now, u.ID)
+	_, err = tx.Exec(query, newUsername, now, now, u.ID)
 	if err != nil {
 		return err
 	}
 
+	// Keep the old name on record so mentions and reports can be traced
+	_, err = tx.Exec(`INSERT INTO username_history (user_id, old_username, new_username, changed_at) VALUES (?, ?, ?, ?)`,
+		u.ID, u.Username, newUsername, now)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
 	u.Username = newUsername
+	u.UsernameChangedAt = &now
 	u.UpdatedAt = now
+	utils.ForgetUserSessions(u.ID)
 	return nil
 }
 
+// SettingUsernameChangeCooldown overrides the username change cooldown (in days) at runtime
+const SettingUsernameChangeCooldown = "users.username_change_cooldown_days"
+
+// UsernameChangeCooldown returns how long users must wait between username changes
+func UsernameChangeCooldown() time.Duration {
+	days := Settings.GetInt(SettingUsernameChangeCooldown, config.GetUsernameChangeCooldownDays())
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// NextUsernameChangeAt returns when the user may next change their username
+// (the zero time if they never have)
+func (u *User) NextUsernameChangeAt() time.Time {
+	if u.UsernameChangedAt == nil {
+		return time.Time{}
+	}
+	return u.UsernameChangedAt.Add(UsernameChangeCooldown())
+}
+
 // UpdateEmail updates the user's email
 func (u *User) UpdateEmail(newEmail string) error {
 	if strings.TrimSpace(newEmail) == "" {
@@ -182,34 +267,69 @@ func (u *User) GetAvatarURL() string {
 	return strings.TrimSpace(u.Avatar) // empty string if no avatar
 }
 
-// UpdateAvatar updates the user's avatar
-func (u *User) UpdateAvatar(avatarURL string) error {
+// avatarLocks serializes avatar changes per user (striped by ID), so two uploads
+// racing each other can't both see the same avatar as the one they replaced
+var avatarLocks [64]sync.Mutex
+
+// UpdateAvatar updates the user's avatar and returns the one it replaced, read
+// from the database rather than u, so each replaced file is reported exactly once
+func (u *User) UpdateAvatar(avatarURL string) (previous string, err error) {
+	lock := &avatarLocks[u.ID%len(avatarLocks)]
+	lock.Lock()
+	defer lock.Unlock()
+
+	err = database.GetDB().QueryRow(`SELECT COALESCE(avatar, '') FROM users WHERE id = ?`, u.ID).Scan(&previous)
+	if err != nil {
+		return "", err
+	}
+
 	query := `UPDATE users SET avatar = ?, updated_at = ? WHERE id = ?`
 	now := time.Now()
 
-	_, err := database.GetDB().Exec(query, avatarURL, now, u.ID)
+	_, err = database.GetDB().Exec(query, avatarURL, now, u.ID)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	u.Avatar = avatarURL
 	u.UpdatedAt = now
-	return nil
+	return previous, nil
 }
 
-// UpdateLastLogin update the last login timestamp for the user
+// UpdateLastLogin records a login as activity (updated_at is left for profile edits)
 func (u *User) UpdateLastLogin() error {
-	query := `UPDATE users SET updated_at = ? WHERE id = ?`
 	now := time.Now()
-
-	_, err := database.GetDB().Exec(query, now, u.ID)
-	if err != nil {
+	if err := UpdateLastActive(u.ID, now); err != nil {
 		return err
 	}
-	u.UpdatedAt = now
+	u.LastActive = &now
 	return nil
 }
 
+// UpdateLastActive sets the last activity timestamp for a user
+func UpdateLastActive(userID int, at time.Time) error {
+	query := `UPDATE users SET last_active = ? WHERE id = ?`
+	_, err := database.GetDB().Exec(query, at, userID)
+	return err
+}
+
+// IsOnline reports whether the user was active within OnlineWindow
+func (u *User) IsOnline() bool {
+	return u.LastActive != nil && time.Since(*u.LastActive) < OnlineWindow
+}
+
+// IsNewMember reports whether an account created at joinedAt is still within the
+// configured new member period
+func IsNewMember(joinedAt time.Time) bool {
+	period := config.GetNewMemberPeriod()
+	return period > 0 && time.Since(joinedAt) < period
+}
+
+// IsNewMember reports whether the user joined within the new member period
+func (u *User) IsNewMember() bool {
+	return IsNewMember(u.CreatedAt)
+}
+
 // UpdateProfile updates multiple user fields at once
 func (u *User) UpdateProfile(updates map[string]interface{}) error {
 	if len(updates) == 0 {
@@ -265,14 +385,70 @@ func (u *User) UpdateProfile(updates map[string]interface{}) error {
 	return nil
 }
 
+// IsAdmin checks if the user has the admin role
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// IsModerator checks if the user can moderate content (moderators and admins)
+func (u *User) IsModerator() bool {
+	return u.Role == RoleModerator || u.Role == RoleAdmin
+}
+
+// UpdateRole changes the user's role
+func (u *User) UpdateRole(role string) error {
+	if !IsValidRole(role) {
+		return errors.New("invalid role")
+	}
+
+	query := `UPDATE users SET role = ?, updated_at = ? WHERE id = ?`
+	now := time.Now()
+	_, err := database.GetDB().Exec(query, role, now, u.ID)
+	if err != nil {
+		return err
+	}
+
+	u.Role = role
+	u.UpdatedAt = now
+	utils.ForgetUserSessions(u.ID)
+	return nil
+}
+
+// IsValidRole checks if a role name is known
+func IsValidRole(role string) bool {
+	return role == RoleUser || role == RoleModerator || role == RoleAdmin
+}
+
+// IsModeratorRole checks if a role grants moderation rights
+func IsModeratorRole(role string) bool {
+	return role == RoleModerator || role == RoleAdmin
+}
+
+// PromoteAdmins grants the admin role to the given usernames (used at startup)
+func PromoteAdmins(usernames []string) error {
+	for _, username := range usernames {
+		username = strings.TrimSpace(username)
+		if username == "" {
+			continue
+		}
+		query := `UPDATE users SET role = ? WHERE username = ?`
+		if _, err := database.GetDB().Exec(query, RoleAdmin, username); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetPublicProfile returns user data safe for public viewing
 func (u *User) GetPublicProfile() map[string]interface{} {
 	return map[string]interface{}{
-		"id":         u.ID,
-		"username":   u.Username,
-		"avatar":     u.GetAvatarURL(),
-		"created_at": u.CreatedAt,
-		"updated_at": u.UpdatedAt,
+		"id":          u.ID,
+		"username":    u.Username,
+		"avatar":      u.GetAvatarURL(),
+		"created_at":  utils.NewJSONTime(u.CreatedAt),
+		"updated_at":  utils.NewJSONTime(u.UpdatedAt),
+		"last_active": utils.NewJSONTimePtr(u.LastActive),
+		"is_online":   u.IsOnline(),
 	}
 }
 
@@ -282,3 +458,29 @@ func (u *User) GetPrivateProfile() map[string]interface{} {
 	profile["email"] = u.Email
 	return profile
 }
+
+// PrivacySettings holds which parts of a user's activity are hidden from other
+// users. The user always sees their own.
+type PrivacySettings struct {
+	HidePosts    bool `json:"hide_posts"`
+	HideComments bool `json:"hide_comments"`
+	HideStats    bool `json:"hide_stats"` // post/comment counts, votes received and reputation
+}
+
+// GetPrivacySettings returns the user's privacy settings
+func GetPrivacySettings(userID int) (*PrivacySettings, error) {
+	settings := &PrivacySettings{}
+	query := `SELECT hide_posts, hide_comments, hide_stats FROM users WHERE id = ?`
+	err := database.GetDB().QueryRow(query, userID).Scan(&settings.HidePosts, &settings.HideComments, &settings.HideStats)
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// UpdatePrivacySettings saves the user's privacy settings
+func UpdatePrivacySettings(userID int, settings *PrivacySettings) error {
+	query := `UPDATE users SET hide_posts = ?, hide_comments = ?, hide_stats = ? WHERE id = ?`
+	_, err := database.GetDB().Exec(query, settings.HidePosts, settings.HideComments, settings.HideStats, userID)
+	return err
+}
@@ -3,11 +3,14 @@ package models
 import (
 	"database/sql"
 	"errors"
-	"strconv"
+	"fmt"
 	"strings"
 	"time"
 
+	"forum/config"
 	"forum/database"
+	"forum/events"
+	"forum/utils"
 )
 
 // Vote represents a like or dislike on a post or comment
@@ -22,7 +25,7 @@ type Vote struct {
 
 // VoteResult represents the result of a voting operation
 type VoteResult struct {
-	Action      string `json:"action"`       // "added", "removed", "changed"
+	Action      string `json:"action"`       // "added", "removed", "changed", "unchanged"
 	VoteType    string `json:"vote_type"`    // "like" or "dislike"
 	NewLikes    int    `json:"new_likes"`    // Updated like count
 	NewDislikes int    `json:"new_dislikes"` // Updated dislike count
@@ -36,10 +39,111 @@ type VoteStats struct {
 	LikesReceived int `json:"likes_received"`
 }
 
-// TogglePostVote handles voting logic for posts (like/dislike toggle)
-func TogglePostVote(userID, postID int, voteType string) (*VoteResult, error) {
-	// Validate vote type
-	if voteType != "like" && voteType != "dislike" {
+// IsCollapsedScore reports whether content with these cached counts has been voted
+// down to the collapse threshold
+func IsCollapsedScore(likes, dislikes int) bool {
+	return likes-dislikes <= config.GetCollapseScoreThreshold()
+}
+
+// Daily vote budget per user, overridable at runtime through settings (0 disables it)
+const (
+	SettingDailyVoteLimit = "votes.daily_limit"
+	DefaultDailyVoteLimit = 0
+)
+
+// DailyVoteLimit returns how many new votes a user may cast per UTC day, 0 for no limit
+func DailyVoteLimit() int {
+	return Settings.GetInt(SettingDailyVoteLimit, DefaultDailyVoteLimit)
+}
+
+// VoteBudgetError is returned when a user has cast all the votes allowed for the day
+type VoteBudgetError struct {
+	Limit   int
+	ResetAt time.Time // start of the next UTC day
+}
+
+func (e *VoteBudgetError) Error() string {
+	return fmt.Sprintf("daily limit of %d votes reached", e.Limit)
+}
+
+// checkVoteBudget returns a VoteBudgetError when the user already cast the day's allowance
+// of new votes. Days start at midnight UTC, moderators are exempt, and changing or removing
+// a vote doesn't count since only new vote rows do.
+func checkVoteBudget(tx *sql.Tx, userID int) error {
+	limit := DailyVoteLimit()
+	if limit <= 0 {
+		return nil
+	}
+
+	var role string
+	if err := tx.QueryRow(`SELECT role FROM users WHERE id = ?`, userID).Scan(&role); err != nil {
+		return err
+	}
+	if IsModeratorRole(role) {
+		return nil
+	}
+
+	dayStart := time.Now().UTC().Truncate(24 * time.Hour)
+	var count int
+	query := `SELECT COUNT(*) FROM votes WHERE user_id = ? AND datetime(created_at) >= ?`
+	if err := tx.QueryRow(query, userID, dayStart.Format(sqliteDateTime)).Scan(&count); err != nil {
+		return err
+	}
+	if count >= limit {
+		return &VoteBudgetError{Limit: limit, ResetAt: dayStart.Add(24 * time.Hour)}
+	}
+	return nil
+}
+
+// Vote modes: how a vote request combines with the user's existing vote
+const (
+	VoteModeToggle = "toggle" // same vote again removes it (the default)
+	VoteModeSet    = "set"    // make the vote this type; repeating it changes nothing
+	VoteModeRemove = "remove" // remove any existing vote
+)
+
+// IsValidVoteMode checks if a vote mode is supported
+func IsValidVoteMode(mode string) bool {
+	return mode == VoteModeToggle || mode == VoteModeSet || mode == VoteModeRemove
+}
+
+// decideVoteAction works out what a vote request does given the user's existing vote
+func decideVoteAction(mode string, hasVote bool, existingVoteType, voteType string) string {
+	switch {
+	case mode == VoteModeRemove && !hasVote:
+		return "unchanged"
+	case mode == VoteModeRemove:
+		return "removed"
+	case !hasVote:
+		return "added"
+	case existingVoteType != voteType:
+		return "changed"
+	case mode == VoteModeSet:
+		return "unchanged"
+	default:
+		return "removed"
+	}
+}
+
+// ErrSelfVote is returned when self-votes are disabled and a user votes on their own content
+var ErrSelfVote = errors.New("you cannot vote on your own content")
+
+// VoteNotifier runs inside the vote transaction once the vote and counts are stored.
+// Returning an error rolls the vote back, so a vote and its notification never diverge.
+type VoteNotifier func(tx *sql.Tx, result *VoteResult) error
+
+// TogglePostVote handles voting logic for posts (like/dislike toggle).
+// mode is one of the VoteMode constants, toggle when empty.
+// notify is optional and runs in the same transaction as the vote.
+func TogglePostVote(userID, postID int, voteType, mode string, notify VoteNotifier) (*VoteResult, error) {
+	// Validate vote type (removing a vote doesn't need one)
+	if mode == "" {
+		mode = VoteModeToggle
+	}
+	if !IsValidVoteMode(mode) {
+		return nil, errors.New("invalid vote mode")
+	}
+	if mode != VoteModeRemove && voteType != "like" && voteType != "dislike" {
 		return nil, errors.New("invalid vote type")
 	}
 
@@ -50,65 +154,101 @@ func TogglePostVote(userID, postID int, voteType string) (*VoteResult, error) {
 	}
 	defer tx.Rollback()
 
+	if err := checkSelfVote(tx, "posts", postID, userID); err != nil {
+		return nil, err
+	}
+
 	// Check if user already voted on this post
 	var existingVoteType string
 	query := `SELECT vote_type FROM votes WHERE user_id = ? AND post_id = ?`
 	err = tx.QueryRow(query, userID, postID).Scan(&existingVoteType)
 
+	if err != nil && err != sql.ErrNoRows {
+		// Database error
+		return nil, err
+	}
+	hasVote := err == nil
+
 	var result VoteResult
 	result.VoteType = voteType
+	if mode == VoteModeRemove {
+		result.VoteType = existingVoteType
+	}
+	result.Action = decideVoteAction(mode, hasVote, existingVoteType, voteType)
 
-	if err == sql.ErrNoRows {
-		// No existing vote - create new vote
-		err = createPostVote(tx, userID, postID, voteType)
-		if err != nil {
-			return nil, err
+	switch result.Action {
+	case "added":
+		if err = checkVoteBudget(tx, userID); err == nil {
+			err = createPostVote(tx, userID, postID, voteType)
 		}
-		result.Action = "added"
-	} else if err != nil {
-		// Database error
-		return nil, err
-	} else if existingVoteType == voteType {
-		// Same vote type - remove the vote (toggle off)
+	case "removed":
 		err = removePostVote(tx, userID, postID)
-		if err != nil {
-			return nil, err
-		}
-		result.Action = "removed"
-	} else {
-		// Different vote type - update the vote
+	case "changed":
 		err = updatePostVote(tx, userID, postID, voteType)
-		if err != nil {
-			return nil, err
-		}
-		result.Action = "changed"
+	default:
+		err = nil // nothing to write
 	}
-
-	// Update post vote counts
-	err = updatePostVoteCounts(tx, postID)
 	if err != nil {
 		return nil, err
 	}
 
+	// Update post vote counts
+	if result.Action != "unchanged" {
+		err = updatePostVoteCounts(tx, postID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Get updated counts
 	result.NewLikes, result.NewDislikes, err = getPostVoteCounts(tx, postID)
 	if err != nil {
 		return nil, err
 	}
 
+	if notify != nil {
+		if err := notify(tx, &result); err != nil {
+			return nil, err
+		}
+	}
+
 	// Commit transaction
 	err = tx.Commit()
 	if err != nil {
 		return nil, err
 	}
 
+	if result.Action == "added" {
+		publishVoteAdded(TargetPost, postID, userID, &result)
+	}
+
 	return &result, nil
 }
 
-// ToggleCommentVote handles voting logic for comments
-func ToggleCommentVote(userID, commentID int, voteType string) (*VoteResult, error) {
-	// Validate vote type
-	if voteType != "like" && voteType != "dislike" {
+// publishVoteAdded announces a new vote once it has been committed
+func publishVoteAdded(targetType string, targetID, userID int, result *VoteResult) {
+	events.Publish(events.VoteAdded, map[string]interface{}{
+		"target_type": targetType,
+		"target_id":   targetID,
+		"user_id":     userID,
+		"vote_type":   result.VoteType,
+		"likes":       result.NewLikes,
+		"dislikes":    result.NewDislikes,
+	})
+}
+
+// ToggleCommentVote handles voting logic for comments.
+// mode is one of the VoteMode constants, toggle when empty.
+// notify is optional and runs in the same transaction as the vote.
+func ToggleCommentVote(userID, commentID int, voteType, mode string, notify VoteNotifier) (*VoteResult, error) {
+	// Validate vote type (removing a vote doesn't need one)
+	if mode == "" {
+		mode = VoteModeToggle
+	}
+	if !IsValidVoteMode(mode) {
+		return nil, errors.New("invalid vote mode")
+	}
+	if mode != VoteModeRemove && voteType != "like" && voteType != "dislike" {
 		return nil, errors.New("invalid vote type")
 	}
 
@@ -119,58 +259,74 @@ func ToggleCommentVote(userID, commentID int, voteType string) (*VoteResult, err
 	}
 	defer tx.Rollback()
 
+	if err := checkSelfVote(tx, "comments", commentID, userID); err != nil {
+		return nil, err
+	}
+
 	// Check if user already voted on this comment
 	var existingVoteType string
 	query := `SELECT vote_type FROM votes WHERE user_id = ? AND comment_id = ?`
 	err = tx.QueryRow(query, userID, commentID).Scan(&existingVoteType)
 
+	if err != nil && err != sql.ErrNoRows {
+		// Database error
+		return nil, err
+	}
+	hasVote := err == nil
+
 	var result VoteResult
 	result.VoteType = voteType
+	if mode == VoteModeRemove {
+		result.VoteType = existingVoteType
+	}
+	result.Action = decideVoteAction(mode, hasVote, existingVoteType, voteType)
 
-	if err == sql.ErrNoRows {
-		// No existing vote - create new vote
-		err = createCommentVote(tx, userID, commentID, voteType)
-		if err != nil {
-			return nil, err
+	switch result.Action {
+	case "added":
+		if err = checkVoteBudget(tx, userID); err == nil {
+			err = createCommentVote(tx, userID, commentID, voteType)
 		}
-		result.Action = "added"
-	} else if err != nil {
-		// Database error
-		return nil, err
-	} else if existingVoteType == voteType {
-		// Same vote type - remove the vote (toggle off)
+	case "removed":
 		err = removeCommentVote(tx, userID, commentID)
-		if err != nil {
-			return nil, err
-		}
-		result.Action = "removed"
-	} else {
-		// Different vote type - update the vote
+	case "changed":
 		err = updateCommentVote(tx, userID, commentID, voteType)
-		if err != nil {
-			return nil, err
-		}
-		result.Action = "changed"
+	default:
+		err = nil // nothing to write
 	}
-
-	// Update comment vote counts
-	err = updateCommentVoteCounts(tx, commentID)
 	if err != nil {
 		return nil, err
 	}
 
+	// Update comment vote counts
+	if result.Action != "unchanged" {
+		err = updateCommentVoteCounts(tx, commentID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Get updated counts
 	result.NewLikes, result.NewDislikes, err = getCommentVoteCounts(tx, commentID)
 	if err != nil {
 		return nil, err
 	}
 
+	if notify != nil {
+		if err := notify(tx, &result); err != nil {
+			return nil, err
+		}
+	}
+
 	// Commit transaction
 	err = tx.Commit()
 	if err != nil {
 		return nil, err
 	}
 
+	if result.Action == "added" {
+		publishVoteAdded(TargetComment, commentID, userID, &result)
+	}
+
 	return &result, nil
 }
 
@@ -216,70 +372,304 @@ func ToggleCommentVote(userID, commentID int, voteType string) (*VoteResult, err
 // 	return posts, nil
 // }
 
-// GetUserLikedPosts returns posts that a user has liked
-func GetUserLikedPosts(userID int, limit, offset int) ([]Post, error) {
-	var posts []Post
+// checkSelfVote returns ErrSelfVote when self-votes are disabled and the user wrote the
+// target post or comment. It reads the author inside the vote transaction.
+func checkSelfVote(tx *sql.Tx, table string, targetID, userID int) error {
+	if config.AllowSelfVotes() {
+		return nil
+	}
+
+	var authorID int
+	err := tx.QueryRow(`SELECT user_id FROM `+table+` WHERE id = ?`, targetID).Scan(&authorID)
+	if err != nil {
+		return err
+	}
+	if authorID == userID {
+		return ErrSelfVote
+	}
+	return nil
+}
+
+// LikedComment is a comment a user liked, with its post's title for context
+type LikedComment struct {
+	ID        int       `json:"id"`
+	Content   string    `json:"content"`
+	UserID    int       `json:"user_id"`
+	Username  string    `json:"username"`
+	PostID    int       `json:"post_id"`
+	PostTitle string    `json:"post_title"`
+	Likes     int       `json:"likes"`
+	Dislikes  int       `json:"dislikes"`
+	Score     int       `json:"score"` // likes minus dislikes
+	CreatedAt time.Time `json:"created_at"`
+	LikedAt   time.Time `json:"liked_at"`
+}
+
+// GetUserLikedComments returns the visible comments a user has liked, most recently
+// liked first, along with the total number of liked comments
+func GetUserLikedComments(userID int, limit, offset int) ([]LikedComment, int, error) {
+	comments := []LikedComment{}
+
+	var total int
+	countQuery := `
+		SELECT COUNT(*)
+		FROM votes v
+		JOIN visible_comments c ON v.comment_id = c.id
+		WHERE v.user_id = ? AND v.vote_type = 'like'
+	`
+	if err := database.GetReadDB().QueryRow(countQuery, userID).Scan(&total); err != nil {
+		return comments, 0, err
+	}
 
 	query := `
-		SELECT p.id, p.title, p.content, p.user_id, u.username,
-		       p.likes, p.dislikes, p.created_at, p.updated_at,
-		       (SELECT COUNT(*) FROM comments WHERE post_id = p.id) as comment_count,
-		       GROUP_CONCAT(c.id) as category_ids,
-		       GROUP_CONCAT(c.name) as category_names
-		FROM posts p
-		JOIN users u ON p.user_id = u.id
-		LEFT JOIN post_categories pc ON p.id = pc.post_id
-		LEFT JOIN categories c ON pc.category_id = c.id
-		JOIN votes v ON p.id = v.post_id
+		SELECT c.id, c.content, c.user_id, u.username, c.post_id, p.title,
+		       c.likes, c.dislikes, c.created_at, v.created_at
+		FROM votes v
+		JOIN visible_comments c ON v.comment_id = c.id
+		JOIN posts p ON c.post_id = p.id
+		JOIN users u ON c.user_id = u.id
 		WHERE v.user_id = ? AND v.vote_type = 'like'
-		GROUP BY p.id, p.title, p.content, p.user_id, u.username, 
-		         p.likes, p.dislikes, p.created_at, p.updated_at
-		ORDER BY v.created_at DESC
+		ORDER BY v.created_at DESC, v.id DESC
 		LIMIT ? OFFSET ?
 	`
-
-	rows, err := database.GetDB().Query(query, userID, limit, offset)
+	rows, err := database.GetReadDB().Query(query, userID, limit, offset)
 	if err != nil {
-		return posts, err
+		return comments, 0, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var post Post
-		var categoryIDs, categoryNames sql.NullString
-		
-		err := rows.Scan(
-			&post.ID, &post.Title, &post.Content, &post.UserID, &post.Username,
-			&post.Likes, &post.Dislikes, &post.CreatedAt, &post.UpdatedAt, 
-			&post.CommentCount, &categoryIDs, &categoryNames,
-		)
+		var comment LikedComment
+		err := rows.Scan(&comment.ID, &comment.Content, &comment.UserID, &comment.Username,
+			&comment.PostID, &comment.PostTitle, &comment.Likes, &comment.Dislikes,
+			&comment.CreatedAt, &comment.LikedAt)
 		if err != nil {
-			continue
+			return comments, 0, err
+		}
+		comment.Score = comment.Likes - comment.Dislikes
+		comments = append(comments, comment)
+	}
+
+	return comments, total, rows.Err()
+}
+
+// GetUserLikedPosts returns posts that a user has liked, most recently liked first,
+// along with the total number of liked posts
+func GetUserLikedPosts(userID int, limit, offset int) ([]Post, int, error) {
+	return GetPosts(PostFilters{
+		CurrentUserID: userID,
+		LikedByUserID: userID,
+		Limit:         limit,
+		Offset:        offset,
+	})
+}
+
+// GetUserVoteTypes returns the user's vote type on each of the given posts or comments
+// (targetType is TargetPost or TargetComment) with one query. Targets the user hasn't
+// voted on, including ones that don't exist, are simply missing from the map.
+func GetUserVoteTypes(userID int, targetType string, targetIDs []int) (map[int]string, error) {
+	votes := make(map[int]string, len(targetIDs))
+	if len(targetIDs) == 0 {
+		return votes, nil
+	}
+
+	column := "post_id"
+	if targetType == TargetComment {
+		column = "comment_id"
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(targetIDs)), ",")
+	args := make([]interface{}, 0, len(targetIDs)+1)
+	args = append(args, userID)
+	for _, id := range targetIDs {
+		args = append(args, id)
+	}
+
+	query := `SELECT ` + column + `, vote_type FROM votes WHERE user_id = ? AND ` + column + ` IN (` + placeholders + `)`
+	rows, err := database.GetDB().Query(query, args...)
+	if err != nil {
+		return votes, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var targetID int
+		var voteType string
+		if err := rows.Scan(&targetID, &voteType); err != nil {
+			return votes, err
+		}
+		votes[targetID] = voteType
+	}
+
+	return votes, rows.Err()
+}
+
+// VoteCounts is how many likes and dislikes something received
+type VoteCounts struct {
+	Likes    int `json:"likes"`
+	Dislikes int `json:"dislikes"`
+}
+
+// GetCommentVoteCounts counts the votes on each of the given comments straight from
+// the votes table with one query, so the result can't drift like the cached likes and
+// dislikes columns can. Comments without votes, including ones that don't exist,
+// are missing from the map.
+func GetCommentVoteCounts(commentIDs []int) (map[int]VoteCounts, error) {
+	counts := make(map[int]VoteCounts, len(commentIDs))
+	if len(commentIDs) == 0 {
+		return counts, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(commentIDs)), ",")
+	args := make([]interface{}, 0, len(commentIDs))
+	for _, id := range commentIDs {
+		args = append(args, id)
+	}
+
+	query := `
+		SELECT comment_id,
+		       COALESCE(SUM(CASE WHEN vote_type = 'like' THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN vote_type = 'dislike' THEN 1 ELSE 0 END), 0)
+		FROM votes
+		WHERE comment_id IN (` + placeholders + `)
+		GROUP BY comment_id
+	`
+	rows, err := database.GetReadDB().Query(query, args...)
+	if err != nil {
+		return counts, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var commentID int
+		var c VoteCounts
+		if err := rows.Scan(&commentID, &c.Likes, &c.Dislikes); err != nil {
+			return counts, err
+		}
+		counts[commentID] = c
+	}
+
+	return counts, rows.Err()
+}
+
+// VoteExcerptLength is how many characters of the voted content a history entry shows
+const VoteExcerptLength = 120
+
+// VoteHistoryEntry is one of a user's votes together with what it was cast on
+type VoteHistoryEntry struct {
+	VoteID     int            `json:"vote_id"`
+	VoteType   string         `json:"vote_type"`   // "like" or "dislike"
+	TargetType string         `json:"target_type"` // "post" or "comment"
+	TargetID   int            `json:"target_id"`
+	PostID     int            `json:"post_id"`    // the post itself, or the post a comment belongs to
+	PostTitle  string         `json:"post_title"` // title of that post
+	Excerpt    string         `json:"excerpt"`    // start of the voted content
+	Score      int            `json:"score"`      // current likes minus dislikes of the target
+	VotedAt    utils.JSONTime `json:"voted_at"`
+
+	sortKey float64 // julianday of the vote time, for the next cursor
+}
+
+// VoteHistoryCursor marks the last vote of a page; the next page starts after it
+type VoteHistoryCursor struct {
+	SortKey float64 // julianday of the vote time
+	VoteID  int
+}
+
+// VoteHistoryFilter narrows a user's vote history
+type VoteHistoryFilter struct {
+	VoteType   string             // "like", "dislike" or "" for both
+	TargetType string             // TargetPost, TargetComment or "" for both
+	After      *VoteHistoryCursor // nil for the first page
+}
+
+// GetUserVoteHistory returns a page of the user's votes on posts and visible comments,
+// newest first. Pages are keyed on the vote time rather than an offset so votes cast
+// while paging don't shift the results; next is nil on the last page.
+func GetUserVoteHistory(userID int, filter VoteHistoryFilter, limit int) (entries []VoteHistoryEntry, next *VoteHistoryCursor, err error) {
+	entries = []VoteHistoryEntry{}
+
+	voteTypeClause := ""
+	if filter.VoteType != "" {
+		voteTypeClause = " AND v.vote_type = ?"
+	}
+
+	var parts []string
+	var args []interface{}
+	if filter.TargetType == "" || filter.TargetType == TargetPost {
+		parts = append(parts, `
+			SELECT v.id, v.vote_type, '`+TargetPost+`' AS target_type, p.id AS target_id, p.id AS post_id,
+			       p.title AS post_title, substr(p.content, 1, ?) AS excerpt, p.likes - p.dislikes AS score,
+			       v.created_at AS voted_at
+			FROM votes v
+			JOIN posts p ON v.post_id = p.id
+			WHERE v.user_id = ?`+voteTypeClause)
+		args = append(args, VoteExcerptLength+1, userID)
+		if filter.VoteType != "" {
+			args = append(args, filter.VoteType)
+		}
+	}
+	if filter.TargetType == "" || filter.TargetType == TargetComment {
+		parts = append(parts, `
+			SELECT v.id, v.vote_type, '`+TargetComment+`' AS target_type, c.id AS target_id, c.post_id AS post_id,
+			       p.title AS post_title, substr(c.content, 1, ?) AS excerpt, c.likes - c.dislikes AS score,
+			       v.created_at AS voted_at
+			FROM votes v
+			JOIN visible_comments c ON v.comment_id = c.id
+			JOIN posts p ON c.post_id = p.id
+			WHERE v.user_id = ?`+voteTypeClause)
+		args = append(args, VoteExcerptLength+1, userID)
+		if filter.VoteType != "" {
+			args = append(args, filter.VoteType)
 		}
+	}
 
-		// Parse category IDs and names from GROUP_CONCAT
-		if categoryIDs.Valid && categoryNames.Valid {
-			ids := strings.Split(categoryIDs.String, ",")
-			names := strings.Split(categoryNames.String, ",")
-			
-			post.Categories = make([]Category, 0, len(ids))
-			for i := range ids {
-				id, _ := strconv.Atoi(ids[i])
-				post.Categories = append(post.Categories, Category{
-					ID:   id,
-					Name: names[i],
-				})
-			}
+	query := `
+		SELECT id, vote_type, target_type, target_id, post_id, post_title, excerpt, score,
+		       voted_at, julianday(voted_at) AS sort_key
+		FROM (` + strings.Join(parts, " UNION ALL ") + `) h
+	`
+	if filter.After != nil {
+		query += ` WHERE julianday(voted_at) < ? OR (julianday(voted_at) = ? AND id < ?)`
+		args = append(args, filter.After.SortKey, filter.After.SortKey, filter.After.VoteID)
+	}
+	// One extra row tells whether another page follows
+	query += ` ORDER BY sort_key DESC, id DESC LIMIT ?`
+	args = append(args, limit+1)
+
+	rows, err := database.GetReadDB().Query(query, args...)
+	if err != nil {
+		return entries, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry VoteHistoryEntry
+		var votedAt time.Time
+		if err := rows.Scan(&entry.VoteID, &entry.VoteType, &entry.TargetType, &entry.TargetID,
+			&entry.PostID, &entry.PostTitle, &entry.Excerpt, &entry.Score, &votedAt, &entry.sortKey); err != nil {
+			return entries, nil, err
 		}
+		entry.VotedAt = utils.NewJSONTime(votedAt)
 
-		// User has liked this post by definition
-		likeVote := "like"
-		post.UserVote = &likeVote
+		excerpt := []rune(strings.TrimSpace(entry.Excerpt))
+		if len(excerpt) > VoteExcerptLength {
+			excerpt = append(excerpt[:VoteExcerptLength], '…')
+		}
+		entry.Excerpt = string(excerpt)
 
-		posts = append(posts, post)
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return entries, nil, err
 	}
 
-	return posts, nil
+	if len(entries) > limit {
+		entries = entries[:limit]
+		last := entries[limit-1]
+		next = &VoteHistoryCursor{SortKey: last.sortKey, VoteID: last.VoteID}
+	}
+	return entries, next, nil
 }
 
 // GetVoteStats returns voting statistics for a user
@@ -295,7 +685,7 @@ func GetVoteStats(userID int) (*VoteStats, error) {
 		FROM votes 
 		WHERE user_id = ?
 	`
-	err := database.GetDB().QueryRow(query, userID).Scan(&stats.TotalVotes, &stats.LikesGiven, &stats.DislikesGiven)
+	err := database.GetReadDB().QueryRow(query, userID).Scan(&stats.TotalVotes, &stats.LikesGiven, &stats.DislikesGiven)
 	if err != nil {
 		return nil, err
 	}
@@ -307,7 +697,7 @@ func GetVoteStats(userID int) (*VoteStats, error) {
 		LEFT JOIN comments c ON v.comment_id = c.id
 		WHERE (p.user_id = ? OR c.user_id = ?) AND v.vote_type = 'like'
 	`
-	err = database.GetDB().QueryRow(query, userID, userID).Scan(&stats.LikesReceived)
+	err = database.GetReadDB().QueryRow(query, userID, userID).Scan(&stats.LikesReceived)
 	if err != nil {
 		return nil, err
 	}
@@ -420,6 +810,79 @@ func CleanupOrphanedVotes() error {
 	return tx.Commit()
 }
 
+// recountBatchSize is how many posts or comments one recount transaction covers
+const recountBatchSize = 500
+
+// VoteRecountResult reports how many cached counters a recount corrected
+type VoteRecountResult struct {
+	PostsUpdated    int `json:"posts_updated"`
+	CommentsUpdated int `json:"comments_updated"`
+}
+
+// RecountVotes removes orphaned votes, then recomputes the cached likes/dislikes
+// of every post and comment from the votes table. Rows are processed in batches,
+// each in its own transaction, so voting is never blocked for long.
+func RecountVotes() (VoteRecountResult, error) {
+	var result VoteRecountResult
+
+	if err := CleanupOrphanedVotes(); err != nil {
+		return result, err
+	}
+
+	var err error
+	result.PostsUpdated, err = recountVoteTable("posts", "post_id")
+	if err != nil {
+		return result, err
+	}
+	result.CommentsUpdated, err = recountVoteTable("comments", "comment_id")
+	return result, err
+}
+
+// recountVoteTable recounts the likes/dislikes of one table batch by batch and
+// returns how many rows actually changed
+func recountVoteTable(table, voteColumn string) (int, error) {
+	likes := `(SELECT COUNT(*) FROM votes v WHERE v.` + voteColumn + ` = ` + table + `.id AND v.vote_type = 'like')`
+	dislikes := `(SELECT COUNT(*) FROM votes v WHERE v.` + voteColumn + ` = ` + table + `.id AND v.vote_type = 'dislike')`
+	update := `
+		UPDATE ` + table + `
+		SET likes = ` + likes + `, dislikes = ` + dislikes + `
+		WHERE id > ? AND id <= ? AND (likes != ` + likes + ` OR dislikes != ` + dislikes + `)
+	`
+	bound := `SELECT MAX(id) FROM (SELECT id FROM ` + table + ` WHERE id > ? ORDER BY id LIMIT ?)`
+
+	updated := 0
+	lastID := 0
+	for {
+		var upperID sql.NullInt64
+		if err := database.GetDB().QueryRow(bound, lastID, recountBatchSize).Scan(&upperID); err != nil {
+			return updated, err
+		}
+		if !upperID.Valid {
+			return updated, nil
+		}
+
+		tx, err := database.GetDB().Begin()
+		if err != nil {
+			return updated, err
+		}
+		res, err := tx.Exec(update, lastID, upperID.Int64)
+		if err != nil {
+			tx.Rollback()
+			return updated, err
+		}
+		if err := tx.Commit(); err != nil {
+			return updated, err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return updated, err
+		}
+		updated += int(affected)
+		lastID = int(upperID.Int64)
+	}
+}
+
 // GetByUserAndPost fetches the vote a user made on a specific post
 func (v *Vote) GetByUserAndPost(userID, postID int) error {
 	query := `SELECT id, user_id, post_id, vote_type, created_at FROM votes WHERE user_id = ? AND post_id = ? LIMIT 1`
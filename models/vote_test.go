@@ -0,0 +1,463 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"forum/database"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// rowCount counts the rows of table matching where
+func rowCount(t *testing.T, table, where string, args ...interface{}) int {
+	t.Helper()
+
+	var n int
+	if err := database.GetDB().QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE `+where, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestVoteRollsBackWhenNotifierFails(t *testing.T) {
+	author := newTestUser(t)
+	voter := newTestUser(t)
+	post := newTestPost(t, author.ID)
+	comment := newTestComment(t, author.ID, post.ID, "A comment to like")
+	errNotify := errors.New("notification failed")
+
+	tests := []struct {
+		name      string
+		target    string // votes on the target, with its ID as the argument
+		targetID  int
+		commentID *int
+		vote      func(notify VoteNotifier) error
+		likes     func() int
+	}{
+		{"post", "post_id = ? AND comment_id IS NULL", post.ID, nil, func(notify VoteNotifier) error {
+			_, err := TogglePostVote(voter.ID, post.ID, "like", VoteModeSet, notify)
+			return err
+		}, func() int {
+			loaded := &Post{}
+			if err := loaded.GetByID(post.ID, nil); err != nil {
+				t.Fatal(err)
+			}
+			return loaded.Likes
+		}},
+		{"comment", "comment_id = ?", comment.ID, &comment.ID, func(notify VoteNotifier) error {
+			_, err := ToggleCommentVote(voter.ID, comment.ID, "like", VoteModeSet, notify)
+			return err
+		}, func() int {
+			loaded := &Comment{}
+			if err := loaded.GetByID(comment.ID, nil); err != nil {
+				t.Fatal(err)
+			}
+			return loaded.Likes
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifications := func() int {
+				return rowCount(t, "notifications", "user_id = ? AND type = ?", author.ID, NotificationLike)
+			}
+			before := notifications()
+
+			// The notification is written, then fails: nothing of the vote may remain
+			err := tt.vote(func(tx *sql.Tx, result *VoteResult) error {
+				if err := LikeNotifier(voter.ID, author.ID, post.ID, tt.commentID)(tx, result); err != nil {
+					return err
+				}
+				return errNotify
+			})
+			if !errors.Is(err, errNotify) {
+				t.Fatalf("vote error = %v, want %v", err, errNotify)
+			}
+			if n := rowCount(t, "votes", "user_id = ? AND "+tt.target, voter.ID, tt.targetID); n != 0 {
+				t.Fatalf("%d votes stored after the notifier failed", n)
+			}
+			if likes := tt.likes(); likes != 0 {
+				t.Fatalf("likes = %d after the notifier failed, want 0", likes)
+			}
+			if n := notifications(); n != before {
+				t.Fatalf("notifications = %d after the notifier failed, want %d", n, before)
+			}
+
+			// With a working notifier both are stored
+			if err := tt.vote(LikeNotifier(voter.ID, author.ID, post.ID, tt.commentID)); err != nil {
+				t.Fatal(err)
+			}
+			if likes := tt.likes(); likes != 1 {
+				t.Fatalf("likes = %d, want 1", likes)
+			}
+			if n := notifications(); n != before+1 {
+				t.Fatalf("notifications = %d, want %d", n, before+1)
+			}
+		})
+	}
+}
+
+func TestConcurrentVotesStoreOneVote(t *testing.T) {
+	author := newTestUser(t)
+	voter := newTestUser(t)
+	post := newTestPost(t, author.ID)
+	comment := newTestComment(t, author.ID, post.ID, "A comment to like")
+
+	// Many requests racing to set the same vote once had room to insert it twice.
+	// SQLite may turn some of them away as busy; none may store a second vote.
+	const racers = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*racers)
+	for i := 0; i < racers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := TogglePostVote(voter.ID, post.ID, "like", VoteModeSet, nil); err != nil {
+				errs <- err
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := ToggleCommentVote(voter.ID, comment.ID, "like", VoteModeSet, nil); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked) {
+			continue
+		}
+		t.Errorf("vote: %v", err)
+	}
+	// A retry settles the vote even if every racer was turned away
+	if _, err := TogglePostVote(voter.ID, post.ID, "like", VoteModeSet, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ToggleCommentVote(voter.ID, comment.ID, "like", VoteModeSet, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := rowCount(t, "votes", "user_id = ? AND post_id = ? AND comment_id IS NULL", voter.ID, post.ID); n != 1 {
+		t.Fatalf("%d post votes stored, want 1", n)
+	}
+	if n := rowCount(t, "votes", "user_id = ? AND comment_id = ?", voter.ID, comment.ID); n != 1 {
+		t.Fatalf("%d comment votes stored, want 1", n)
+	}
+
+	loadedPost, loadedComment := &Post{}, &Comment{}
+	if err := loadedPost.GetByID(post.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadedComment.GetByID(comment.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if loadedPost.Likes != 1 || loadedComment.Likes != 1 {
+		t.Fatalf("likes = %d on the post and %d on the comment, want 1 each", loadedPost.Likes, loadedComment.Likes)
+	}
+}
+
+func TestVoteConstraints(t *testing.T) {
+	author := newTestUser(t)
+	voter := newTestUser(t)
+	post := newTestPost(t, author.ID)
+	comment := newTestComment(t, author.ID, post.ID, "A comment to vote on")
+
+	insert := func(postID, commentID interface{}) error {
+		_, err := database.GetDB().Exec(`INSERT INTO votes (user_id, post_id, comment_id, vote_type) VALUES (?, ?, ?, 'like')`,
+			voter.ID, postID, commentID)
+		return err
+	}
+
+	if err := insert(post.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := insert(post.ID, nil); err == nil {
+		t.Fatal("a second vote on the same post was stored")
+	}
+	if err := insert(nil, comment.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := insert(nil, comment.ID); err == nil {
+		t.Fatal("a second vote on the same comment was stored")
+	}
+	if err := insert(nil, nil); err == nil {
+		t.Fatal("a vote without a target was stored")
+	}
+	if err := insert(post.ID, comment.ID); err == nil {
+		t.Fatal("a vote on both a post and a comment was stored")
+	}
+}
+
+func TestDailyVoteBudget(t *testing.T) {
+	if err := Settings.Set(SettingDailyVoteLimit, "2"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Settings.Delete(SettingDailyVoteLimit) })
+
+	author := newTestUser(t)
+	posts := []*Post{newTestPost(t, author.ID), newTestPost(t, author.ID), newTestPost(t, author.ID)}
+	comment := newTestComment(t, author.ID, posts[0].ID, "A comment to vote on")
+
+	// vote casts voteType on posts[i] and returns the action taken
+	vote := func(t *testing.T, userID, i int, voteType, mode string) (string, error) {
+		t.Helper()
+		result, err := TogglePostVote(userID, posts[i].ID, voteType, mode, nil)
+		if err != nil {
+			return "", err
+		}
+		return result.Action, nil
+	}
+	expectBudgetError := func(t *testing.T, err error) {
+		t.Helper()
+		var budgetErr *VoteBudgetError
+		if !errors.As(err, &budgetErr) {
+			t.Fatalf("err = %v, want a VoteBudgetError", err)
+		}
+		reset := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+		if budgetErr.Limit != 2 || !budgetErr.ResetAt.Equal(reset) {
+			t.Fatalf("budget error = %+v, want limit 2 resetting at %v", budgetErr, reset)
+		}
+	}
+
+	t.Run("new votes stop at the limit", func(t *testing.T) {
+		voter := newTestUser(t)
+		for i := 0; i < 2; i++ {
+			if action, err := vote(t, voter.ID, i, "like", VoteModeSet); err != nil || action != "added" {
+				t.Fatalf("vote %d = %q, %v", i, action, err)
+			}
+		}
+		_, err := vote(t, voter.ID, 2, "like", VoteModeSet)
+		expectBudgetError(t, err)
+		_, err = ToggleCommentVote(voter.ID, comment.ID, "like", VoteModeSet, nil)
+		expectBudgetError(t, err)
+		if n := rowCount(t, "votes", "user_id = ?", voter.ID); n != 2 {
+			t.Fatalf("%d votes stored, want 2", n)
+		}
+
+		// Changing and removing existing votes still work at the limit
+		if action, err := vote(t, voter.ID, 0, "dislike", VoteModeSet); err != nil || action != "changed" {
+			t.Fatalf("change = %q, %v", action, err)
+		}
+		if action, err := vote(t, voter.ID, 1, "", VoteModeRemove); err != nil || action != "removed" {
+			t.Fatalf("remove = %q, %v", action, err)
+		}
+	})
+
+	t.Run("moderators are exempt", func(t *testing.T) {
+		moderator := newTestUser(t)
+		if err := moderator.UpdateRole(RoleModerator); err != nil {
+			t.Fatal(err)
+		}
+		for i := range posts {
+			if _, err := vote(t, moderator.ID, i, "like", VoteModeSet); err != nil {
+				t.Fatalf("vote %d: %v", i, err)
+			}
+		}
+	})
+
+	t.Run("days start at midnight UTC", func(t *testing.T) {
+		midnight := time.Now().UTC().Truncate(24 * time.Hour)
+		tests := []struct {
+			name    string
+			at      time.Time // when the voter's first vote was cast
+			blocked bool      // whether a third vote is refused
+		}{
+			// A second before midnight UTC is yesterday, even written in a zone where it's already today
+			{"yesterday", midnight.Add(-time.Second).In(time.FixedZone("UTC+14", 14*60*60)), false},
+			// Midnight UTC itself is today, even written in a zone where it's still yesterday
+			{"today", midnight.In(time.FixedZone("UTC-12", -12*60*60)), true},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				voter := newTestUser(t)
+				if _, err := vote(t, voter.ID, 0, "like", VoteModeSet); err != nil {
+					t.Fatal(err)
+				}
+				var voteID int
+				if err := database.GetDB().QueryRow(`SELECT id FROM votes WHERE user_id = ?`, voter.ID).Scan(&voteID); err != nil {
+					t.Fatal(err)
+				}
+				setCreatedAt(t, "votes", voteID, tt.at)
+
+				if _, err := vote(t, voter.ID, 1, "like", VoteModeSet); err != nil {
+					t.Fatal(err)
+				}
+				_, err := vote(t, voter.ID, 2, "like", VoteModeSet)
+				if tt.blocked {
+					expectBudgetError(t, err)
+				} else if err != nil {
+					t.Fatalf("third vote: %v", err)
+				}
+			})
+		}
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		if err := Settings.Delete(SettingDailyVoteLimit); err != nil {
+			t.Fatal(err)
+		}
+		voter := newTestUser(t)
+		for i := range posts {
+			if _, err := vote(t, voter.ID, i, "like", VoteModeSet); err != nil {
+				t.Fatalf("vote %d: %v", i, err)
+			}
+		}
+	})
+}
+
+func TestRecountVotes(t *testing.T) {
+	author := newTestUser(t)
+	post := newTestPost(t, author.ID)
+	comment := newTestComment(t, author.ID, post.ID, "A comment with drifted counts")
+	votePost(t, post.ID, "like")
+	votePost(t, post.ID, "like")
+	votePost(t, post.ID, "dislike")
+	voteComment(t, comment.ID, "like")
+
+	db := database.GetDB()
+	if _, err := db.Exec(`UPDATE posts SET likes = 40, dislikes = 0 WHERE id = ?`, post.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE comments SET likes = 0, dislikes = 7 WHERE id = ?`, comment.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := RecountVotes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.PostsUpdated < 1 || result.CommentsUpdated < 1 {
+		t.Fatalf("result = %+v, want the drifted post and comment counted", result)
+	}
+
+	loadedPost, loadedComment := &Post{}, &Comment{}
+	if err := loadedPost.GetByID(post.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadedComment.GetByID(comment.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if loadedPost.Likes != 2 || loadedPost.Dislikes != 1 {
+		t.Fatalf("post counts = %d/%d, want 2/1", loadedPost.Likes, loadedPost.Dislikes)
+	}
+	if loadedComment.Likes != 1 || loadedComment.Dislikes != 0 {
+		t.Fatalf("comment counts = %d/%d, want 1/0", loadedComment.Likes, loadedComment.Dislikes)
+	}
+
+	// Once in sync, nothing changes
+	if result, err := RecountVotes(); err != nil || result != (VoteRecountResult{}) {
+		t.Fatalf("second recount = %+v, %v, want nothing updated", result, err)
+	}
+}
+
+func TestGetCommentVoteCounts(t *testing.T) {
+	author := newTestUser(t)
+	post := newTestPost(t, author.ID)
+
+	seeded := map[string][]string{
+		"liked":    {"like", "like", "like"},
+		"mixed":    {"like", "dislike", "dislike"},
+		"disliked": {"dislike"},
+		"unvoted":  nil,
+	}
+	ids := make(map[string]int, len(seeded))
+	want := make(map[int]VoteCounts)
+	for name, votes := range seeded {
+		comment := newTestComment(t, author.ID, post.ID, "A comment that is "+name)
+		ids[name] = comment.ID
+
+		var counts VoteCounts
+		for _, vote := range votes {
+			voteComment(t, comment.ID, vote)
+			if vote == "like" {
+				counts.Likes++
+			} else {
+				counts.Dislikes++
+			}
+		}
+		if len(votes) > 0 {
+			want[comment.ID] = counts
+		}
+	}
+
+	// Drifted cached columns don't affect the counts
+	if _, err := database.GetDB().Exec(`UPDATE comments SET likes = 50, dislikes = 50 WHERE id = ?`, ids["liked"]); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := []int{ids["liked"], ids["mixed"], ids["disliked"], ids["unvoted"], 999999}
+	var got map[int]VoteCounts
+	queries := countQueries(t, func() {
+		var err error
+		got, err = GetCommentVoteCounts(batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	if queries != 1 {
+		t.Errorf("GetCommentVoteCounts ran %d queries, want 1", queries)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("counts = %v, want %v", got, want)
+	}
+
+	if got, err := GetCommentVoteCounts(nil); err != nil || len(got) != 0 {
+		t.Errorf("GetCommentVoteCounts(nil) = %v, %v, want an empty map", got, err)
+	}
+}
+
+func TestRecountVotesAfterVoteRowsChange(t *testing.T) {
+	author := newTestUser(t)
+	post := newTestPost(t, author.ID)
+	comment := newTestComment(t, author.ID, post.ID, "A comment whose votes are edited by hand")
+	votePost(t, post.ID, "like")
+	votePost(t, post.ID, "dislike")
+	voteComment(t, comment.ID, "like")
+	voteComment(t, comment.ID, "like")
+
+	// Edit the votes table directly, as a manual fix would; the cached columns don't follow
+	db := database.GetDB()
+	if _, err := db.Exec(`DELETE FROM votes WHERE post_id = ? AND vote_type = 'dislike'`, post.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE votes SET vote_type = 'dislike' WHERE comment_id = ?`, comment.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	loadedPost, loadedComment := &Post{}, &Comment{}
+	if err := loadedPost.GetByID(post.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if loadedPost.Likes != 1 || loadedPost.Dislikes != 1 {
+		t.Fatalf("post counts before recounting = %d/%d, want the stale 1/1", loadedPost.Likes, loadedPost.Dislikes)
+	}
+
+	result, err := RecountVotes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.PostsUpdated < 1 || result.CommentsUpdated < 1 {
+		t.Fatalf("result = %+v, want the drifted post and comment counted", result)
+	}
+
+	if err := loadedPost.GetByID(post.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadedComment.GetByID(comment.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if loadedPost.Likes != 1 || loadedPost.Dislikes != 0 {
+		t.Errorf("post counts = %d/%d, want 1/0", loadedPost.Likes, loadedPost.Dislikes)
+	}
+	if loadedComment.Likes != 0 || loadedComment.Dislikes != 2 {
+		t.Errorf("comment counts = %d/%d, want 0/2", loadedComment.Likes, loadedComment.Dislikes)
+	}
+}
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"forum/database"
+)
+
+// MaxWebhookFailures is how many deliveries in a row may fail before an endpoint is disabled
+const MaxWebhookFailures = 5
+
+// Webhook is an endpoint that receives event notifications
+type Webhook struct {
+	ID             int        `json:"id"`
+	URL            string     `json:"url"`
+	Secret         string     `json:"-"`
+	Events         []string   `json:"events"`
+	Active         bool       `json:"active"`
+	FailureCount   int        `json:"failure_count"`
+	LastDeliveryAt *time.Time `json:"last_delivery_at"`
+	LastError      string     `json:"last_error"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Subscribes reports whether the webhook wants an event
+func (wh *Webhook) Subscribes(event string) bool {
+	for _, name := range wh.Events {
+		if name == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Create stores a new webhook
+func (wh *Webhook) Create() error {
+	query := `
+		INSERT INTO webhooks (url, secret, events, active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	now := time.Now()
+	result, err := database.GetDB().Exec(query, wh.URL, wh.Secret, strings.Join(wh.Events, ","), wh.Active, now, now)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	wh.ID = int(id)
+	wh.CreatedAt = now
+	wh.UpdatedAt = now
+	return nil
+}
+
+// Update saves the webhook's URL, secret, events and active flag.
+// Re-enabling an endpoint clears its failure count.
+func (wh *Webhook) Update() error {
+	query := `
+		UPDATE webhooks
+		SET url = ?, secret = ?, events = ?, active = ?,
+		    failure_count = CASE WHEN ? THEN 0 ELSE failure_count END,
+		    updated_at = ?
+		WHERE id = ?
+	`
+	wh.UpdatedAt = time.Now()
+	_, err := database.GetDB().Exec(query, wh.URL, wh.Secret, strings.Join(wh.Events, ","), wh.Active,
+		wh.Active, wh.UpdatedAt, wh.ID)
+	if err == nil && wh.Active {
+		wh.FailureCount = 0
+	}
+	return err
+}
+
+// GetWebhookByID loads a single webhook
+func GetWebhookByID(id int) (*Webhook, error) {
+	row := database.GetDB().QueryRow(webhookSelect+` WHERE id = ?`, id)
+	wh, err := scanWebhook(row)
+	if err != nil {
+		return nil, err
+	}
+	return wh, nil
+}
+
+// GetWebhooks returns every webhook, oldest first
+func GetWebhooks() ([]Webhook, error) {
+	return queryWebhooks(webhookSelect + ` ORDER BY id ASC`)
+}
+
+// GetActiveWebhooksForEvent returns the enabled webhooks subscribed to an event
+func GetActiveWebhooksForEvent(event string) ([]Webhook, error) {
+	all, err := queryWebhooks(webhookSelect + ` WHERE active = 1 ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := []Webhook{}
+	for _, wh := range all {
+		if wh.Subscribes(event) {
+			webhooks = append(webhooks, wh)
+		}
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook; deleted is false when it didn't exist
+func DeleteWebhook(id int) (deleted bool, err error) {
+	result, err := database.GetDB().Exec(`DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RecordWebhookSuccess notes a delivered event and resets the failure count
+func RecordWebhookSuccess(id int) error {
+	query := `UPDATE webhooks SET failure_count = 0, last_error = '', last_delivery_at = ? WHERE id = ?`
+	_, err := database.GetDB().Exec(query, time.Now(), id)
+	return err
+}
+
+// RecordWebhookFailure notes an event that couldn't be delivered, disabling the
+// webhook once MaxWebhookFailures deliveries in a row have failed
+func RecordWebhookFailure(id int, reason string) (disabled bool, err error) {
+	query := `
+		UPDATE webhooks
+		SET failure_count = failure_count + 1,
+		    last_error = ?,
+		    active = CASE WHEN failure_count + 1 >= ? THEN 0 ELSE active END
+		WHERE id = ?
+	`
+	if _, err := database.GetDB().Exec(query, reason, MaxWebhookFailures, id); err != nil {
+		return false, err
+	}
+
+	var active bool
+	if err := database.GetDB().QueryRow(`SELECT active FROM webhooks WHERE id = ?`, id).Scan(&active); err != nil {
+		return false, err
+	}
+	return !active, nil
+}
+
+const webhookSelect = `
+	SELECT id, url, secret, events, active, failure_count, last_delivery_at, last_error, created_at, updated_at
+	FROM webhooks`
+
+func scanWebhook(row rowScanner) (*Webhook, error) {
+	var wh Webhook
+	var events string
+	var lastDelivery sql.NullTime
+	if err := row.Scan(&wh.ID, &wh.URL, &wh.Secret, &events, &wh.Active, &wh.FailureCount,
+		&lastDelivery, &wh.LastError, &wh.CreatedAt, &wh.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	wh.Events = []string{}
+	if events != "" {
+		wh.Events = strings.Split(events, ",")
+	}
+	wh.LastDeliveryAt = nullTimePtr(lastDelivery)
+	return &wh, nil
+}
+
+func queryWebhooks(query string) ([]Webhook, error) {
+	webhooks := []Webhook{}
+	rows, err := database.GetDB().Query(query)
+	if err != nil {
+		return webhooks, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		wh, err := scanWebhook(rows)
+		if err != nil {
+			return webhooks, err
+		}
+		webhooks = append(webhooks, *wh)
+	}
+	return webhooks, rows.Err()
+}
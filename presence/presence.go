@@ -0,0 +1,151 @@
+package presence
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Timing for viewer heartbeats
+const (
+	HeartbeatInterval = 15 * time.Second // how often clients should re-announce themselves
+	ViewerTTL         = 45 * time.Second // viewers not heard from for this long have left
+	sweepInterval     = 10 * time.Second
+)
+
+// post holds the viewers of one post. changed is closed, and replaced,
+// whenever the number of viewers changes so waiters can wake up.
+type post struct {
+	viewers map[string]time.Time // viewer ID -> last heartbeat
+	changed chan struct{}
+}
+
+var (
+	mu    sync.Mutex
+	posts = make(map[int]*post)
+)
+
+// Start removes viewers that stopped sending heartbeats, in the background.
+// Call it once at startup.
+func Start() {
+	go func() {
+		ticker := time.NewTicker(sweepInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			sweep(time.Now())
+		}
+	}()
+}
+
+// Join records that a viewer has a post open, or refreshes their heartbeat when
+// they already do, so reconnecting with the same viewer ID doesn't count twice.
+// It returns the number of viewers.
+func Join(postID int, viewerID string) int {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p := posts[postID]
+	if p == nil {
+		p = &post{viewers: make(map[string]time.Time), changed: make(chan struct{})}
+		posts[postID] = p
+	}
+	_, known := p.viewers[viewerID]
+	p.viewers[viewerID] = time.Now()
+	if !known {
+		p.notifyLocked()
+	}
+	return len(p.viewers)
+}
+
+// Leave records that a viewer closed a post and returns the number left
+func Leave(postID int, viewerID string) int {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p := posts[postID]
+	if p == nil {
+		return 0
+	}
+	if _, known := p.viewers[viewerID]; known {
+		delete(p.viewers, viewerID)
+		p.notifyLocked()
+	}
+	count := len(p.viewers)
+	if count == 0 {
+		close(p.changed)
+		delete(posts, postID)
+	}
+	return count
+}
+
+// Count returns how many viewers have a post open
+func Count(postID int) int {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if p := posts[postID]; p != nil {
+		return len(p.viewers)
+	}
+	return 0
+}
+
+// Wait blocks until the number of viewers of a post differs from known, the
+// timeout passes or ctx is done, and returns the current number
+func Wait(ctx context.Context, postID, known int, timeout time.Duration) int {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		mu.Lock()
+		p := posts[postID]
+		if p == nil {
+			// Keep an empty entry so there is something to wait on; the sweep removes it
+			p = &post{viewers: make(map[string]time.Time), changed: make(chan struct{})}
+			posts[postID] = p
+		}
+		count, changed := len(p.viewers), p.changed
+		mu.Unlock()
+
+		if count != known {
+			return count
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return Count(postID)
+		case <-ctx.Done():
+			return Count(postID)
+		}
+	}
+}
+
+// sweep drops viewers whose last heartbeat is older than ViewerTTL, and posts
+// left with no viewers. Waiters are woken either way; those on a dropped post
+// go on to wait on a fresh entry.
+func sweep(now time.Time) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for postID, p := range posts {
+		removed := false
+		for viewerID, seen := range p.viewers {
+			if now.Sub(seen) > ViewerTTL {
+				delete(p.viewers, viewerID)
+				removed = true
+			}
+		}
+		if len(p.viewers) == 0 {
+			close(p.changed)
+			delete(posts, postID)
+		} else if removed {
+			p.notifyLocked()
+		}
+	}
+}
+
+// notifyLocked wakes everyone waiting on the post. The lock must be held.
+func (p *post) notifyLocked() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
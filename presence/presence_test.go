@@ -0,0 +1,159 @@
+package presence
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestJoinAndLeaveChangeTheCount(t *testing.T) {
+	const postID = 1
+
+	if got := Count(postID); got != 0 {
+		t.Fatalf("Count before anyone joined = %d, want 0", got)
+	}
+
+	if got := Join(postID, "a"); got != 1 {
+		t.Errorf("Join(a) = %d, want 1", got)
+	}
+	if got := Join(postID, "b"); got != 2 {
+		t.Errorf("Join(b) = %d, want 2", got)
+	}
+	if got := Count(postID); got != 2 {
+		t.Errorf("Count = %d, want 2", got)
+	}
+
+	// A reconnect with the same viewer ID is a heartbeat, not a new viewer
+	if got := Join(postID, "a"); got != 2 {
+		t.Errorf("Join(a) again = %d, want 2", got)
+	}
+
+	if got := Leave(postID, "a"); got != 1 {
+		t.Errorf("Leave(a) = %d, want 1", got)
+	}
+	if got := Leave(postID, "a"); got != 1 {
+		t.Errorf("Leave(a) again = %d, want 1", got)
+	}
+	if got := Leave(postID, "b"); got != 0 {
+		t.Errorf("Leave(b) = %d, want 0", got)
+	}
+	if got := Count(postID); got != 0 {
+		t.Errorf("Count after everyone left = %d, want 0", got)
+	}
+	if got := Leave(postID, "b"); got != 0 {
+		t.Errorf("Leave on an empty post = %d, want 0", got)
+	}
+}
+
+func TestCountsArePerPost(t *testing.T) {
+	Join(2, "a")
+	Join(3, "a")
+	Join(3, "b")
+	t.Cleanup(func() {
+		Leave(2, "a")
+		Leave(3, "a")
+		Leave(3, "b")
+	})
+
+	if got := Count(2); got != 1 {
+		t.Errorf("Count(2) = %d, want 1", got)
+	}
+	if got := Count(3); got != 2 {
+		t.Errorf("Count(3) = %d, want 2", got)
+	}
+}
+
+func TestSweepDropsStaleViewers(t *testing.T) {
+	const postID = 4
+
+	Join(postID, "stale")
+	Join(postID, "fresh")
+	t.Cleanup(func() { Leave(postID, "fresh") })
+
+	mu.Lock()
+	posts[postID].viewers["stale"] = time.Now().Add(-ViewerTTL - time.Second)
+	mu.Unlock()
+
+	sweep(time.Now())
+	if got := Count(postID); got != 1 {
+		t.Errorf("Count after sweep = %d, want 1", got)
+	}
+
+	// Once the last viewer goes quiet the post is forgotten entirely
+	sweep(time.Now().Add(ViewerTTL + time.Second))
+	if got := Count(postID); got != 0 {
+		t.Errorf("Count after sweeping every viewer = %d, want 0", got)
+	}
+	mu.Lock()
+	_, tracked := posts[postID]
+	mu.Unlock()
+	if tracked {
+		t.Error("post with no viewers is still tracked after the sweep")
+	}
+}
+
+func TestWait(t *testing.T) {
+	const postID = 5
+
+	t.Run("returns at once when the count already differs", func(t *testing.T) {
+		Join(postID, "a")
+		t.Cleanup(func() { Leave(postID, "a") })
+
+		if got := Wait(context.Background(), postID, 0, time.Minute); got != 1 {
+			t.Errorf("Wait = %d, want 1", got)
+		}
+	})
+
+	t.Run("wakes on join and leave", func(t *testing.T) {
+		done := make(chan int)
+		go func() { done <- Wait(context.Background(), postID, 0, time.Minute) }()
+
+		time.Sleep(20 * time.Millisecond)
+		Join(postID, "b")
+		select {
+		case got := <-done:
+			if got != 1 {
+				t.Errorf("Wait after join = %d, want 1", got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Wait did not return after a viewer joined")
+		}
+
+		go func() { done <- Wait(context.Background(), postID, 1, time.Minute) }()
+
+		time.Sleep(20 * time.Millisecond)
+		Leave(postID, "b")
+		select {
+		case got := <-done:
+			if got != 0 {
+				t.Errorf("Wait after leave = %d, want 0", got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Wait did not return after the last viewer left")
+		}
+	})
+
+	t.Run("gives up after the timeout", func(t *testing.T) {
+		start := time.Now()
+		if got := Wait(context.Background(), postID, 0, 50*time.Millisecond); got != 0 {
+			t.Errorf("Wait = %d, want 0", got)
+		}
+		if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+			t.Errorf("Wait returned after %v, before its timeout", elapsed)
+		}
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan int)
+		go func() { done <- Wait(ctx, postID, 0, time.Minute) }()
+
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Wait did not return after its context was cancelled")
+		}
+	})
+}
@@ -25,6 +25,9 @@ func SetupRoutes() *http.ServeMux {
 	handler := apiHandler()
 	
 	// Apply middlewares from innermost to outermost
+	// (Maintenance and CSRF sit inside OptionalAuth so they can see the session)
+	handler = middleware.Maintenance(handler)
+	handler = middleware.CSRF(handler)
 	handler = middleware.OptionalAuth(handler)
 	
 	// RateLimit returns http.Handler, so we need to convert back to HandlerFunc
@@ -38,33 +41,39 @@ func SetupRoutes() *http.ServeMux {
 	// Continue with remaining middlewares
 	handler = middleware.LogRequests(handlerFunc)
 	handler = middleware.Recovery(handler)
+
+	// Compress API responses (outermost, so the logger sees the real status)
+	handler = middleware.Gzip(handler)
 	
 	mux.Handle("/api/", handler)
 
 	// Static files (CSS, JS, images, etc.)
-	mux.Handle("/static/",
+	mux.Handle("/static/", middleware.Gzip(
 		http.StripPrefix("/static/",
-			http.FileServer(http.Dir(StaticDir)),
-		),
-	)
+			middleware.ServeStatic(StaticDir),
+		).ServeHTTP,
+	))
 
-	// Uploaded files (avatars, etc.)
+	// Uploaded files (avatars, etc.), cached long-term and never listed
 	mux.Handle("/uploads/",
 		http.StripPrefix("/uploads/",
-			http.FileServer(http.Dir(UploadsDir)),
+			middleware.ServeUploads(UploadsDir),
 		),
 	)
 
-	// SPA fallback for all other routes (except API & static)
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	// Uploads are served uncompressed since images are already compressed
+
+	// SPA fallback for all other routes (except API & static), always revalidated
+	serveIndex := middleware.ServeIndex(IndexFile)
+	mux.HandleFunc("/", middleware.Gzip(func(w http.ResponseWriter, r *http.Request) {
 		if strings.HasPrefix(r.URL.Path, "/api/") ||
 			strings.HasPrefix(r.URL.Path, "/static/") ||
 			strings.HasPrefix(r.URL.Path, "/uploads/") {
 			http.NotFound(w, r)
 			return
 		}
-		http.ServeFile(w, r, IndexFile)
-	})
+		serveIndex(w, r)
+	}))
 
 	return mux
 }
@@ -151,10 +160,14 @@ type Route struct {
 
 // List of all API routes
 var apiRoutes = []Route{
+	// Health
+	{Method: http.MethodGet, Path: "/health", Handler: controllers.HealthController},
+
 	// Auth routes
 	{Method: http.MethodPost, Path: "/auth/register", Handler: controllers.RegisterController},
+	{Method: http.MethodGet, Path: "/auth/registration", Handler: controllers.RegistrationStatusController},
 	{Method: http.MethodPost, Path: "/auth/login", Handler: controllers.LoginController},
-	{Method: http.MethodPost, Path: "/auth/logout", Handler: controllers.LogoutController, RequiresAuth: true},
+	{Method: http.MethodPost, Path: "/auth/logout", Handler: controllers.LogoutController}, // banned users must still be able to log out
 	{Method: http.MethodGet, Path: "/auth/me", Handler: controllers.MeController, RequiresAuth: true},
 	{Method: http.MethodPost, Path: "/auth/refresh", Handler: controllers.RefreshSessionController},
 	{Method: http.MethodGet, Path: "/auth/check-username", Handler: controllers.CheckUsernameController},
@@ -167,30 +180,97 @@ var apiRoutes = []Route{
 	{Method: http.MethodPut, Path: "/posts/{id}", Handler: middleware.RequireAuth(controllers.UpdatePostController), RequiresAuth: true},
 	{Method: http.MethodDelete, Path: "/posts/{id}", Handler: middleware.RequireAuth(controllers.DeletePostController), RequiresAuth: true},
 	{Method: http.MethodPost, Path: "/posts/{id}/vote", Handler: middleware.RequireAuth(controllers.VotePostController), RequiresAuth: true},
+	{Method: http.MethodPost, Path: "/posts/{id}/accept/{id}", Handler: middleware.RequireAuth(controllers.AcceptAnswerController), RequiresAuth: true},
+	{Method: http.MethodDelete, Path: "/posts/{id}/accept/{id}", Handler: middleware.RequireAuth(controllers.UnacceptAnswerController), RequiresAuth: true},
+	{Method: http.MethodPut, Path: "/posts/{id}/pin", Handler: middleware.RequireModerator(controllers.PinPostController), RequiresAuth: true},
+	{Method: http.MethodPut, Path: "/posts/{id}/unpin", Handler: middleware.RequireModerator(controllers.UnpinPostController), RequiresAuth: true},
+	{Method: http.MethodPost, Path: "/posts/{id}/reactions", Handler: middleware.RequireAuth(controllers.AddPostReactionController), RequiresAuth: true},
+	{Method: http.MethodDelete, Path: "/posts/{id}/reactions", Handler: middleware.RequireAuth(controllers.RemovePostReactionController), RequiresAuth: true},
+	{Method: http.MethodGet, Path: "/posts/{id}/presence", Handler: controllers.GetPostPresenceController},
+	{Method: http.MethodPost, Path: "/posts/{id}/presence", Handler: controllers.JoinPostPresenceController},
+	{Method: http.MethodDelete, Path: "/posts/{id}/presence", Handler: controllers.LeavePostPresenceController},
+	{Method: http.MethodPost, Path: "/posts/{id}/attachments", Handler: middleware.RequireAuth(controllers.AddPostAttachmentController), RequiresAuth: true},
 
 	// Post comments
 	{Method: http.MethodGet, Path: "/posts/{id}/comments", Handler: middleware.OptionalAuth(controllers.GetCommentsController)},
 	{Method: http.MethodPost, Path: "/posts/{id}/comments", Handler: middleware.RequireAuth(controllers.CreateCommentController), RequiresAuth: true},
+	{Method: http.MethodGet, Path: "/posts/{id}/comments/search", Handler: controllers.SearchPostCommentsController},
+
+	// Search
+	{Method: http.MethodGet, Path: "/search", Handler: controllers.SearchController},
 
 	// Comments
 	{Method: http.MethodPost, Path: "/comments", Handler: middleware.RequireAuth(controllers.CreateCommentController), RequiresAuth: true},
 	{Method: http.MethodGet, Path: "/comments/{id}", Handler: middleware.OptionalAuth(controllers.GetCommentController)},
+	{Method: http.MethodGet, Path: "/comments/{id}/context", Handler: middleware.OptionalAuth(controllers.GetCommentContextController)},
 	{Method: http.MethodPut, Path: "/comments/{id}", Handler: middleware.RequireAuth(controllers.UpdateCommentController), RequiresAuth: true},
 	{Method: http.MethodDelete, Path: "/comments/{id}", Handler: middleware.RequireAuth(controllers.DeleteCommentController), RequiresAuth: true},
 	{Method: http.MethodPost, Path: "/comments/{id}/vote", Handler: middleware.RequireAuth(controllers.VoteCommentController), RequiresAuth: true},
+	{Method: http.MethodPost, Path: "/votes/status", Handler: middleware.RequireAuth(controllers.GetVoteStatusController), RequiresAuth: true},
+	{Method: http.MethodPost, Path: "/votes/counts", Handler: controllers.GetVoteCountsController},
+	{Method: http.MethodPost, Path: "/comments/{id}/report", Handler: middleware.RequireAuth(controllers.ReportCommentController), RequiresAuth: true},
+	{Method: http.MethodPost, Path: "/comments/{id}/reactions", Handler: middleware.RequireAuth(controllers.AddCommentReactionController), RequiresAuth: true},
+	{Method: http.MethodDelete, Path: "/comments/{id}/reactions", Handler: middleware.RequireAuth(controllers.RemoveCommentReactionController), RequiresAuth: true},
+
+	// Notifications
+	{Method: http.MethodGet, Path: "/notifications", Handler: middleware.RequireAuth(controllers.GetNotificationsController), RequiresAuth: true},
+	{Method: http.MethodGet, Path: "/notifications/unread-count", Handler: middleware.RequireAuth(controllers.GetUnreadNotificationCountController), RequiresAuth: true},
+	{Method: http.MethodPost, Path: "/notifications/read-all", Handler: middleware.RequireAuth(controllers.MarkAllNotificationsReadController), RequiresAuth: true},
+	{Method: http.MethodGet, Path: "/notifications/preferences", Handler: middleware.RequireAuth(controllers.GetNotificationPreferencesController), RequiresAuth: true},
+	{Method: http.MethodPut, Path: "/notifications/preferences", Handler: middleware.RequireAuth(controllers.UpdateNotificationPreferencesController), RequiresAuth: true},
+	{Method: http.MethodPost, Path: "/notifications/{id}/read", Handler: middleware.RequireAuth(controllers.MarkNotificationReadController), RequiresAuth: true},
 
 	// Users
+	{Method: http.MethodGet, Path: "/users/me/liked-posts", Handler: controllers.GetLikedPostsController, RequiresAuth: true},
+	{Method: http.MethodGet, Path: "/users/me/liked-comments", Handler: controllers.GetLikedCommentsController, RequiresAuth: true},
+	{Method: http.MethodGet, Path: "/users/me/votes", Handler: controllers.GetVoteHistoryController, RequiresAuth: true},
+	{Method: http.MethodGet, Path: "/users/me/privacy", Handler: middleware.RequireAuth(controllers.GetPrivacySettingsController), RequiresAuth: true},
+	{Method: http.MethodPut, Path: "/users/me/privacy", Handler: middleware.RequireAuth(controllers.UpdatePrivacySettingsController), RequiresAuth: true},
 	{Method: http.MethodGet, Path: "/users/{id}", Handler: controllers.GetUserProfileController},
 	{Method: http.MethodPut, Path: "/users/{id}", Handler: middleware.RequireAuth(controllers.UpdateUserProfileController), RequiresAuth: true},
 	{Method: http.MethodPost, Path: "/users/{id}/avatar", Handler: middleware.RequireAuth(controllers.UploadAvatarController), RequiresAuth: true},
 	{Method: http.MethodDelete, Path: "/users/{id}/avatar", Handler: middleware.RequireAuth(controllers.DeleteAvatarController), RequiresAuth: true},
 	{Method: http.MethodGet, Path: "/users/{id}/posts", Handler: controllers.GetUserPostsController},
 	{Method: http.MethodGet, Path: "/users/{id}/comments", Handler: controllers.GetUserCommentsController},
+	{Method: http.MethodGet, Path: "/users/{id}/commented-posts", Handler: controllers.GetUserCommentedPostsController},
 	{Method: http.MethodGet, Path: "/users/{id}/stats", Handler: controllers.GetUserStatsController},
+	{Method: http.MethodGet, Path: "/users/{id}/reputation", Handler: controllers.GetUserReputationController},
+	{Method: http.MethodGet, Path: "/users/{id}/export", Handler: middleware.RequireAuth(controllers.ExportUserDataController), RequiresAuth: true},
+	{Method: http.MethodPost, Path: "/users/{id}/ban", Handler: middleware.RequireModerator(controllers.BanUserController), RequiresAuth: true},
+	{Method: http.MethodPost, Path: "/users/{id}/unban", Handler: middleware.RequireModerator(controllers.UnbanUserController), RequiresAuth: true},
 
 	// Categories
-	{Method: http.MethodGet, Path: "/categories", Handler: controllers.GetCategoriesController},
+	{Method: http.MethodGet, Path: "/categories", Handler: middleware.OptionalAuth(controllers.GetCategoriesController)},
+	{Method: http.MethodGet, Path: "/categories/popular", Handler: controllers.GetPopularCategoriesController},
 	{Method: http.MethodGet, Path: "/categories/{id}", Handler: controllers.GetCategoryController},
+	{Method: http.MethodGet, Path: "/categories/{id}/stats", Handler: controllers.GetCategoryStatsController},
+	{Method: http.MethodGet, Path: "/categories/{id}/posts", Handler: middleware.OptionalAuth(controllers.GetCategoryPostsController)},
+	{Method: http.MethodGet, Path: "/categories/slug/{slug}", Handler: controllers.GetCategoryBySlugController},
+	{Method: http.MethodPost, Path: "/categories", Handler: middleware.RequireAdmin(controllers.CreateCategoryController), RequiresAuth: true},
+	{Method: http.MethodPut, Path: "/categories/{id}", Handler: middleware.RequireAdmin(controllers.UpdateCategoryController), RequiresAuth: true},
+	{Method: http.MethodDelete, Path: "/categories/{id}", Handler: middleware.RequireAdmin(controllers.DeleteCategoryController), RequiresAuth: true},
+
+	// Admin
+	{Method: http.MethodGet, Path: "/admin/maintenance", Handler: middleware.RequireAdmin(controllers.GetMaintenanceController), RequiresAuth: true},
+	{Method: http.MethodPut, Path: "/admin/maintenance", Handler: middleware.RequireAdmin(controllers.SetMaintenanceController), RequiresAuth: true},
+	{Method: http.MethodPost, Path: "/admin/maintenance/recount-votes", Handler: middleware.RequireAdmin(controllers.RecountVotesController), RequiresAuth: true},
+	{Method: http.MethodPost, Path: "/admin/maintenance/recount-category-posts", Handler: middleware.RequireAdmin(controllers.RecountCategoryPostsController), RequiresAuth: true},
+	{Method: http.MethodPost, Path: "/admin/maintenance/clear-category-cache", Handler: middleware.RequireAdmin(controllers.ClearCategoryCacheController), RequiresAuth: true},
+	{Method: http.MethodGet, Path: "/admin/invites", Handler: middleware.RequireAdmin(controllers.GetInvitesController), RequiresAuth: true},
+	{Method: http.MethodPost, Path: "/admin/invites", Handler: middleware.RequireAdmin(controllers.CreateInviteController), RequiresAuth: true},
+	{Method: http.MethodDelete, Path: "/admin/invites/{id}", Handler: middleware.RequireAdmin(controllers.DeleteInviteController), RequiresAuth: true},
+	{Method: http.MethodGet, Path: "/admin/webhooks", Handler: middleware.RequireAdmin(controllers.GetWebhooksController), RequiresAuth: true},
+	{Method: http.MethodPost, Path: "/admin/webhooks", Handler: middleware.RequireAdmin(controllers.CreateWebhookController), RequiresAuth: true},
+	{Method: http.MethodPut, Path: "/admin/webhooks/{id}", Handler: middleware.RequireAdmin(controllers.UpdateWebhookController), RequiresAuth: true},
+	{Method: http.MethodDelete, Path: "/admin/webhooks/{id}", Handler: middleware.RequireAdmin(controllers.DeleteWebhookController), RequiresAuth: true},
+	{Method: http.MethodPut, Path: "/admin/categories/order", Handler: middleware.RequireAdmin(controllers.ReorderCategoriesController), RequiresAuth: true},
+	{Method: http.MethodPost, Path: "/admin/categories/{id}/archive", Handler: middleware.RequireAdmin(controllers.ArchiveCategoryController), RequiresAuth: true},
+	{Method: http.MethodPost, Path: "/admin/categories/{id}/unarchive", Handler: middleware.RequireAdmin(controllers.UnarchiveCategoryController), RequiresAuth: true},
+	{Method: http.MethodGet, Path: "/admin/settings", Handler: middleware.RequireAdmin(controllers.GetSettingsController), RequiresAuth: true},
+	{Method: http.MethodPut, Path: "/admin/settings", Handler: middleware.RequireAdmin(controllers.UpdateSettingsController), RequiresAuth: true},
+	{Method: http.MethodGet, Path: "/admin/reports", Handler: middleware.RequireModerator(controllers.GetReportsController), RequiresAuth: true},
+	{Method: http.MethodPost, Path: "/admin/reports/{id}/resolve", Handler: middleware.RequireModerator(controllers.ResolveReportController), RequiresAuth: true},
+	{Method: http.MethodGet, Path: "/admin/moderation-log", Handler: middleware.RequireModerator(controllers.GetModerationLogController), RequiresAuth: true},
 }
 
 // apiHandler returns the main API handler that routes all /api/* requests
@@ -218,7 +298,7 @@ func apiHandler() http.HandlerFunc {
 	}
 }
 
-// matchRoute matches dynamic paths with {id} placeholder
+// matchRoute matches dynamic paths with {id} (numeric) and other {name} placeholders
 func matchRoute(actual, template string) bool {
 	actualParts := strings.Split(strings.Trim(actual, "/"), "/")
 	templateParts := strings.Split(strings.Trim(template, "/"), "/")
@@ -235,6 +315,13 @@ func matchRoute(actual, template string) bool {
 			}
 			continue
 		}
+		if strings.HasPrefix(templateParts[i], "{") && strings.HasSuffix(templateParts[i], "}") {
+			// Other placeholders (like {slug}) match any non-empty segment
+			if actualParts[i] == "" {
+				return false
+			}
+			continue
+		}
 		if templateParts[i] != actualParts[i] {
 			return false
 		}
@@ -245,8 +332,12 @@ func matchRoute(actual, template string) bool {
 // GetRoutesList returns a list of all available routes for debugging
 func GetRoutesList() []string {
 	return []string{
+		"GET    /api/health",
+		"",
+
 		// Auth routes
 		"POST   /api/auth/register",
+		"GET    /api/auth/registration",
 		"POST   /api/auth/login",
 		"POST   /api/auth/logout",
 		"GET    /api/auth/me",
@@ -262,17 +353,47 @@ func GetRoutesList() []string {
 		"PUT    /api/posts/{id}",
 		"DELETE /api/posts/{id}",
 		"POST   /api/posts/{id}/vote",
+		"POST   /api/posts/{id}/accept/{commentID}",
+		"DELETE /api/posts/{id}/accept/{commentID}",
+		"PUT    /api/posts/{id}/pin",
+		"PUT    /api/posts/{id}/unpin",
+		"POST   /api/posts/{id}/reactions",
+		"DELETE /api/posts/{id}/reactions",
+		"GET    /api/posts/{id}/presence",
+		"POST   /api/posts/{id}/presence",
+		"DELETE /api/posts/{id}/presence",
+		"POST   /api/posts/{id}/attachments",
 		"",
 		"GET    /api/posts/{id}/comments",
 		"POST   /api/posts/{id}/comments",
+		"GET    /api/posts/{id}/comments/search",
+		"",
+
+		// Search routes
+		"GET    /api/search",
 		"",
 
 		// Comment routes
 		"POST   /api/comments",
 		"GET    /api/comments/{id}",
+		"GET    /api/comments/{id}/context",
 		"PUT    /api/comments/{id}",
 		"DELETE /api/comments/{id}",
 		"POST   /api/comments/{id}/vote",
+		"POST   /api/votes/status",
+		"POST   /api/votes/counts",
+		"POST   /api/comments/{id}/report",
+		"POST   /api/comments/{id}/reactions",
+		"DELETE /api/comments/{id}/reactions",
+		"",
+
+		// Notification routes
+		"GET    /api/notifications",
+		"GET    /api/notifications/unread-count",
+		"POST   /api/notifications/read-all",
+		"GET    /api/notifications/preferences",
+		"PUT    /api/notifications/preferences",
+		"POST   /api/notifications/{id}/read",
 		"",
 
 		// User routes
@@ -280,14 +401,54 @@ func GetRoutesList() []string {
 		"PUT    /api/users/{id}",
 		"POST   /api/users/{id}/avatar",
 		"DELETE /api/users/{id}/avatar",
+		"GET    /api/users/me/liked-posts",
+		"GET    /api/users/me/liked-comments",
+		"GET    /api/users/me/votes",
+		"GET    /api/users/me/privacy",
+		"PUT    /api/users/me/privacy",
 		"GET    /api/users/{id}/posts",
 		"GET    /api/users/{id}/comments",
+		"GET    /api/users/{id}/commented-posts",
 		"GET    /api/users/{id}/stats",
+		"GET    /api/users/{id}/reputation",
+		"GET    /api/users/{id}/export",
+		"POST   /api/users/{id}/ban",
+		"POST   /api/users/{id}/unban",
 		"",
 
 		// Category routes
 		"GET    /api/categories",
+		"GET    /api/categories/popular",
 		"GET    /api/categories/{id}",
+		"GET    /api/categories/{id}/stats",
+		"GET    /api/categories/{id}/posts",
+		"GET    /api/categories/slug/{slug}",
+		"POST   /api/categories",
+		"PUT    /api/categories/{id}",
+		"DELETE /api/categories/{id}",
+		"",
+
+		// Admin routes
+		"GET    /api/admin/maintenance",
+		"PUT    /api/admin/maintenance",
+		"POST   /api/admin/maintenance/recount-votes",
+		"POST   /api/admin/maintenance/recount-category-posts",
+		"POST   /api/admin/maintenance/clear-category-cache",
+		"GET    /api/admin/invites",
+		"POST   /api/admin/invites",
+		"DELETE /api/admin/invites/{id}",
+		"GET    /api/admin/webhooks",
+		"POST   /api/admin/webhooks",
+		"PUT    /api/admin/webhooks/{id}",
+		"DELETE /api/admin/webhooks/{id}",
+		"PUT    /api/admin/categories/order",
+		"POST   /api/admin/categories/{id}/archive",
+		"POST   /api/admin/categories/{id}/unarchive",
+		"GET    /api/admin/settings",
+		"PUT    /api/admin/settings",
+		"GET    /api/admin/reports",
+		"POST   /api/admin/reports/{id}/resolve",
+		"GET    /api/admin/moderation-log",
 		"",
 
 		// Static & Uploads (optional)
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+const (
+	// CSRFCookieName is the cookie holding the CSRF token. It is readable by
+	// JavaScript so the frontend can echo it back in the CSRFHeaderName header.
+	CSRFCookieName = "forum_csrf"
+	// CSRFHeaderName is the request header that must match the CSRF cookie
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// GenerateCSRFToken returns a new random CSRF token
+func GenerateCSRFToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// SetCSRFCookie issues a fresh CSRF token cookie that expires with the session
+func SetCSRFCookie(w http.ResponseWriter, session *Session) string {
+	token, err := GenerateCSRFToken()
+	if err != nil {
+		return ""
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookieName,
+		Value:    token,
+		Expires:  session.ExpiresAt,
+		HttpOnly: false, // Must be readable by the frontend
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/",
+	})
+
+	return token
+}
+
+// ClearCSRFCookie removes the CSRF token cookie
+func ClearCSRFCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookieName,
+		Value:    "",
+		Expires:  time.Now().Add(-time.Hour),
+		HttpOnly: false,
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/",
+	})
+}
+
+// ValidCSRFToken reports whether the request's CSRF header matches its CSRF cookie
+func ValidCSRFToken(r *http.Request) bool {
+	cookie, err := r.Cookie(CSRFCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+
+	header := r.Header.Get(CSRFHeaderName)
+	if header == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(header)) == 1
+}
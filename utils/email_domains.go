@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"forum/config"
+)
+
+// Email domain check errors
+var (
+	ErrBlockedEmailDomain = errors.New("email addresses from this domain are not accepted")
+	ErrEmailDomainNoMX    = errors.New("email domain does not accept mail")
+)
+
+var (
+	emailDomainsMu      sync.RWMutex
+	blockedEmailDomains = map[string]bool{}
+	emailMXCheckEnabled bool
+
+	// lookupMX resolves a domain's MX records; tests swap it out to stay off the network
+	lookupMX = net.LookupMX
+)
+
+// ApplyEmailConfig loads the disposable-domain blocklist and MX check setting
+// from the application config
+func ApplyEmailConfig() {
+	if path := config.GetEmailDomainBlocklistFile(); path != "" {
+		count, err := LoadEmailDomainBlocklist(path)
+		if err != nil {
+			log.Printf("Warning: failed to load EMAIL_DOMAIN_BLOCKLIST_FILE %q: %v", path, err)
+		} else {
+			log.Printf("Loaded %d blocked email domains", count)
+		}
+	}
+
+	emailDomainsMu.Lock()
+	emailMXCheckEnabled = config.IsEmailMXCheckEnabled()
+	emailDomainsMu.Unlock()
+}
+
+// LoadEmailDomainBlocklist replaces the blocked domains with those listed in a file,
+// one per line. Blank lines and lines starting with # are ignored.
+func LoadEmailDomainBlocklist(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	domains := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains[strings.TrimPrefix(line, "@")] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	emailDomainsMu.Lock()
+	blockedEmailDomains = domains
+	emailDomainsMu.Unlock()
+	return len(domains), nil
+}
+
+// IsBlockedEmailDomain reports whether a domain, or any domain it is a subdomain
+// of, is on the blocklist
+func IsBlockedEmailDomain(domain string) bool {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+
+	emailDomainsMu.RLock()
+	defer emailDomainsMu.RUnlock()
+
+	for domain != "" {
+		if blockedEmailDomains[domain] {
+			return true
+		}
+		dot := strings.IndexByte(domain, '.')
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+	return false
+}
+
+// ValidateEmailDomain checks an already well-formed address against the domain
+// blocklist and, when enabled, that its domain has MX records. DNS failures other
+// than the domain not existing let the address through rather than block signups.
+func ValidateEmailDomain(email string) error {
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return errors.New("invalid email format")
+	}
+	domain := strings.ToLower(email[at+1:])
+
+	if IsBlockedEmailDomain(domain) {
+		return ErrBlockedEmailDomain
+	}
+
+	emailDomainsMu.RLock()
+	checkMX := emailMXCheckEnabled
+	emailDomainsMu.RUnlock()
+	if !checkMX {
+		return nil
+	}
+
+	records, err := lookupMX(domain)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return ErrEmailDomainNoMX
+		}
+		log.Printf("MX lookup for %s failed, allowing: %v", domain, err)
+		return nil
+	}
+	if len(records) == 0 {
+		return ErrEmailDomainNoMX
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withBlocklist loads a blocklist file with the given contents for one test
+func withBlocklist(t *testing.T, contents string) int {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	count, err := LoadEmailDomainBlocklist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		emailDomainsMu.Lock()
+		blockedEmailDomains = map[string]bool{}
+		emailDomainsMu.Unlock()
+	})
+	return count
+}
+
+func TestEmailDomainBlocklist(t *testing.T) {
+	count := withBlocklist(t, "# disposable domains\n\n@Mailinator.com\n  tempmail.io  \n")
+	if count != 2 {
+		t.Fatalf("loaded %d domains, want 2", count)
+	}
+
+	tests := []struct {
+		email string
+		want  error
+	}{
+		{"someone@mailinator.com", ErrBlockedEmailDomain},
+		{"SOMEONE@MAILINATOR.COM", ErrBlockedEmailDomain},
+		{"someone@inbox.tempmail.io", ErrBlockedEmailDomain},
+		{"someone@example.com", nil},
+		{"someone@notmailinator.com", nil},
+		{"mailinator.com@example.com", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			if err := ValidateEmailDomain(tt.email); err != tt.want {
+				t.Fatalf("ValidateEmailDomain = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if errs := ValidateRegistrationForm("someone", "someone@mailinator.com", "Passw0rd!"); len(errs) != 1 || errs[0].Field != "email" {
+		t.Fatalf("registration errors = %+v, want one on email", errs)
+	}
+	if errs := ValidateRegistrationForm("someone", "someone@example.com", "Passw0rd!"); len(errs) != 0 {
+		t.Fatalf("registration errors = %+v, want none", errs)
+	}
+}
+
+func TestEmailMXCheck(t *testing.T) {
+	previous := lookupMX
+	t.Cleanup(func() {
+		lookupMX = previous
+		emailMXCheckEnabled = false
+	})
+	lookupMX = func(domain string) ([]*net.MX, error) {
+		switch domain {
+		case "example.com":
+			return []*net.MX{{Host: "mail.example.com.", Pref: 10}}, nil
+		case "nomail.example":
+			return nil, nil
+		case "flaky.example":
+			return nil, &net.DNSError{Err: "timeout", Name: domain, IsTimeout: true}
+		default:
+			return nil, &net.DNSError{Err: "no such host", Name: domain, IsNotFound: true}
+		}
+	}
+
+	tests := []struct {
+		email string
+		want  error
+	}{
+		{"someone@example.com", nil},
+		{"someone@nomail.example", ErrEmailDomainNoMX},
+		{"someone@missing.example", ErrEmailDomainNoMX},
+		// A resolver having trouble doesn't block signups
+		{"someone@flaky.example", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			emailMXCheckEnabled = false
+			if err := ValidateEmailDomain(tt.email); err != nil {
+				t.Fatalf("with the MX check off: %v", err)
+			}
+			emailMXCheckEnabled = true
+			if err := ValidateEmailDomain(tt.email); err != tt.want {
+				t.Fatalf("ValidateEmailDomain = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// jpegQuality is used when re-encoding uploaded JPEGs
+const jpegQuality = 90
+
+// maxImagePixels caps the dimensions of images we decode. A small file can declare a
+// huge canvas, and decoding allocates memory for every pixel of it.
+const maxImagePixels = 40 * 1000 * 1000
+
+// StripImageMetadata returns the image with its metadata (EXIF, GPS, comments) removed.
+// JPEG, PNG and GIF are decoded and re-encoded, which also proves they are valid images;
+// WebP has no decoder in the standard library, so its metadata chunks are dropped instead.
+func StripImageMetadata(data []byte, contentType string) ([]byte, error) {
+	if contentType != "image/webp" {
+		// Check the declared size before decoding anything
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil || "image/"+format != contentType {
+			return nil, fmt.Errorf("file is not a valid image")
+		}
+		if int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+			return nil, fmt.Errorf("image dimensions are too large (max %d megapixels)", maxImagePixels/1000000)
+		}
+	}
+
+	var out bytes.Buffer
+
+	switch contentType {
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("file is not a valid image")
+		}
+		if err := jpeg.Encode(&out, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("file is not a valid image")
+		}
+		if err := png.Encode(&out, img); err != nil {
+			return nil, err
+		}
+
+	case "image/gif":
+		// DecodeAll/EncodeAll keep every frame of animated avatars
+		img, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("file is not a valid image")
+		}
+		if err := gif.EncodeAll(&out, img); err != nil {
+			return nil, err
+		}
+
+	case "image/webp":
+		return stripWebPMetadata(data)
+
+	default:
+		return nil, fmt.Errorf("unsupported image type %s", contentType)
+	}
+
+	return out.Bytes(), nil
+}
+
+// WebP VP8X feature flags for embedded metadata
+const (
+	webpFlagXMP  = 0x04
+	webpFlagEXIF = 0x08
+)
+
+// stripWebPMetadata removes the EXIF and XMP chunks from a WebP RIFF container
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("file is not a valid image")
+	}
+
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+
+	for pos := 12; pos < len(data); {
+		if pos+8 > len(data) {
+			return nil, fmt.Errorf("file is not a valid image")
+		}
+		fourCC := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+
+		// Chunks are padded to an even size
+		end := pos + 8 + size + size%2
+		if size < 0 || end > len(data) {
+			return nil, fmt.Errorf("file is not a valid image")
+		}
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+			// drop the chunk
+		case "VP8X":
+			chunk := append([]byte(nil), data[pos:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= webpFlagEXIF | webpFlagXMP
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out, nil
+}
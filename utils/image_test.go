@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// exifMarker is planted in test EXIF data so tests can look for it in the output
+const exifMarker = "GPS 51.5007N 0.1246W"
+
+// testJPEGWithEXIF encodes a small JPEG and inserts an APP1 EXIF segment after the SOI marker
+func testJPEGWithEXIF(t *testing.T) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 16; y++ {
+			img.Set(x, y, color.RGBA{B: 180, A: 255})
+		}
+	}
+	var plain bytes.Buffer
+	if err := jpeg.Encode(&plain, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := append([]byte("Exif\x00\x00"), exifMarker...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	data := plain.Bytes()
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+// pngHeader returns just the signature and IHDR chunk of a PNG claiming the given size,
+// which is all a decoder reads before allocating the canvas
+func pngHeader(width, height uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	ihdr[8] = 8 // bit depth
+	ihdr[9] = 2 // truecolor
+
+	chunk := make([]byte, 4)
+	binary.BigEndian.PutUint32(chunk, uint32(len(ihdr)))
+	chunk = append(chunk, "IHDR"...)
+	chunk = append(chunk, ihdr...)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(chunk[4:]))
+	chunk = append(chunk, crc...)
+
+	return append([]byte("\x89PNG\r\n\x1a\n"), chunk...)
+}
+
+func TestStripImageMetadataRemovesEXIF(t *testing.T) {
+	data := testJPEGWithEXIF(t)
+	if !bytes.Contains(data, []byte(exifMarker)) {
+		t.Fatal("test JPEG is missing its EXIF data")
+	}
+
+	stripped, err := StripImageMetadata(data, "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stripped, []byte(exifMarker)) || bytes.Contains(stripped, []byte("Exif\x00")) {
+		t.Fatal("EXIF data survived stripping")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(stripped)); err != nil {
+		t.Fatalf("stripped JPEG doesn't decode: %v", err)
+	}
+}
+
+func TestStripImageMetadataRejects(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+	}{
+		{"decompression bomb", pngHeader(20000, 20000), "image/png"},
+		{"truncated image", testPNG(t, 8, 8)[:40], "image/png"},
+		{"type mismatch", testPNG(t, 8, 8), "image/jpeg"},
+		{"unsupported type", []byte("BM"), "image/bmp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := StripImageMetadata(tt.data, tt.contentType); err == nil {
+				t.Fatal("StripImageMetadata accepted the image")
+			}
+		})
+	}
+}
+
+func TestHandleFileUploadChecks(t *testing.T) {
+	t.Run("disallowed extension", func(t *testing.T) {
+		for _, name := range []string{"avatar.php", "avatar.svg", "avatar.png.exe", "avatar"} {
+			r := newUploadRequest(t, "avatar", name, "image/png", testPNG(t, 8, 8))
+			if _, err := HandleFileUpload(r, "avatar", AvatarUploadConfig); err == nil {
+				t.Fatalf("%s was accepted", name)
+			}
+		}
+	})
+
+	t.Run("stored JPEG has no EXIF", func(t *testing.T) {
+		r := newUploadRequest(t, "avatar", "photo.jpg", "image/jpeg", testJPEGWithEXIF(t))
+		result, err := HandleFileUpload(r, "avatar", AvatarUploadConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := GetAvatarFilePath(result.Filename)
+		t.Cleanup(func() { DeleteFile(path) })
+
+		stored, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(stored, []byte(exifMarker)) {
+			t.Fatal("stored file still has its EXIF data")
+		}
+	})
+
+	t.Run("extension follows the content", func(t *testing.T) {
+		r := newUploadRequest(t, "avatar", "photo.jpg", "image/jpeg", testPNG(t, 8, 8))
+		result, err := HandleFileUpload(r, "avatar", AvatarUploadConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { DeleteFile(GetAvatarFilePath(result.Filename)) })
+
+		if ext := filepath.Ext(result.Filename); ext != ".png" || result.MimeType != "image/png" {
+			t.Fatalf("stored as %s (%s), want .png", result.Filename, result.MimeType)
+		}
+	})
+
+	t.Run("decompression bomb", func(t *testing.T) {
+		r := newUploadRequest(t, "avatar", "bomb.png", "image/png", pngHeader(50000, 50000))
+		_, err := HandleFileUpload(r, "avatar", AvatarUploadConfig)
+		if err == nil || !strings.Contains(err.Error(), "too large") {
+			t.Fatalf("err = %v, want a dimensions error", err)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+// JSONTimeFormat is how every timestamp in API responses is written: UTC with
+// exactly three fractional digits, e.g. "2024-05-01T12:30:45.123Z". It is the
+// same format JavaScript's Date.toISOString produces.
+const JSONTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// JSONTime is a time.Time that serializes in JSONTimeFormat. A zero time is
+// written as null. When read back it accepts any RFC3339 timestamp.
+type JSONTime struct {
+	time.Time
+}
+
+// NewJSONTime wraps t for a response
+func NewJSONTime(t time.Time) JSONTime {
+	return JSONTime{t}
+}
+
+// NewJSONTimePtr wraps an optional time, keeping nil as nil
+func NewJSONTimePtr(t *time.Time) *JSONTime {
+	if t == nil {
+		return nil
+	}
+	return &JSONTime{*t}
+}
+
+// String formats the time in JSONTimeFormat
+func (t JSONTime) String() string {
+	return t.UTC().Format(JSONTimeFormat)
+}
+
+// MarshalJSON implements json.Marshaler
+func (t JSONTime) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + t.String() + `"`), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (t *JSONTime) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		t.Time = time.Time{}
+		return nil
+	}
+	if len(data) < 2 || data[0] != '"' || data[len(data)-1] != '"' {
+		return errors.New("timestamp must be an RFC3339 string, e.g. 2024-05-01T12:30:45.123Z")
+	}
+
+	parsed, err := time.Parse(time.RFC3339, string(data[1:len(data)-1]))
+	if err != nil {
+		return errors.New("timestamp must be an RFC3339 string, e.g. 2024-05-01T12:30:45.123Z")
+	}
+	t.Time = parsed
+	return nil
+}
+
+// Matches reports whether t, as sent back by a client, is the stored time once
+// that is cut to the precision responses carry. Use it for optimistic-concurrency
+// checks instead of comparing with Equal.
+func (t JSONTime) Matches(stored time.Time) bool {
+	return t.Truncate(time.Millisecond).Equal(stored.Truncate(time.Millisecond))
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestJSONTimeMarshal(t *testing.T) {
+	plus2 := time.FixedZone("UTC+2", 2*60*60)
+	tests := []struct {
+		name string
+		in   time.Time
+		want string
+	}{
+		{"utc", time.Date(2024, 5, 1, 12, 30, 45, 123456789, time.UTC), `"2024-05-01T12:30:45.123Z"`},
+		{"other zones are converted to utc", time.Date(2024, 5, 1, 14, 30, 45, 0, plus2), `"2024-05-01T12:30:45.000Z"`},
+		{"whole seconds keep three digits", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), `"2024-05-01T00:00:00.000Z"`},
+		{"zero is null", time.Time{}, `null`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(NewJSONTime(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("marshal = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if got, _ := json.Marshal(struct {
+		At *JSONTime `json:"at"`
+	}{NewJSONTimePtr(nil)}); string(got) != `{"at":null}` {
+		t.Fatalf("nil pointer = %s, want null", got)
+	}
+}
+
+func TestJSONTimeUnmarshal(t *testing.T) {
+	want := time.Date(2024, 5, 1, 12, 30, 45, 123000000, time.UTC)
+	for _, in := range []string{
+		`"2024-05-01T12:30:45.123Z"`,
+		`"2024-05-01T12:30:45.123000000Z"`,
+		`"2024-05-01T14:30:45.123+02:00"`,
+	} {
+		var got JSONTime
+		if err := json.Unmarshal([]byte(in), &got); err != nil {
+			t.Fatalf("unmarshal %s: %v", in, err)
+		}
+		if !got.Equal(want) {
+			t.Fatalf("unmarshal %s = %v, want %v", in, got.Time, want)
+		}
+	}
+
+	var null JSONTime
+	if err := json.Unmarshal([]byte(`null`), &null); err != nil || !null.IsZero() {
+		t.Fatalf("null = %v, %v, want the zero time", null.Time, err)
+	}
+
+	for _, in := range []string{`1714566645`, `"2024-05-01"`, `"yesterday"`, `"2024-05-01 12:30:45"`} {
+		var got JSONTime
+		if err := json.Unmarshal([]byte(in), &got); err == nil {
+			t.Fatalf("unmarshal %s succeeded, want an error", in)
+		}
+	}
+}
+
+func TestJSONTimeRoundTrip(t *testing.T) {
+	// Stored times carry more precision than responses do
+	stored := time.Date(2024, 5, 1, 12, 30, 45, 123456789, time.Local)
+
+	encoded, err := json.Marshal(NewJSONTime(stored))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sentBack JSONTime
+	if err := json.Unmarshal(encoded, &sentBack); err != nil {
+		t.Fatal(err)
+	}
+	if !sentBack.Matches(stored) {
+		t.Fatalf("%s doesn't match the stored %v it came from", encoded, stored)
+	}
+	if sentBack.Matches(stored.Add(time.Millisecond)) {
+		t.Fatalf("%s matches a stored time a millisecond later", encoded)
+	}
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"forum/config"
+	"forum/database"
+)
+
+// TestMain runs the package tests from a scratch directory, so uploads land under
+// its ./uploads, against a fresh database
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+
+	dir, err := os.MkdirTemp("", "forum-utils-test")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+
+	os.Setenv("DATABASE_URL", filepath.Join(dir, "forum.db"))
+	config.Load()
+	database.Init()
+
+	code := m.Run()
+
+	database.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
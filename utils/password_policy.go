@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"forum/config"
+)
+
+// Password length limits. Basic and strict policies make up for fewer or
+// stricter character rules with a longer minimum.
+const (
+	MinPasswordLength       = 8
+	MinLongPasswordLength   = 12
+	MaxPasswordLength       = 128
+	minPasswordPatternRun   = 4 // sequences like "abcd" or "4321" at least this long are rejected by the strict policy
+	maxPasswordRepeatedRuns = 2 // a character may repeat this many times in a row under the strict policy
+)
+
+// passwordSequences are runs of characters people type in order; strict
+// passwords may not contain minPasswordPatternRun of them in a row, either way
+var passwordSequences = []string{
+	"abcdefghijklmnopqrstuvwxyz",
+	"0123456789",
+	"qwertyuiop",
+	"asdfghjkl",
+	"zxcvbnm",
+}
+
+// commonPasswordWords are the words most often found in leaked passwords
+var commonPasswordWords = []string{
+	"password", "passw0rd", "letmein", "welcome", "admin", "iloveyou",
+	"monkey", "dragon", "football", "baseball", "sunshine", "princess",
+}
+
+// ValidatePasswordForPolicy checks a password against one of the config.PasswordPolicy* levels.
+// Unknown policies are treated as the standard one.
+func ValidatePasswordForPolicy(password, policy string) error {
+	if password == "" {
+		return errors.New("password is required")
+	}
+
+	minLength := MinPasswordLength
+	if policy == config.PasswordPolicyBasic || policy == config.PasswordPolicyStrict {
+		minLength = MinLongPasswordLength
+	}
+	if len(password) < minLength {
+		return fmt.Errorf("password must be at least %d characters long", minLength)
+	}
+
+	if len(password) > MaxPasswordLength {
+		return fmt.Errorf("password is too long (max %d characters)", MaxPasswordLength)
+	}
+
+	if policy == config.PasswordPolicyBasic {
+		return nil
+	}
+
+	if err := checkPasswordCharacters(password); err != nil {
+		return err
+	}
+
+	if policy == config.PasswordPolicyStrict {
+		return checkPasswordPatterns(password)
+	}
+	return nil
+}
+
+// checkPasswordCharacters requires upper and lower case letters, a digit and a special character
+func checkPasswordCharacters(password string) error {
+	hasUpper := false
+	hasLower := false
+	hasDigit := false
+	hasSpecial := false
+
+	for _, char := range password {
+		switch {
+		case unicode.IsUpper(char):
+			hasUpper = true
+		case unicode.IsLower(char):
+			hasLower = true
+		case unicode.IsDigit(char):
+			hasDigit = true
+		case unicode.IsPunct(char) || unicode.IsSymbol(char):
+			hasSpecial = true
+		}
+	}
+
+	if !hasUpper {
+		return errors.New("password must contain at least one uppercase letter")
+	}
+
+	if !hasLower {
+		return errors.New("password must contain at least one lowercase letter")
+	}
+
+	if !hasDigit {
+		return errors.New("password must contain at least one digit")
+	}
+
+	if !hasSpecial {
+		return errors.New("password must contain at least one special character")
+	}
+
+	return nil
+}
+
+// checkPasswordPatterns rejects the guessable patterns that satisfy the character
+// rules anyway: common words, repeated characters and keyboard or alphabet runs
+func checkPasswordPatterns(password string) error {
+	lower := strings.ToLower(password)
+
+	for _, word := range commonPasswordWords {
+		if strings.Contains(lower, word) {
+			return errors.New("password must not contain common words like '" + word + "'")
+		}
+	}
+
+	runes := []rune(lower)
+	repeated := 1
+	for i := 1; i < len(runes); i++ {
+		if runes[i] == runes[i-1] {
+			repeated++
+			if repeated > maxPasswordRepeatedRuns {
+				return errors.New("password must not repeat a character more than twice in a row")
+			}
+		} else {
+			repeated = 1
+		}
+	}
+
+	for i := 0; i+minPasswordPatternRun <= len(runes); i++ {
+		chunk := string(runes[i : i+minPasswordPatternRun])
+		for _, sequence := range passwordSequences {
+			if strings.Contains(sequence, chunk) || strings.Contains(sequence, reverseString(chunk)) {
+				return errors.New("password must not contain sequences like 'abcd', '1234' or 'qwer'")
+			}
+		}
+	}
+
+	return nil
+}
+
+// reverseString reverses s rune by rune
+func reverseString(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
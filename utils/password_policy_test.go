@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"forum/config"
+)
+
+func TestValidatePasswordForPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    string
+		password  string
+		wantError string
+	}{
+		// basic: length only, with the longer minimum
+		{"basic passphrase", config.PasswordPolicyBasic, "correct horse battery staple", ""},
+		{"basic lowercase only", config.PasswordPolicyBasic, "lowercaseonly", ""},
+		{"basic too short", config.PasswordPolicyBasic, "Sh0rt!pass", "at least 12 characters"},
+		{"basic too long", config.PasswordPolicyBasic, strings.Repeat("a", MaxPasswordLength+1), "too long"},
+
+		// standard: the original rules
+		{"standard valid", config.PasswordPolicyStandard, "Str0ng!pw", ""},
+		{"standard too short", config.PasswordPolicyStandard, "S0!pw", "at least 8 characters"},
+		{"standard passphrase", config.PasswordPolicyStandard, "correct horse battery staple", "uppercase letter"},
+		{"standard no lowercase", config.PasswordPolicyStandard, "STR0NG!PW", "lowercase letter"},
+		{"standard no digit", config.PasswordPolicyStandard, "Strong!pw", "digit"},
+		{"standard no special", config.PasswordPolicyStandard, "Str0ngpw1", "special character"},
+		{"standard allows patterns", config.PasswordPolicyStandard, "Password1234!", ""},
+
+		// strict: standard rules, the longer minimum and no obvious patterns
+		{"strict valid", config.PasswordPolicyStrict, "Tr0mbone!Gale", ""},
+		{"strict too short", config.PasswordPolicyStrict, "Str0ng!pw", "at least 12 characters"},
+		{"strict no special", config.PasswordPolicyStrict, "Tr0mboneGale", "special character"},
+		{"strict common word", config.PasswordPolicyStrict, "MyPassword!9x", "common words"},
+		{"strict repeated characters", config.PasswordPolicyStrict, "Tr0mbooone!G", "repeat a character"},
+		{"strict alphabet run", config.PasswordPolicyStrict, "Tr0mb!Ghijkx", "sequences"},
+		{"strict digit run", config.PasswordPolicyStrict, "Tr0mb!Gx1234", "sequences"},
+		{"strict reversed run", config.PasswordPolicyStrict, "Tr0mb!Gx9876", "sequences"},
+		{"strict keyboard run", config.PasswordPolicyStrict, "Tr0mb!GxQwer", "sequences"},
+
+		// unknown policies fall back to standard
+		{"unknown valid", "nonsense", "Str0ng!pw", ""},
+		{"unknown no special", "nonsense", "Str0ngpw1", "special character"},
+
+		{"empty", config.PasswordPolicyStandard, "", "password is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePasswordForPolicy(tt.password, tt.policy)
+			if tt.wantError == "" {
+				if err != nil {
+					t.Fatalf("ValidatePasswordForPolicy(%q, %q) = %v, want nil", tt.password, tt.policy, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Fatalf("ValidatePasswordForPolicy(%q, %q) = %v, want an error containing %q", tt.password, tt.policy, err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestValidatePasswordUsesTheConfiguredPolicy(t *testing.T) {
+	previous := config.AppConfig.PasswordPolicy
+	t.Cleanup(func() { config.AppConfig.PasswordPolicy = previous })
+
+	passphrase := "correct horse battery staple"
+
+	config.AppConfig.PasswordPolicy = config.PasswordPolicyStandard
+	if err := ValidatePassword(passphrase); err == nil {
+		t.Error("standard policy accepted a passphrase without upper case, digits or symbols")
+	}
+
+	config.AppConfig.PasswordPolicy = config.PasswordPolicyBasic
+	if err := ValidatePassword(passphrase); err != nil {
+		t.Errorf("basic policy rejected a long passphrase: %v", err)
+	}
+}
@@ -3,10 +3,13 @@ package utils
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+
+	"forum/config"
 )
 
 type APIResponse struct {
@@ -14,6 +17,7 @@ type APIResponse struct {
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+	Code    string      `json:"code,omitempty"` // machine-readable error code
 }
 
 // Success sends a successful JSON response
@@ -46,6 +50,45 @@ func Error(w http.ResponseWriter, statusCode int, message string) {
 	sendJSON(w, statusCode, response)
 }
 
+// ErrorWithCode sends an error JSON response carrying a machine-readable code
+// so clients can react to specific failures without parsing messages
+func ErrorWithCode(w http.ResponseWriter, statusCode int, code, message string) {
+	response := APIResponse{
+		Success: false,
+		Message: "Request failed",
+		Error:   message,
+		Code:    code,
+	}
+	sendJSON(w, statusCode, response)
+}
+
+// Throttled sends a 429 response telling the client how many seconds to wait,
+// both in the Retry-After header and as data.seconds_remaining
+func Throttled(w http.ResponseWriter, code, message string, secondsRemaining int) {
+	w.Header().Set("Retry-After", strconv.Itoa(secondsRemaining))
+	response := APIResponse{
+		Success: false,
+		Message: "Request failed",
+		Data:    map[string]int{"seconds_remaining": secondsRemaining},
+		Error:   message,
+		Code:    code,
+	}
+	sendJSON(w, http.StatusTooManyRequests, response)
+}
+
+// ConflictWithData sends a 409 response with a machine-readable code and data
+// about the existing resource the request clashes with
+func ConflictWithData(w http.ResponseWriter, code, message string, data interface{}) {
+	response := APIResponse{
+		Success: false,
+		Message: "Request failed",
+		Data:    data,
+		Error:   message,
+		Code:    code,
+	}
+	sendJSON(w, http.StatusConflict, response)
+}
+
 // BadRequest sends a 400 Bad Request JSON response
 func BadRequest(w http.ResponseWriter, message string) {
 	Error(w, http.StatusBadRequest, message)
@@ -86,18 +129,68 @@ func TooManyRequests(w http.ResponseWriter, message string) {
 	Error(w, http.StatusTooManyRequests, message)
 }
 
+// ServiceUnavailable sends a 503 Service Unavailable JSON response
+func ServiceUnavailable(w http.ResponseWriter, message string) {
+	Error(w, http.StatusServiceUnavailable, message)
+}
+
 // ValidationError sends a 422 Unprocessable Entity JSON response
-// Used for validation errors with detailed field information
-func ValidationError(w http.ResponseWriter, errors map[string]string) {
-	response := APIResponse{
-		Success: false,
-		Message: "Validation failed",
-		Data:    errors,
+// Used for validation errors with detailed field information.
+// "data" keeps the legacy field -> message shape (multiple messages joined with "; "),
+// while "errors" lists every problem in a deterministic order.
+func ValidationError(w http.ResponseWriter, errors ValidationErrors) {
+	legacy := make(map[string]string)
+	for field, messages := range errors.ByField() {
+		legacy[field] = strings.Join(messages, "; ")
+	}
+
+	response := struct {
+		APIResponse
+		Errors []FieldError `json:"errors"`
+	}{
+		APIResponse: APIResponse{
+			Success: false,
+			Message: "Validation failed",
+			Data:    legacy,
+		},
+		Errors: errors,
 	}
 
 	sendJSON(w, http.StatusUnprocessableEntity, response)
 }
 
+// SetPaginationLinks adds an RFC 5988 Link header with first, prev, next and last page URLs.
+// The URLs reuse the request's path and query with page and limit replaced; prev and next
+// are left out on the first and last pages. Call it before writing the response.
+func SetPaginationLinks(w http.ResponseWriter, r *http.Request, page, limit, total int) {
+	totalPages := (total + limit - 1) / limit
+	if totalPages < 1 {
+		totalPages = 1
+	}
+
+	pageURL := func(p int) string {
+		query := r.URL.Query()
+		query.Set("page", strconv.Itoa(p))
+		query.Set("limit", strconv.Itoa(limit))
+		return r.URL.Path + "?" + query.Encode()
+	}
+
+	links := []string{fmt.Sprintf(`<%s>; rel="first"`, pageURL(1))}
+	if page > 1 {
+		prev := page - 1
+		if prev > totalPages {
+			prev = totalPages
+		}
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pageURL(prev)))
+	}
+	if page < totalPages {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(page+1)))
+	}
+	links = append(links, fmt.Sprintf(`<%s>; rel="last"`, pageURL(totalPages)))
+
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
+
 // PaginatedSuccess sends a successful JSON response with pagination info
 func PaginatedSuccess(w http.ResponseWriter, message string, data interface{}, pagination interface{}) {
 	response := struct {
@@ -119,15 +212,24 @@ func PaginatedSuccess(w http.ResponseWriter, message string, data interface{}, p
 func sendJSON(w http.ResponseWriter, statusCode int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 
-	w.WriteHeader(statusCode)
-
-	if err := json.NewEncoder(w).Encode(data); err != nil {
+	// Encode before writing the status so a failure can still become a 500
+	var body []byte
+	var err error
+	if config.IsPrettyJSON() {
+		body, err = json.MarshalIndent(data, "", "  ")
+	} else {
+		body, err = json.Marshal(data)
+	}
+	if err != nil {
 		log.Printf("Failed to encode JSON response: %v", err)
 
-		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(`{"success":false,"message":"Internal server error","error":"Failed to encode response"}`))
+		return
 	}
+
+	w.WriteHeader(statusCode)
+	w.Write(append(body, '\n'))
 }
 
 // ParseJSON is a helper function to parse JSON request bodies
@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidationErrorKeepsEveryMessageInOrder(t *testing.T) {
+	var errs ValidationErrors
+	errs.Add("password", "password is too short")
+	errs.Add("username", "username is required")
+	errs.Add("password", "password needs a digit")
+	errs.Add("password", "password needs a special character")
+
+	var first string
+	for i := 0; i < 5; i++ {
+		rec := httptest.NewRecorder()
+		ValidationError(rec, errs)
+
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("status = %d, want 422", rec.Code)
+		}
+		if i == 0 {
+			first = rec.Body.String()
+		} else if rec.Body.String() != first {
+			t.Fatalf("response changed between runs:\n%s\n%s", first, rec.Body.String())
+		}
+	}
+
+	var body struct {
+		Data   map[string]string `json:"data"`
+		Errors []FieldError      `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(first), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(body.Errors, []FieldError(errs)) {
+		t.Fatalf("errors = %v, want %v", body.Errors, errs)
+	}
+	want := "password is too short; password needs a digit; password needs a special character"
+	if body.Data["password"] != want {
+		t.Fatalf("data.password = %q, want %q", body.Data["password"], want)
+	}
+}
+
+func TestValidationErrorsByField(t *testing.T) {
+	var errs ValidationErrors
+	errs.Add("title", "first")
+	errs.Add("content", "other")
+	errs.Add("title", "second")
+
+	got := errs.ByField()
+	if !reflect.DeepEqual(got["title"], []string{"first", "second"}) || !reflect.DeepEqual(got["content"], []string{"other"}) {
+		t.Fatalf("ByField() = %v", got)
+	}
+	if err := errs.ToError(); err == nil || err.Error() != "title: first; content: other; title: second" {
+		t.Fatalf("ToError() = %v", err)
+	}
+}
+
+func TestSetPaginationLinks(t *testing.T) {
+	tests := []struct {
+		name  string
+		page  int
+		total int
+		want  []string
+	}{
+		{"middle page", 2, 25, []string{
+			`</api/posts?category=3&limit=10&page=1>; rel="first"`,
+			`</api/posts?category=3&limit=10&page=1>; rel="prev"`,
+			`</api/posts?category=3&limit=10&page=3>; rel="next"`,
+			`</api/posts?category=3&limit=10&page=3>; rel="last"`,
+		}},
+		{"first page", 1, 25, []string{
+			`</api/posts?category=3&limit=10&page=1>; rel="first"`,
+			`</api/posts?category=3&limit=10&page=2>; rel="next"`,
+			`</api/posts?category=3&limit=10&page=3>; rel="last"`,
+		}},
+		{"last page", 3, 25, []string{
+			`</api/posts?category=3&limit=10&page=1>; rel="first"`,
+			`</api/posts?category=3&limit=10&page=2>; rel="prev"`,
+			`</api/posts?category=3&limit=10&page=3>; rel="last"`,
+		}},
+		{"no results", 1, 0, []string{
+			`</api/posts?category=3&limit=10&page=1>; rel="first"`,
+			`</api/posts?category=3&limit=10&page=1>; rel="last"`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/posts?category=3&page=7&limit=10", nil)
+			rec := httptest.NewRecorder()
+			SetPaginationLinks(rec, r, tt.page, 10, tt.total)
+
+			if got := strings.Split(rec.Header().Get("Link"), ", "); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Link =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestResponseIndentation(t *testing.T) {
+	const compact = `{"success":true,"message":"ok","data":{"id":1}}` + "\n"
+	const indented = "{\n  \"success\": true,\n  \"message\": \"ok\",\n  \"data\": {\n    \"id\": 1\n  }\n}\n"
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"production", map[string]string{"APP_ENV": "production"}, compact},
+		{"development", map[string]string{"APP_ENV": "development"}, indented},
+		{"development with pretty JSON off", map[string]string{"APP_ENV": "development", "PRETTY_JSON": "false"}, compact},
+		{"production with pretty JSON on", map[string]string{"APP_ENV": "production", "PRETTY_JSON": "true"}, indented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				withEnv(t, key, value)
+			}
+
+			rec := httptest.NewRecorder()
+			Success(rec, "ok", map[string]int{"id": 1})
+			if got := rec.Body.String(); got != tt.want {
+				t.Fatalf("body = %q, want %q", got, tt.want)
+			}
+
+			// A response that can't be encoded falls back to the same fixed error either way
+			rec = httptest.NewRecorder()
+			Success(rec, "ok", map[string]interface{}{"bad": make(chan int)})
+			if rec.Code != http.StatusInternalServerError || !strings.HasPrefix(rec.Body.String(), `{"success":false,`) {
+				t.Fatalf("unencodable response = %d %q", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
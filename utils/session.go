@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"time"
 
+	"forum/config"
 	"forum/database"
 
 	"github.com/google/uuid"
@@ -21,8 +22,10 @@ type Session struct {
 // SessionDuration defines how long sessions last (24 hours)
 const SessionDuration = 24 * time.Hour
 
-// CookieName is the name of the session cookie
-const CookieName = "forum_session"
+// SessionCookieName returns the configured name of the session cookie
+func SessionCookieName() string {
+	return config.GetSessionCookieName()
+}
 
 // CreateSession creates a new session for a user
 func CreateSession(userID int) (*Session, error) {
@@ -76,6 +79,7 @@ func GetSession(sessionID string) (*Session, error) {
 func DeleteSession(sessionID string) error {
 	query := `DELETE FROM sessions WHERE id = ?`
 	_, err := database.GetDB().Exec(query, sessionID)
+	forgetSession(sessionID)
 	return err
 }
 
@@ -83,6 +87,7 @@ func DeleteSession(sessionID string) error {
 func DeleteUserSessions(userID int) error {
 	query := `DELETE FROM sessions WHERE user_id = ?`
 	_, err := database.GetDB().Exec(query, userID)
+	ForgetUserSessions(userID)
 	return err
 }
 
@@ -106,6 +111,7 @@ func RefreshSession(sessionID string) (*Session, error) {
 		return nil, err
 	}
 
+	forgetSession(sessionID)
 	if rowsAffected == 0 {
 		return nil, sql.ErrNoRows
 	}
@@ -117,7 +123,7 @@ func RefreshSession(sessionID string) (*Session, error) {
 // SetSessionCookie sets the session cookie in the HTTP response
 func SetSessionCookie(w http.ResponseWriter, session *Session) {
 	cookie := &http.Cookie{
-		Name:     CookieName,
+		Name:     SessionCookieName(),
 		Value:    session.ID,
 		Expires:  session.ExpiresAt,
 		HttpOnly: true, // Prevent XSS attacks
@@ -132,7 +138,7 @@ func SetSessionCookie(w http.ResponseWriter, session *Session) {
 // ClearSessionCookie removes the session cookie
 func ClearSessionCookie(w http.ResponseWriter) {
 	cookie := &http.Cookie{
-		Name:     CookieName,
+		Name:     SessionCookieName(),
 		Value:    "",
 		Expires:  time.Now().Add(-time.Hour), // Expire in the past
 		HttpOnly: true,
@@ -147,18 +153,19 @@ func ClearSessionCookie(w http.ResponseWriter) {
 // GetSessionFromRequest extracts session from HTTP request
 func GetSessionFromRequest(r *http.Request) (*Session, error) {
 	// Get session cookie
-	cookie, err := r.Cookie(CookieName)
+	cookie, err := r.Cookie(SessionCookieName())
 	if err != nil {
 		return nil, err
 	}
 
-	// Get session from database
-	session, err := GetSession(cookie.Value)
+	// Get session from the cache or the database
+	entry, err := lookupSession(cookie.Value)
 	if err != nil {
 		return nil, err
 	}
 
-	return session, nil
+	session := entry.session
+	return &session, nil
 }
 
 // GetUserFromSession gets user ID from session in request
@@ -204,22 +211,20 @@ func RequireLogin(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-// GetCurrentUser gets current user info from session
-func GetCurrentUser(r *http.Request) (int, string, error) {
-	session, err := GetSessionFromRequest(r)
+// GetCurrentUser gets current user info (id, username, role) from session
+func GetCurrentUser(r *http.Request) (int, string, string, error) {
+	cookie, err := r.Cookie(SessionCookieName())
 	if err != nil {
-		return 0, "", err
+		return 0, "", "", err
 	}
 
-	// Get username from database
-	query := `SELECT username FROM users WHERE id = ?`
-	var username string
-	err = database.GetDB().QueryRow(query, session.UserID).Scan(&username)
+	// The session lookup already carries the username and role
+	entry, err := lookupSession(cookie.Value)
 	if err != nil {
-		return 0, "", err
+		return 0, "", "", err
 	}
 
-	return session.UserID, username, nil
+	return entry.session.UserID, entry.username, entry.role, nil
 }
 
 // CleanupExpiredSessions removes expired sessions from database
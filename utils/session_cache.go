@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"database/sql"
+	"net/http"
+	"sync"
+	"time"
+
+	"forum/database"
+)
+
+// Cached session lookups keep authenticated requests from hitting the sessions
+// and users tables every time. Entries are short-lived and dropped on logout,
+// password change, role or username change and bans, so stale data lasts at most
+// sessionCacheTTL for changes made outside those paths.
+const (
+	sessionCacheTTL     = 30 * time.Second
+	sessionCacheMaxSize = 10000
+)
+
+// cachedSession is a session together with the user fields auth needs
+type cachedSession struct {
+	session     Session
+	username    string
+	role        string
+	bannedUntil sql.NullTime
+	banReason   string
+	cachedAt    time.Time
+}
+
+var sessionCache = struct {
+	sync.Mutex
+	entries map[string]cachedSession
+}{entries: make(map[string]cachedSession)}
+
+// lookupSession returns a live session and its user's name and role,
+// from the cache when possible and with a single query otherwise
+func lookupSession(sessionID string) (*cachedSession, error) {
+	now := time.Now()
+
+	sessionCache.Lock()
+	entry, ok := sessionCache.entries[sessionID]
+	if ok && (now.Sub(entry.cachedAt) > sessionCacheTTL || !entry.session.ExpiresAt.After(now)) {
+		delete(sessionCache.entries, sessionID)
+		ok = false
+	}
+	sessionCache.Unlock()
+	if ok {
+		return &entry, nil
+	}
+
+	query := `
+		SELECT s.id, s.user_id, s.expires_at, s.created_at, u.username, u.role,
+		       u.banned_until, u.ban_reason
+		FROM sessions s
+		JOIN users u ON s.user_id = u.id
+		WHERE s.id = ? AND s.expires_at > ?
+	`
+	entry = cachedSession{cachedAt: now}
+	err := database.GetDB().QueryRow(query, sessionID, now).Scan(
+		&entry.session.ID, &entry.session.UserID, &entry.session.ExpiresAt, &entry.session.CreatedAt,
+		&entry.username, &entry.role, &entry.bannedUntil, &entry.banReason,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionCache.Lock()
+	if len(sessionCache.entries) >= sessionCacheMaxSize {
+		evictSessionCacheLocked(now)
+	}
+	sessionCache.entries[sessionID] = entry
+	sessionCache.Unlock()
+
+	return &entry, nil
+}
+
+// GetSessionBan returns when the requesting user's ban ends and why, from the same
+// lookup as GetCurrentUser; the time is nil when they aren't banned
+func GetSessionBan(r *http.Request) (*time.Time, string, error) {
+	cookie, err := r.Cookie(SessionCookieName())
+	if err != nil {
+		return nil, "", err
+	}
+
+	entry, err := lookupSession(cookie.Value)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !entry.bannedUntil.Valid || !entry.bannedUntil.Time.After(time.Now()) {
+		return nil, "", nil
+	}
+	return &entry.bannedUntil.Time, entry.banReason, nil
+}
+
+// evictSessionCacheLocked makes room in a full cache: stale entries go first,
+// then arbitrary ones until a tenth of the cache is free. The lock must be held.
+func evictSessionCacheLocked(now time.Time) {
+	for id, entry := range sessionCache.entries {
+		if now.Sub(entry.cachedAt) > sessionCacheTTL || !entry.session.ExpiresAt.After(now) {
+			delete(sessionCache.entries, id)
+		}
+	}
+	for id := range sessionCache.entries {
+		if len(sessionCache.entries) < sessionCacheMaxSize*9/10 {
+			break
+		}
+		delete(sessionCache.entries, id)
+	}
+}
+
+// forgetSession drops one session from the cache
+func forgetSession(sessionID string) {
+	sessionCache.Lock()
+	delete(sessionCache.entries, sessionID)
+	sessionCache.Unlock()
+}
+
+// ForgetUserSessions drops every cached session of a user. Call it after changing
+// anything the cache holds (password, role, username, ban) so the next request reloads it.
+func ForgetUserSessions(userID int) {
+	sessionCache.Lock()
+	for id, entry := range sessionCache.entries {
+		if entry.session.UserID == userID {
+			delete(sessionCache.entries, id)
+		}
+	}
+	sessionCache.Unlock()
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"forum/database"
+)
+
+var sessionUserSeq int
+
+// newSessionRequest creates a user and a session for them, and returns a request
+// carrying the session cookie
+func newSessionRequest(t *testing.T) (*http.Request, int, *Session) {
+	t.Helper()
+
+	sessionUserSeq++
+	name := fmt.Sprintf("sessionuser%d", sessionUserSeq)
+	result, err := database.GetDB().Exec(
+		`INSERT INTO users (username, email, password_hash, created_at, updated_at) VALUES (?, ?, 'x', ?, ?)`,
+		name, name+"@example.com", time.Now(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID, _ := result.LastInsertId()
+
+	session, err := CreateSession(int(userID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/api/auth/me", nil)
+	r.AddCookie(&http.Cookie{Name: SessionCookieName(), Value: session.ID})
+	return r, int(userID), session
+}
+
+func TestCachedSessionSkipsTheDatabase(t *testing.T) {
+	r, userID, session := newSessionRequest(t)
+
+	if id, _, role, err := GetCurrentUser(r); err != nil || id != userID || role != "user" {
+		t.Fatalf("GetCurrentUser = %d, %q, %v, want %d, user", id, role, err, userID)
+	}
+
+	// With the row gone behind the cache's back, only a cached entry can still answer
+	if _, err := database.GetDB().Exec(`DELETE FROM sessions WHERE id = ?`, session.ID); err != nil {
+		t.Fatal(err)
+	}
+	if id, _, _, err := GetCurrentUser(r); err != nil || id != userID {
+		t.Fatalf("cached GetCurrentUser = %d, %v, want %d", id, err, userID)
+	}
+	if _, err := GetSessionFromRequest(r); err != nil {
+		t.Fatalf("cached GetSessionFromRequest: %v", err)
+	}
+
+	forgetSession(session.ID)
+	if _, _, _, err := GetCurrentUser(r); err == nil {
+		t.Fatal("GetCurrentUser found a deleted session once the cache entry was dropped")
+	}
+}
+
+func TestLogoutInvalidatesCachedSession(t *testing.T) {
+	r, _, session := newSessionRequest(t)
+	if _, _, _, err := GetCurrentUser(r); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeleteSession(session.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := GetCurrentUser(r); err == nil {
+		t.Fatal("GetCurrentUser still accepts a logged out session")
+	}
+}
+
+func TestSessionBanIsCached(t *testing.T) {
+	r, userID, _ := newSessionRequest(t)
+	if until, _, err := GetSessionBan(r); err != nil || until != nil {
+		t.Fatalf("GetSessionBan = %v, %v before any ban", until, err)
+	}
+
+	ban := func(until interface{}, reason string) {
+		t.Helper()
+		query := `UPDATE users SET banned_until = ?, ban_reason = ? WHERE id = ?`
+		if _, err := database.GetDB().Exec(query, until, reason, userID); err != nil {
+			t.Fatal(err)
+		}
+		ForgetUserSessions(userID)
+	}
+
+	want := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	ban(want, "spam")
+	until, reason, err := GetSessionBan(r)
+	if err != nil || until == nil || !until.Equal(want) || reason != "spam" {
+		t.Fatalf("GetSessionBan = %v, %q, %v, want %v, spam", until, reason, err, want)
+	}
+
+	// An expired ban no longer counts, even from the cache
+	ban(time.Now().Add(-time.Minute).UTC(), "spam")
+	if until, _, err := GetSessionBan(r); err != nil || until != nil {
+		t.Fatalf("GetSessionBan = %v, %v after the ban ended", until, err)
+	}
+
+	ban(nil, "")
+	if until, _, err := GetSessionBan(r); err != nil || until != nil {
+		t.Fatalf("GetSessionBan = %v, %v after unbanning", until, err)
+	}
+}
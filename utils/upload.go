@@ -3,6 +3,7 @@ package utils
 import (
 	"fmt"
 	"io"
+	"log"
 	"mime"
 	"mime/multipart"
 	"net/http"
@@ -11,6 +12,8 @@ import (
 	"strings"
 	"time"
 
+	"forum/config"
+
 	"github.com/google/uuid"
 )
 
@@ -30,6 +33,32 @@ var AvatarUploadConfig = UploadConfig{
 	URLPrefix:    "/uploads/avatars",
 }
 
+// Post attachment upload configuration. Attachments go through the same image
+// checks and metadata stripping as avatars, with a larger size limit.
+var AttachmentUploadConfig = UploadConfig{
+	MaxFileSize:  10 << 20,
+	AllowedTypes: []string{"image/jpeg", "image/png", "image/gif", "image/webp"},
+	UploadDir:    "./uploads/attachments",
+	URLPrefix:    "/uploads/attachments",
+}
+
+// imageExtensions maps each supported image type to the file extensions accepted for it
+var imageExtensions = map[string][]string{
+	"image/jpeg": {".jpg", ".jpeg"},
+	"image/png":  {".png"},
+	"image/gif":  {".gif"},
+	"image/webp": {".webp"},
+}
+
+// AllowedExtensions returns the file extensions matching the allowed MIME types
+func (c UploadConfig) AllowedExtensions() []string {
+	var extensions []string
+	for _, allowedType := range c.AllowedTypes {
+		extensions = append(extensions, imageExtensions[allowedType]...)
+	}
+	return extensions
+}
+
 // UploadResult contains information about uploaded file
 type UploadResult struct {
 	Filename     string `json:"filename"`
@@ -39,10 +68,31 @@ type UploadResult struct {
 	MimeType     string `json:"mime_type"`
 }
 
+// ApplyUploadConfig updates upload limits from the loaded application config
+func ApplyUploadConfig() {
+	AvatarUploadConfig.MaxFileSize = config.GetMaxAvatarSize()
+
+	// Only types we know how to strip metadata from can be allowed
+	if types := config.GetAvatarAllowedTypes(); len(types) > 0 {
+		var allowed []string
+		for _, allowedType := range types {
+			if _, ok := imageExtensions[allowedType]; !ok {
+				log.Printf("Warning: unsupported avatar type %q in AVATAR_ALLOWED_TYPES, ignoring", allowedType)
+				continue
+			}
+			allowed = append(allowed, allowedType)
+		}
+		if len(allowed) > 0 {
+			AvatarUploadConfig.AllowedTypes = allowed
+		}
+	}
+}
+
 // InitUploadDirectories create necessary upload directories
 func InitUploadDirectories() error {
 	dirs := []string{
 		AvatarUploadConfig.UploadDir,
+		AttachmentUploadConfig.UploadDir,
 	}
 
 	for _, dir := range dirs {
@@ -59,10 +109,14 @@ func HandleFileUpload(r *http.Request, fieldName string, config UploadConfig) (*
 		return nil, fmt.Errorf("failed to create upload directory: %v", err)
 	}
 
+	// Cap the request body so oversized uploads are rejected before hitting the disk
+	// (a little headroom is left for the multipart boundaries and other fields)
+	r.Body = http.MaxBytesReader(nil, r.Body, config.MaxFileSize+(1<<20))
+
 	// Parse multipart form with size limit
 	err := r.ParseMultipartForm(config.MaxFileSize)
 	if err != nil {
-		return nil, fmt.Errorf("file too large or invalid form data")
+		return nil, fmt.Errorf("file too large (max %d MB) or invalid form data", config.MaxFileSize/(1<<20))
 	}
 
 	// Get file from form
@@ -82,8 +136,29 @@ func HandleFileUpload(r *http.Request, fieldName string, config UploadConfig) (*
 		return nil, fmt.Errorf("invalid file type. Allowed types: %s", strings.Join(config.AllowedTypes, ", "))
 	}
 
-	// Generate unique filename
-	filename, err := generateUniqueFilename(header.Filename)
+	// Validate file extension
+	if !isValidExtension(header.Filename, config.AllowedExtensions()) {
+		return nil, fmt.Errorf("invalid file extension. Allowed extensions: %s", strings.Join(config.AllowedExtensions(), ", "))
+	}
+
+	// Check the actual content, not just what the browser claims
+	data, err := io.ReadAll(io.LimitReader(file, config.MaxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	contentType := http.DetectContentType(data)
+	if !contains(config.AllowedTypes, contentType) {
+		return nil, fmt.Errorf("file is not a valid image")
+	}
+
+	// Re-encode to drop EXIF (including GPS) and other metadata
+	data, err = StripImageMetadata(data, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate unique filename, named after the detected type rather than the client's name
+	filename, err := generateUniqueFilename(imageExtensions[contentType][0])
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate filename: %v", err)
 	}
@@ -92,7 +167,7 @@ func HandleFileUpload(r *http.Request, fieldName string, config UploadConfig) (*
 	fullPath := filepath.Join(config.UploadDir, filename)
 	
 	// Save file to disk
-	if err := saveFile(file, fullPath); err != nil {
+	if err := saveFile(data, fullPath); err != nil {
 		return nil, fmt.Errorf("failed to save file: %v", err)
 	}
 
@@ -100,9 +175,9 @@ func HandleFileUpload(r *http.Request, fieldName string, config UploadConfig) (*
 	result := &UploadResult{
 		Filename:     filename,
 		OriginalName: header.Filename,
-		Size:         header.Size,
+		Size:         int64(len(data)),
 		URL:          config.URLPrefix + "/" + filename,
-		MimeType:     header.Header.Get("Content-Type"),
+		MimeType:     contentType,
 	}
 
 	return result, nil
@@ -119,18 +194,26 @@ func isValidFileType(header *multipart.FileHeader, allowedTypes []string) bool {
 	}
 
 	// Check against allowed types
-	for _, allowed := range allowedTypes {
-		if contentType == allowed {
+	return contains(allowedTypes, contentType)
+}
+
+// isValidExtension checks the file extension (case-insensitively) against the allowlist
+func isValidExtension(filename string, allowedExtensions []string) bool {
+	return contains(allowedExtensions, strings.ToLower(filepath.Ext(filename)))
+}
+
+// contains reports whether value is in list
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
 			return true
 		}
 	}
 	return false
 }
 
-// generateUniqueFilename creates a unique filename while preserving extension
-func generateUniqueFilename(originalName string) (string, error) {
-	// Get file extension
-	ext := filepath.Ext(originalName)
+// generateUniqueFilename creates a unique filename with the given extension
+func generateUniqueFilename(ext string) (string, error) {
 	if ext == "" {
 		return "", fmt.Errorf("file must have an extension")
 	}
@@ -145,33 +228,53 @@ func generateUniqueFilename(originalName string) (string, error) {
 	return filename, nil
 }
 
-// saveFile saves uploaded file to specified path
-func saveFile(src multipart.File, dst string) error {
-	// Create destination file
-	out, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer out.Close()
-
-	// Copy file content
-	_, err = io.Copy(out, src)
-	return err
+// saveFile saves uploaded file contents to specified path
+func saveFile(data []byte, dst string) error {
+	return os.WriteFile(dst, data, 0o644)
 }
 
+// uploadsRoot is the directory every uploaded file lives under
+const uploadsRoot = "./uploads"
+
 // DeleteFile removes a file from the filesystem
-func DeleteFile(filepath string) error {
-	// Security check: ensure file is in allowed directory
-	if !strings.HasPrefix(filepath, "./uploads/") {
+func DeleteFile(path string) error {
+	// Security check: ensure file is in allowed directory, after resolving any ".."
+	if !isWithinDir(path, uploadsRoot) {
 		return fmt.Errorf("file path not in allowed directory")
 	}
 
 	// Check if file exists
-	if _, err := os.Stat(filepath); os.IsNotExist(err) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return nil // File doesn't exist, consider it deleted
 	}
 
-	return os.Remove(filepath)
+	return os.Remove(path)
+}
+
+// isWithinDir reports whether path points inside dir once both are made absolute
+func isWithinDir(path, dir string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// isSafeFilename accepts a bare file name: no directories, no "..", nothing hidden
+func isSafeFilename(name string) bool {
+	if name == "" || strings.HasPrefix(name, ".") || strings.Contains(name, "..") {
+		return false
+	}
+	return !strings.ContainsAny(name, `/\`) && filepath.Base(name) == name
 }
 
 // GetFileInfo returns information about an uploaded file
@@ -242,10 +345,17 @@ func ValidateImageFile(file multipart.File) error {
 
 // GetAvatarFilePath returns the full file path for an avatar
 func GetAvatarFilePath(filename string) string {
-	return filepath.Join(AvatarUploadConfig.UploadDir, filename)
+	return filepath.Join(AvatarUploadConfig.UploadDir, filepath.Base(filename))
+}
+
+// GetAttachmentFilePath returns the full file path for a post attachment
+func GetAttachmentFilePath(filename string) string {
+	return filepath.Join(AttachmentUploadConfig.UploadDir, filepath.Base(filename))
 }
 
-// ExtractFilenameFromURL extracts filename from avatar URL
+// ExtractFilenameFromURL extracts filename from avatar URL.
+// It returns "" unless what is left is a plain file name, so a crafted avatar
+// value can't point outside the avatar directory.
 func ExtractFilenameFromURL(url string) string {
 	if url == "" {
 		return ""
@@ -255,5 +365,8 @@ func ExtractFilenameFromURL(url string) string {
 	filename := strings.TrimPrefix(url, AvatarUploadConfig.URLPrefix+"/")
 	filename = strings.TrimPrefix(filename, "/")
 
+	if !isSafeFilename(filename) {
+		return ""
+	}
 	return filename
 }
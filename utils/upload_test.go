@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"forum/config"
+)
+
+// newUploadRequest builds a multipart request carrying data as the given form file
+func newUploadRequest(t *testing.T, field, filename, contentType string, data []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="`+field+`"; filename="`+filename+`"`)
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	writer.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+	return r
+}
+
+// testPNG encodes a small solid PNG
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: 200, A: 255})
+		}
+	}
+	var out bytes.Buffer
+	if err := png.Encode(&out, img); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+// withEnv sets an environment variable and reloads the config for one test
+func withEnv(t *testing.T, key, value string) {
+	t.Helper()
+
+	old, had := os.LookupEnv(key)
+	os.Setenv(key, value)
+	config.Load()
+	t.Cleanup(func() {
+		if had {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+		config.Load()
+	})
+}
+
+func TestMaxAvatarSizeFromEnv(t *testing.T) {
+	saved := AvatarUploadConfig
+	t.Cleanup(func() { AvatarUploadConfig = saved })
+
+	withEnv(t, "MAX_AVATAR_SIZE", "1")
+	ApplyUploadConfig()
+
+	if AvatarUploadConfig.MaxFileSize != 1<<20 {
+		t.Fatalf("MaxFileSize = %d, want %d", AvatarUploadConfig.MaxFileSize, 1<<20)
+	}
+
+	// Just over the limit, but within the request headroom, so the size check itself rejects it
+	oversized := make([]byte, 1<<20+512<<10)
+	r := newUploadRequest(t, "avatar", "big.png", "image/png", oversized)
+	if _, err := HandleFileUpload(r, "avatar", AvatarUploadConfig); err == nil || !strings.Contains(err.Error(), "1 MB") {
+		t.Fatalf("oversized upload: err = %v, want a 1 MB size error", err)
+	}
+
+	r = newUploadRequest(t, "avatar", "small.png", "image/png", testPNG(t, 8, 8))
+	result, err := HandleFileUpload(r, "avatar", AvatarUploadConfig)
+	if err != nil {
+		t.Fatalf("upload under the limit: %v", err)
+	}
+	DeleteFile(GetAvatarFilePath(result.Filename))
+}
+
+func TestMaxAvatarSizeOutOfRangeUsesDefault(t *testing.T) {
+	withEnv(t, "MAX_AVATAR_SIZE", "500")
+
+	if got := config.GetMaxAvatarSize(); got != config.DefaultMaxAvatarSizeMB<<20 {
+		t.Fatalf("GetMaxAvatarSize() = %d, want the %d MB default", got, config.DefaultMaxAvatarSizeMB)
+	}
+}
+
+func TestExtractFilenameFromURL(t *testing.T) {
+	tests := map[string]string{
+		"/uploads/avatars/3b1f0c9e-5a4d-4c8e-9f7a-2d6b8e1c4a7f.png": "3b1f0c9e-5a4d-4c8e-9f7a-2d6b8e1c4a7f.png",
+		"3b1f0c9e-5a4d-4c8e-9f7a-2d6b8e1c4a7f.jpg":                  "3b1f0c9e-5a4d-4c8e-9f7a-2d6b8e1c4a7f.jpg",
+		"":                                   "",
+		"../../etc/passwd":                   "",
+		"/uploads/avatars/../../etc/passwd":  "",
+		"/uploads/avatars/..":                "",
+		"/uploads/avatars/sub/avatar.png":    "",
+		`/uploads/avatars/..\..\windows.ini`: "",
+		"/uploads/avatars/.htaccess":         "",
+		"/etc/passwd":                        "",
+		"/uploads/avatars/":                  "",
+	}
+
+	for input, want := range tests {
+		if got := ExtractFilenameFromURL(input); got != want {
+			t.Errorf("ExtractFilenameFromURL(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestAvatarPathsStayInUploads(t *testing.T) {
+	avatarDir, err := filepath.Abs(AvatarUploadConfig.UploadDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"../../etc/passwd", "../forum.db", "/etc/passwd", "avatar.png"} {
+		path, err := filepath.Abs(GetAvatarFilePath(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Dir(path) != avatarDir {
+			t.Errorf("GetAvatarFilePath(%q) = %q, outside %q", name, path, avatarDir)
+		}
+	}
+}
+
+func TestDeleteFileRefusesPathsOutsideUploads(t *testing.T) {
+	if err := os.MkdirAll(AvatarUploadConfig.UploadDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	victim := "victim.txt"
+	if err := os.WriteFile(victim, []byte("keep me"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(victim) })
+
+	for _, path := range []string{victim, "./uploads/../victim.txt", filepath.Join(AvatarUploadConfig.UploadDir, "..", "..", victim)} {
+		if err := DeleteFile(path); err == nil {
+			t.Errorf("DeleteFile(%q) succeeded", path)
+		}
+	}
+	if _, err := os.Stat(victim); err != nil {
+		t.Fatalf("file outside uploads was removed: %v", err)
+	}
+
+	avatar := GetAvatarFilePath("3b1f0c9e-5a4d-4c8e-9f7a-2d6b8e1c4a7f.png")
+	if err := os.WriteFile(avatar, []byte("avatar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteFile(avatar); err != nil {
+		t.Fatalf("DeleteFile(%q): %v", avatar, err)
+	}
+	if _, err := os.Stat(avatar); !os.IsNotExist(err) {
+		t.Fatalf("avatar still exists: %v", err)
+	}
+}
@@ -2,17 +2,28 @@ package utils
 
 import (
 	"errors"
+	"fmt"
+	"html"
 	"regexp"
 	"strings"
-	"unicode"
+	"unicode/utf8"
+
+	"forum/config"
 )
 
-// ValidationErrors holds field-specific validation errors
-type ValidationErrors map[string]string
+// FieldError is a single validation problem for a field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors holds field-specific validation errors in the order they were added.
+// A field may carry several messages.
+type ValidationErrors []FieldError
 
 // Add adds a validation error for a specific field
-func (ve ValidationErrors) Add(field, message string) {
-	ve[field] = message
+func (ve *ValidationErrors) Add(field, message string) {
+	*ve = append(*ve, FieldError{Field: field, Message: message})
 }
 
 // HasErrors checks if there are any validation errors
@@ -20,15 +31,24 @@ func (ve ValidationErrors) HasErrors() bool {
 	return len(ve) > 0
 }
 
+// ByField groups messages per field, preserving the order they were added
+func (ve ValidationErrors) ByField() map[string][]string {
+	fields := make(map[string][]string)
+	for _, fe := range ve {
+		fields[fe.Field] = append(fields[fe.Field], fe.Message)
+	}
+	return fields
+}
+
 // ToError converts validation errors to a single error
 func (ve ValidationErrors) ToError() error {
 	if !ve.HasErrors() {
 		return nil
 	}
 
-	var messages []string
-	for field, message := range ve {
-		messages = append(messages, field+": "+message)
+	messages := make([]string, 0, len(ve))
+	for _, fe := range ve {
+		messages = append(messages, fe.Field+": "+fe.Message)
 	}
 
 	return errors.New(strings.Join(messages, "; "))
@@ -40,6 +60,9 @@ var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]
 // Username validation regex pattern (alphanumeric, underscore, hyphen)
 var usernameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
 
+// mentionRegex matches @username references that aren't part of a word or email address
+var mentionRegex = regexp.MustCompile(`(?:^|[^a-zA-Z0-9_@.-])@([a-zA-Z0-9][a-zA-Z0-9_-]{1,48}[a-zA-Z0-9])`)
+
 // ValidateEmail checks if an email address is valid
 func ValidateEmail(email string) error {
 	if email == "" {
@@ -99,67 +122,21 @@ func ValidateUsernameString(username string) string {
 	return ""
 }
 
-// ValidatePassword checks if a password meets security requirements
+// ValidatePassword checks if a password meets the configured password policy
 func ValidatePassword(password string) error {
-	if password == "" {
-		return errors.New("password is required")
-	}
-
-	if len(password) < 8 {
-		return errors.New("password must be at least 8 characters long")
-	}
-
-	if len(password) > 128 {
-		return errors.New("password is too long (max 128 characters)")
-	}
-
-	// Check for at least one uppercase letter
-	hasUpper := false
-	hasLower := false
-	hasDigit := false
-	hasSpecial := false
-
-	for _, char := range password {
-		switch {
-		case unicode.IsUpper(char):
-			hasUpper = true
-		case unicode.IsLower(char):
-			hasLower = true
-		case unicode.IsDigit(char):
-			hasDigit = true
-		case unicode.IsPunct(char) || unicode.IsSymbol(char):
-			hasSpecial = true
-		}
-	}
-
-	if !hasUpper {
-		return errors.New("password must contain at least one uppercase letter")
-	}
-
-	if !hasLower {
-		return errors.New("password must contain at least one lowercase letter")
-	}
-
-	if !hasDigit {
-		return errors.New("password must contain at least one digit")
-	}
-
-	if !hasSpecial {
-		return errors.New("password must contain at least one special character")
-	}
-
-	return nil
+	return ValidatePasswordForPolicy(password, config.GetPasswordPolicy())
 }
 
 // ValidatePostTitle checks if a post title is valid
 func ValidatePostTitle(title string) error {
-	title = strings.TrimSpace(title)
+	title = SanitizeString(title)
+	visible := VisibleText(title)
 
-	if title == "" {
+	if visible == "" {
 		return errors.New("post title is required")
 	}
 
-	if len(title) < 5 {
+	if len(visible) < 5 {
 		return errors.New("post title must be at least 5 characters long")
 	}
 
@@ -172,13 +149,14 @@ func ValidatePostTitle(title string) error {
 
 // ValidatePostContent checks if post content is valid
 func ValidatePostContent(content string) error {
-	content = strings.TrimSpace(content)
+	content = SanitizeString(content)
+	visible := VisibleText(content)
 
-	if content == "" {
+	if visible == "" {
 		return errors.New("post content is required")
 	}
 
-	if len(content) < 10 {
+	if len(visible) < 10 {
 		return errors.New("post content must be at least 10 characters long")
 	}
 
@@ -191,9 +169,9 @@ func ValidatePostContent(content string) error {
 
 // ValidateCommentContent checks if comment content is valid
 func ValidateCommentContent(content string) error {
-	content = strings.TrimSpace(content)
+	content = SanitizeString(content)
 
-	if content == "" {
+	if VisibleText(content) == "" {
 		return errors.New("comment content is required")
 	}
 
@@ -208,9 +186,37 @@ func ValidateCommentContent(content string) error {
 	return nil
 }
 
+// MaxCategoriesPerPost is the maximum number of categories a post can belong to
+const MaxCategoriesPerPost = 5
+
+// ValidateCategoryIDs checks that a post's category list is non-empty,
+// within the per-post limit, and contains only unique positive IDs
+func ValidateCategoryIDs(categoryIDs []int) error {
+	if len(categoryIDs) == 0 {
+		return errors.New("at least one category is required")
+	}
+
+	if len(categoryIDs) > MaxCategoriesPerPost {
+		return fmt.Errorf("maximum %d categories allowed", MaxCategoriesPerPost)
+	}
+
+	seen := make(map[int]bool, len(categoryIDs))
+	for _, catID := range categoryIDs {
+		if catID <= 0 {
+			return errors.New("invalid category ID")
+		}
+		if seen[catID] {
+			return errors.New("duplicate category IDs not allowed")
+		}
+		seen[catID] = true
+	}
+
+	return nil
+}
+
 // ValidateRegistrationForm validates user registration data
 func ValidateRegistrationForm(username, email, password string) ValidationErrors {
-	errors := make(ValidationErrors)
+	var errors ValidationErrors
 
 	// Validate username
 	if err := ValidateUsername(username); err != nil {
@@ -220,6 +226,8 @@ func ValidateRegistrationForm(username, email, password string) ValidationErrors
 	// Validate email
 	if err := ValidateEmail(email); err != nil {
 		errors.Add("email", err.Error())
+	} else if err := ValidateEmailDomain(email); err != nil {
+		errors.Add("email", err.Error())
 	}
 
 	// Validate password
@@ -232,7 +240,7 @@ func ValidateRegistrationForm(username, email, password string) ValidationErrors
 
 // ValidateLoginForm validates user login data
 func ValidateLoginForm(username, password string) ValidationErrors {
-	errors := make(ValidationErrors)
+	var errors ValidationErrors
 
 	// Validate username (can be email or username)
 	if username == "" {
@@ -249,7 +257,7 @@ func ValidateLoginForm(username, password string) ValidationErrors {
 
 // ValidatePostForm validates post creation/update data
 func ValidatePostForm(title, content string, categoryIDs []int) ValidationErrors {
-	errors := make(ValidationErrors)
+	var errors ValidationErrors
 
 	// Validate title
 	if err := ValidatePostTitle(title); err != nil {
@@ -261,29 +269,9 @@ func ValidatePostForm(title, content string, categoryIDs []int) ValidationErrors
 		errors.Add("content", err.Error())
 	}
 
-	// // Validate category
-	// if categoryID <= 0 {
-	// 	errors.Add("category", "please select a valid category")
-	// }
-		// Validate categories
-	if len(categoryIDs) == 0 {
-		errors.Add("categories", "At least one category is required")
-	} else if len(categoryIDs) > 5 {
-		errors.Add("categories", "Maximum 5 categories allowed")
-	} else {
-		// Check for duplicate category IDs
-		seen := make(map[int]bool)
-		for _, catID := range categoryIDs {
-			if catID <= 0 {
-				errors.Add("categories", "Invalid category ID")
-				break
-			}
-			if seen[catID] {
-				errors.Add("categories", "Duplicate category IDs not allowed")
-				break
-			}
-			seen[catID] = true
-		}
+	// Validate categories
+	if err := ValidateCategoryIDs(categoryIDs); err != nil {
+		errors.Add("categories", err.Error())
 	}
 
 	return errors
@@ -291,7 +279,7 @@ func ValidatePostForm(title, content string, categoryIDs []int) ValidationErrors
 
 // ValidateCommentForm validates comment creation/update data
 func ValidateCommentForm(content string) ValidationErrors {
-	errors := make(ValidationErrors)
+	var errors ValidationErrors
 
 	// Validate content
 	if err := ValidateCommentContent(content); err != nil {
@@ -301,11 +289,57 @@ func ValidateCommentForm(content string) ValidationErrors {
 	return errors
 }
 
+// ValidateVoteForm validates a post or comment vote. action is "toggle" (the default when
+// empty), "set" or "remove"; removing a vote doesn't need a vote_type.
+func ValidateVoteForm(voteType, action string) ValidationErrors {
+	var errors ValidationErrors
+
+	if action != "" && action != "toggle" && action != "set" && action != "remove" {
+		errors.Add("action", "Action must be 'toggle', 'set' or 'remove'")
+	}
+
+	if !IsValidVoteType(voteType) && !(action == "remove" && voteType == "") {
+		errors.Add("vote_type", "Vote type must be 'like' or 'dislike'")
+	}
+
+	return errors
+}
+
+// zeroWidthChars are invisible characters that can make content look empty. They stay in
+// stored text, since joiners are part of emoji sequences and of words in some scripts,
+// and are only ignored when deciding whether content has anything visible in it.
+var zeroWidthChars = map[rune]bool{
+	'\u200B': true, // zero width space
+	'\u200C': true, // zero width non-joiner
+	'\u200D': true, // zero width joiner
+	'\u2060': true, // word joiner
+	'\uFEFF': true, // zero width no-break space (BOM)
+}
+
+// bidiControlChars reorder surrounding text and can disguise what content really says
+var bidiControlChars = map[rune]bool{
+	'\u061C': true, // arabic letter mark
+	'\u200E': true, // left-to-right mark
+	'\u200F': true, // right-to-left mark
+	'\u202A': true, // left-to-right embedding
+	'\u202B': true, // right-to-left embedding
+	'\u202C': true, // pop directional formatting
+	'\u202D': true, // left-to-right override
+	'\u202E': true, // right-to-left override
+	'\u2066': true, // left-to-right isolate
+	'\u2067': true, // right-to-left isolate
+	'\u2068': true, // first strong isolate
+	'\u2069': true, // pop directional isolate
+}
+
 // SanitizeString removes dangerous characters and trims whitespace
 func SanitizeString(input string) string {
-	// Remove null bytes and other control characters
+	// Remove null bytes, other control characters and bidi control characters
 	cleaned := strings.Map(func(r rune) rune {
-		if r == 0 || (r < 32 && r != '\n' && r != '\r' && r != '\t') {
+		if r == 0 || (r < 32 && r != '\n' && r != '\r' && r != '\t') || r == 0x7F {
+			return -1
+		}
+		if bidiControlChars[r] {
 			return -1
 		}
 		return r
@@ -315,6 +349,26 @@ func SanitizeString(input string) string {
 	return strings.TrimSpace(cleaned)
 }
 
+// VisibleText returns sanitized text without zero-width characters, for emptiness and
+// minimum length checks only
+func VisibleText(input string) string {
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if zeroWidthChars[r] {
+			return -1
+		}
+		return r
+	}, SanitizeString(input)))
+}
+
+// ContentHTML renders user content as HTML that is always safe to insert into a page.
+// Content is stored as plain text, so every markup character is escaped and only
+// line breaks are turned into tags.
+func ContentHTML(content string) string {
+	escaped := html.EscapeString(content)
+	escaped = strings.ReplaceAll(escaped, "\r\n", "\n")
+	return strings.ReplaceAll(escaped, "\n", "<br>")
+}
+
 // IsValidVoteType checks if vote type is valid
 func IsValidVoteType(voteType string) bool {
 	return voteType == "like" || voteType == "dislike"
@@ -349,3 +403,46 @@ func ValidateID(id int, fieldName string) error {
 	}
 	return nil
 }
+
+// Search query length limits
+const (
+	MinSearchQueryLength = 2
+	MaxSearchQueryLength = 100
+)
+
+// ValidateSearchQuery checks that a search query is neither too short nor too long
+func ValidateSearchQuery(query string) error {
+	length := utf8.RuneCountInString(query)
+	if length < MinSearchQueryLength {
+		return fmt.Errorf("search query must be at least %d characters", MinSearchQueryLength)
+	}
+	if length > MaxSearchQueryLength {
+		return fmt.Errorf("search query cannot exceed %d characters", MaxSearchQueryLength)
+	}
+	return nil
+}
+
+// MaxMentionsPerComment caps how many users a single comment can notify
+const MaxMentionsPerComment = 10
+
+// ExtractMentions returns the distinct usernames mentioned as @username in the content,
+// in order of first appearance (case-insensitive de-duplication, capped at MaxMentionsPerComment)
+func ExtractMentions(content string) []string {
+	var mentions []string
+	seen := make(map[string]bool)
+
+	for _, match := range mentionRegex.FindAllStringSubmatch(content, -1) {
+		key := strings.ToLower(match[1])
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		mentions = append(mentions, match[1])
+
+		if len(mentions) == MaxMentionsPerComment {
+			break
+		}
+	}
+
+	return mentions
+}
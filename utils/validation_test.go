@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestValidatePostFormCategories(t *testing.T) {
+	tests := []struct {
+		name        string
+		categoryIDs []int
+		wantError   string
+	}{
+		{"empty", []int{}, "at least one category is required"},
+		{"nil", nil, "at least one category is required"},
+		{"duplicate", []int{1, 2, 1}, "duplicate category IDs not allowed"},
+		{"oversized", []int{1, 2, 3, 4, 5, 6}, "maximum 5 categories allowed"},
+		{"invalid ID", []int{1, 0}, "invalid category ID"},
+		{"valid", []int{1, 2, 3, 4, 5}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidatePostForm("A valid title", "Some valid post content", tt.categoryIDs)
+			messages := errs.ByField()["categories"]
+
+			if tt.wantError == "" {
+				if errs.HasErrors() {
+					t.Fatalf("unexpected errors: %v", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || len(messages) != 1 || messages[0] != tt.wantError {
+				t.Fatalf("errors = %v, want only categories: %q", errs, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestSanitizeString(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"trims whitespace", "  hello \n", "hello"},
+		{"drops control characters", "he\x00ll\x07o\x7f", "hello"},
+		{"keeps newlines and tabs", "a\n\tb", "a\n\tb"},
+		{"drops bidi controls", "abc\u202edef", "abcdef"},
+		{"keeps emoji joiners", "family 👨\u200d👩\u200d👧", "family 👨\u200d👩\u200d👧"},
+		{"keeps non-joiners", "می\u200cخواهم", "می\u200cخواهم"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeString(tt.input); got != tt.want {
+				t.Fatalf("SanitizeString(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateContentRejectsInvisibleOnly(t *testing.T) {
+	inputs := map[string]string{
+		"null bytes":        "\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00",
+		"control only":      "\x01\x02\x03\x04\x05\x06\x07\x08\x0b\x0c\x0e\x0f",
+		"zero-width only":   "\u200b\u200c\u200d\u2060\ufeff\u200b\u200c\u200d\u2060\ufeff",
+		"zero-width spaced": "\u200b \u200d \u200b \u200c \u200b \u200d \u200b",
+		"bidi only":         "\u202e\u202d\u2066\u2069\u200e\u200f\u202e\u202d\u2066\u2069",
+	}
+
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			if err := ValidateCommentContent(input); err == nil {
+				t.Fatal("ValidateCommentContent accepted invisible content")
+			}
+			if err := ValidatePostContent(input); err == nil {
+				t.Fatal("ValidatePostContent accepted invisible content")
+			}
+			if err := ValidatePostTitle(input); err == nil {
+				t.Fatal("ValidatePostTitle accepted invisible content")
+			}
+			if errs := ValidateCommentForm(input); !errs.HasErrors() {
+				t.Fatal("ValidateCommentForm accepted invisible content")
+			}
+		})
+	}
+}
+
+func TestValidateContentIgnoresInvisibleInMinimumLength(t *testing.T) {
+	// Nine visible characters padded out with joiners is still too short for a post
+	padded := "too\u200d\u200d\u200dshort"
+	if err := ValidatePostContent(padded); err == nil {
+		t.Fatal("zero-width padding counted towards the minimum length")
+	}
+
+	if err := ValidateCommentContent("👨\u200d👩\u200d👧"); err != nil {
+		t.Fatalf("emoji sequence rejected: %v", err)
+	}
+	if err := ValidatePostContent("می\u200cخواهم بروم خانه"); err != nil {
+		t.Fatalf("text with a non-joiner rejected: %v", err)
+	}
+}
+
+func TestContentHTML(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"script tag", "<script>alert(1)</script>", "&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{"event handler", `<img src=x onerror="alert(1)">`, "&lt;img src=x onerror=&#34;alert(1)&#34;&gt;"},
+		{"attribute breakout", `"><svg onload=alert(1)>`, "&#34;&gt;&lt;svg onload=alert(1)&gt;"},
+		{"single quotes", `' onmouseover='alert(1)`, "&#39; onmouseover=&#39;alert(1)"},
+		{"entities stay literal", "&lt;b&gt;", "&amp;lt;b&amp;gt;"},
+		{"line breaks", "one\r\ntwo\nthree", "one<br>two<br>three"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ContentHTML(tt.input); got != tt.want {
+				t.Fatalf("ContentHTML(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeStringStripsSpoofingCharacters(t *testing.T) {
+	// Bidi overrides and isolates can make "exe.txt" read as "txt.exe"
+	inputs := map[string]string{
+		"right-to-left override": "invoice\u202etxt.exe",
+		"left-to-right override": "invoice\u202dtxt.exe",
+		"embeddings":             "invoice\u202a\u202btxt.exe\u202c",
+		"isolates":               "invoice\u2066\u2067\u2068txt.exe\u2069",
+		"marks":                  "invoice\u200e\u200f\u061ctxt.exe",
+	}
+
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			if got := SanitizeString(input); got != "invoicetxt.exe" {
+				t.Fatalf("SanitizeString(%q) = %q, want %q", input, got, "invoicetxt.exe")
+			}
+		})
+	}
+}
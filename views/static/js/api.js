@@ -1,16 +1,34 @@
 import { API_BASE } from "./config.js";
 
+// CSRF double-submit token: the server sets this cookie and expects it
+// echoed back in the X-CSRF-Token header on state-changing requests
+const CSRF_COOKIE = "forum_csrf";
+const CSRF_HEADER = "X-CSRF-Token";
+
+export function getCsrfToken() {
+  const match = document.cookie
+    .split("; ")
+    .find((row) => row.startsWith(`${CSRF_COOKIE}=`));
+  return match ? decodeURIComponent(match.split("=")[1]) : "";
+}
+
+export function csrfHeaders() {
+  const token = getCsrfToken();
+  return token ? { [CSRF_HEADER]: token } : {};
+}
+
 // API Helper Functions
 export async function apiRequest(endpoint, options = {}) {
   const url = `${API_BASE}${endpoint}`;
 
   const config = {
+    credentials: "include",
+    ...options,
     headers: {
       "Content-Type": "application/json",
+      ...csrfHeaders(),
       ...options.headers,
     },
-    credentials: "include",
-    ...options,
   };
 
   try {
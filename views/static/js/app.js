@@ -1,7 +1,7 @@
 import { checkAuthStatus, login, register, logout } from "./auth.js";
 import { loadPosts, loadSinglePost, createPost, deletePost } from "./posts.js";
 import { loadComments, submitComment, deleteComment } from "./comments.js";
-import { loadCategories } from "./categories.js";
+import { loadCategories, loadPopularCategories } from "./categories.js";
 import { handlePostVote, handleCommentVote } from "./voting.js";
 import {
   showSection,
@@ -9,6 +9,7 @@ import {
   renderSinglePost,
   renderComments,
   renderCategories,
+  renderPopularCategories,
   renderPagination,
   showMessage,
 } from "./ui.js";
@@ -284,6 +285,11 @@ async createPost(event) {
     if (result.success) {
       renderCategories();
     }
+
+    const popular = await loadPopularCategories();
+    if (popular.success) {
+      renderPopularCategories();
+    }
   },
 
 async loadCategoriesForForm() {
@@ -1,5 +1,5 @@
 import { apiRequest } from './api.js';
-import {  updateCategories } from './state.js';
+import {  updateCategories, updatePopularCategories } from './state.js';
 
 export async function loadCategories() {
     try {
@@ -14,4 +14,20 @@ export async function loadCategories() {
         updateCategories([]);
         return { success: false, error: error.message };
     }
-}
\ No newline at end of file
+}
+
+// Categories with the most new posts in a window (day, week, month or all)
+export async function loadPopularCategories(window = 'week', limit = 5) {
+    try {
+        const response = await apiRequest(`/categories/popular?t=${window}&limit=${limit}`);
+
+        if (response.success) {
+            updatePopularCategories(response.data.categories);
+            return { success: true };
+        }
+    } catch (error) {
+        console.error('Failed to load popular categories:', error);
+        updatePopularCategories([]);
+        return { success: false, error: error.message };
+    }
+}
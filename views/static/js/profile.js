@@ -1,4 +1,4 @@
-import { apiRequest } from "./api.js";
+import { apiRequest, csrfHeaders } from "./api.js";
 import { state, updateUser, updateCurrentProfile } from "./state.js";
 import { showMessage, updateAvatarDisplay, updateAvatarButtons } from "./ui.js";
 
@@ -63,6 +63,7 @@ export async function uploadAvatar(userId, file) {
     // Upload file (note: different from regular API request due to FormData)
     const response = await fetch(`/api/users/${userId}/avatar`, {
       method: "POST",
+      headers: csrfHeaders(),
       body: formData,
       credentials: "include",
     });
@@ -5,6 +5,7 @@ export const state = {
   currentPost: null,
   currentProfile: null,
   categories: [],
+  popularCategories: [],
   comments: [],
   currentPage: 1,
   currentCategory: null,
@@ -35,3 +36,7 @@ export function updateComments(comments) {
 export function updateCategories(categories) {
   state.categories = categories;
 }
+
+export function updatePopularCategories(categories) {
+  state.popularCategories = categories;
+}
@@ -25,7 +25,7 @@ export function renderPosts() {
                 <div>
                     <h3 class="post-title">${escapeHtml(post.title)}</h3>
                     <div class="post-meta">
-                        <span>by ${escapeHtml(post.author.username)}</span>
+                        <span>by ${escapeHtml(post.author.username)}${newMemberBadge(post.author)}</span>
                         <span>•</span>
                         <span>${formatDate(post.created_at)}</span>
                     </div>
@@ -80,7 +80,7 @@ export function renderSinglePost() {
             <h1 class="post-title">${escapeHtml(post.title)}</h1>
             ${deleteBtn}
             <div class="post-meta">
-                <span>by ${escapeHtml(post.author.username)}</span>
+                <span>by ${escapeHtml(post.author.username)}${newMemberBadge(post.author)}</span>
                 <span>•</span>
                 <span>${formatDate(post.created_at)}</span>
             </div>
@@ -137,7 +137,7 @@ export function renderComments() {
             <div class="comment-header">
                 <span class="comment-author">${escapeHtml(
                   comment.author.username
-                )}</span>
+                )}${newMemberBadge(comment.author)}</span>
                 <span class="comment-date">${formatDate(
                   comment.created_at
                 )}</span>
@@ -185,6 +185,33 @@ export function renderCategories() {
   });
 }
 
+export function renderPopularCategories() {
+  const container = document.getElementById("popular-categories");
+
+  if (!state.popularCategories || state.popularCategories.length === 0) {
+    container.innerHTML = '<li class="sidebar-empty">No new posts this week.</li>';
+    return;
+  }
+
+  container.innerHTML = "";
+  state.popularCategories.forEach((category) => {
+    const li = document.createElement("li");
+    li.innerHTML = `<button onclick="app.filterByCategory(${
+      category.id
+    })">${escapeHtml(category.name)}<span class="category-count">${
+      category.post_count
+    }</span></button>`;
+    container.appendChild(li);
+  });
+}
+
+// Small "New" tag next to authors who joined within the new member period
+function newMemberBadge(author) {
+  return author && author.new_member
+    ? ' <span class="new-member-badge" title="New member">New</span>'
+    : "";
+}
+
 export function renderPagination(pagination) {
   const container = document.getElementById("pagination");
 
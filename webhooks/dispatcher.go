@@ -0,0 +1,184 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"forum/events"
+	"forum/models"
+	"forum/utils"
+
+	"github.com/google/uuid"
+)
+
+// Delivery settings
+const (
+	MaxAttempts      = 3                // tries per delivery before it counts as failed
+	InitialBackoff   = 2 * time.Second  // wait before the first retry, doubled after each one
+	RequestTimeout   = 10 * time.Second // per attempt
+	Workers          = 8                // deliveries in flight at once
+	QueueSize        = 256              // events or deliveries waiting their turn; more are dropped
+	SignatureHeader  = "X-Forum-Signature"
+	EventHeader      = "X-Forum-Event"
+	DeliveryIDHeader = "X-Forum-Delivery"
+)
+
+// Payload is the JSON body posted to webhook endpoints
+type Payload struct {
+	ID         string         `json:"id"`
+	Event      string         `json:"event"`
+	OccurredAt utils.JSONTime `json:"occurred_at"`
+	Data       interface{}    `json:"data"`
+}
+
+// delivery is one payload on its way to one webhook
+type delivery struct {
+	webhook models.Webhook
+	payload Payload
+	body    []byte
+}
+
+var (
+	client = &http.Client{
+		Timeout: RequestTimeout,
+		// Only the saved URL is trusted; a redirect elsewhere counts as a failed delivery
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	pending = make(chan events.Event, QueueSize) // events waiting to be matched to webhooks
+	queue   = make(chan delivery, QueueSize)     // deliveries waiting for a worker
+)
+
+// Start subscribes the dispatcher to application events and starts its workers.
+// Call it once at startup.
+func Start() {
+	go fanOut()
+	for i := 0; i < Workers; i++ {
+		go work()
+	}
+	events.Subscribe(dispatch)
+}
+
+// Sign returns the signature header value for a body: "sha256=" followed by the
+// hex HMAC-SHA256 of the body keyed with the webhook secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// dispatch queues an event without blocking the publisher. When the queue is
+// full the event is dropped rather than piling up behind slow endpoints.
+func dispatch(event events.Event) {
+	select {
+	case pending <- event:
+	default:
+		log.Printf("Webhook queue full, dropping %s event", event.Name)
+	}
+}
+
+// fanOut queues a delivery of each pending event to every webhook subscribed to it
+func fanOut() {
+	for event := range pending {
+		webhooks, err := models.GetActiveWebhooksForEvent(event.Name)
+		if err != nil {
+			log.Printf("Failed to load webhooks for %s: %v", event.Name, err)
+			continue
+		}
+
+		for _, wh := range webhooks {
+			payload := Payload{
+				ID:         uuid.New().String(),
+				Event:      event.Name,
+				OccurredAt: utils.NewJSONTime(event.OccurredAt),
+				Data:       event.Data,
+			}
+			body, err := json.Marshal(payload)
+			if err != nil {
+				log.Printf("Failed to encode %s webhook payload: %v", event.Name, err)
+				break
+			}
+			enqueue(delivery{webhook: wh, payload: payload, body: body})
+		}
+	}
+}
+
+// enqueue hands a delivery to the workers, dropping it when they are too far behind
+func enqueue(d delivery) bool {
+	select {
+	case queue <- d:
+		return true
+	default:
+		log.Printf("Webhook queue full, dropping %s delivery %s to webhook %d", d.payload.Event, d.payload.ID, d.webhook.ID)
+		return false
+	}
+}
+
+// work delivers queued payloads one at a time
+func work() {
+	for d := range queue {
+		deliver(d.webhook, d.payload, d.body)
+	}
+}
+
+// deliver posts a payload, retrying with exponential backoff, and records the outcome
+func deliver(wh models.Webhook, payload Payload, body []byte) {
+	backoff := InitialBackoff
+	var lastErr error
+	for attempt := 1; attempt <= MaxAttempts; attempt++ {
+		if lastErr = send(wh, payload, body); lastErr == nil {
+			if err := models.RecordWebhookSuccess(wh.ID); err != nil {
+				log.Printf("Failed to record webhook %d delivery: %v", wh.ID, err)
+			}
+			return
+		}
+		if attempt < MaxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	log.Printf("Webhook %d: %s delivery %s failed after %d attempts: %v", wh.ID, payload.Event, payload.ID, MaxAttempts, lastErr)
+	disabled, err := models.RecordWebhookFailure(wh.ID, lastErr.Error())
+	if err != nil {
+		log.Printf("Failed to record webhook %d failure: %v", wh.ID, err)
+		return
+	}
+	if disabled {
+		log.Printf("Webhook %d disabled after %d failed deliveries in a row", wh.ID, models.MaxWebhookFailures)
+	}
+}
+
+// send makes a single delivery attempt; any non-2xx response is an error
+func send(wh models.Webhook, payload Payload, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "forum-webhooks/1.0")
+	req.Header.Set(EventHeader, payload.Event)
+	req.Header.Set(DeliveryIDHeader, payload.ID)
+	req.Header.Set(SignatureHeader, Sign(wh.Secret, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return nil
+}
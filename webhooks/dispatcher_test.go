@@ -0,0 +1,101 @@
+package webhooks
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"forum/events"
+	"forum/models"
+)
+
+// The tests never call Start, so nothing drains the queues behind their back
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestSendSignsTheBody(t *testing.T) {
+	body := []byte(`{"event":"post.created"}`)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := io.ReadAll(r.Body)
+		if string(got) != string(body) || r.Header.Get(SignatureHeader) != Sign("s3cret", body) ||
+			r.Header.Get(EventHeader) != events.PostCreated || r.Header.Get(DeliveryIDHeader) != "delivery-1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	wh := models.Webhook{ID: 1, URL: srv.URL, Secret: "s3cret"}
+	if err := send(wh, Payload{ID: "delivery-1", Event: events.PostCreated}, body); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSendDoesNotFollowRedirects(t *testing.T) {
+	var followed int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/internal", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/internal", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&followed, 1)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	wh := models.Webhook{ID: 1, URL: srv.URL + "/hook", Secret: "s3cret"}
+	if err := send(wh, Payload{ID: "delivery-1", Event: events.PostCreated}, []byte(`{}`)); err == nil {
+		t.Fatal("a redirect counted as a successful delivery")
+	}
+	if atomic.LoadInt32(&followed) != 0 {
+		t.Fatal("the redirect was followed")
+	}
+}
+
+func TestFullQueuesDropInsteadOfBlocking(t *testing.T) {
+	t.Cleanup(func() {
+		for len(queue) > 0 {
+			<-queue
+		}
+		for len(pending) > 0 {
+			<-pending
+		}
+	})
+
+	for i := 0; i < QueueSize; i++ {
+		if !enqueue(delivery{webhook: models.Webhook{ID: 1}}) {
+			t.Fatalf("delivery %d was dropped before the queue filled", i)
+		}
+	}
+	if enqueue(delivery{webhook: models.Webhook{ID: 1}}) {
+		t.Fatal("a delivery was queued past the queue size")
+	}
+	if len(queue) != QueueSize {
+		t.Fatalf("queue holds %d deliveries, want %d", len(queue), QueueSize)
+	}
+
+	// Publishers never wait on a full event queue either
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i <= QueueSize; i++ {
+			dispatch(events.Event{Name: events.PostCreated})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("dispatch blocked on a full queue")
+	}
+	if len(pending) != QueueSize {
+		t.Fatalf("%d events pending, want %d", len(pending), QueueSize)
+	}
+}